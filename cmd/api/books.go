@@ -1,8 +1,10 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"github.com/go-chi/chi/v5"
 	"github.com/rrebeiz/quickbooks/internal/data"
 	"github.com/rrebeiz/quickbooks/internal/validator"
 	"net/http"
@@ -10,18 +12,25 @@ import (
 
 func (app *application) getAllBooksHandler(w http.ResponseWriter, r *http.Request) {
 	var input struct {
-		Title string
+		Title       string
+		PublisherID int
+		Facets      bool
 		data.Filters
 	}
 	v := validator.NewValidator()
 
 	qs := r.URL.Query()
 	input.Title = app.readString(qs, "title", "")
+	input.PublisherID = app.readInt(qs, "publisher_id", 0, v)
+	input.Facets = app.readBool(qs, "facets", false, v)
 	input.Filters.Page = app.readInt(qs, "page", 1, v)
 	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
 	input.Filters.Sort = app.readString(qs, "sort", "id")
 	input.Filters.SortSafeList = []string{"id", "title", "publication_year", "-id", "-title", "-publication_year"}
+	input.Filters.Cursor = app.readString(qs, "cursor", "")
+	input.Filters.Cursors = app.cursors
 
+	v.Check(input.PublisherID >= 0, "publisher_id", "must not be negative")
 	data.ValidateFilters(v, input.Filters)
 
 	if !v.Valid() {
@@ -29,17 +38,29 @@ func (app *application) getAllBooksHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	books, metadata, err := app.models.Books.GetAll(input.Title, input.Filters)
+	books, metadata, err := app.models.Books.GetAll(r.Context(), input.Title, int64(input.PublisherID), input.Filters)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrNoRecordFound):
 			app.notfoundResponse(w, r)
+		case errors.Is(err, data.ErrInvalidCursor):
+			app.invalidCursorResponse(w, r)
 		default:
 			app.serverErrorResponse(w, r, err)
 		}
 		return
 	}
-	err = app.writeJSON(w, http.StatusOK, envelope{"books": books, "metadata": metadata}, nil)
+	links, headers := app.paginationLinks(r, metadata)
+	env := envelope{"books": books, "metadata": metadata, "links": links}
+	if input.Facets {
+		facets, err := app.models.Books.GetFacets(r.Context(), input.Title, int64(input.PublisherID), input.Filters)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		env["facets"] = facets
+	}
+	err = app.writeJSON(w, http.StatusOK, env, headers)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
@@ -52,7 +73,7 @@ func (app *application) getBookByIDHandler(w http.ResponseWriter, r *http.Reques
 		app.notfoundResponse(w, r)
 		return
 	}
-	book, err := app.models.Books.GetByID(id)
+	book, err := app.models.Books.GetByID(r.Context(), id)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrNoRecordFound):
@@ -70,14 +91,43 @@ func (app *application) getBookByIDHandler(w http.ResponseWriter, r *http.Reques
 	}
 }
 
+// getBookBySlugHandler responds with the book with the slug, or redirects to its current slug when
+// it is one the book had before being renamed.
 func (app *application) getBookBySlugHandler(w http.ResponseWriter, r *http.Request) {
-	var input struct {
-		Slug string
+	slug := chi.URLParamFromCtx(r.Context(), "slug")
+	book, err := app.models.Books.GetBySlug(r.Context(), slug)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRecordFound):
+			app.notfoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
 	}
-	qs := r.URL.Query()
-	input.Slug = app.readString(qs, "slug", "")
+	if book.Slug != slug {
+		headers := make(http.Header)
+		headers.Set("Location", "/v1/books/slug/"+book.Slug)
+		err = app.writeJSON(w, http.StatusMovedPermanently, envelope{"slug": book.Slug}, headers)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"book": book}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
 
-	book, err := app.models.Books.GetBySlug(input.Slug)
+func (app *application) getBookByISBNHandler(w http.ResponseWriter, r *http.Request) {
+	isbn13, ok := data.ParseISBN(chi.URLParamFromCtx(r.Context(), "isbn"))
+	if !ok {
+		app.failedValidationResponse(w, r, map[string]string{"isbn": "must be a valid ISBN-10 or ISBN-13"})
+		return
+	}
+	book, err := app.models.Books.GetByISBN(r.Context(), isbn13)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrNoRecordFound):
@@ -96,10 +146,19 @@ func (app *application) getBookBySlugHandler(w http.ResponseWriter, r *http.Requ
 
 func (app *application) createBookHandler(w http.ResponseWriter, r *http.Request) {
 	var input struct {
-		Title           string `json:"title"`
-		AuthorID        int    `json:"author_id"`
-		PublicationYear int    `json:"publication_year"`
-		Description     string `json:"description"`
+		Title           string             `json:"title"`
+		AuthorID        int                `json:"author_id"`
+		PublicationYear int                `json:"publication_year"`
+		Description     string             `json:"description"`
+		Language        string             `json:"language"`
+		ISBN10          string             `json:"isbn_10"`
+		ISBN13          string             `json:"isbn_13"`
+		WorkID          int64              `json:"work_id"`
+		Format          string             `json:"format"`
+		PublisherID     int64              `json:"publisher_id"`
+		PageCount       int                `json:"page_count"`
+		Contributors    []data.Contributor `json:"contributors"`
+		Series          []data.SeriesEntry `json:"series"`
 	}
 	err := app.readJSON(w, r, &input)
 	if err != nil {
@@ -111,18 +170,57 @@ func (app *application) createBookHandler(w http.ResponseWriter, r *http.Request
 	book.AuthorID = input.AuthorID
 	book.PublicationYear = input.PublicationYear
 	book.Description = input.Description
-	book.Slug = book.Title
+	book.Language = input.Language
+	book.ISBN10 = input.ISBN10
+	book.ISBN13 = input.ISBN13
+	book.WorkID = input.WorkID
+	book.Format = input.Format
+	book.PublisherID = input.PublisherID
+	book.PageCount = input.PageCount
+	book.Contributors = input.Contributors
+	book.Series = input.Series
 
 	v := validator.NewValidator()
 	data.ValidateBook(v, &book)
+	if v.Valid() {
+		err = app.checkAuthors(r.Context(), v, &book)
+		if err == nil {
+			err = app.checkSeries(r.Context(), v, book.Series)
+		}
+		if err == nil {
+			err = app.checkPublisher(r.Context(), v, book.PublisherID)
+		}
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
 	if !v.Valid() {
 		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
+	if book.WorkID != 0 {
+		// The book is a new edition of an existing work.
+		_, err := app.models.Books.GetWork(r.Context(), book.WorkID)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrNoRecordFound):
+				app.failedValidationResponse(w, r, map[string]string{"work_id": "does not exist"})
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+	}
 
-	err = app.models.Books.Insert(&book)
+	err = app.models.Books.Insert(r.Context(), &book)
 	if err != nil {
-		app.serverErrorResponse(w, r, err)
+		switch {
+		case errors.Is(err, data.ErrDuplicateISBN):
+			app.duplicateISBNResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
 		return
 	}
 	headers := make(http.Header)
@@ -140,7 +238,7 @@ func (app *application) updateBookHandler(w http.ResponseWriter, r *http.Request
 		app.notfoundResponse(w, r)
 		return
 	}
-	book, err := app.models.Books.GetByID(id)
+	book, err := app.models.Books.GetByID(r.Context(), id)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrNoRecordFound):
@@ -151,11 +249,19 @@ func (app *application) updateBookHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 	var input struct {
-		Title           *string  `json:"title"`
-		AuthorID        *int     `json:"author_id"`
-		PublicationYear *int     `json:"publication_year"`
-		Description     *string  `json:"description"`
-		Genres          []string `json:"genres"`
+		Title           *string            `json:"title"`
+		AuthorID        *int               `json:"author_id"`
+		PublicationYear *int               `json:"publication_year"`
+		Description     *string            `json:"description"`
+		Language        *string            `json:"language"`
+		Genres          []string           `json:"genres"`
+		ISBN10          *string            `json:"isbn_10"`
+		ISBN13          *string            `json:"isbn_13"`
+		Format          *string            `json:"format"`
+		PublisherID     *int64             `json:"publisher_id"`
+		PageCount       *int               `json:"page_count"`
+		Contributors    []data.Contributor `json:"contributors"`
+		Series          []data.SeriesEntry `json:"series"`
 	}
 	err = app.readJSON(w, r, &input)
 	if err != nil {
@@ -167,8 +273,16 @@ func (app *application) updateBookHandler(w http.ResponseWriter, r *http.Request
 	if input.Title != nil {
 		book.Title = *input.Title
 	}
-	if input.AuthorID != nil {
+	switch {
+	case input.Contributors != nil:
+		v.Check(len(input.Contributors) > 0, "contributors", "should not be empty")
+		book.Contributors = input.Contributors
+	case input.AuthorID != nil:
+		// A new author_id replaces the first contributor, the others are kept.
 		book.AuthorID = *input.AuthorID
+		if len(book.Contributors) > 0 {
+			book.Contributors[0].AuthorID = int64(book.AuthorID)
+		}
 	}
 	if input.PublicationYear != nil {
 		book.PublicationYear = *input.PublicationYear
@@ -176,32 +290,76 @@ func (app *application) updateBookHandler(w http.ResponseWriter, r *http.Request
 	if input.Description != nil {
 		book.Description = *input.Description
 	}
+	if input.Language != nil {
+		book.Language = *input.Language
+	}
+	if input.Format != nil {
+		book.Format = *input.Format
+	}
+	if input.PublisherID != nil {
+		// 0 removes the publisher.
+		book.PublisherID = *input.PublisherID
+	}
+	if input.PageCount != nil {
+		book.PageCount = *input.PageCount
+	}
+	if input.ISBN10 != nil || input.ISBN13 != nil {
+		// Either ISBN replaces both, the other one is derived from it. An empty one removes them.
+		book.ISBN10, book.ISBN13 = "", ""
+		if input.ISBN10 != nil {
+			book.ISBN10 = *input.ISBN10
+		}
+		if input.ISBN13 != nil {
+			book.ISBN13 = *input.ISBN13
+		}
+	}
 	if input.Genres != nil {
 		book.Genres = input.Genres
-		v.Check(validator.Unique(book.Genres), "genres", "values must be unique")
+		data.ValidateGenres(v, book.Genres)
 		if !v.Valid() {
 			app.failedValidationResponse(w, r, v.Errors)
 			return
 		}
-		var genreList = []string{"Science Fiction", "Fantasy", "Romance", "Thriller", "Mystery", "Horror", "Classic", "Self-help"}
-		msg := fmt.Sprintf("please use the following genres %s", genreList)
-		for i := range book.Genres {
-			v.Check(validator.PermittedValue(book.Genres[i], genreList...), "genres", msg)
-			if !v.Valid() {
-				app.failedValidationResponse(w, r, v.Errors)
-				return
-			}
-		}
+	}
+	if input.Series != nil {
+		// The series replace the current ones, an empty list removes the book from its series.
+		book.Series = input.Series
 	}
 	data.ValidateBook(v, book)
+	if v.Valid() && (input.Contributors != nil || input.AuthorID != nil) {
+		err = app.checkAuthors(r.Context(), v, book)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+	if v.Valid() && input.Series != nil {
+		err = app.checkSeries(r.Context(), v, book.Series)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+	if v.Valid() && input.PublisherID != nil {
+		err = app.checkPublisher(r.Context(), v, book.PublisherID)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
 	if !v.Valid() {
 		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
 
-	err = app.models.Books.Update(book)
+	err = app.models.Books.Update(r.Context(), book)
 	if err != nil {
-		app.serverErrorResponse(w, r, err)
+		switch {
+		case errors.Is(err, data.ErrDuplicateISBN):
+			app.duplicateISBNResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
 		return
 	}
 
@@ -212,13 +370,15 @@ func (app *application) updateBookHandler(w http.ResponseWriter, r *http.Request
 	}
 }
 
+// deleteBookHandler moves the book to the trash with its reviews, its cover images are removed
+// when it is purged.
 func (app *application) deleteBookHandler(w http.ResponseWriter, r *http.Request) {
 	id, err := app.readParamID(r)
 	if err != nil {
 		app.notfoundResponse(w, r)
 		return
 	}
-	err = app.models.Books.Delete(id)
+	err = app.models.Books.Delete(r.Context(), id)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrNoRecordFound):
@@ -249,6 +409,8 @@ func (app *application) getAllAuthorsHandler(w http.ResponseWriter, r *http.Requ
 	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
 	input.Filters.Sort = app.readString(qs, "sort", "id")
 	input.Filters.SortSafeList = []string{"id", "author_name", "-id", "-author_name"}
+	input.Filters.Cursor = app.readString(qs, "cursor", "")
+	input.Filters.Cursors = app.cursors
 
 	data.ValidateFilters(v, input.Filters)
 
@@ -256,12 +418,18 @@ func (app *application) getAllAuthorsHandler(w http.ResponseWriter, r *http.Requ
 		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
-	authors, metadata, err := app.models.Books.GetAllAuthors(input.Author, input.Filters)
+	authors, metadata, err := app.models.Books.GetAllAuthors(r.Context(), input.Author, input.Filters)
 	if err != nil {
-		app.serverErrorResponse(w, r, err)
+		switch {
+		case errors.Is(err, data.ErrInvalidCursor):
+			app.invalidCursorResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
 		return
 	}
-	err = app.writeJSON(w, http.StatusOK, envelope{"authors": authors, "metadata": metadata}, nil)
+	links, headers := app.paginationLinks(r, metadata)
+	err = app.writeJSON(w, http.StatusOK, envelope{"authors": authors, "metadata": metadata, "links": links}, headers)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
@@ -280,6 +448,8 @@ func (app *application) getAllReviewsByUser(w http.ResponseWriter, r *http.Reque
 	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
 	input.Filters.Sort = app.readString(qs, "sort", "id")
 	input.Filters.SortSafeList = []string{"id", "user", "-id", "-user"}
+	input.Filters.Cursor = app.readString(qs, "cursor", "")
+	input.Filters.Cursors = app.cursors
 
 	data.ValidateFilters(v, input.Filters)
 
@@ -288,11 +458,18 @@ func (app *application) getAllReviewsByUser(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	reviews, metadata, err := app.models.Books.GetAllReviewsByUser(input.User, input.Filters)
+	reviews, metadata, err := app.models.Books.GetAllReviewsByUser(r.Context(), input.User, input.Filters)
 	if err != nil {
-		app.serverErrorResponse(w, r, err)
+		switch {
+		case errors.Is(err, data.ErrInvalidCursor):
+			app.invalidCursorResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
 	}
-	err = app.writeJSON(w, http.StatusOK, envelope{"reviews": reviews, "metadata": metadata}, nil)
+	links, headers := app.paginationLinks(r, metadata)
+	err = app.writeJSON(w, http.StatusOK, envelope{"reviews": reviews, "metadata": metadata, "links": links}, headers)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
@@ -322,9 +499,14 @@ func (app *application) createReviewHandler(w http.ResponseWriter, r *http.Reque
 		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
-	err = app.models.Books.InsertReview(&review)
+	err = app.models.Books.InsertReview(r.Context(), &review)
 	if err != nil {
-		app.serverErrorResponse(w, r, err)
+		switch {
+		case errors.Is(err, data.ErrNoRecordFound):
+			app.failedValidationResponse(w, r, map[string]string{"review": "must be of a book and by a user that exist"})
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
 		return
 	}
 
@@ -338,7 +520,7 @@ func (app *application) updateReviewHandler(w http.ResponseWriter, r *http.Reque
 		app.notfoundResponse(w, r)
 		return
 	}
-	review, err := app.models.Books.GetReviewByID(id)
+	review, err := app.models.Books.GetReviewByID(r.Context(), id)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrNoRecordFound):
@@ -376,7 +558,7 @@ func (app *application) updateReviewHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 	review.ID = id
-	err = app.models.Books.UpdateReview(review)
+	err = app.models.Books.UpdateReview(r.Context(), review)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrNoRecordFound):
@@ -395,7 +577,7 @@ func (app *application) getReviewByIDHandler(w http.ResponseWriter, r *http.Requ
 		app.notfoundResponse(w, r)
 		return
 	}
-	review, err := app.models.Books.GetReviewByID(id)
+	review, err := app.models.Books.GetReviewByID(r.Context(), id)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrNoRecordFound):
@@ -418,7 +600,7 @@ func (app *application) deleteReviewHandler(w http.ResponseWriter, r *http.Reque
 		app.notfoundResponse(w, r)
 		return
 	}
-	err = app.models.Books.DeleteReview(id)
+	err = app.models.Books.DeleteReview(r.Context(), id)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrNoRecordFound):
@@ -435,3 +617,30 @@ func (app *application) deleteReviewHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 }
+
+// checkAuthors records a validation error when the author of the book, or one of its contributors
+// when it has some, does not exist.
+func (app *application) checkAuthors(ctx context.Context, v *validator.Validator, book *data.Book) error {
+	key := "contributors"
+	ids := make([]int64, 0, len(book.Contributors))
+	for _, contributor := range book.Contributors {
+		ids = append(ids, contributor.AuthorID)
+	}
+	if len(ids) == 0 {
+		key = "author_id"
+		ids = append(ids, int64(book.AuthorID))
+	}
+	for _, id := range ids {
+		_, err := app.models.Books.GetAuthorByID(ctx, id)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrNoRecordFound):
+				v.Check(false, key, "does not exist")
+				return nil
+			default:
+				return err
+			}
+		}
+	}
+	return nil
+}
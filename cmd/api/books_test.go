@@ -0,0 +1,535 @@
+package main
+
+import (
+	"fmt"
+	"github.com/rrebeiz/quickbooks/internal/data"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+type booksEnvelope struct {
+	Books []struct {
+		ID     int64    `json:"id"`
+		Title  string   `json:"title"`
+		Genres []string `json:"genres"`
+	} `json:"books"`
+	Metadata struct {
+		CurrentPage  int    `json:"current_page"`
+		PageSize     int    `json:"page_size"`
+		LastPage     int    `json:"last_page"`
+		TotalRecords int    `json:"total_records"`
+		Next         string `json:"next"`
+		Prev         string `json:"prev"`
+	} `json:"metadata"`
+}
+
+func (e booksEnvelope) titles() []string {
+	var titles []string
+	for _, book := range e.Books {
+		titles = append(titles, book.Title)
+	}
+	return titles
+}
+
+func TestBookRoutes(t *testing.T) {
+	ts := newTestServer(t)
+	_, token := ts.userToken(t, "Alice", "alice@example.com")
+	herbert := ts.seedAuthor(t, "Frank Herbert")
+
+	book := ts.createBook(t, token, "Dune Messiah", herbert, 1969)
+	if book.ID == 0 || book.Slug != "dune-messiah" {
+		t.Errorf("created book = %+v", book)
+	}
+
+	unauthenticated := []struct {
+		method string
+		path   string
+	}{
+		{http.MethodPost, "/v1/books"},
+		{http.MethodPatch, fmt.Sprintf("/v1/books/%d", book.ID)},
+		{http.MethodDelete, fmt.Sprintf("/v1/books/%d", book.ID)},
+	}
+	for _, route := range unauthenticated {
+		checkStatus(t, ts.do(t, route.method, route.path, "", nil), http.StatusBadRequest)
+	}
+
+	res := ts.do(t, http.MethodPost, "/v1/books", token, map[string]any{"author_id": herbert.ID})
+	checkStatus(t, res, http.StatusUnprocessableEntity)
+	message, _ := res.errorEnvelope(t)
+	if fmt.Sprint(message) != "map[description:should not be empty publication_year:should not be empty title:should not be empty]" {
+		t.Errorf("error = %v", message)
+	}
+
+	path := fmt.Sprintf("/v1/books/%d", book.ID)
+	res = ts.do(t, http.MethodPatch, path, token, map[string]any{"title": "Dune", "genres": []string{"Science Fiction", "Classic"}})
+	checkStatus(t, res, http.StatusOK)
+
+	res = ts.get(t, path, "")
+	checkStatus(t, res, http.StatusOK)
+	var env struct {
+		Book struct {
+			Title  string   `json:"title"`
+			Slug   string   `json:"slug"`
+			Genres []string `json:"genres"`
+			Author struct {
+				AuthorName string `json:"author_name"`
+			} `json:"author"`
+		} `json:"book"`
+	}
+	res.decode(t, &env)
+	if env.Book.Title != "Dune" || env.Book.Slug != "dune" || fmt.Sprint(env.Book.Genres) != "[Classic Science Fiction]" || env.Book.Author.AuthorName != "Frank Herbert" {
+		t.Errorf("book = %+v", env.Book)
+	}
+
+	res = ts.get(t, "/v1/books/slug/dune", "")
+	checkStatus(t, res, http.StatusOK)
+	res = ts.get(t, "/v1/books/slug/dune-messiah", "")
+	checkStatus(t, res, http.StatusMovedPermanently)
+	if location := res.header.Get("Location"); location != "/v1/books/slug/dune" {
+		t.Errorf("old slug Location = %q; want /v1/books/slug/dune", location)
+	}
+	checkStatus(t, ts.get(t, "/v1/books/slug/children-of-dune", ""), http.StatusNotFound)
+
+	invalidUpdates := []any{
+		map[string]any{"genres": []string{"Classic", "Classic"}},
+		map[string]any{"genres": []string{"Poetry"}},
+		map[string]any{"title": ""},
+	}
+	for _, body := range invalidUpdates {
+		checkStatus(t, ts.do(t, http.MethodPatch, path, token, body), http.StatusUnprocessableEntity)
+	}
+	checkStatus(t, ts.do(t, http.MethodPatch, path, token, `{"title": 1}`), http.StatusBadRequest)
+	checkStatus(t, ts.do(t, http.MethodPatch, fmt.Sprintf("/v1/books/%d", book.ID+100), token, map[string]any{"title": "X"}), http.StatusNotFound)
+
+	checkStatus(t, ts.do(t, http.MethodDelete, path, token, nil), http.StatusOK)
+	checkStatus(t, ts.get(t, path, ""), http.StatusNotFound)
+	checkStatus(t, ts.get(t, "/v1/books/abc", ""), http.StatusNotFound)
+}
+
+func TestListBooks(t *testing.T) {
+	ts := newTestServer(t)
+	_, token := ts.userToken(t, "Alice", "alice@example.com")
+	herbert := ts.seedAuthor(t, "Frank Herbert")
+	tolkien := ts.seedAuthor(t, "Tolkien")
+	ts.createBook(t, token, "Dune", herbert, 1965)
+	ts.createBook(t, token, "Dune Messiah", herbert, 1969)
+	ts.createBook(t, token, "The Hobbit", tolkien, 1937)
+
+	tests := []struct {
+		query  string
+		titles string
+		total  int
+	}{
+		{"", "[Dune Dune Messiah The Hobbit]", 3},
+		{"?title=dune", "[Dune Dune Messiah]", 2},
+		{"?sort=publication_year", "[The Hobbit Dune Dune Messiah]", 3},
+		{"?sort=-title&page_size=2", "[The Hobbit Dune Messiah]", 3},
+		{"?sort=-title&page_size=2&page=2", "[Dune]", 3},
+		{"?title=silmarillion", "[]", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			res := ts.get(t, "/v1/books"+tt.query, "")
+			checkStatus(t, res, http.StatusOK)
+			var env booksEnvelope
+			res.decode(t, &env)
+			if fmt.Sprint(env.titles()) != tt.titles || env.Metadata.TotalRecords != tt.total {
+				t.Errorf("books = %v of %d; want %s of %d", env.titles(), env.Metadata.TotalRecords, tt.titles, tt.total)
+			}
+		})
+	}
+
+	for _, query := range []string{"?sort=author", "?page=0", "?page_size=101", "?page=abc"} {
+		t.Run(query, func(t *testing.T) {
+			checkStatus(t, ts.get(t, "/v1/books"+query, ""), http.StatusUnprocessableEntity)
+		})
+	}
+}
+
+func TestListBooksWithCursors(t *testing.T) {
+	ts := newTestServer(t)
+	_, token := ts.userToken(t, "Alice", "alice@example.com")
+	herbert := ts.seedAuthor(t, "Frank Herbert")
+	for _, title := range []string{"Dune", "Emma", "Arrival", "Zen", "Dune"} {
+		ts.createBook(t, token, title, herbert, 1965)
+	}
+
+	list := func(query string) booksEnvelope {
+		t.Helper()
+		res := ts.get(t, "/v1/books"+query, "")
+		checkStatus(t, res, http.StatusOK)
+		var env booksEnvelope
+		res.decode(t, &env)
+		return env
+	}
+
+	var titles []string
+	env := list("?sort=-title&page_size=2")
+	if env.Metadata.Prev != "" || env.Metadata.TotalRecords != 5 {
+		t.Errorf("first page metadata = %+v; want a total and no prev cursor", env.Metadata)
+	}
+	titles = append(titles, env.titles()...)
+	for env.Metadata.Next != "" {
+		env = list("?sort=-title&page_size=2&cursor=" + env.Metadata.Next)
+		titles = append(titles, env.titles()...)
+	}
+	if fmt.Sprint(titles) != "[Zen Emma Dune Dune Arrival]" {
+		t.Errorf("pages followed by cursor = %v", titles)
+	}
+
+	env = list("?sort=-title&page_size=2&cursor=" + env.Metadata.Prev)
+	if fmt.Sprint(env.titles()) != "[Dune Dune]" || env.Metadata.Next == "" || env.Metadata.Prev == "" {
+		t.Errorf("page before the last = %v, %+v", env.titles(), env.Metadata)
+	}
+
+	for _, query := range []string{
+		"?sort=-title&cursor=garbage",
+		"?sort=-title&cursor=" + env.Metadata.Next + "x",
+		"?sort=title&cursor=" + env.Metadata.Next,
+		"?sort=-title&page=2&cursor=" + env.Metadata.Next,
+	} {
+		res := ts.get(t, "/v1/books"+query, "")
+		checkStatus(t, res, http.StatusUnprocessableEntity)
+	}
+	res := ts.get(t, "/v1/books/authors?sort=-author_name&cursor="+env.Metadata.Next, "")
+	checkStatus(t, res, http.StatusUnprocessableEntity)
+}
+
+func TestPaginationLinks(t *testing.T) {
+	ts := newTestServer(t)
+	_, token := ts.userToken(t, "Alice", "alice@example.com")
+	herbert := ts.seedAuthor(t, "Frank Herbert")
+	for _, title := range []string{"Dune", "Dune Messiah", "Children of Dune", "Heretics of Dune", "Chapterhouse Dune"} {
+		ts.createBook(t, token, title, herbert, 1965)
+	}
+
+	res := ts.get(t, "/v1/books?title=dune&page_size=2&page=2", "")
+	checkStatus(t, res, http.StatusOK)
+	var env struct {
+		Links map[string]string `json:"links"`
+	}
+	res.decode(t, &env)
+	want := map[string]string{
+		"first": "/v1/books?page_size=2&title=dune",
+		"prev":  "/v1/books?page=1&page_size=2&title=dune",
+		"next":  "/v1/books?page=3&page_size=2&title=dune",
+		"last":  "/v1/books?page=3&page_size=2&title=dune",
+	}
+	if fmt.Sprint(env.Links) != fmt.Sprint(want) {
+		t.Errorf("links = %v; want %v", env.Links, want)
+	}
+	wantHeader := `</v1/books?page_size=2&title=dune>; rel="first", </v1/books?page=1&page_size=2&title=dune>; rel="prev", ` +
+		`</v1/books?page=3&page_size=2&title=dune>; rel="next", </v1/books?page=3&page_size=2&title=dune>; rel="last"`
+	if got := res.header.Get("Link"); got != wantHeader {
+		t.Errorf("Link = %s; want %s", got, wantHeader)
+	}
+
+	// Cursor pages link to the next cursors, and following the links walks the whole listing.
+	var first booksEnvelope
+	res = ts.get(t, "/v1/books?page_size=2&sort=title", "")
+	res.decode(t, &first)
+	titles := first.titles()
+	next := "/v1/books?page_size=2&sort=title&cursor=" + first.Metadata.Next
+	for pages := 0; next != ""; pages++ {
+		if pages > 5 {
+			t.Fatal("the next links do not come to an end")
+		}
+		res := ts.get(t, next, "")
+		checkStatus(t, res, http.StatusOK)
+		var env struct {
+			booksEnvelope
+			Links map[string]string `json:"links"`
+		}
+		res.decode(t, &env)
+		titles = append(titles, env.titles()...)
+		if _, ok := env.Links["last"]; ok || !strings.Contains(env.Links["prev"], "cursor=") {
+			t.Errorf("cursor page links = %v; want cursors and no last page", env.Links)
+		}
+		if env.Links["first"] != "/v1/books?page_size=2&sort=title" {
+			t.Errorf("first = %s", env.Links["first"])
+		}
+		next = env.Links["next"]
+	}
+	if fmt.Sprint(titles) != "[Chapterhouse Dune Children of Dune Dune Dune Messiah Heretics of Dune]" {
+		t.Errorf("titles following the next links = %v", titles)
+	}
+}
+
+func TestListBooksWithFacets(t *testing.T) {
+	ts := newTestServer(t)
+	_, token := ts.userToken(t, "Alice", "alice@example.com")
+	herbert := ts.seedAuthor(t, "Frank Herbert")
+	tolkien := ts.seedAuthor(t, "Tolkien")
+	dune := ts.createBook(t, token, "Dune", herbert, 1965)
+	ts.createBook(t, token, "Dune Messiah", herbert, 1969)
+	ts.createBook(t, token, "The Hobbit", tolkien, 1937)
+	res := ts.do(t, http.MethodPatch, fmt.Sprintf("/v1/books/%d", dune.ID), token, map[string]any{"genres": []string{"Science Fiction"}})
+	checkStatus(t, res, http.StatusOK)
+
+	res = ts.get(t, "/v1/books?title=dune&page_size=1&facets=true", "")
+	checkStatus(t, res, http.StatusOK)
+	var env struct {
+		Books  []any `json:"books"`
+		Facets struct {
+			Genres  []data.FacetCount `json:"genres"`
+			Authors []data.FacetCount `json:"authors"`
+			Decades []data.FacetCount `json:"decades"`
+			Ratings []data.FacetCount `json:"ratings"`
+		} `json:"facets"`
+	}
+	res.decode(t, &env)
+	if len(env.Books) != 1 {
+		t.Errorf("books = %v; want a single page", env.Books)
+	}
+	want := fmt.Sprintf("{[{1 Science Fiction 1}] [{%d Frank Herbert 2}] [{0 1960s 2}] [{0 unrated 2}]}", herbert.ID)
+	if got := fmt.Sprint(env.Facets); got != want {
+		t.Errorf("facets = %s; want %s", got, want)
+	}
+
+	res = ts.get(t, "/v1/books", "")
+	var plain map[string]any
+	res.decode(t, &plain)
+	if _, ok := plain["facets"]; ok {
+		t.Error("facets were returned without being asked for")
+	}
+	checkStatus(t, ts.get(t, "/v1/books?facets=maybe", ""), http.StatusUnprocessableEntity)
+}
+
+func TestListAuthors(t *testing.T) {
+	ts := newTestServer(t)
+	ts.seedAuthor(t, "Frank Herbert")
+	ts.seedAuthor(t, "Tolkien")
+	ts.seedAuthor(t, "Brian Herbert")
+
+	res := ts.get(t, "/v1/books/authors?author_name=herbert&sort=-author_name", "")
+	checkStatus(t, res, http.StatusOK)
+	var env struct {
+		Authors []struct {
+			AuthorName string `json:"author_name"`
+		} `json:"authors"`
+		Metadata struct {
+			TotalRecords int `json:"total_records"`
+		} `json:"metadata"`
+	}
+	res.decode(t, &env)
+	if len(env.Authors) != 2 || env.Authors[0].AuthorName != "Frank Herbert" || env.Metadata.TotalRecords != 2 {
+		t.Errorf("authors = %+v", env)
+	}
+
+	checkStatus(t, ts.get(t, "/v1/books/authors?sort=title", ""), http.StatusUnprocessableEntity)
+}
+
+func TestBookContributors(t *testing.T) {
+	ts := newTestServer(t)
+	_, token := ts.userToken(t, "Alice", "alice@example.com")
+	homer := ts.seedAuthor(t, "Homer")
+	fagles := ts.seedAuthor(t, "Robert Fagles")
+	knox := ts.seedAuthor(t, "Bernard Knox")
+
+	res := ts.do(t, http.MethodPost, "/v1/books", token, map[string]any{
+		"title":            "The Odyssey",
+		"publication_year": 1996,
+		"description":      "The Odyssey description",
+		"contributors": []map[string]any{
+			{"author_id": homer.ID, "role": "author"},
+			{"author_id": fagles.ID, "role": "translator"},
+		},
+	})
+	checkStatus(t, res, http.StatusOK)
+	var env struct {
+		Book data.Book `json:"book"`
+	}
+	res.decode(t, &env)
+	path := fmt.Sprintf("/v1/books/%d", env.Book.ID)
+	if env.Book.AuthorID != int(homer.ID) || fmt.Sprint(env.Book.Contributors) != "[{1 Homer author} {2 Robert Fagles translator}]" {
+		t.Errorf("created book author_id %d, contributors %+v", env.Book.AuthorID, env.Book.Contributors)
+	}
+
+	res = ts.do(t, http.MethodPatch, path, token, map[string]any{"author_id": knox.ID})
+	checkStatus(t, res, http.StatusOK)
+	res = ts.get(t, path, "")
+	checkStatus(t, res, http.StatusOK)
+	res.decode(t, &env)
+	if env.Book.Author.AuthorName != "Bernard Knox" || fmt.Sprint(env.Book.Contributors) != "[{3 Bernard Knox author} {2 Robert Fagles translator}]" {
+		t.Errorf("after changing author_id, author %q, contributors %+v", env.Book.Author.AuthorName, env.Book.Contributors)
+	}
+
+	res = ts.get(t, "/v1/books/authors?sort=id", "")
+	checkStatus(t, res, http.StatusOK)
+	var authors struct {
+		Authors []data.Author `json:"authors"`
+	}
+	res.decode(t, &authors)
+	var counts []int
+	for _, author := range authors.Authors {
+		counts = append(counts, author.BookCount)
+	}
+	if fmt.Sprint(counts) != "[0 1 1]" {
+		t.Errorf("book counts = %v; want [0 1 1]", counts)
+	}
+
+	invalid := []any{
+		map[string]any{"contributors": []map[string]any{}},
+		map[string]any{"contributors": []map[string]any{{"author_id": homer.ID, "role": "narrator"}}},
+		map[string]any{"contributors": []map[string]any{{"role": "author"}}},
+		map[string]any{"contributors": []map[string]any{{"author_id": homer.ID, "role": "author"}, {"author_id": homer.ID, "role": "author"}}},
+		map[string]any{"contributors": []map[string]any{{"author_id": homer.ID, "role": "author"}, {"author_id": knox.ID + 100, "role": "translator"}}},
+	}
+	for _, body := range invalid {
+		res := ts.do(t, http.MethodPatch, path, token, body)
+		checkStatus(t, res, http.StatusUnprocessableEntity)
+		message, _ := res.errorEnvelope(t)
+		if !strings.HasPrefix(fmt.Sprint(message), "map[contributors:") {
+			t.Errorf("PATCH %v error = %v", body, message)
+		}
+	}
+	res = ts.do(t, http.MethodPost, "/v1/books", token, map[string]any{"title": "X", "publication_year": 2000, "description": "X"})
+	checkStatus(t, res, http.StatusUnprocessableEntity)
+
+	// Authors that do not exist are rejected before the book is saved.
+	for _, body := range []map[string]any{
+		{"title": "X", "publication_year": 2000, "description": "X", "author_id": knox.ID + 100},
+		{"title": "X", "publication_year": 2000, "description": "X", "contributors": []map[string]any{{"author_id": knox.ID + 100, "role": "author"}}},
+	} {
+		res = ts.do(t, http.MethodPost, "/v1/books", token, body)
+		checkStatus(t, res, http.StatusUnprocessableEntity)
+		message, _ := res.errorEnvelope(t)
+		if got := fmt.Sprint(message); got != "map[author_id:does not exist]" && got != "map[contributors:does not exist]" {
+			t.Errorf("POST %v error = %v; want the missing author", body, got)
+		}
+	}
+	res = ts.do(t, http.MethodPatch, path, token, map[string]any{"author_id": knox.ID + 100})
+	checkStatus(t, res, http.StatusUnprocessableEntity)
+}
+
+func TestBookISBN(t *testing.T) {
+	ts := newTestServer(t)
+	_, token := ts.userToken(t, "Alice", "alice@example.com")
+	herbert := ts.seedAuthor(t, "Frank Herbert")
+	book := ts.createBook(t, token, "Dune", herbert, 1965)
+	path := fmt.Sprintf("/v1/books/%d", book.ID)
+
+	res := ts.do(t, http.MethodPatch, path, token, map[string]any{"isbn_10": "0-306-40615-2"})
+	checkStatus(t, res, http.StatusOK)
+	var env struct {
+		Book data.Book `json:"book"`
+	}
+	res.decode(t, &env)
+	if env.Book.ISBN10 != "0306406152" || env.Book.ISBN13 != "9780306406157" {
+		t.Errorf("updated ISBNs = %q and %q", env.Book.ISBN10, env.Book.ISBN13)
+	}
+
+	for _, isbn := range []string{"0306406152", "978-0-306-40615-7"} {
+		res = ts.get(t, "/v1/books/isbn/"+isbn, "")
+		checkStatus(t, res, http.StatusOK)
+		res.decode(t, &env)
+		if env.Book.ID != book.ID {
+			t.Errorf("GET isbn %s = book %d; want %d", isbn, env.Book.ID, book.ID)
+		}
+	}
+	checkStatus(t, ts.get(t, "/v1/books/isbn/9791000000008", ""), http.StatusNotFound)
+	checkStatus(t, ts.get(t, "/v1/books/isbn/0306406153", ""), http.StatusUnprocessableEntity)
+
+	invalid := []struct {
+		body any
+		want string
+	}{
+		{map[string]any{"isbn_13": "9780306406158"}, "map[isbn_13:must be a valid ISBN-13]"},
+		{map[string]any{"isbn_10": "0306406152", "isbn_13": "9791000000008"}, "map[isbn_10:must be the ISBN-10 of isbn_13]"},
+	}
+	for _, test := range invalid {
+		res := ts.do(t, http.MethodPatch, path, token, test.body)
+		checkStatus(t, res, http.StatusUnprocessableEntity)
+		message, _ := res.errorEnvelope(t)
+		if fmt.Sprint(message) != test.want {
+			t.Errorf("PATCH %v error = %v; want %s", test.body, message, test.want)
+		}
+	}
+
+	res = ts.do(t, http.MethodPost, "/v1/books", token, map[string]any{
+		"title": "Dune again", "author_id": herbert.ID, "publication_year": 1965, "description": "Dune", "isbn_13": "9780306406157",
+	})
+	checkStatus(t, res, http.StatusBadRequest)
+
+	res = ts.do(t, http.MethodPatch, path, token, map[string]any{"isbn_13": ""})
+	checkStatus(t, res, http.StatusOK)
+	checkStatus(t, ts.get(t, "/v1/books/isbn/9780306406157", ""), http.StatusNotFound)
+}
+
+func TestReviewRoutes(t *testing.T) {
+	ts := newTestServer(t)
+	alice, token := ts.userToken(t, "Alice", "alice@example.com")
+	book := ts.createBook(t, token, "Dune", ts.seedAuthor(t, "Frank Herbert"), 1965)
+
+	body := map[string]any{"rating": 5, "review": "Great", "book_id": book.ID, "user_id": alice.ID}
+	checkStatus(t, ts.do(t, http.MethodPost, "/v1/books/reviews", "", body), http.StatusBadRequest)
+	res := ts.do(t, http.MethodPost, "/v1/books/reviews", token, body)
+	checkStatus(t, res, http.StatusOK)
+	var created struct {
+		Review struct {
+			ID     int64  `json:"id"`
+			Rating int    `json:"rating"`
+			Review string `json:"review"`
+		} `json:"review"`
+	}
+	res.decode(t, &created)
+	if created.Review.ID == 0 || created.Review.Rating != 5 {
+		t.Errorf("created review = %+v", created.Review)
+	}
+
+	res = ts.do(t, http.MethodPost, "/v1/books/reviews", token, map[string]any{"rating": 6, "book_id": book.ID, "user_id": alice.ID})
+	checkStatus(t, res, http.StatusUnprocessableEntity)
+
+	path := fmt.Sprintf("/v1/books/reviews/%d", created.Review.ID)
+	checkStatus(t, ts.do(t, http.MethodPatch, path, token, map[string]any{"rating": 3}), http.StatusOK)
+	checkStatus(t, ts.do(t, http.MethodPatch, path, token, map[string]any{"rating": 0}), http.StatusUnprocessableEntity)
+	checkStatus(t, ts.do(t, http.MethodPatch, path, token, map[string]any{"review": ""}), http.StatusUnprocessableEntity)
+	checkStatus(t, ts.do(t, http.MethodPatch, path, token, map[string]any{"review": "Great"}), http.StatusOK)
+
+	res = ts.get(t, path, "")
+	checkStatus(t, res, http.StatusOK)
+	var got struct {
+		Review struct {
+			Rating int    `json:"rating"`
+			Review string `json:"review"`
+		} `json:"review"`
+	}
+	res.decode(t, &got)
+	if got.Review.Rating != 3 || got.Review.Review != "Great" {
+		t.Errorf("review = %+v; want rating 3", got.Review)
+	}
+
+	res = ts.get(t, fmt.Sprintf("/v1/books/%d", book.ID), "")
+	checkStatus(t, res, http.StatusOK)
+	var withReviews struct {
+		Book struct {
+			Reviews []struct {
+				User string `json:"user"`
+			} `json:"reviews"`
+		} `json:"book"`
+	}
+	res.decode(t, &withReviews)
+	if len(withReviews.Book.Reviews) != 1 || withReviews.Book.Reviews[0].User != "Alice" {
+		t.Errorf("book reviews = %+v", withReviews.Book.Reviews)
+	}
+
+	res = ts.get(t, "/v1/books/reviews?user=alice", "")
+	checkStatus(t, res, http.StatusOK)
+	var byUser struct {
+		Reviews []struct {
+			Book string `json:"book"`
+			User string `json:"user"`
+		} `json:"reviews"`
+	}
+	res.decode(t, &byUser)
+	if len(byUser.Reviews) != 1 || byUser.Reviews[0].Book != "Dune" || byUser.Reviews[0].User != "Alice" {
+		t.Errorf("reviews by user = %+v", byUser.Reviews)
+	}
+	checkStatus(t, ts.get(t, "/v1/books/reviews?sort=rating", ""), http.StatusUnprocessableEntity)
+
+	checkStatus(t, ts.do(t, http.MethodDelete, path, token, nil), http.StatusOK)
+	checkStatus(t, ts.do(t, http.MethodDelete, path, token, nil), http.StatusNotFound)
+	checkStatus(t, ts.get(t, path, ""), http.StatusNotFound)
+	checkStatus(t, ts.do(t, http.MethodPatch, path, token, map[string]any{"rating": 3}), http.StatusNotFound)
+}
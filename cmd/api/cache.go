@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"github.com/rrebeiz/quickbooks/internal/cache"
+	"github.com/rrebeiz/quickbooks/internal/data"
+	"log/slog"
+	"time"
+)
+
+// cacheModels puts the configured cache in front of the book lookups of models, and has the user
+// deletions invalidate it. The returned function releases the store.
+func cacheModels(cfg config, models *data.Models, m *metrics, logger *slog.Logger) (func() error, error) {
+	var store cache.Store
+	closeStore := func() error { return nil }
+	switch cfg.cache.store {
+	case "none":
+		return closeStore, nil
+	case "redis":
+		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		defer cancel()
+		redis, err := cache.OpenRedis(ctx, cfg.cache.redisURL, "quickbooks:")
+		if err != nil {
+			return nil, err
+		}
+		store, closeStore = redis, redis.Close
+	default:
+		store = cache.NewLRU(cfg.cache.size)
+	}
+	books := cache.NewBooks(models.Books, store, cfg.cache.ttl, cacheMetrics{metrics: m, logger: logger})
+	models.Books = books
+	models.Users = cache.NewUsers(models.Users, books)
+	return closeStore, nil
+}
+
+// cacheMetrics counts the cache lookups, and logs the store failing.
+type cacheMetrics struct {
+	metrics *metrics
+	logger  *slog.Logger
+}
+
+func (c cacheMetrics) CacheHit(lookup string) {
+	c.metrics.cacheLookups.WithLabelValues(lookup, "hit").Inc()
+}
+
+func (c cacheMetrics) CacheMiss(lookup string) {
+	c.metrics.cacheLookups.WithLabelValues(lookup, "miss").Inc()
+}
+
+func (c cacheMetrics) CacheError(op string, err error) {
+	c.metrics.cacheErrors.WithLabelValues(op).Inc()
+	c.logger.Warn("cache store failed", "op", op, "error", err)
+}
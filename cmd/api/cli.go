@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/rrebeiz/quickbooks/internal/data"
+	"github.com/rrebeiz/quickbooks/internal/exporter"
+	"github.com/rrebeiz/quickbooks/internal/importer"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// importFiles is the import command: it imports the books of the files, a CSV or JSON Lines
+// file each depending on its extension, the way POST /v1/imports does but in the foreground.
+// The rows that could not be imported are written to w, it fails if there are any.
+func (app *application) importFiles(ctx context.Context, w io.Writer, files []string) error {
+	if len(files) == 0 {
+		return errors.New("import: no files given, usage: quickbooks import [flags] FILE...")
+	}
+	failed := false
+	for _, file := range files {
+		imp, err := app.importFile(ctx, file)
+		if err != nil {
+			return fmt.Errorf("import %s: %w", file, err)
+		}
+		rowErrors, err := app.models.Imports.GetErrors(ctx, imp.ID)
+		if err != nil {
+			return fmt.Errorf("import %s: %w", file, err)
+		}
+		for _, rowError := range rowErrors {
+			fields := make([]string, 0, len(rowError.Errors))
+			for field := range rowError.Errors {
+				fields = append(fields, field)
+			}
+			sort.Strings(fields)
+			problems := make([]string, len(fields))
+			for i, field := range fields {
+				problems[i] = field + " " + rowError.Errors[field]
+			}
+			fmt.Fprintf(w, "%s:%d: %s\n", file, rowError.Line, strings.Join(problems, "; "))
+		}
+		fmt.Fprintf(w, "%s: import %d %s, %d of %d books created, %d failed\n", file, imp.ID, imp.Status, imp.Created, imp.Total, imp.Failed)
+		if imp.Status != data.ImportCompleted {
+			return fmt.Errorf("import %s: %s", file, imp.Error)
+		}
+		failed = failed || imp.Failed > 0
+	}
+	if failed {
+		return errors.New("import: some books could not be imported")
+	}
+	return nil
+}
+
+// importFile records and runs the import of a file.
+func (app *application) importFile(ctx context.Context, file string) (*data.Import, error) {
+	format, ok := importer.FormatOf(file)
+	if !ok {
+		return nil, errors.New("unknown format, the file must end in .csv, .jsonl or .ndjson")
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	rows, err := importer.Parse(f, format)
+	if err != nil {
+		return nil, err
+	}
+	imp := &data.Import{Format: format, Total: len(rows)}
+	err = app.models.Imports.Insert(ctx, imp)
+	if err != nil {
+		return nil, err
+	}
+	app.runImport(ctx, imp, rows)
+	return imp, nil
+}
+
+// exportBooks is the export command: it writes the books to a file, or stdout, the way
+// GET /v1/exports/books does. Its arguments are the format, then the filters of the export and
+// the file:
+//
+//	quickbooks export [flags] csv|jsonl|marcxml [-title TITLE] [-publisher-id ID] [-o FILE]
+func (app *application) exportBooks(ctx context.Context, stdout io.Writer, args []string) error {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return fmt.Errorf("export: no format given, usage: quickbooks export [flags] %s [-title TITLE] [-publisher-id ID] [-o FILE]",
+			strings.Join(exporter.Formats, "|"))
+	}
+	format := args[0]
+	var filter exporter.Filter
+	var output string
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.StringVar(&filter.Title, "title", "", "export the books matching the title search")
+	fs.Int64Var(&filter.PublisherID, "publisher-id", 0, "export the books of the publisher")
+	fs.StringVar(&output, "o", "", "file the books are written to (default: stdout)")
+	err := fs.Parse(args[1:])
+	if err != nil {
+		return fmt.Errorf("export: %w", err)
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("export: unexpected argument %q", fs.Arg(0))
+	}
+
+	w := stdout
+	var f *os.File
+	if output != "" {
+		// The books are written to a temporary file, the output only appears once it is complete.
+		f, err = os.CreateTemp(filepath.Dir(output), "."+filepath.Base(output)+".*")
+		if err != nil {
+			return fmt.Errorf("export: %w", err)
+		}
+		defer os.Remove(f.Name())
+		defer f.Close()
+		w = f
+	}
+	count, err := exporter.Export(ctx, w, app.models.Books, app.cursors, format, filter, nil)
+	if err != nil {
+		return fmt.Errorf("export: %w", err)
+	}
+	if f != nil {
+		err = f.Close()
+		if err == nil {
+			err = os.Rename(f.Name(), output)
+		}
+		if err != nil {
+			return fmt.Errorf("export: %w", err)
+		}
+		fmt.Fprintf(stdout, "%s: %d books exported\n", output, count)
+	}
+	return nil
+}
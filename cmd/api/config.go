@@ -0,0 +1,432 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"github.com/rrebeiz/quickbooks/internal/data"
+	"github.com/rrebeiz/quickbooks/internal/validator"
+	"golang.org/x/crypto/bcrypt"
+	"gopkg.in/yaml.v3"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const (
+	defaultPepper = "super-secret-pepper"
+	envPrefix     = "QUICKBOOKS_"
+)
+
+type config struct {
+	port     int
+	env      string
+	logLevel string
+	db       struct {
+		dsn             string
+		pepper          string
+		maxOpenConns    int
+		maxIdleConns    int
+		connMaxLifetime time.Duration
+		queryTimeout    time.Duration
+		migrate         bool
+	}
+	jwt struct {
+		secret string
+	}
+	token struct {
+		ttl time.Duration
+	}
+	bcrypt struct {
+		cost int
+	}
+	cors struct {
+		trustedOrigins []string
+	}
+	metrics struct {
+		addr     string
+		username string
+		password string
+	}
+	otel struct {
+		endpoint    string
+		insecure    bool
+		sampleRatio float64
+	}
+	cursor struct {
+		secret string
+	}
+	cache struct {
+		store    string
+		ttl      time.Duration
+		size     int
+		redisURL string
+	}
+	covers struct {
+		store    string
+		dir      string
+		url      string
+		maxBytes int64
+		s3       struct {
+			endpoint  string
+			region    string
+			bucket    string
+			accessKey string
+			secretKey string
+			publicURL string
+		}
+	}
+	imports struct {
+		maxBytes int64
+	}
+	trash struct {
+		retention     time.Duration
+		purgeInterval time.Duration
+	}
+
+	file        string
+	printConfig bool
+}
+
+// metaFlags control how the configuration is loaded, they are not settings themselves.
+var metaFlags = map[string]bool{"config": true, "print-config": true}
+
+// secretFlags are redacted by -print-config.
+var secretFlags = map[string]bool{"db-dsn": true, "db-pepper": true, "metrics-password": true, "cache-redis-url": true, "cursor-secret": true, "covers-s3-secret-key": true}
+
+// legacyEnv maps settings to the environment variables used before QUICKBOOKS_* existed.
+var legacyEnv = map[string]string{"db-dsn": "DSN"}
+
+// newFlagSet registers every setting as a flag. The flags are the single list of settings: the
+// config file key and the environment variable of a setting are derived from its flag name,
+// db-max-open-conns is read from db.max_open_conns in the file and QUICKBOOKS_DB_MAX_OPEN_CONNS
+// from the environment.
+func newFlagSet(cfg *config) *flag.FlagSet {
+	fs := flag.NewFlagSet("quickbooks", flag.ContinueOnError)
+
+	fs.StringVar(&cfg.file, "config", "", "path to a YAML or TOML config file (env: QUICKBOOKS_CONFIG)")
+	fs.BoolVar(&cfg.printConfig, "print-config", false, "print the resolved configuration with secrets redacted and exit")
+
+	fs.IntVar(&cfg.port, "port", 4000, "the port of the application")
+	fs.StringVar(&cfg.env, "environment", "development", "environment, development | production")
+	fs.StringVar(&cfg.logLevel, "log-level", "info", "minimum log level, debug | info | warn | error")
+
+	fs.StringVar(&cfg.db.dsn, "db-dsn", "", "DB DSN, postgres://... or sqlite:path/to/file.db (env: DSN)")
+	fs.StringVar(&cfg.db.pepper, "db-pepper", defaultPepper, "DB pepper")
+	fs.IntVar(&cfg.db.maxOpenConns, "db-max-open-conns", 5, "maximum number of open DB connections")
+	fs.IntVar(&cfg.db.maxIdleConns, "db-max-idle-conns", 5, "maximum number of idle DB connections")
+	fs.DurationVar(&cfg.db.connMaxLifetime, "db-conn-max-lifetime", 5*time.Minute, "maximum lifetime of a DB connection")
+	fs.DurationVar(&cfg.db.queryTimeout, "db-query-timeout", 3*time.Second, "maximum duration of a single DB call")
+	fs.BoolVar(&cfg.db.migrate, "db-migrate", true, "apply pending schema migrations on startup")
+
+	fs.DurationVar(&cfg.token.ttl, "token-ttl", 24*time.Hour, "lifetime of authentication tokens")
+	fs.IntVar(&cfg.bcrypt.cost, "bcrypt-cost", 12, "bcrypt cost used to hash passwords")
+
+	cfg.cors.trustedOrigins = []string{"https://*", "http://*"}
+	fs.Var((*stringList)(&cfg.cors.trustedOrigins), "cors-trusted-origins", "trusted CORS origins, separated by spaces or commas")
+
+	fs.StringVar(&cfg.metrics.addr, "metrics-addr", "", "separate listen address for /metrics, e.g. :9090 (default: served on the API port)")
+	fs.StringVar(&cfg.metrics.username, "metrics-username", "", "basic auth username for /metrics (disabled when empty)")
+	fs.StringVar(&cfg.metrics.password, "metrics-password", "", "basic auth password for /metrics")
+
+	fs.StringVar(&cfg.otel.endpoint, "otel-endpoint", "", "OTLP/HTTP collector endpoint for traces, e.g. localhost:4318 (tracing disabled when empty)")
+	fs.BoolVar(&cfg.otel.insecure, "otel-insecure", false, "send traces over plain HTTP")
+	fs.Float64Var(&cfg.otel.sampleRatio, "otel-sample-ratio", 1, "fraction of new traces to sample, between 0 and 1")
+
+	fs.StringVar(&cfg.cursor.secret, "cursor-secret", "", "key signing the pagination cursors (default: a random key per process)")
+
+	fs.StringVar(&cfg.cache.store, "cache-store", "memory", "where book lookups are cached, memory | redis | none")
+	fs.DurationVar(&cfg.cache.ttl, "cache-ttl", time.Minute, "how long a cached book is served")
+	fs.IntVar(&cfg.cache.size, "cache-size", 10_000, "maximum number of books cached in memory")
+	fs.StringVar(&cfg.cache.redisURL, "cache-redis-url", "", "Redis URL when cache-store is redis, e.g. redis://localhost:6379/0")
+
+	fs.StringVar(&cfg.covers.store, "covers-store", "local", "where cover images are stored, local | s3")
+	fs.StringVar(&cfg.covers.dir, "covers-dir", "covers", "directory of the cover images when covers-store is local")
+	fs.StringVar(&cfg.covers.url, "covers-url", "/covers", "URL the local cover images are served at, the API serves them under its path")
+	fs.Int64Var(&cfg.covers.maxBytes, "covers-max-bytes", 5<<20, "maximum size of an uploaded cover image in bytes")
+	fs.StringVar(&cfg.covers.s3.endpoint, "covers-s3-endpoint", "", "S3-compatible endpoint when covers-store is s3, e.g. https://s3.eu-west-1.amazonaws.com")
+	fs.StringVar(&cfg.covers.s3.region, "covers-s3-region", "us-east-1", "region of the S3 bucket")
+	fs.StringVar(&cfg.covers.s3.bucket, "covers-s3-bucket", "", "S3 bucket of the cover images")
+	fs.StringVar(&cfg.covers.s3.accessKey, "covers-s3-access-key", "", "S3 access key ID")
+	fs.StringVar(&cfg.covers.s3.secretKey, "covers-s3-secret-key", "", "S3 secret access key")
+	fs.StringVar(&cfg.covers.s3.publicURL, "covers-s3-public-url", "", "URL clients fetch the cover images from, e.g. a CDN (default: the bucket URL)")
+	fs.Int64Var(&cfg.imports.maxBytes, "imports-max-bytes", 10<<20, "maximum size of a file uploaded to POST /v1/imports in bytes")
+
+	fs.DurationVar(&cfg.trash.retention, "trash-retention", 30*24*time.Hour, "how long deleted books, reviews and users can be restored before they are purged")
+	fs.DurationVar(&cfg.trash.purgeInterval, "trash-purge-interval", time.Hour, "how often the trash is purged of what is older than trash-retention")
+
+	return fs
+}
+
+// loadConfig resolves the configuration from, in increasing order of precedence, the defaults,
+// the config file, the environment and the command line flags.
+func loadConfig(args []string, lookupEnv func(string) (string, bool)) (config, *flag.FlagSet, error) {
+	var cfg config
+	fs := newFlagSet(&cfg)
+	err := fs.Parse(args)
+	if err != nil {
+		return cfg, nil, err
+	}
+
+	explicit := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = true
+	})
+	set := func(name, value, source string) error {
+		if explicit[name] {
+			return nil
+		}
+		err := fs.Set(name, value)
+		if err != nil {
+			return fmt.Errorf("%s: invalid value %q for %s: %w", source, value, name, err)
+		}
+		return nil
+	}
+
+	if cfg.file == "" {
+		cfg.file, _ = lookupEnv(envPrefix + "CONFIG")
+	}
+	if cfg.file != "" {
+		values, err := readConfigFile(cfg.file)
+		if err != nil {
+			return cfg, nil, err
+		}
+		names := make([]string, 0, len(values))
+		for name := range values {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if fs.Lookup(name) == nil || metaFlags[name] {
+				return cfg, nil, fmt.Errorf("%s: unknown setting %q", cfg.file, name)
+			}
+			err := set(name, values[name], cfg.file)
+			if err != nil {
+				return cfg, nil, err
+			}
+		}
+	}
+
+	var envErr error
+	fs.VisitAll(func(f *flag.Flag) {
+		if envErr != nil || metaFlags[f.Name] {
+			return
+		}
+		names := []string{envName(f.Name)}
+		if legacy, ok := legacyEnv[f.Name]; ok {
+			names = []string{legacy, envName(f.Name)}
+		}
+		for _, name := range names {
+			if value, ok := lookupEnv(name); ok {
+				envErr = set(f.Name, value, "environment variable "+name)
+			}
+		}
+	})
+	if envErr != nil {
+		return cfg, nil, envErr
+	}
+
+	return cfg, fs, nil
+}
+
+func envName(flagName string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// readConfigFile reads a YAML or TOML file, depending on its extension, and flattens it into
+// flag names: the db.max_open_conns key becomes db-max-open-conns.
+func readConfigFile(path string) (map[string]string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	tree := make(map[string]any)
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(content, &tree)
+	case ".toml":
+		err = toml.Unmarshal(content, &tree)
+	default:
+		return nil, fmt.Errorf("%s: unsupported config file format, use .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	values := make(map[string]string)
+	flatten("", tree, values)
+	return values, nil
+}
+
+func flatten(prefix string, tree map[string]any, values map[string]string) {
+	for key, value := range tree {
+		name := strings.ToLower(strings.ReplaceAll(key, "_", "-"))
+		if prefix != "" {
+			name = prefix + "-" + name
+		}
+		switch value := value.(type) {
+		case map[string]any:
+			flatten(name, value, values)
+		case []any:
+			items := make([]string, len(value))
+			for i := range value {
+				items[i] = fmt.Sprint(value[i])
+			}
+			values[name] = strings.Join(items, ",")
+		default:
+			values[name] = fmt.Sprint(value)
+		}
+	}
+}
+
+// validate rejects configurations the server should not start with.
+func (cfg config) validate() error {
+	v := validator.NewValidator()
+
+	v.Check(cfg.port > 0 && cfg.port <= 65535, "port", "must be between 1 and 65535")
+	v.Check(validator.PermittedValue(cfg.env, "development", "production"), "environment", "must be development or production")
+	var level slog.Level
+	v.Check(level.UnmarshalText([]byte(cfg.logLevel)) == nil, "log-level", "must be debug, info, warn or error")
+
+	_, _, err := data.ParseDSN(cfg.db.dsn)
+	if err != nil {
+		v.AddError("db-dsn", err.Error())
+	}
+	v.Check(cfg.db.pepper != "", "db-pepper", "must be provided")
+	v.Check(cfg.env != "production" || cfg.db.pepper != defaultPepper, "db-pepper", "the default pepper must not be used in production")
+	v.Check(cfg.db.maxOpenConns > 0, "db-max-open-conns", "must be greater than 0")
+	v.Check(cfg.db.maxIdleConns >= 0, "db-max-idle-conns", "must not be negative")
+	v.Check(cfg.db.maxIdleConns <= cfg.db.maxOpenConns, "db-max-idle-conns", "must not be more than db-max-open-conns")
+	v.Check(cfg.db.connMaxLifetime > 0, "db-conn-max-lifetime", "must be greater than 0")
+	v.Check(cfg.db.queryTimeout > 0, "db-query-timeout", "must be greater than 0")
+
+	v.Check(cfg.token.ttl > 0, "token-ttl", "must be greater than 0")
+	v.Check(cfg.bcrypt.cost >= bcrypt.MinCost && cfg.bcrypt.cost <= bcrypt.MaxCost, "bcrypt-cost",
+		fmt.Sprintf("must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost))
+	v.Check(len(cfg.cors.trustedOrigins) > 0, "cors-trusted-origins", "must not be empty")
+
+	v.Check(cfg.metrics.username == "" || cfg.metrics.password != "", "metrics-password", "must be provided when metrics-username is set")
+	v.Check(cfg.otel.sampleRatio >= 0 && cfg.otel.sampleRatio <= 1, "otel-sample-ratio", "must be between 0 and 1")
+
+	v.Check(validator.PermittedValue(cfg.cache.store, "memory", "redis", "none"), "cache-store", "must be memory, redis or none")
+	v.Check(cfg.cache.ttl > 0, "cache-ttl", "must be greater than 0")
+	v.Check(cfg.cache.size > 0, "cache-size", "must be greater than 0")
+	v.Check(cfg.cache.store != "redis" || cfg.cache.redisURL != "", "cache-redis-url", "must be provided when cache-store is redis")
+
+	v.Check(validator.PermittedValue(cfg.covers.store, "local", "s3"), "covers-store", "must be local or s3")
+	v.Check(cfg.covers.maxBytes > 0, "covers-max-bytes", "must be greater than 0")
+	if cfg.covers.store == "local" {
+		v.Check(cfg.covers.dir != "", "covers-dir", "must be provided when covers-store is local")
+		u, err := url.Parse(cfg.covers.url)
+		v.Check(err == nil && strings.HasPrefix(u.Path, "/") && len(strings.TrimSuffix(u.Path, "/")) > 1, "covers-url", "must be a path other than /, or a URL with one")
+	}
+	if cfg.covers.store == "s3" {
+		v.Check(cfg.covers.s3.endpoint != "", "covers-s3-endpoint", "must be provided when covers-store is s3")
+		v.Check(cfg.covers.s3.region != "", "covers-s3-region", "must be provided when covers-store is s3")
+		v.Check(cfg.covers.s3.bucket != "", "covers-s3-bucket", "must be provided when covers-store is s3")
+		v.Check(cfg.covers.s3.accessKey != "", "covers-s3-access-key", "must be provided when covers-store is s3")
+		v.Check(cfg.covers.s3.secretKey != "", "covers-s3-secret-key", "must be provided when covers-store is s3")
+	}
+	v.Check(cfg.imports.maxBytes > 0, "imports-max-bytes", "must be greater than 0")
+
+	v.Check(cfg.trash.retention > 0, "trash-retention", "must be greater than 0")
+	v.Check(cfg.trash.purgeInterval > 0, "trash-purge-interval", "must be greater than 0")
+
+	if v.Valid() {
+		return nil
+	}
+	keys := make([]string, 0, len(v.Errors))
+	for key := range v.Errors {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	problems := make([]string, len(keys))
+	for i, key := range keys {
+		problems[i] = fmt.Sprintf("%s: %s", key, v.Errors[key])
+	}
+	return errors.New("invalid configuration:\n  " + strings.Join(problems, "\n  "))
+}
+
+// writeConfig writes the resolved configuration as YAML, in the format accepted by -config,
+// with secrets redacted.
+func writeConfig(w io.Writer, fs *flag.FlagSet) error {
+	tree := make(map[string]any)
+	fs.VisitAll(func(f *flag.Flag) {
+		if metaFlags[f.Name] {
+			return
+		}
+		var value any = f.Value.String()
+		if getter, ok := f.Value.(flag.Getter); ok {
+			switch v := getter.Get().(type) {
+			case time.Duration:
+				value = v.String()
+			default:
+				value = v
+			}
+		}
+		if secretFlags[f.Name] && f.Value.String() != "" {
+			value = redact(f.Value.String())
+		}
+
+		section, key, nested := strings.Cut(f.Name, "-")
+		if !nested {
+			tree[strings.ReplaceAll(f.Name, "-", "_")] = value
+			return
+		}
+		sub, ok := tree[section].(map[string]any)
+		if !ok {
+			sub = make(map[string]any)
+			tree[section] = sub
+		}
+		sub[strings.ReplaceAll(key, "-", "_")] = value
+	})
+
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	err := enc.Encode(tree)
+	if err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// redact hides a secret, keeping the non-secret parts of URLs such as the DB DSN.
+func redact(secret string) string {
+	u, err := url.Parse(secret)
+	if err == nil && u.Scheme != "" && u.Host != "" {
+		if _, hasPassword := u.User.Password(); hasPassword {
+			u.User = url.UserPassword(u.User.Username(), "REDACTED")
+		}
+		return u.String()
+	}
+	return "REDACTED"
+}
+
+// stringList is a flag.Value holding a list of strings separated by spaces or commas.
+type stringList []string
+
+func (l *stringList) String() string {
+	if l == nil {
+		return ""
+	}
+	return strings.Join(*l, " ")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = strings.FieldsFunc(value, func(r rune) bool {
+		return r == ',' || unicode.IsSpace(r)
+	})
+	return nil
+}
+
+func (l *stringList) Get() any {
+	return []string(*l)
+}
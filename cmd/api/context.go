@@ -0,0 +1,34 @@
+package main
+
+import (
+	"context"
+	"net/http"
+)
+
+type contextKey string
+
+const requestInfoContextKey = contextKey("requestInfo")
+
+// requestInfo holds the per-request values that are filled in as the request
+// travels down the middleware chain and read back once it has been served.
+type requestInfo struct {
+	id     string
+	userID int64
+}
+
+func (app *application) contextSetRequestInfo(r *http.Request, info *requestInfo) *http.Request {
+	ctx := context.WithValue(r.Context(), requestInfoContextKey, info)
+	return r.WithContext(ctx)
+}
+
+func (app *application) contextGetRequestInfo(r *http.Request) *requestInfo {
+	info, ok := r.Context().Value(requestInfoContextKey).(*requestInfo)
+	if !ok {
+		return &requestInfo{}
+	}
+	return info
+}
+
+func (app *application) contextSetUserID(r *http.Request, userID int64) {
+	app.contextGetRequestInfo(r).userID = userID
+}
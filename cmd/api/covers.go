@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"github.com/rrebeiz/quickbooks/internal/blob"
+	"github.com/rrebeiz/quickbooks/internal/covers"
+	"github.com/rrebeiz/quickbooks/internal/data"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// multipartOverhead is allowed on top of the cover size for the multipart boundaries and headers.
+const multipartOverhead = 64 << 10
+
+var (
+	errCoverTooLarge = errors.New("cover too large")
+	errNoCover       = errors.New("the request must have a cover file field")
+)
+
+// newCoverStore returns the configured store of the cover images.
+func newCoverStore(cfg config) (blob.Store, error) {
+	switch cfg.covers.store {
+	case "s3":
+		return blob.NewS3(blob.S3Config{
+			Endpoint:  cfg.covers.s3.endpoint,
+			Region:    cfg.covers.s3.region,
+			Bucket:    cfg.covers.s3.bucket,
+			AccessKey: cfg.covers.s3.accessKey,
+			SecretKey: cfg.covers.s3.secretKey,
+			PublicURL: cfg.covers.s3.publicURL,
+		})
+	default:
+		return blob.NewLocal(cfg.covers.dir, cfg.covers.url), nil
+	}
+}
+
+// coversPath returns the path the local cover images are served under.
+func (app *application) coversPath() string {
+	u, err := url.Parse(app.config.covers.url)
+	if err != nil {
+		return "/covers"
+	}
+	return strings.TrimSuffix(u.Path, "/")
+}
+
+// updateBookCoverHandler replaces the cover of a book with the image of the cover field of a
+// multipart form. The image is re-encoded in every size, the type the client claims is ignored.
+func (app *application) updateBookCoverHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readParamID(r)
+	if err != nil {
+		app.notfoundResponse(w, r)
+		return
+	}
+	book, err := app.models.Books.GetByID(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRecordFound):
+			app.notfoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	content, err := app.readCover(w, r)
+	if err != nil {
+		switch {
+		case errors.Is(err, errCoverTooLarge):
+			app.payloadTooLargeResponse(w, r, app.config.covers.maxBytes)
+		default:
+			app.badRequestResponse(w, r, err)
+		}
+		return
+	}
+	renditions, err := covers.Render(content)
+	if err != nil {
+		switch {
+		case errors.Is(err, covers.ErrUnsupportedType):
+			app.unsupportedMediaTypeResponse(w, r, covers.ContentTypes)
+		case errors.Is(err, covers.ErrTooManyPixels):
+			app.failedValidationResponse(w, r, map[string]string{"cover": fmt.Sprintf("must not have more than %d pixels", covers.MaxPixels)})
+		case errors.Is(err, covers.ErrInvalidImage):
+			app.failedValidationResponse(w, r, map[string]string{"cover": "is not a valid image"})
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	// Keys are derived from the content, a new cover never overwrites the images of the previous
+	// one while they may still be served.
+	sum := sha256.Sum256(content)
+	var bookCovers []data.Cover
+	for _, rendition := range renditions {
+		key := fmt.Sprintf("covers/%d/%x-%s.jpg", id, sum[:8], rendition.Size)
+		err = app.covers.Put(r.Context(), key, rendition.Data, "image/jpeg")
+		if err != nil {
+			app.removeCovers(r, bookCovers, nil)
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		bookCovers = append(bookCovers, data.Cover{Size: rendition.Size, URL: app.covers.URL(key), Width: rendition.Width,
+			Height: rendition.Height, Key: key})
+	}
+	previous, err := app.models.Books.SetCovers(r.Context(), id, bookCovers)
+	if err != nil {
+		app.removeCovers(r, bookCovers, nil)
+		switch {
+		case errors.Is(err, data.ErrNoRecordFound):
+			app.notfoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	app.removeCovers(r, previous, bookCovers)
+
+	book.Covers = bookCovers
+	err = app.writeJSON(w, http.StatusOK, envelope{"book": book}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+// readCover returns the content of the cover field of the multipart request body.
+func (app *application) readCover(w http.ResponseWriter, r *http.Request) ([]byte, error) {
+	maxBytes := app.config.covers.maxBytes
+	r.Body = http.MaxBytesReader(w, r.Body, maxBytes+multipartOverhead)
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return nil, errors.New("the request body must be multipart/form-data")
+	}
+	for {
+		part, err := reader.NextPart()
+		if err != nil {
+			var maxBytesError *http.MaxBytesError
+			switch {
+			case errors.As(err, &maxBytesError):
+				return nil, errCoverTooLarge
+			case errors.Is(err, io.EOF):
+				return nil, errNoCover
+			default:
+				return nil, err
+			}
+		}
+		if part.FormName() != "cover" {
+			continue
+		}
+		content, err := io.ReadAll(io.LimitReader(part, maxBytes+1))
+		if err != nil {
+			var maxBytesError *http.MaxBytesError
+			if errors.As(err, &maxBytesError) {
+				return nil, errCoverTooLarge
+			}
+			return nil, err
+		}
+		if int64(len(content)) > maxBytes {
+			return nil, errCoverTooLarge
+		}
+		if len(content) == 0 {
+			return nil, errNoCover
+		}
+		return content, nil
+	}
+}
+
+// removeCovers deletes the images of the covers that are not kept. Failing to is only logged, the
+// images are left behind but nothing refers to them anymore.
+func (app *application) removeCovers(r *http.Request, removed, kept []data.Cover) {
+	keep := make(map[string]bool)
+	for _, cover := range kept {
+		keep[cover.Key] = true
+	}
+	var keys []string
+	for _, cover := range removed {
+		if !keep[cover.Key] {
+			keys = append(keys, cover.Key)
+		}
+	}
+	if len(keys) == 0 {
+		return
+	}
+	err := app.covers.Delete(context.WithoutCancel(r.Context()), keys...)
+	if err != nil {
+		app.requestLogger(r).Warn("failed to remove cover images", "keys", keys, "error", err)
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/rrebeiz/quickbooks/internal/data"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// uploadCover sends the content as the file of the field of a multipart form.
+func (ts *testServer) uploadCover(t *testing.T, bookID int64, token, field string, content []byte) testResponse {
+	t.Helper()
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile(field, "cover.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write(content)
+	form.Close()
+
+	req, err := http.NewRequest(http.MethodPut, fmt.Sprintf("%s/v1/books/%d/cover", ts.URL, bookID), &body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	res, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	resBody, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return testResponse{status: res.StatusCode, header: res.Header, body: resBody}
+}
+
+func testPNG(t *testing.T, width, height int, fill color.Color) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			img.Set(x, y, fill)
+		}
+	}
+	var buf bytes.Buffer
+	err := png.Encode(&buf, img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestBookCovers(t *testing.T) {
+	ts := newTestServer(t)
+	_, token := ts.userToken(t, "Alice", "alice@example.com")
+	herbert := ts.seedAuthor(t, "Frank Herbert")
+	dune := ts.createBook(t, token, "Dune", herbert, 1965)
+
+	res := ts.uploadCover(t, dune.ID, token, "cover", testPNG(t, 800, 1200, color.RGBA{R: 200, A: 255}))
+	checkStatus(t, res, http.StatusOK)
+	var env struct {
+		Book data.Book `json:"book"`
+	}
+	res.decode(t, &env)
+	first := env.Book.Covers
+	sizes := fmt.Sprint(first)
+	if len(first) != 3 || first[0].Size != "small" || first[0].Width != 128 || first[0].Height != 192 ||
+		first[2].Size != "large" || first[2].Width != 640 || first[2].Height != 960 {
+		t.Fatalf("covers = %s; want small 128x192, medium and large 640x960", sizes)
+	}
+	for _, cover := range first {
+		if !strings.HasPrefix(cover.URL, fmt.Sprintf("/covers/covers/%d/", dune.ID)) {
+			t.Errorf("cover URL = %q; want it under /covers/covers/%d/", cover.URL, dune.ID)
+		}
+		res = ts.get(t, cover.URL, "")
+		checkStatus(t, res, http.StatusOK)
+		if contentType := res.header.Get("Content-Type"); contentType != "image/jpeg" {
+			t.Errorf("cover %s Content-Type = %q; want image/jpeg", cover.Size, contentType)
+		}
+	}
+
+	res = ts.get(t, fmt.Sprintf("/v1/books/%d", dune.ID), "")
+	checkStatus(t, res, http.StatusOK)
+	env.Book = data.Book{}
+	res.decode(t, &env)
+	if fmt.Sprint(env.Book.Covers) != sizes {
+		t.Errorf("book covers = %v; want %s", env.Book.Covers, sizes)
+	}
+
+	// A new cover replaces the images of the previous one.
+	res = ts.uploadCover(t, dune.ID, token, "cover", testPNG(t, 100, 150, color.RGBA{B: 200, A: 255}))
+	checkStatus(t, res, http.StatusOK)
+	env.Book = data.Book{}
+	res.decode(t, &env)
+	second := env.Book.Covers
+	if len(second) != 3 || second[2].Width != 100 || second[2].Height != 150 {
+		t.Errorf("covers of a small image = %v; want them all 100x150", second)
+	}
+	checkStatus(t, ts.get(t, first[0].URL, ""), http.StatusNotFound)
+	checkStatus(t, ts.get(t, second[0].URL, ""), http.StatusOK)
+
+	checkStatus(t, ts.uploadCover(t, dune.ID, "", "cover", testPNG(t, 10, 10, color.White)), http.StatusBadRequest)
+	checkStatus(t, ts.uploadCover(t, dune.ID+100, token, "cover", testPNG(t, 10, 10, color.White)), http.StatusNotFound)
+	checkStatus(t, ts.uploadCover(t, dune.ID, token, "image", testPNG(t, 10, 10, color.White)), http.StatusBadRequest)
+	checkStatus(t, ts.uploadCover(t, dune.ID, token, "cover", []byte("just some text")), http.StatusUnsupportedMediaType)
+	checkStatus(t, ts.uploadCover(t, dune.ID, token, "cover", testPNG(t, 10, 10, color.White)[:40]), http.StatusUnprocessableEntity)
+	tooLarge := append(testPNG(t, 10, 10, color.White), make([]byte, ts.app.config.covers.maxBytes)...)
+	checkStatus(t, ts.uploadCover(t, dune.ID, token, "cover", tooLarge), http.StatusRequestEntityTooLarge)
+	checkStatus(t, ts.do(t, http.MethodPut, fmt.Sprintf("/v1/books/%d/cover", dune.ID), token, map[string]string{"cover": "x"}), http.StatusBadRequest)
+	checkStatus(t, ts.get(t, second[0].URL, ""), http.StatusOK)
+
+	// Deleting the book keeps its cover images until it is purged from the trash.
+	checkStatus(t, ts.do(t, http.MethodDelete, fmt.Sprintf("/v1/books/%d", dune.ID), token, nil), http.StatusOK)
+	checkStatus(t, ts.get(t, second[0].URL, ""), http.StatusOK)
+	err := ts.app.purgeTrash(context.Background(), time.Now().Add(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, cover := range second {
+		checkStatus(t, ts.get(t, cover.URL, ""), http.StatusNotFound)
+	}
+}
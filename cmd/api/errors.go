@@ -2,24 +2,40 @@ package main
 
 import (
 	"fmt"
+	"go.opentelemetry.io/otel/trace"
+	"log/slog"
 	"net/http"
+	"strings"
 )
 
-func (app *application) printError(err error) {
-	app.errorLog.Println(err)
+// requestLogger returns the application logger with the current request ID attached,
+// so every line logged while serving a request can be correlated. The trace ID is
+// added as well when the request is being traced.
+func (app *application) requestLogger(r *http.Request) *slog.Logger {
+	logger := app.logger.With("request_id", app.contextGetRequestInfo(r).id)
+	if sc := trace.SpanContextFromContext(r.Context()); sc.HasTraceID() {
+		logger = logger.With("trace_id", sc.TraceID().String())
+	}
+	return logger
+}
+
+func (app *application) logError(r *http.Request, err error) {
+	trace.SpanFromContext(r.Context()).RecordError(err)
+	app.requestLogger(r).Error(err.Error(), "method", r.Method, "uri", r.URL.RequestURI())
 }
+
 func (app *application) errorResponse(w http.ResponseWriter, r *http.Request, status int, message any) {
 
-	env := envelope{"error": message}
+	env := envelope{"error": message, "request_id": app.contextGetRequestInfo(r).id}
 	err := app.writeJSON(w, status, env, nil)
 	if err != nil {
-		app.errorLog.Println(err, r.Method)
+		app.logError(r, err)
 		w.WriteHeader(http.StatusInternalServerError)
 	}
 }
 
 func (app *application) serverErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
-	app.printError(err)
+	app.logError(r, err)
 	message := "the server encountered a problem."
 	app.errorResponse(w, r, http.StatusInternalServerError, message)
 }
@@ -55,3 +71,37 @@ func (app *application) duplicateEmailResponse(w http.ResponseWriter, r *http.Re
 	message := "email address already taken"
 	app.errorResponse(w, r, http.StatusBadRequest, message)
 }
+
+func (app *application) duplicateISBNResponse(w http.ResponseWriter, r *http.Request) {
+	message := "a book with this ISBN already exists"
+	app.errorResponse(w, r, http.StatusBadRequest, message)
+}
+
+func (app *application) duplicatePublisherResponse(w http.ResponseWriter, r *http.Request) {
+	message := "a publisher with this name already exists"
+	app.errorResponse(w, r, http.StatusBadRequest, message)
+}
+
+func (app *application) editConflictResponse(w http.ResponseWriter, r *http.Request) {
+	message := "unable to update the record due to an edit conflict, please try again"
+	app.errorResponse(w, r, http.StatusConflict, message)
+}
+
+func (app *application) payloadTooLargeResponse(w http.ResponseWriter, r *http.Request, maxBytes int64) {
+	message := fmt.Sprintf("the request body must not be larger than %d bytes", maxBytes)
+	app.errorResponse(w, r, http.StatusRequestEntityTooLarge, message)
+}
+
+func (app *application) unsupportedMediaTypeResponse(w http.ResponseWriter, r *http.Request, supported []string) {
+	message := fmt.Sprintf("the content type is not supported, it must be one of %s", strings.Join(supported, ", "))
+	app.errorResponse(w, r, http.StatusUnsupportedMediaType, message)
+}
+
+func (app *application) invalidCursorResponse(w http.ResponseWriter, r *http.Request) {
+	app.failedValidationResponse(w, r, map[string]string{"cursor": "is invalid"})
+}
+
+func (app *application) libraryImportNotConfirmableResponse(w http.ResponseWriter, r *http.Request, status string) {
+	message := fmt.Sprintf("the import is %s, only a previewed or failed import can be confirmed", status)
+	app.errorResponse(w, r, http.StatusConflict, message)
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/rrebeiz/quickbooks/internal/exporter"
+	"github.com/rrebeiz/quickbooks/internal/validator"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// exportBooksHandler streams the books, with the filters of the book listing, as a file in the
+// format parameter. The response is written as the books are read, so an error past the first
+// page can only abort it: the connection is closed without ending the body, and the client sees
+// a truncated download rather than a short catalogue.
+func (app *application) exportBooksHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.NewValidator()
+	qs := r.URL.Query()
+	format := app.readString(qs, "format", "csv")
+	filter := exporter.Filter{Title: app.readString(qs, "title", "")}
+	filter.PublisherID = int64(app.readInt(qs, "publisher_id", 0, v))
+	v.Check(validator.PermittedValue(format, exporter.Formats...), "format", "must be one of "+strings.Join(exporter.Formats, ", "))
+	v.Check(filter.PublisherID >= 0, "publisher_id", "must not be negative")
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	controller := http.NewResponseController(w)
+	// The whole catalogue can take longer to send than the write timeout of the server.
+	err := controller.SetWriteDeadline(time.Time{})
+	if err != nil && !errors.Is(err, http.ErrNotSupported) {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", exporter.ContentTypes[format])
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="books%s"`, exporter.Extensions[format]))
+	w.WriteHeader(http.StatusOK)
+	count, err := exporter.Export(r.Context(), w, app.models.Books, app.cursors, format, filter, func() error {
+		err := controller.Flush()
+		if errors.Is(err, http.ErrNotSupported) {
+			return nil
+		}
+		return err
+	})
+	if err != nil {
+		app.logError(r, fmt.Errorf("export stopped after %d books: %w", count, err))
+		panic(http.ErrAbortHandler)
+	}
+}
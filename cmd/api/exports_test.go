@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExportBooks(t *testing.T) {
+	ts := newTestServer(t)
+	_, adminToken := ts.adminToken(t)
+	_, token := ts.userToken(t, "Alice", "alice@example.com")
+	herbert := ts.seedAuthor(t, "Frank Herbert")
+	ts.createBook(t, token, "Dune", herbert, 1965)
+	ts.createBook(t, token, "Dune Messiah", herbert, 1969)
+	ts.createBook(t, token, "Hyperion", herbert, 1989)
+
+	res := ts.get(t, "/v1/exports/books", adminToken)
+	checkStatus(t, res, http.StatusOK)
+	if contentType := res.header.Get("Content-Type"); contentType != "text/csv; charset=utf-8" {
+		t.Errorf("Content-Type = %q; want CSV", contentType)
+	}
+	if disposition := res.header.Get("Content-Disposition"); disposition != `attachment; filename="books.csv"` {
+		t.Errorf("Content-Disposition = %q; want books.csv", disposition)
+	}
+	records, err := csv.NewReader(bytes.NewReader(res.body)).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 4 || records[1][1] != "Dune" || records[3][1] != "Hyperion" {
+		t.Errorf("CSV export = %q; want the header and 3 books", records)
+	}
+
+	res = ts.get(t, "/v1/exports/books?format=jsonl&title=dune", adminToken)
+	checkStatus(t, res, http.StatusOK)
+	if lines := strings.Count(string(res.body), "\n"); lines != 2 || res.header.Get("Content-Type") != "application/jsonl" {
+		t.Errorf("JSON Lines export of the title search = %d lines of %s; want the 2 Dune books", lines, res.header.Get("Content-Type"))
+	}
+	res = ts.get(t, "/v1/exports/books?format=marcxml&publisher_id=1", adminToken)
+	checkStatus(t, res, http.StatusOK)
+	if strings.Contains(string(res.body), "<record>") || !strings.Contains(string(res.body), "<collection") {
+		t.Errorf("MARCXML export of a publisher without books = %s; want an empty collection", res.body)
+	}
+
+	checkStatus(t, ts.get(t, "/v1/exports/books", ""), http.StatusBadRequest)
+	checkStatus(t, ts.get(t, "/v1/exports/books", token), http.StatusUnauthorized)
+	checkStatus(t, ts.get(t, "/v1/exports/books?format=xml", adminToken), http.StatusUnprocessableEntity)
+	checkStatus(t, ts.get(t, "/v1/exports/books?publisher_id=-1", adminToken), http.StatusUnprocessableEntity)
+}
+
+func TestExportCommand(t *testing.T) {
+	ts := newTestServer(t)
+	_, token := ts.userToken(t, "Alice", "alice@example.com")
+	herbert := ts.seedAuthor(t, "Frank Herbert")
+	ts.createBook(t, token, "Dune", herbert, 1965)
+	ts.createBook(t, token, "Hyperion", herbert, 1989)
+
+	var out bytes.Buffer
+	err := ts.app.exportBooks(context.Background(), &out, []string{"jsonl", "-title", "dune"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n"); len(lines) != 1 || !strings.Contains(lines[0], `"title":"Dune"`) {
+		t.Errorf("export to stdout = %q; want Dune", out.String())
+	}
+
+	file := filepath.Join(t.TempDir(), "books.xml")
+	out.Reset()
+	err = ts.app.exportBooks(context.Background(), &out, []string{"marcxml", "-o", file})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != fmt.Sprintf("%s: 2 books exported\n", file) {
+		t.Errorf("output = %q; want the count of books exported", out.String())
+	}
+	content, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Count(string(content), "<record>") != 2 {
+		t.Errorf("exported file = %s; want 2 records", content)
+	}
+	entries, err := os.ReadDir(filepath.Dir(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("export left %d files; want only the export", len(entries))
+	}
+
+	for _, args := range [][]string{nil, {"-title", "dune"}, {"xml", "-o", file}, {"csv", "-limit", "1"}, {"csv", "extra"}} {
+		err = ts.app.exportBooks(context.Background(), &out, args)
+		if err == nil {
+			t.Errorf("exportBooks(%q) = nil error; want one", args)
+		}
+	}
+}
@@ -1,8 +1,10 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/go-chi/chi/v5"
 	"github.com/rrebeiz/quickbooks/internal/data"
 	"github.com/rrebeiz/quickbooks/internal/validator"
@@ -87,22 +89,22 @@ func (app *application) readAuthHeader(r *http.Request) (*string, error) {
 		return nil, ErrNoAuthHeader
 	}
 
-	tkn, err := app.models.Tokens.GetByToken(token)
+	tkn, err := app.models.Tokens.GetByToken(r.Context(), token)
 	if err != nil {
 		return nil, err
 	}
 	return &tkn.Token, nil
 }
 
-func (app *application) getValidToken(plainTextToken *string) (*data.Token, error) {
+func (app *application) getValidToken(ctx context.Context, plainTextToken *string) (*data.Token, error) {
 
-	token, err := app.models.Tokens.GetByToken(*plainTextToken)
+	token, err := app.models.Tokens.GetByToken(ctx, *plainTextToken)
 	if err != nil {
 		return nil, err
 	}
 
 	if token.Expiry.Before(time.Now()) {
-		return nil, err
+		return nil, data.ErrNoRecordFound
 	}
 	return token, nil
 }
@@ -135,3 +137,61 @@ func (app *application) readInt(qs url.Values, key string, defaultValue int, v *
 	}
 	return i
 }
+
+func (app *application) readBool(qs url.Values, key string, defaultValue bool, v *validator.Validator) bool {
+	s := qs.Get(key)
+	if s == "" {
+		return defaultValue
+	}
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		v.AddError(key, "must be true or false")
+		return defaultValue
+	}
+	return b
+}
+
+// paginationLinks returns the first, prev, next and last URLs of the listing page described by
+// metadata, for the links of the envelope, and the same URLs as an RFC 8288 Link header. The URLs
+// keep the query string of the request, and page by number or by cursor like the request does.
+// The last page is unknown in cursor mode.
+func (app *application) paginationLinks(r *http.Request, metadata data.Metadata) (map[string]string, http.Header) {
+	link := func(key, value string) string {
+		qs := r.URL.Query()
+		qs.Del("page")
+		qs.Del("cursor")
+		if key != "" {
+			qs.Set(key, value)
+		}
+		if len(qs) == 0 {
+			return r.URL.Path
+		}
+		return r.URL.Path + "?" + qs.Encode()
+	}
+
+	links := map[string]string{"first": link("", "")}
+	if metadata.CurrentPage > 0 {
+		if metadata.CurrentPage > 1 {
+			links["prev"] = link("page", strconv.Itoa(metadata.CurrentPage-1))
+		}
+		if metadata.CurrentPage < metadata.LastPage {
+			links["next"] = link("page", strconv.Itoa(metadata.CurrentPage+1))
+		}
+		links["last"] = link("page", strconv.Itoa(metadata.LastPage))
+	} else {
+		if metadata.Prev != "" {
+			links["prev"] = link("cursor", metadata.Prev)
+		}
+		if metadata.Next != "" {
+			links["next"] = link("cursor", metadata.Next)
+		}
+	}
+
+	var values []string
+	for _, rel := range []string{"first", "prev", "next", "last"} {
+		if target, ok := links[rel]; ok {
+			values = append(values, fmt.Sprintf(`<%s>; rel="%s"`, target, rel))
+		}
+	}
+	return links, http.Header{"Link": {strings.Join(values, ", ")}}
+}
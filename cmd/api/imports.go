@@ -0,0 +1,213 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/rrebeiz/quickbooks/internal/data"
+	"github.com/rrebeiz/quickbooks/internal/importer"
+	"github.com/rrebeiz/quickbooks/internal/validator"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// importProgressRows is how many rows are imported between two saves of an import's progress.
+const importProgressRows = 100
+
+// importContentTypes are the content types of the import formats, for requests without a format
+// parameter.
+var importContentTypes = map[string]string{
+	"text/csv":             "csv",
+	"application/jsonl":    "jsonl",
+	"application/x-ndjson": "jsonl",
+}
+
+// createImportHandler queues an import of the books of the request body, a CSV or JSON Lines file.
+// The file is read before responding, so that a file that cannot be read at all is rejected and
+// the import knows its number of rows, the books are then added in the background.
+func (app *application) createImportHandler(w http.ResponseWriter, r *http.Request) {
+	format := app.readString(r.URL.Query(), "format", "")
+	if format == "" {
+		contentType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		format = importContentTypes[contentType]
+	}
+	if !validator.PermittedValue(format, importer.Formats...) {
+		app.failedValidationResponse(w, r, map[string]string{"format": "must be " + strings.Join(importer.Formats, " or ") +
+			", given by the format parameter or the Content-Type"})
+		return
+	}
+
+	maxBytes := app.config.imports.maxBytes
+	content, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBytes))
+	if err != nil {
+		var maxBytesError *http.MaxBytesError
+		switch {
+		case errors.As(err, &maxBytesError):
+			app.payloadTooLargeResponse(w, r, maxBytes)
+		default:
+			app.badRequestResponse(w, r, err)
+		}
+		return
+	}
+	rows, err := importer.Parse(bytes.NewReader(content), format)
+	if err == nil && len(rows) == 0 {
+		err = errors.New("has no books")
+	}
+	if err != nil {
+		app.failedValidationResponse(w, r, map[string]string{"file": err.Error()})
+		return
+	}
+
+	imp := &data.Import{UserID: app.contextGetRequestInfo(r).userID, Format: format, Total: len(rows)}
+	err = app.models.Imports.Insert(r.Context(), imp)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	job := *imp
+	app.background(func(ctx context.Context) {
+		app.runImport(ctx, &job, rows)
+	})
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/imports/%d", imp.ID))
+	err = app.writeJSON(w, http.StatusAccepted, envelope{"import": imp}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+// getImportHandler returns an import, with its progress while it runs.
+func (app *application) getImportHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readParamID(r)
+	if err != nil {
+		app.notfoundResponse(w, r)
+		return
+	}
+	imp, err := app.models.Imports.GetByID(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRecordFound):
+			app.notfoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"import": imp}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+// getImportErrorsHandler returns the rows of an import that could not be imported, so far.
+func (app *application) getImportErrorsHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readParamID(r)
+	if err != nil {
+		app.notfoundResponse(w, r)
+		return
+	}
+	_, err = app.models.Imports.GetByID(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRecordFound):
+			app.notfoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	rowErrors, err := app.models.Imports.GetErrors(r.Context(), id)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if rowErrors == nil {
+		rowErrors = []data.ImportRowError{}
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"errors": rowErrors}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+// runImport imports the rows, saving the progress of imp and the rows that could not be imported
+// every importProgressRows rows. An import stopped by ctx or a failing model is saved as failed.
+func (app *application) runImport(ctx context.Context, imp *data.Import, rows []importer.Row) {
+	logger := app.logger.With("import_id", imp.ID)
+	var pending []data.ImportRowError
+	save := func(ctx context.Context) error {
+		if len(pending) > 0 {
+			err := app.models.Imports.InsertErrors(ctx, imp.ID, pending)
+			if err != nil {
+				return err
+			}
+			pending = nil
+		}
+		return app.models.Imports.Update(ctx, imp)
+	}
+	fail := func(err error) {
+		logger.Error("import failed", "error", err, "processed_rows", imp.Processed)
+		imp.Status = data.ImportFailed
+		imp.Error = fmt.Sprintf("stopped after %d rows on an internal error", imp.Processed)
+		if ctx.Err() != nil {
+			imp.Error = fmt.Sprintf("interrupted after %d rows", imp.Processed)
+		}
+		finishedAt := time.Now().UTC().Truncate(time.Second)
+		imp.FinishedAt = &finishedAt
+		// The import is saved even though ctx may be cancelled.
+		err = save(context.WithoutCancel(ctx))
+		if err != nil {
+			logger.Error("failed to save the failed import", "error", err)
+		}
+	}
+
+	imp.Status = data.ImportRunning
+	err := save(ctx)
+	if err != nil {
+		fail(err)
+		return
+	}
+	im := importer.New(app.models.Books)
+	for _, row := range rows {
+		if ctx.Err() != nil {
+			fail(ctx.Err())
+			return
+		}
+		rowError, err := im.Import(ctx, row)
+		if err != nil {
+			fail(err)
+			return
+		}
+		imp.Processed++
+		if rowError != nil {
+			imp.Failed++
+			pending = append(pending, *rowError)
+		} else {
+			imp.Created++
+		}
+		if imp.Processed%importProgressRows == 0 {
+			err = save(ctx)
+			if err != nil {
+				fail(err)
+				return
+			}
+		}
+	}
+	imp.Status = data.ImportCompleted
+	finishedAt := time.Now().UTC().Truncate(time.Second)
+	imp.FinishedAt = &finishedAt
+	err = save(ctx)
+	if err != nil {
+		fail(err)
+		return
+	}
+	logger.Info("import completed", "created_rows", imp.Created, "failed_rows", imp.Failed)
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"github.com/rrebeiz/quickbooks/internal/data"
+	"github.com/rrebeiz/quickbooks/internal/importer"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// postImport sends the file to POST /v1/imports with the content type, when there is one.
+func (ts *testServer) postImport(t *testing.T, query, token, contentType, file string) testResponse {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, ts.URL+"/v1/imports"+query, strings.NewReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	res, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return testResponse{status: res.StatusCode, header: res.Header, body: body}
+}
+
+// waitImport waits for the import of the response to finish and returns it.
+func (ts *testServer) waitImport(t *testing.T, res testResponse, token string) data.Import {
+	t.Helper()
+	checkStatus(t, res, http.StatusAccepted)
+	ts.app.jobsWG.Wait()
+	res = ts.get(t, res.header.Get("Location"), token)
+	checkStatus(t, res, http.StatusOK)
+	var env struct {
+		Import data.Import `json:"import"`
+	}
+	res.decode(t, &env)
+	return env.Import
+}
+
+func TestImports(t *testing.T) {
+	ts := newTestServer(t)
+	admin, adminToken := ts.adminToken(t)
+	_, token := ts.userToken(t, "Alice", "alice@example.com")
+	ts.seedAuthor(t, "Frank Herbert")
+
+	file := "title,author,year,description,genres,isbn\n" +
+		"Dune,Frank Herbert,1965,Spice,Science Fiction;Classic,9780441172719\n" +
+		"Hyperion,Dan Simmons,1989,Pilgrims,Science Fiction,\n" +
+		"The Fall of Hyperion,dan simmons,1990,The Shrike,,\n" +
+		",Nobody,1990,No title,,\n" +
+		"Dune,Frank Herbert,1965,Again,,0441172717\n" +
+		"Odd,Someone,next year,Bad year,,\n"
+	res := ts.postImport(t, "", adminToken, "text/csv; charset=utf-8", file)
+	if location := res.header.Get("Location"); !strings.HasPrefix(location, "/v1/imports/") {
+		t.Errorf("Location = %q; want the import", location)
+	}
+	imp := ts.waitImport(t, res, adminToken)
+	if imp.Status != data.ImportCompleted || imp.Format != "csv" || imp.UserID != admin.ID || imp.Total != 6 || imp.Processed != 6 ||
+		imp.Created != 3 || imp.Failed != 3 || imp.FinishedAt == nil {
+		t.Errorf("import = %+v; want it completed with 3 of 6 rows created", imp)
+	}
+
+	res = ts.get(t, res.header.Get("Location")+"/errors", adminToken)
+	checkStatus(t, res, http.StatusOK)
+	var env struct {
+		Errors []data.ImportRowError `json:"errors"`
+	}
+	res.decode(t, &env)
+	if len(env.Errors) != 3 || env.Errors[0].Line != 5 || env.Errors[0].Errors["title"] == "" ||
+		env.Errors[1].Line != 6 || env.Errors[1].Title != "Dune" || env.Errors[1].Errors["isbn"] == "" ||
+		env.Errors[2].Line != 7 || env.Errors[2].Errors["year"] == "" {
+		t.Errorf("errors = %+v; want the rows of lines 5, 6 and 7", env.Errors)
+	}
+
+	// The missing author was created once, for both of their books.
+	res = ts.get(t, "/v1/books?title=hyperion", "")
+	checkStatus(t, res, http.StatusOK)
+	var books struct {
+		Books []data.Book `json:"books"`
+	}
+	res.decode(t, &books)
+	if len(books.Books) != 2 || books.Books[0].AuthorID != books.Books[1].AuthorID {
+		t.Errorf("Hyperion books = %+v; want both by the same author", books.Books)
+	}
+
+	jsonl := `{"title": "Children of Dune", "author": "Frank Herbert", "year": 1976, "description": "Leto", "genres": ["Science Fiction"]}` + "\n"
+	imp = ts.waitImport(t, ts.postImport(t, "", adminToken, "application/x-ndjson", jsonl), adminToken)
+	if imp.Status != data.ImportCompleted || imp.Format != "jsonl" || imp.Created != 1 {
+		t.Errorf("JSON Lines import = %+v; want its book created", imp)
+	}
+	jsonl = `{"title": "Dune Messiah", "author": "Frank Herbert", "year": 1969, "description": "Paul"}` + "\n"
+	imp = ts.waitImport(t, ts.postImport(t, "?format=jsonl", adminToken, "text/plain", jsonl), adminToken)
+	if imp.Format != "jsonl" || imp.Created != 1 {
+		t.Errorf("import of the format parameter = %+v; want it read as JSON Lines", imp)
+	}
+
+	checkStatus(t, ts.postImport(t, "", "", "text/csv", file), http.StatusBadRequest)
+	checkStatus(t, ts.postImport(t, "", token, "text/csv", file), http.StatusUnauthorized)
+	checkStatus(t, ts.postImport(t, "", adminToken, "text/plain", file), http.StatusUnprocessableEntity)
+	checkStatus(t, ts.postImport(t, "?format=xml", adminToken, "text/csv", file), http.StatusUnprocessableEntity)
+	checkStatus(t, ts.postImport(t, "", adminToken, "text/csv", "title,author\n"), http.StatusUnprocessableEntity)
+	checkStatus(t, ts.postImport(t, "", adminToken, "text/csv", "title,author,year,description\n"), http.StatusUnprocessableEntity)
+	tooLarge := file + strings.Repeat("x", int(ts.app.config.imports.maxBytes))
+	checkStatus(t, ts.postImport(t, "", adminToken, "text/csv", tooLarge), http.StatusRequestEntityTooLarge)
+	checkStatus(t, ts.get(t, "/v1/imports/100", adminToken), http.StatusNotFound)
+	checkStatus(t, ts.get(t, "/v1/imports/100/errors", adminToken), http.StatusNotFound)
+	checkStatus(t, ts.get(t, "/v1/imports/1", token), http.StatusUnauthorized)
+}
+
+func TestImportCommand(t *testing.T) {
+	ts := newTestServer(t)
+	dir := t.TempDir()
+	books := filepath.Join(dir, "books.csv")
+	err := os.WriteFile(books, []byte("title,author,year,description\nDune,Frank Herbert,1965,Spice\n,Nobody,1990,No title\n"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	more := filepath.Join(dir, "more.jsonl")
+	err = os.WriteFile(more, []byte(`{"title": "Hyperion", "author": "Dan Simmons", "year": 1989, "description": "Pilgrims"}`+"\n"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	err = ts.app.importFiles(context.Background(), &out, []string{books, more})
+	if err == nil {
+		t.Error("importFiles with a failed row = nil error; want one")
+	}
+	want := books + ":3: title should not be empty\n" +
+		books + ": import 1 completed, 1 of 2 books created, 1 failed\n" +
+		more + ": import 2 completed, 1 of 1 books created, 0 failed\n"
+	if out.String() != want {
+		t.Errorf("output = %q; want %q", out.String(), want)
+	}
+
+	out.Reset()
+	err = ts.app.importFiles(context.Background(), &out, []string{more})
+	if err != nil {
+		t.Errorf("importFiles = %v; want nil", err)
+	}
+	for _, files := range [][]string{nil, {filepath.Join(dir, "books.txt")}, {filepath.Join(dir, "missing.csv")}} {
+		err = ts.app.importFiles(context.Background(), io.Discard, files)
+		if err == nil {
+			t.Errorf("importFiles(%v) = nil error; want one", files)
+		}
+	}
+}
+
+func TestInterruptedImport(t *testing.T) {
+	ts := newTestServer(t)
+	imp := &data.Import{Format: "csv", Total: 1}
+	err := ts.app.models.Imports.Insert(context.Background(), imp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	ts.app.runImport(ctx, imp, []importer.Row{{Line: 2, Title: "Dune", Author: "Frank Herbert", Year: 1965, Description: "Spice"}})
+
+	saved, err := ts.app.models.Imports.GetByID(context.Background(), imp.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.Status != data.ImportFailed || saved.Error == "" || saved.FinishedAt == nil {
+		t.Errorf("import = %+v; want it failed", saved)
+	}
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/rrebeiz/quickbooks/internal/data"
+	"github.com/rrebeiz/quickbooks/internal/library"
+	"io"
+	"net/http"
+	"slices"
+	"time"
+)
+
+// libraryImportSummary counts the rows of a library import by how they were matched and by
+// status.
+type libraryImportSummary struct {
+	Rows       int `json:"rows"`
+	Matched    int `json:"matched"`
+	Candidates int `json:"candidates"`
+	Unmatched  int `json:"unmatched"`
+	Pending    int `json:"pending"`
+	Imported   int `json:"imported"`
+	Skipped    int `json:"skipped"`
+	Failed     int `json:"failed"`
+}
+
+func summarizeLibraryImport(imp *data.LibraryImport) libraryImportSummary {
+	summary := libraryImportSummary{Rows: len(imp.Rows)}
+	for _, row := range imp.Rows {
+		switch row.Match {
+		case data.MatchISBN, data.MatchTitleAuthor:
+			summary.Matched++
+		case data.MatchCandidate:
+			summary.Candidates++
+		default:
+			summary.Unmatched++
+		}
+		switch row.Status {
+		case data.LibraryRowPending:
+			summary.Pending++
+		case data.LibraryRowImported:
+			summary.Imported++
+		case data.LibraryRowSkipped:
+			summary.Skipped++
+		case data.LibraryRowFailed:
+			summary.Failed++
+		}
+	}
+	return summary
+}
+
+// createLibraryImportHandler previews the import of the user's Goodreads or StoryGraph export,
+// the request body: every row is matched to a book and the import is saved, nothing else changes until the
+// user confirms it.
+func (app *application) createLibraryImportHandler(w http.ResponseWriter, r *http.Request) {
+	maxBytes := app.config.imports.maxBytes
+	content, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBytes))
+	if err != nil {
+		var maxBytesError *http.MaxBytesError
+		switch {
+		case errors.As(err, &maxBytesError):
+			app.payloadTooLargeResponse(w, r, maxBytes)
+		default:
+			app.badRequestResponse(w, r, err)
+		}
+		return
+	}
+	source, rows, err := library.Parse(bytes.NewReader(content))
+	if err == nil && len(rows) == 0 {
+		err = errors.New("has no books")
+	}
+	if err != nil {
+		app.failedValidationResponse(w, r, map[string]string{"file": err.Error()})
+		return
+	}
+
+	imp := &data.LibraryImport{UserID: app.contextGetRequestInfo(r).userID, Source: source, Rows: rows}
+	err = library.Preview(r.Context(), app.models.Books, imp.UserID, imp.Rows)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	err = app.models.LibraryImports.Insert(r.Context(), imp)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/library-imports/%d", imp.ID))
+	err = app.writeJSON(w, http.StatusOK, envelope{"library_import": imp, "summary": summarizeLibraryImport(imp)}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+// readLibraryImport returns the import of the id parameter, writing the error response when
+// there is none. The imports of other users do not exist for the user.
+func (app *application) readLibraryImport(w http.ResponseWriter, r *http.Request) (*data.LibraryImport, bool) {
+	id, err := app.readParamID(r)
+	if err != nil {
+		app.notfoundResponse(w, r)
+		return nil, false
+	}
+	imp, err := app.models.LibraryImports.GetByID(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRecordFound):
+			app.notfoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+	if imp.UserID != app.contextGetRequestInfo(r).userID {
+		app.notfoundResponse(w, r)
+		return nil, false
+	}
+	return imp, true
+}
+
+// getLibraryImportHandler returns a library import, the preview before it is confirmed and the
+// outcome of every row after.
+func (app *application) getLibraryImportHandler(w http.ResponseWriter, r *http.Request) {
+	imp, ok := app.readLibraryImport(w, r)
+	if !ok {
+		return
+	}
+	err := app.writeJSON(w, http.StatusOK, envelope{"library_import": imp, "summary": summarizeLibraryImport(imp)}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+// getLibraryImportUnmatchedHandler reports the rows that matched no book: the candidates, whose
+// books are added by the confirmation, and those that could not be matched at all.
+func (app *application) getLibraryImportUnmatchedHandler(w http.ResponseWriter, r *http.Request) {
+	imp, ok := app.readLibraryImport(w, r)
+	if !ok {
+		return
+	}
+	unmatched := []data.LibraryImportRow{}
+	for _, row := range imp.Rows {
+		if row.Match == data.MatchCandidate || row.Match == data.MatchNone {
+			unmatched = append(unmatched, row)
+		}
+	}
+	err := app.writeJSON(w, http.StatusOK, envelope{"rows": unmatched}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+// confirmLibraryImportHandler starts importing the pending rows of a previewed import as the
+// user's reviews, in the background. The import is marked as confirming first, so that it is only
+// confirmed once even if the request is repeated while it runs. A failed import can be confirmed
+// again, its rows still pending are imported.
+func (app *application) confirmLibraryImportHandler(w http.ResponseWriter, r *http.Request) {
+	imp, ok := app.readLibraryImport(w, r)
+	if !ok {
+		return
+	}
+	if imp.Status != data.LibraryImportPreviewed && imp.Status != data.LibraryImportFailed {
+		app.libraryImportNotConfirmableResponse(w, r, imp.Status)
+		return
+	}
+	// Only the status changes, the rows are saved by the job.
+	confirming := *imp
+	confirming.Status = data.LibraryImportConfirming
+	confirming.ConfirmedAt = nil
+	confirming.Rows = nil
+	err := app.models.LibraryImports.Update(r.Context(), &confirming)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRecordFound):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	confirming.Rows = imp.Rows
+	job := confirming
+	job.Rows = slices.Clone(imp.Rows)
+	app.background(func(ctx context.Context) {
+		app.runLibraryImport(ctx, &job)
+	})
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/library-imports/%d", imp.ID))
+	err = app.writeJSON(w, http.StatusAccepted, envelope{"library_import": confirming, "summary": summarizeLibraryImport(&confirming)}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+// runLibraryImport imports the pending rows of imp, saving the rows done every importProgressRows
+// rows. An import stopped by ctx or a failing model is saved as failed, with the rows not imported
+// yet still pending.
+func (app *application) runLibraryImport(ctx context.Context, imp *data.LibraryImport) {
+	logger := app.logger.With("library_import_id", imp.ID)
+	saved := 0
+	// save saves the status of the import and the rows done since the last save.
+	save := func(ctx context.Context, done int) error {
+		progress := *imp
+		progress.Rows = imp.Rows[saved:done]
+		err := app.models.LibraryImports.Update(ctx, &progress)
+		if err != nil {
+			return err
+		}
+		imp.Version, imp.UpdatedAt = progress.Version, progress.UpdatedAt
+		saved = done
+		return nil
+	}
+
+	err := library.Apply(ctx, app.models.Books, imp.UserID, imp.Rows, func(done int) error {
+		if done-saved < importProgressRows {
+			return nil
+		}
+		return save(ctx, done)
+	})
+	imp.Status = data.LibraryImportConfirmed
+	if err != nil {
+		logger.Error("library import failed", "error", err)
+		imp.Status = data.LibraryImportFailed
+	}
+	confirmedAt := time.Now().UTC().Truncate(time.Second)
+	imp.ConfirmedAt = &confirmedAt
+	// The outcome is saved even though ctx may be cancelled, the reviews are already created.
+	err = save(context.WithoutCancel(ctx), len(imp.Rows))
+	if err != nil {
+		logger.Error("failed to save the library import", "status", imp.Status, "error", err)
+		return
+	}
+	summary := summarizeLibraryImport(imp)
+	logger.Info("library import "+imp.Status, "imported_rows", summary.Imported, "pending_rows", summary.Pending)
+}
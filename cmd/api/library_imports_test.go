@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/rrebeiz/quickbooks/internal/data"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+type libraryImportEnvelope struct {
+	LibraryImport data.LibraryImport      `json:"library_import"`
+	Summary       libraryImportSummary    `json:"summary"`
+	Rows          []data.LibraryImportRow `json:"rows"`
+}
+
+// goodreadsExport is a Goodreads export of the books, each a title, author, ISBN13, rating,
+// year and review.
+func goodreadsExport(books ...[6]string) string {
+	var b strings.Builder
+	b.WriteString("Book Id,Title,Author,Author l-f,Additional Authors,ISBN,ISBN13,My Rating,Average Rating,Publisher,Binding," +
+		"Number of Pages,Year Published,Original Publication Year,Date Read,Date Added,Bookshelves,Bookshelves with positions," +
+		"Exclusive Shelf,My Review,Spoiler,Private Notes,Read Count,Owned Copies\n")
+	for i, book := range books {
+		fmt.Fprintf(&b, "%d,%q,%s,,,\"=\"\"\"\"\",\"=\"\"%s\"\"\",%s,4.0,,,,%s,%s,,,,,read,%q,,,1,0\n", i+1, book[0], book[1], book[2], book[3],
+			book[4], book[4], book[5])
+	}
+	return b.String()
+}
+
+// confirmLibraryImport confirms the import and returns it once the job importing it is done.
+func (ts *testServer) confirmLibraryImport(t *testing.T, location, token string) libraryImportEnvelope {
+	t.Helper()
+	res := ts.do(t, http.MethodPost, location+"/confirm", token, nil)
+	checkStatus(t, res, http.StatusAccepted)
+	var env libraryImportEnvelope
+	res.decode(t, &env)
+	if env.LibraryImport.Status != data.LibraryImportConfirming || res.header.Get("Location") != location {
+		t.Errorf("confirmation = %+v, Location %q; want it confirming", env.LibraryImport, res.header.Get("Location"))
+	}
+	ts.app.jobsWG.Wait()
+	res = ts.get(t, location, token)
+	checkStatus(t, res, http.StatusOK)
+	env = libraryImportEnvelope{}
+	res.decode(t, &env)
+	return env
+}
+
+func TestLibraryImports(t *testing.T) {
+	ts := newTestServer(t)
+	_, token := ts.userToken(t, "Alice", "alice@example.com")
+	_, otherToken := ts.userToken(t, "Bob", "bob@example.com")
+	herbert := ts.seedAuthor(t, "Frank Herbert")
+	dune := ts.createBook(t, token, "Dune", herbert, 1965)
+	messiah := ts.createBook(t, token, "Dune Messiah", herbert, 1969)
+
+	file := goodreadsExport(
+		[6]string{"Dune (Dune Chronicles, #1)", "Frank Herbert", "", "5", "1965", "Spice<br/>and sand"},
+		[6]string{"Dune Messiah", "Frank Herbert", "", "3", "1969", ""},
+		[6]string{"Hyperion (Hyperion Cantos, #1)", "Dan Simmons", "9780553283686", "4", "1989", ""},
+		[6]string{"Ubik", "Philip K. Dick", "", "0", "1969", ""},
+		[6]string{"Mystery", "", "", "2", "", ""},
+	)
+	res := ts.do(t, http.MethodPost, "/v1/library-imports", token, file)
+	checkStatus(t, res, http.StatusOK)
+	location := res.header.Get("Location")
+	if !strings.HasPrefix(location, "/v1/library-imports/") {
+		t.Errorf("Location = %q; want the library import", location)
+	}
+	var env libraryImportEnvelope
+	res.decode(t, &env)
+	want := libraryImportSummary{Rows: 5, Matched: 2, Candidates: 1, Unmatched: 2, Pending: 3, Skipped: 2}
+	if env.LibraryImport.Status != data.LibraryImportPreviewed || env.LibraryImport.Source != "goodreads" || env.Summary != want {
+		t.Errorf("preview = %+v, %+v; want it previewed with %+v", env.LibraryImport, env.Summary, want)
+	}
+	rows := env.LibraryImport.Rows
+	if rows[0].BookID != dune.ID || rows[0].Match != data.MatchTitleAuthor || rows[1].BookID != messiah.ID || rows[2].Match != data.MatchCandidate {
+		t.Errorf("rows = %+v; want Dune and Dune Messiah matched, Hyperion a candidate", rows)
+	}
+
+	// Nothing is imported before the confirmation.
+	res = ts.get(t, fmt.Sprintf("/v1/books/%d", dune.ID), "")
+	checkStatus(t, res, http.StatusOK)
+	if strings.Contains(string(res.body), "Spice") {
+		t.Error("the review was created by the preview")
+	}
+
+	res = ts.get(t, location+"/unmatched", token)
+	checkStatus(t, res, http.StatusOK)
+	env = libraryImportEnvelope{}
+	res.decode(t, &env)
+	if len(env.Rows) != 3 || env.Rows[0].Title != "Hyperion (Hyperion Cantos, #1)" || env.Rows[1].Reason != "has no rating" ||
+		env.Rows[2].Match != data.MatchNone {
+		t.Errorf("unmatched rows = %+v; want Hyperion, Ubik and the row without an author", env.Rows)
+	}
+
+	checkStatus(t, ts.get(t, location, otherToken), http.StatusNotFound)
+	checkStatus(t, ts.do(t, http.MethodPost, location+"/confirm", otherToken, nil), http.StatusNotFound)
+
+	env = ts.confirmLibraryImport(t, location, token)
+	want = libraryImportSummary{Rows: 5, Matched: 2, Candidates: 1, Unmatched: 2, Imported: 3, Skipped: 2}
+	if env.LibraryImport.Status != data.LibraryImportConfirmed || env.LibraryImport.ConfirmedAt == nil || env.Summary != want {
+		t.Errorf("confirmed import = %+v, %+v; want it confirmed with %+v", env.LibraryImport, env.Summary, want)
+	}
+	hyperionID := env.LibraryImport.Rows[2].BookID
+	res = ts.get(t, fmt.Sprintf("/v1/books/%d", hyperionID), "")
+	checkStatus(t, res, http.StatusOK)
+	var book struct {
+		Book data.Book `json:"book"`
+	}
+	res.decode(t, &book)
+	if book.Book.Title != "Hyperion" || book.Book.Author.AuthorName != "Dan Simmons" || book.Book.ISBN13 != "9780553283686" ||
+		len(book.Book.Reviews) != 1 || book.Book.Reviews[0].Rating != 4 {
+		t.Errorf("added book = %+v; want Hyperion with the rating", book.Book)
+	}
+	res = ts.get(t, fmt.Sprintf("/v1/books/%d", dune.ID), "")
+	book.Book = data.Book{}
+	res.decode(t, &book)
+	if len(book.Book.Reviews) != 1 || book.Book.Reviews[0].Review != "Spice\nand sand" || book.Book.Reviews[0].Rating != 5 {
+		t.Errorf("reviews of Dune = %+v; want the imported review", book.Book.Reviews)
+	}
+
+	checkStatus(t, ts.do(t, http.MethodPost, location+"/confirm", token, nil), http.StatusConflict)
+
+	// Importing the same export again finds every book already reviewed.
+	res = ts.do(t, http.MethodPost, "/v1/library-imports", token, file)
+	checkStatus(t, res, http.StatusOK)
+	env = libraryImportEnvelope{}
+	res.decode(t, &env)
+	if env.Summary.Pending != 0 || env.Summary.Matched != 3 || env.LibraryImport.Rows[2].Reason != "the book is already reviewed" {
+		t.Errorf("second preview = %+v; want every book already reviewed", env.Summary)
+	}
+
+	checkStatus(t, ts.do(t, http.MethodPost, "/v1/library-imports", "", file), http.StatusBadRequest)
+	checkStatus(t, ts.do(t, http.MethodPost, "/v1/library-imports", token, "title,author\nDune,Frank Herbert\n"), http.StatusUnprocessableEntity)
+	checkStatus(t, ts.do(t, http.MethodPost, "/v1/library-imports", token, goodreadsExport()), http.StatusUnprocessableEntity)
+	checkStatus(t, ts.do(t, http.MethodPost, "/v1/library-imports", token, strings.Repeat("x", 1<<20+1)), http.StatusRequestEntityTooLarge)
+	checkStatus(t, ts.get(t, "/v1/library-imports/999", token), http.StatusNotFound)
+}
+
+func TestStoryGraphLibraryImport(t *testing.T) {
+	ts := newTestServer(t)
+	_, token := ts.userToken(t, "Alice", "alice@example.com")
+	dune := ts.createBook(t, token, "Dune", ts.seedAuthor(t, "Frank Herbert"), 1965)
+
+	file := "Title,Authors,Contributors,ISBN/UID,Format,Read Status,Date Added,Last Date Read,Dates Read,Read Count," +
+		"Moods,Pace,Character- or Plot-Driven?,Strong Character Development?,Loveable Characters?,Diverse Characters?," +
+		"Flawed Characters?,Star Rating,Review,Content Warnings,Content Warning Description,Tags,Owned?\n" +
+		"Dune,Frank Herbert,,,paperback,read,2020/01/01,,,1,,,,,,,,3.75,Spice,,,,No\n"
+	res := ts.do(t, http.MethodPost, "/v1/library-imports", token, file)
+	checkStatus(t, res, http.StatusOK)
+	var env libraryImportEnvelope
+	res.decode(t, &env)
+	if env.LibraryImport.Source != "storygraph" || len(env.LibraryImport.Rows) != 1 || env.LibraryImport.Rows[0].BookID != dune.ID {
+		t.Errorf("preview = %+v; want a StoryGraph import matching Dune", env.LibraryImport)
+	}
+	env = ts.confirmLibraryImport(t, res.header.Get("Location"), token)
+	if row := env.LibraryImport.Rows[0]; env.LibraryImport.Status != data.LibraryImportConfirmed || row.Status != data.LibraryRowImported || row.Rating != 4 {
+		t.Errorf("import = %+v; want Dune imported with 4 stars", env.LibraryImport)
+	}
+}
+
+func TestInterruptedLibraryImport(t *testing.T) {
+	ts := newTestServer(t)
+	_, token := ts.userToken(t, "Alice", "alice@example.com")
+	dune := ts.createBook(t, token, "Dune", ts.seedAuthor(t, "Frank Herbert"), 1965)
+
+	file := goodreadsExport(
+		[6]string{"Dune", "Frank Herbert", "", "5", "1965", ""},
+		[6]string{"Hyperion", "Dan Simmons", "", "4", "1989", ""},
+	)
+	res := ts.do(t, http.MethodPost, "/v1/library-imports", token, file)
+	checkStatus(t, res, http.StatusOK)
+	location := res.header.Get("Location")
+	var env libraryImportEnvelope
+	res.decode(t, &env)
+
+	// The server shutting down stops the job before it imports anything.
+	imp := env.LibraryImport
+	imp.Status = data.LibraryImportConfirming
+	err := ts.app.models.LibraryImports.Update(context.Background(), &imp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	ts.app.runLibraryImport(ctx, &imp)
+	res = ts.get(t, location, token)
+	checkStatus(t, res, http.StatusOK)
+	env = libraryImportEnvelope{}
+	res.decode(t, &env)
+	if env.LibraryImport.Status != data.LibraryImportFailed || env.Summary.Pending != 2 {
+		t.Fatalf("interrupted import = %+v, %+v; want it failed with both rows pending", env.LibraryImport, env.Summary)
+	}
+
+	env = ts.confirmLibraryImport(t, location, token)
+	if env.LibraryImport.Status != data.LibraryImportConfirmed || env.Summary.Imported != 2 || env.LibraryImport.Rows[0].BookID != dune.ID {
+		t.Errorf("import confirmed again = %+v, %+v; want both rows imported", env.LibraryImport, env.Summary)
+	}
+}
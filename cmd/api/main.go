@@ -2,85 +2,212 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
 	"database/sql"
+	"errors"
 	"flag"
+	"fmt"
 	_ "github.com/jackc/pgconn"
 	_ "github.com/jackc/pgx/v4"
 	_ "github.com/jackc/pgx/v4/stdlib"
+	"github.com/rrebeiz/quickbooks/internal/blob"
 	"github.com/rrebeiz/quickbooks/internal/data"
-	"log"
+	"io"
+	"log/slog"
+	_ "modernc.org/sqlite"
 	"os"
+	"os/signal"
+	"sync"
+	"syscall"
 	"time"
 )
 
-type config struct {
-	port int
-	env  string
-	db   struct {
-		dsn    string
-		pepper string
-	}
-	jwt struct {
-		secret string
-	}
-}
-
 type application struct {
-	config   config
-	infoLog  *log.Logger
-	errorLog *log.Logger
-	models   data.Models
+	config  config
+	logger  *slog.Logger
+	models  data.Models
+	metrics *metrics
+	cursors *data.CursorCodec
+	covers  blob.Store
+	// jobs is the context of the background jobs, stopJobs cancels it when the server shuts down.
+	jobs     context.Context
+	stopJobs context.CancelFunc
+	jobsWG   sync.WaitGroup
 }
 
 const version = "1.0.0"
 
 func main() {
-	var cfg config
+	// quickbooks import [flags] FILE... imports books and quickbooks export [flags] FORMAT exports
+	// them, instead of serving the API.
+	args := os.Args[1:]
+	command := "serve"
+	if len(args) > 0 && (args[0] == "import" || args[0] == "export") {
+		command, args = args[0], args[1:]
+	}
+	cfg, fs, err := loadConfig(args, os.LookupEnv)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return
+		}
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if cfg.printConfig {
+		err = writeConfig(os.Stdout, fs)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+	err = cfg.validate()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
 
-	flag.IntVar(&cfg.port, "port", 4000, "the port of the application")
-	flag.StringVar(&cfg.env, "environment", "development", "environment, development | production")
-	flag.StringVar(&cfg.db.dsn, "db-dsn", os.Getenv("DSN"), "DB DSN")
-	flag.StringVar(&cfg.db.pepper, "db-pepper", "super-secret-pepper", "DB pepper")
-	flag.Parse()
+	// The commands may write their output to stdout, they log to stderr.
+	logOutput := io.Writer(os.Stdout)
+	if command != "serve" {
+		logOutput = os.Stderr
+	}
+	logger, err := newLogger(cfg, logOutput)
+	if err != nil {
+		slog.Error(err.Error())
+		os.Exit(1)
+	}
 
-	infoLog := log.New(os.Stdout, "INFO", log.Ldate|log.Ltime)
-	errorLog := log.New(os.Stdout, "ERROR", log.Ldate|log.Ltime|log.Lshortfile)
-	db, err := openDB(cfg)
+	shutdownTracing, err := setupTracing(cfg)
+	if err != nil {
+		logger.Error("failed to set up tracing", "error", err)
+		os.Exit(1)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		shutdownTracing(ctx)
+	}()
+
+	db, dialect, err := openDB(cfg)
+	if err != nil {
+		logger.Error("failed to connect to the DB", "error", err)
+		os.Exit(1)
+	}
 	defer db.Close()
+
+	if cfg.db.migrate {
+		err = data.Migrate(context.Background(), db, dialect)
+		if err != nil {
+			logger.Error("failed to migrate the DB", "error", err)
+			os.Exit(1)
+		}
+	}
+
+	metrics := newMetrics(db)
+	models := data.NewModels(db, dialect, cfg.db.queryTimeout)
+	// The export reads every book once, caching them would only fill the cache.
+	if command != "export" {
+		closeCache, err := cacheModels(cfg, &models, metrics, logger)
+		if err != nil {
+			logger.Error("failed to set up the cache", "error", err)
+			os.Exit(1)
+		}
+		defer closeCache()
+	}
+
+	cursors, err := newCursorCodec(cfg)
 	if err != nil {
-		errorLog.Println("Failed to connect to the DB.", err)
+		logger.Error("failed to set up the pagination cursors", "error", err)
+		os.Exit(1)
+	}
+	if cfg.env == "production" && cfg.cursor.secret == "" {
+		logger.Warn("cursor-secret is not set, the pagination cursors are signed with a random key and stop working when the server restarts or on other instances")
+	}
 
+	covers, err := newCoverStore(cfg)
+	if err != nil {
+		logger.Error("failed to set up the cover store", "error", err)
+		os.Exit(1)
 	}
 
-	models := data.NewModels(db)
+	jobs, stopJobs := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stopJobs()
 	app := &application{
 		config:   cfg,
-		infoLog:  infoLog,
-		errorLog: errorLog,
+		logger:   logger,
 		models:   models,
+		metrics:  metrics,
+		cursors:  cursors,
+		covers:   covers,
+		jobs:     jobs,
+		stopJobs: stopJobs,
+	}
+
+	switch command {
+	case "import":
+		err = app.importFiles(jobs, os.Stdout, fs.Args())
+	case "export":
+		err = app.exportBooks(jobs, os.Stdout, fs.Args())
+	default:
+		err = app.serve()
+		if err != nil {
+			logger.Error(err.Error())
+		}
+		return
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
 	}
+}
 
-	err = app.serve()
+// newLogger returns the logger writing JSON lines to w.
+func newLogger(cfg config, w io.Writer) (*slog.Logger, error) {
+	var level slog.Level
+	err := level.UnmarshalText([]byte(cfg.logLevel))
 	if err != nil {
-		errorLog.Println(err)
+		return nil, fmt.Errorf("invalid log level %q", cfg.logLevel)
 	}
+	handler := slog.NewJSONHandler(w, &slog.HandlerOptions{Level: level})
+	return slog.New(handler).With("environment", cfg.env, "version", version), nil
 }
 
-func openDB(cfg config) (*sql.DB, error) {
-	db, err := sql.Open("pgx", cfg.db.dsn)
+// newCursorCodec returns the codec of the pagination cursors. Without a configured secret the
+// cursors are signed with a random key, and stop working when the server restarts.
+func newCursorCodec(cfg config) (*data.CursorCodec, error) {
+	secret := []byte(cfg.cursor.secret)
+	if len(secret) == 0 {
+		secret = make([]byte, 32)
+		_, err := rand.Read(secret)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return data.NewCursorCodec(secret), nil
+}
+
+// openDB connects to the database of the DSN, Postgres or SQLite depending on its scheme.
+func openDB(cfg config) (*sql.DB, data.Dialect, error) {
+	dialect, dataSource, err := data.ParseDSN(cfg.db.dsn)
+	if err != nil {
+		return nil, 0, err
+	}
+	db, err := sql.Open(dialect.DriverName(), dataSource)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 
-	db.SetMaxOpenConns(5)
-	db.SetMaxIdleConns(5)
-	db.SetConnMaxLifetime(5 * time.Minute)
+	db.SetMaxOpenConns(cfg.db.maxOpenConns)
+	db.SetMaxIdleConns(cfg.db.maxIdleConns)
+	db.SetConnMaxLifetime(cfg.db.connMaxLifetime)
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 	err = db.PingContext(ctx)
 	if err != nil {
-		return nil, err
+		db.Close()
+		return nil, 0, err
 	}
-	return db, nil
+	return db, dialect, nil
 }
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const metricsNamespace = "quickbooks"
+
+type metrics struct {
+	registry        *prometheus.Registry
+	requests        *prometheus.CounterVec
+	requestDuration *prometheus.HistogramVec
+	inFlight        prometheus.Gauge
+	logins          *prometheus.CounterVec
+	tokensIssued    prometheus.Counter
+	cacheLookups    *prometheus.CounterVec
+	cacheErrors     *prometheus.CounterVec
+}
+
+// newMetrics creates the application metrics on their own registry. The connection pool
+// stats of db are exported as well when db is not nil.
+func newMetrics(db *sql.DB) *metrics {
+	m := &metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "http_requests_total",
+			Help:      "Number of HTTP requests served, by method, route pattern and status code.",
+		}, []string{"method", "route", "status"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "HTTP request latencies, by method and route pattern.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route"}),
+		inFlight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "http_requests_in_flight",
+			Help:      "Number of HTTP requests currently being served.",
+		}),
+		logins: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "logins_total",
+			Help:      "Number of login attempts, by result.",
+		}, []string{"result"}),
+		tokensIssued: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "tokens_issued_total",
+			Help:      "Number of authentication tokens issued.",
+		}),
+		cacheLookups: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "cache_lookups_total",
+			Help:      "Number of cached lookups, by lookup and result (hit or miss).",
+		}, []string{"lookup", "result"}),
+		cacheErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "cache_errors_total",
+			Help:      "Number of failed cache store operations, by operation.",
+		}, []string{"op"}),
+	}
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.requests,
+		m.requestDuration,
+		m.inFlight,
+		m.logins,
+		m.tokensIssued,
+		m.cacheLookups,
+		m.cacheErrors,
+	)
+	if db != nil {
+		m.registry.MustRegister(collectors.NewDBStatsCollector(db, "go_books"))
+	}
+	return m
+}
+
+func (m *metrics) loginSucceeded() {
+	m.logins.WithLabelValues("success").Inc()
+}
+
+func (m *metrics) loginFailed() {
+	m.logins.WithLabelValues("failure").Inc()
+}
+
+func (m *metrics) tokenIssued() {
+	m.tokensIssued.Inc()
+}
+
+func (app *application) recordMetrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		app.metrics.inFlight.Inc()
+		defer app.metrics.inFlight.Dec()
+
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		start := time.Now()
+
+		next.ServeHTTP(ww, r)
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		// Requests that did not match any route are grouped together, otherwise
+		// every random path would create a new time series.
+		route := routePattern(r)
+		if route == "" {
+			route = "unmatched"
+		}
+		app.metrics.requests.WithLabelValues(r.Method, route, strconv.Itoa(status)).Inc()
+		app.metrics.requestDuration.WithLabelValues(r.Method, route).Observe(time.Since(start).Seconds())
+	})
+}
+
+// metricsHandler serves the metrics, behind basic auth when a metrics username is configured.
+func (app *application) metricsHandler() http.Handler {
+	handler := promhttp.HandlerFor(app.metrics.registry, promhttp.HandlerOpts{})
+	if app.config.metrics.username == "" {
+		return handler
+	}
+	return app.metricsBasicAuth(handler)
+}
+
+func (app *application) metricsBasicAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username, password, ok := r.BasicAuth()
+		if ok {
+			usernameMatch := subtle.ConstantTimeCompare([]byte(username), []byte(app.config.metrics.username)) == 1
+			passwordMatch := subtle.ConstantTimeCompare([]byte(password), []byte(app.config.metrics.password)) == 1
+			if usernameMatch && passwordMatch {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+		w.Header().Set("WWW-Authenticate", `Basic realm="metrics", charset="UTF-8"`)
+		app.notAuthorizedResponse(w, r)
+	})
+}
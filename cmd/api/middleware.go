@@ -1,11 +1,96 @@
 package main
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
 	"github.com/rrebeiz/quickbooks/internal/data"
 	"net/http"
+	"regexp"
+	"time"
 )
 
+const requestIDHeader = "X-Request-ID"
+
+var requestIDRX = regexp.MustCompile(`^[a-zA-Z0-9._:-]{1,128}$`)
+
+// requestID reuses the X-Request-ID sent by the client (or an upstream proxy) when it looks sane,
+// otherwise a new one is generated. The ID is echoed back in the response headers.
+func (app *application) requestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if !requestIDRX.MatchString(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(requestIDHeader, id)
+		r = app.contextSetRequestInfo(r, &requestInfo{id: id})
+		next.ServeHTTP(w, r)
+	})
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return fmt.Sprintf("%d", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
+
+func (app *application) logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		start := time.Now()
+
+		next.ServeHTTP(ww, r)
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		attrs := []any{
+			"method", r.Method,
+			"route", routePattern(r),
+			"path", r.URL.Path,
+			"status", status,
+			"bytes", ww.BytesWritten(),
+			"duration_ms", float64(time.Since(start).Microseconds()) / 1000,
+		}
+		if userID := app.contextGetRequestInfo(r).userID; userID > 0 {
+			attrs = append(attrs, "user_id", userID)
+		}
+		app.requestLogger(r).Info("request completed", attrs...)
+	})
+}
+
+func (app *application) recoverPanic(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			if err := recover(); err != nil {
+				if err == http.ErrAbortHandler {
+					panic(err)
+				}
+				w.Header().Set("Connection", "close")
+				app.serverErrorResponse(w, r, fmt.Errorf("%v", err))
+			}
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// routePattern returns the chi route pattern the request was matched against, so that
+// /v1/books/1 and /v1/books/2 are reported under the same /v1/books/{id} route.
+func routePattern(r *http.Request) string {
+	rctx := chi.RouteContext(r.Context())
+	if rctx == nil {
+		return ""
+	}
+	return rctx.RoutePattern()
+}
+
 func (app *application) authTokenMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		plainTextToken, err := app.readAuthHeader(r)
@@ -21,7 +106,7 @@ func (app *application) authTokenMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
-		_, err = app.getValidToken(plainTextToken)
+		token, err := app.getValidToken(r.Context(), plainTextToken)
 		if err != nil {
 			switch {
 			case errors.Is(err, data.ErrNoRecordFound):
@@ -31,6 +116,7 @@ func (app *application) authTokenMiddleware(next http.Handler) http.Handler {
 			}
 			return
 		}
+		app.contextSetUserID(r, token.UserID)
 
 		next.ServeHTTP(w, r)
 	})
@@ -51,7 +137,7 @@ func (app *application) adminMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
-		token, err := app.getValidToken(plaintextToken)
+		token, err := app.getValidToken(r.Context(), plaintextToken)
 		if err != nil {
 			switch {
 			case errors.Is(err, data.ErrNoRecordFound):
@@ -62,7 +148,7 @@ func (app *application) adminMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
-		user, err := app.models.Tokens.GetUserForToken(token)
+		user, err := app.models.Tokens.GetUserForToken(r.Context(), token)
 		if err != nil {
 			switch {
 			case errors.Is(err, data.ErrNoRecordFound):
@@ -73,6 +159,8 @@ func (app *application) adminMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
+		app.contextSetUserID(r, user.ID)
+
 		if user.AccountType != "admin" {
 			app.notAuthorizedResponse(w, r)
 			return
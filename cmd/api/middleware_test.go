@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/rrebeiz/quickbooks/internal/data"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequestID(t *testing.T) {
+	app := newTestApplication(t, data.NewMemoryModels())
+	routes := app.routes()
+
+	tests := []struct {
+		name  string
+		id    string
+		reuse bool
+	}{
+		{"reused", "upstream-id.1:2", true},
+		{"generated", "", false},
+		{"invalid", "spaces are not allowed", false},
+		{"too long", strings.Repeat("a", 129), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/v1/unknown", nil)
+			req.Header.Set(requestIDHeader, tt.id)
+			rr := httptest.NewRecorder()
+			routes.ServeHTTP(rr, req)
+
+			got := rr.Header().Get(requestIDHeader)
+			if tt.reuse && got != tt.id || !tt.reuse && (got == tt.id || len(got) != 32) {
+				t.Errorf("%s = %q for an incoming %q", requestIDHeader, got, tt.id)
+			}
+			var env struct {
+				RequestID string `json:"request_id"`
+			}
+			err := json.Unmarshal(rr.Body.Bytes(), &env)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if env.RequestID != got {
+				t.Errorf("request_id = %q; want %q", env.RequestID, got)
+			}
+		})
+	}
+}
+
+func TestErrorEnvelopes(t *testing.T) {
+	ts := newTestServer(t)
+
+	tests := []struct {
+		method  string
+		path    string
+		status  int
+		message string
+	}{
+		{http.MethodGet, "/v1/unknown", http.StatusNotFound, "the requested resource could not be found"},
+		{http.MethodPut, "/v1/books", http.StatusMethodNotAllowed, "the requested method PUT is not allowed on this resource"},
+		{http.MethodPost, "/v1/books", http.StatusBadRequest, "no authorization header received"},
+		{http.MethodGet, "/v1/users", http.StatusBadRequest, "no authorization header received"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			res := ts.do(t, tt.method, tt.path, "", nil)
+			checkStatus(t, res, tt.status)
+			if ct := res.header.Get("Content-Type"); ct != "application/json" {
+				t.Errorf("Content-Type = %q", ct)
+			}
+			message, requestID := res.errorEnvelope(t)
+			if message != tt.message || requestID == "" {
+				t.Errorf("envelope = %s; want error %q and a request_id", res.body, tt.message)
+			}
+		})
+	}
+}
+
+func TestAuthTokenMiddleware(t *testing.T) {
+	ts := newTestServer(t)
+	user := ts.createUser(t, "Alice", "alice@example.com", "password")
+	expired := expiredToken(t, ts, user.ID)
+
+	tests := []struct {
+		name   string
+		header string
+		status int
+	}{
+		{"no header", "", http.StatusBadRequest},
+		{"not a bearer token", "Basic YWxpY2U6cGFzc3dvcmQ=", http.StatusBadRequest},
+		{"wrong length", "Bearer abc", http.StatusBadRequest},
+		{"unknown token", "Bearer " + strings.Repeat("A", 26), http.StatusUnauthorized},
+		{"expired token", "Bearer " + expired, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, ts.URL+fmt.Sprintf("/v1/users/%d", user.ID), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			res, err := ts.Client().Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			res.Body.Close()
+			if res.StatusCode != tt.status {
+				t.Errorf("status = %d; want %d", res.StatusCode, tt.status)
+			}
+		})
+	}
+}
+
+func TestRecoverPanic(t *testing.T) {
+	app := newTestApplication(t, data.NewMemoryModels())
+	var logs bytes.Buffer
+	app.logger = slog.New(slog.NewJSONHandler(&logs, nil))
+
+	handler := app.requestID(app.recoverPanic(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})))
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if rr.Code != http.StatusInternalServerError || rr.Header().Get("Connection") != "close" {
+		t.Errorf("status %d, Connection %q; want 500 and close", rr.Code, rr.Header().Get("Connection"))
+	}
+	if !strings.Contains(rr.Body.String(), "the server encountered a problem.") {
+		t.Errorf("body = %s", rr.Body)
+	}
+	if !strings.Contains(logs.String(), `"msg":"boom"`) || !strings.Contains(logs.String(), rr.Header().Get(requestIDHeader)) {
+		t.Errorf("logs = %s; want the panic with the request ID", logs.String())
+	}
+}
+
+func TestLogRequests(t *testing.T) {
+	ts := newTestServer(t)
+	var logs bytes.Buffer
+	ts.app.logger = slog.New(slog.NewJSONHandler(&logs, nil))
+	user, token := ts.userToken(t, "Alice", "alice@example.com")
+	logs.Reset()
+
+	res := ts.get(t, fmt.Sprintf("/v1/users/%d", user.ID), token)
+	checkStatus(t, res, http.StatusOK)
+
+	var line struct {
+		Msg       string `json:"msg"`
+		RequestID string `json:"request_id"`
+		Route     string `json:"route"`
+		Status    int    `json:"status"`
+		UserID    int64  `json:"user_id"`
+	}
+	err := json.Unmarshal(logs.Bytes(), &line)
+	if err != nil {
+		t.Fatalf("decoding %q: %v", logs.String(), err)
+	}
+	if line.Msg != "request completed" || line.Route != "/v1/users/{id}" || line.Status != http.StatusOK ||
+		line.UserID != user.ID || line.RequestID != res.header.Get(requestIDHeader) {
+		t.Errorf("log line = %s", logs.String())
+	}
+}
+
+func TestHealthcheckAndMetrics(t *testing.T) {
+	ts := newTestServer(t)
+
+	res := ts.get(t, "/healthcheck", "")
+	checkStatus(t, res, http.StatusOK)
+	var env struct {
+		Status     string            `json:"status"`
+		SystemInfo map[string]string `json:"system_info"`
+	}
+	res.decode(t, &env)
+	if env.Status != "available" || env.SystemInfo["version"] != version || env.SystemInfo["environment"] != "testing" {
+		t.Errorf("healthcheck = %s", res.body)
+	}
+
+	res = ts.get(t, "/metrics", "")
+	checkStatus(t, res, http.StatusOK)
+	want := `quickbooks_http_requests_total{method="GET",route="/healthcheck",status="200"} 1`
+	if !strings.Contains(string(res.body), want) {
+		t.Errorf("metrics do not contain %s", want)
+	}
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/rrebeiz/quickbooks/internal/data"
+	"github.com/rrebeiz/quickbooks/internal/validator"
+	"net/http"
+)
+
+func (app *application) getAllPublishersHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Name string
+		data.Filters
+	}
+	v := validator.NewValidator()
+
+	qs := r.URL.Query()
+	input.Name = app.readString(qs, "name", "")
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Sort = app.readString(qs, "sort", "id")
+	input.Filters.SortSafeList = []string{"id", "name", "-id", "-name"}
+	input.Filters.Cursor = app.readString(qs, "cursor", "")
+	input.Filters.Cursors = app.cursors
+
+	data.ValidateFilters(v, input.Filters)
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	publishers, metadata, err := app.models.Publishers.GetAll(r.Context(), input.Name, input.Filters)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrInvalidCursor):
+			app.invalidCursorResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	links, headers := app.paginationLinks(r, metadata)
+	err = app.writeJSON(w, http.StatusOK, envelope{"publishers": publishers, "metadata": metadata, "links": links}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+func (app *application) getPublisherHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readParamID(r)
+	if err != nil {
+		app.notfoundResponse(w, r)
+		return
+	}
+	publisher, err := app.models.Publishers.GetByID(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRecordFound):
+			app.notfoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"publisher": publisher}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+func (app *application) createPublisherHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Name string `json:"name"`
+	}
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	publisher := data.Publisher{Name: input.Name}
+
+	v := validator.NewValidator()
+	data.ValidatePublisher(v, &publisher)
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	err = app.models.Publishers.Insert(r.Context(), &publisher)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicatePublisher):
+			app.duplicatePublisherResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/publishers/%d", publisher.ID))
+	err = app.writeJSON(w, http.StatusOK, envelope{"publisher": publisher}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+func (app *application) updatePublisherHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readParamID(r)
+	if err != nil {
+		app.notfoundResponse(w, r)
+		return
+	}
+	publisher, err := app.models.Publishers.GetByID(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRecordFound):
+			app.notfoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	var input struct {
+		Name    *string `json:"name"`
+		Version *int    `json:"version"`
+	}
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	if input.Version != nil && *input.Version != publisher.Version {
+		app.editConflictResponse(w, r)
+		return
+	}
+	if input.Name != nil {
+		publisher.Name = *input.Name
+	}
+	v := validator.NewValidator()
+	data.ValidatePublisher(v, publisher)
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	err = app.models.Publishers.Update(r.Context(), publisher)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRecordFound):
+			// The publisher was changed or deleted since it was read.
+			app.editConflictResponse(w, r)
+		case errors.Is(err, data.ErrDuplicatePublisher):
+			app.duplicatePublisherResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"publisher": publisher}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+func (app *application) deletePublisherHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readParamID(r)
+	if err != nil {
+		app.notfoundResponse(w, r)
+		return
+	}
+	err = app.models.Publishers.Delete(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRecordFound):
+			app.notfoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	msg := fmt.Sprintf("publisher with ID %d deleted.", id)
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": msg}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+// checkPublisher records a validation error when the publisher does not exist, 0 is no publisher.
+func (app *application) checkPublisher(ctx context.Context, v *validator.Validator, id int64) error {
+	if id == 0 {
+		return nil
+	}
+	_, err := app.models.Publishers.GetByID(ctx, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrNoRecordFound):
+			v.Check(false, "publisher_id", "does not exist")
+		default:
+			return err
+		}
+	}
+	return nil
+}
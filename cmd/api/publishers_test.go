@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"github.com/rrebeiz/quickbooks/internal/data"
+	"net/http"
+	"testing"
+)
+
+func TestPublishers(t *testing.T) {
+	ts := newTestServer(t)
+	_, token := ts.userToken(t, "Alice", "alice@example.com")
+	herbert := ts.seedAuthor(t, "Frank Herbert")
+
+	createPublisher := func(name string) data.Publisher {
+		res := ts.do(t, http.MethodPost, "/v1/publishers", token, map[string]any{"name": name})
+		checkStatus(t, res, http.StatusOK)
+		var created struct {
+			Publisher data.Publisher `json:"publisher"`
+		}
+		res.decode(t, &created)
+		return created.Publisher
+	}
+	checkStatus(t, ts.do(t, http.MethodPost, "/v1/publishers", "", map[string]any{"name": "Ace"}), http.StatusBadRequest)
+	checkStatus(t, ts.do(t, http.MethodPost, "/v1/publishers", token, map[string]any{"name": ""}), http.StatusUnprocessableEntity)
+	ace := createPublisher("Ace")
+	chilton := createPublisher("Chilton Books")
+	checkStatus(t, ts.do(t, http.MethodPost, "/v1/publishers", token, map[string]any{"name": "Ace"}), http.StatusBadRequest)
+
+	res := ts.do(t, http.MethodPost, "/v1/books", token, map[string]any{
+		"title": "Dune", "author_id": herbert.ID, "publication_year": 1965, "description": "Dune", "publisher_id": chilton.ID,
+	})
+	checkStatus(t, res, http.StatusOK)
+	var created struct {
+		Book data.Book `json:"book"`
+	}
+	res.decode(t, &created)
+	dune := created.Book
+	if dune.Publisher == nil || dune.Publisher.Name != "Chilton Books" {
+		t.Errorf("created book publisher = %+v; want Chilton Books", dune.Publisher)
+	}
+	messiah := ts.createBook(t, token, "Dune Messiah", herbert, 1969)
+	checkStatus(t, ts.do(t, http.MethodPatch, fmt.Sprintf("/v1/books/%d", messiah.ID), token, map[string]any{"publisher_id": ace.ID + 100}), http.StatusUnprocessableEntity)
+	checkStatus(t, ts.do(t, http.MethodPatch, fmt.Sprintf("/v1/books/%d", messiah.ID), token, map[string]any{"publisher_id": ace.ID}), http.StatusOK)
+	ts.createBook(t, token, "Children of Dune", herbert, 1976)
+
+	res = ts.get(t, fmt.Sprintf("/v1/books?publisher_id=%d", ace.ID), "")
+	checkStatus(t, res, http.StatusOK)
+	var list struct {
+		Books []data.Book `json:"books"`
+	}
+	res.decode(t, &list)
+	if len(list.Books) != 1 || list.Books[0].ID != messiah.ID || list.Books[0].Publisher.Name != "Ace" {
+		t.Errorf("books of Ace = %+v; want Dune Messiah", list.Books)
+	}
+	checkStatus(t, ts.get(t, "/v1/books?publisher_id=-1", ""), http.StatusUnprocessableEntity)
+
+	res = ts.get(t, "/v1/publishers?sort=-name", "")
+	checkStatus(t, res, http.StatusOK)
+	var publishers struct {
+		Publishers []data.Publisher `json:"publishers"`
+		Metadata   data.Metadata    `json:"metadata"`
+	}
+	res.decode(t, &publishers)
+	if len(publishers.Publishers) != 2 || publishers.Publishers[0].ID != chilton.ID || publishers.Publishers[0].BookCount != 1 || publishers.Metadata.TotalRecords != 2 {
+		t.Errorf("publishers = %+v", publishers)
+	}
+
+	path := fmt.Sprintf("/v1/publishers/%d", ace.ID)
+	checkStatus(t, ts.do(t, http.MethodPatch, path, token, map[string]any{"name": "Chilton Books"}), http.StatusBadRequest)
+	checkStatus(t, ts.do(t, http.MethodPatch, path, token, map[string]any{"name": "Ace Books", "version": ace.Version + 1}), http.StatusConflict)
+	checkStatus(t, ts.do(t, http.MethodPatch, path, token, map[string]any{"name": "Ace Books", "version": ace.Version}), http.StatusOK)
+	res = ts.get(t, path, "")
+	checkStatus(t, res, http.StatusOK)
+	var found struct {
+		Publisher data.Publisher `json:"publisher"`
+	}
+	res.decode(t, &found)
+	if found.Publisher.Name != "Ace Books" || found.Publisher.BookCount != 1 {
+		t.Errorf("publisher = %+v; want Ace Books with 1 book", found.Publisher)
+	}
+
+	checkStatus(t, ts.do(t, http.MethodDelete, path, token, nil), http.StatusOK)
+	checkStatus(t, ts.get(t, path, ""), http.StatusNotFound)
+	res = ts.get(t, fmt.Sprintf("/v1/books/%d", messiah.ID), "")
+	checkStatus(t, res, http.StatusOK)
+	var book struct {
+		Book data.Book `json:"book"`
+	}
+	res.decode(t, &book)
+	if book.Book.Publisher != nil {
+		t.Errorf("book publisher after deleting it = %+v; want none", book.Book.Publisher)
+	}
+}
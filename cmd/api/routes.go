@@ -2,20 +2,27 @@ package main
 
 import (
 	"github.com/go-chi/chi/v5"
-	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/cors"
+	"github.com/rrebeiz/quickbooks/internal/blob"
 	"net/http"
 )
 
 func (app *application) routes() http.Handler {
 	router := chi.NewRouter()
 
-	router.Use(middleware.Recoverer)
+	router.NotFound(app.notfoundResponse)
+	router.MethodNotAllowed(app.methodNotAllowedResponse)
+
+	router.Use(app.requestID)
+	router.Use(app.traceRequests)
+	router.Use(app.logRequests)
+	router.Use(app.recordMetrics)
+	router.Use(app.recoverPanic)
 	router.Use(cors.Handler(cors.Options{
-		AllowedOrigins:   []string{"https://*", "http://*"},
+		AllowedOrigins:   app.config.cors.trustedOrigins,
 		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
-		ExposedHeaders:   []string{"Link"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token", requestIDHeader},
+		ExposedHeaders:   []string{"Link", requestIDHeader},
 		AllowCredentials: true,
 		MaxAge:           300,
 	}))
@@ -27,9 +34,20 @@ func (app *application) routes() http.Handler {
 		router.Post("/v1/books", app.createBookHandler)
 		router.Patch("/v1/books/{id}", app.updateBookHandler)
 		router.Delete("/v1/books/{id}", app.deleteBookHandler)
+		router.Put("/v1/books/{id}/cover", app.updateBookCoverHandler)
 		router.Post("/v1/books/reviews", app.createReviewHandler)
 		router.Patch("/v1/books/reviews/{id}", app.updateReviewHandler)
 		router.Delete("/v1/books/reviews/{id}", app.deleteReviewHandler)
+		router.Post("/v1/series", app.createSeriesHandler)
+		router.Patch("/v1/series/{id}", app.updateSeriesHandler)
+		router.Delete("/v1/series/{id}", app.deleteSeriesHandler)
+		router.Post("/v1/publishers", app.createPublisherHandler)
+		router.Patch("/v1/publishers/{id}", app.updatePublisherHandler)
+		router.Delete("/v1/publishers/{id}", app.deletePublisherHandler)
+		router.Post("/v1/library-imports", app.createLibraryImportHandler)
+		router.Get("/v1/library-imports/{id}", app.getLibraryImportHandler)
+		router.Get("/v1/library-imports/{id}/unmatched", app.getLibraryImportUnmatchedHandler)
+		router.Post("/v1/library-imports/{id}/confirm", app.confirmLibraryImportHandler)
 	})
 	router.Group(func(router chi.Router) {
 		router.Use(app.adminMiddleware)
@@ -37,9 +55,26 @@ func (app *application) routes() http.Handler {
 		router.Get("/v1/users/authenticated", app.getAllAuthenticatedUsersHandler)
 		router.Delete("/v1/users/{id}", app.deleteUserHandler)
 		router.Delete("/v1/users/logout/{id}", app.adminLogoutHandler)
+		router.Post("/v1/users/{id}/restore", app.restoreUserHandler)
+		router.Post("/v1/books/{id}/restore", app.restoreBookHandler)
+		router.Post("/v1/books/reviews/{id}/restore", app.restoreReviewHandler)
+		router.Get("/v1/trash", app.getTrashHandler)
+		router.Post("/v1/works/{id}/merge", app.mergeWorksHandler)
+		router.Post("/v1/imports", app.createImportHandler)
+		router.Get("/v1/imports/{id}", app.getImportHandler)
+		router.Get("/v1/imports/{id}/errors", app.getImportErrorsHandler)
+		router.Get("/v1/exports/books", app.exportBooksHandler)
 	})
 
 	router.Get("/healthcheck", app.healthCheckHandler)
+	if app.config.metrics.addr == "" {
+		router.Method(http.MethodGet, "/metrics", app.metricsHandler())
+	}
+
+	if local, ok := app.covers.(*blob.Local); ok {
+		router.Method(http.MethodGet, app.coversPath()+"/*", local)
+		router.Method(http.MethodHead, app.coversPath()+"/*", local)
+	}
 
 	router.Post("/v1/users/login", app.loginHandler)
 	router.Get("/v1/users/logout", app.logoutHandler)
@@ -47,10 +82,20 @@ func (app *application) routes() http.Handler {
 	// Book routes
 	router.Get("/v1/books", app.getAllBooksHandler)
 	router.Get("/v1/books/{id}", app.getBookByIDHandler)
-	router.Get("/v1/books/slug", app.getBookBySlugHandler)
+	router.Get("/v1/books/slug/{slug}", app.getBookBySlugHandler)
+	router.Get("/v1/books/isbn/{isbn}", app.getBookByISBNHandler)
 	router.Get("/v1/books/authors", app.getAllAuthorsHandler)
 	router.Get("/v1/books/reviews", app.getAllReviewsByUser)
 	router.Get("/v1/books/reviews/{id}", app.getReviewByIDHandler)
+	router.Get("/v1/works/{id}", app.getWorkHandler)
+	router.Get("/v1/works/{id}/editions", app.getEditionsHandler)
+	router.Get("/v1/series", app.getAllSeriesHandler)
+	router.Get("/v1/series/{id}", app.getSeriesHandler)
+	router.Get("/v1/series/{id}/books", app.getSeriesBooksHandler)
+	router.Get("/v1/publishers", app.getAllPublishersHandler)
+	router.Get("/v1/publishers/{id}", app.getPublisherHandler)
+	router.Get("/v1/search", app.searchBooksHandler)
+	router.Get("/v1/autocomplete", app.autocompleteHandler)
 
 	return router
 }
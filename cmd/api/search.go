@@ -0,0 +1,77 @@
+package main
+
+import (
+	"github.com/rrebeiz/quickbooks/internal/data"
+	"github.com/rrebeiz/quickbooks/internal/validator"
+	"net/http"
+	"strings"
+)
+
+func (app *application) searchBooksHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Query string
+		data.Filters
+	}
+	v := validator.NewValidator()
+
+	qs := r.URL.Query()
+	input.Query = app.readString(qs, "q", "")
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Sort = "rank"
+	input.Filters.SortSafeList = []string{"rank"}
+
+	v.Check(input.Query != "", "q", "must be provided")
+	v.Check(len(input.Query) <= 500, "q", "must not be more than 500 bytes long")
+	data.ValidateFilters(v, input.Filters)
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	results, metadata, err := app.models.Books.Search(r.Context(), input.Query, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	links, headers := app.paginationLinks(r, metadata)
+	err = app.writeJSON(w, http.StatusOK, envelope{"results": results, "metadata": metadata, "links": links}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+func (app *application) autocompleteHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Query string
+		Limit int
+	}
+	v := validator.NewValidator()
+
+	qs := r.URL.Query()
+	input.Query = app.readString(qs, "q", "")
+	input.Limit = app.readInt(qs, "limit", 10, v)
+
+	v.Check(strings.TrimSpace(input.Query) != "", "q", "must be provided")
+	v.Check(len(input.Query) <= 100, "q", "must not be more than 100 bytes long")
+	v.Check(input.Limit > 0, "limit", "must be greater than 0")
+	v.Check(input.Limit <= 25, "limit", "must not be more than 25")
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	suggestions, err := app.models.Books.Autocomplete(r.Context(), input.Query, input.Limit)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"suggestions": suggestions}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
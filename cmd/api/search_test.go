@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestSearch(t *testing.T) {
+	ts := newTestServer(t)
+	_, token := ts.userToken(t, "Alice", "alice@example.com")
+	herbert := ts.seedAuthor(t, "Frank Herbert")
+	tolkien := ts.seedAuthor(t, "Tolkien")
+	dune := ts.createBook(t, token, "Dune", herbert, 1965)
+	ts.createBook(t, token, "The Hobbit", tolkien, 1937)
+
+	res := ts.do(t, http.MethodPatch, fmt.Sprintf("/v1/books/%d", dune.ID), token, map[string]any{"language": "klingon"})
+	checkStatus(t, res, http.StatusUnprocessableEntity)
+	res = ts.do(t, http.MethodPatch, fmt.Sprintf("/v1/books/%d", dune.ID), token, map[string]any{"language": "english"})
+	checkStatus(t, res, http.StatusOK)
+
+	res = ts.get(t, "/v1/search?q=herbert", "")
+	checkStatus(t, res, http.StatusOK)
+	var env struct {
+		Results []struct {
+			Book struct {
+				ID       int64  `json:"id"`
+				Language string `json:"language"`
+			} `json:"book"`
+			Rank       float64 `json:"rank"`
+			Highlights struct {
+				Title string `json:"title"`
+			} `json:"highlights"`
+		} `json:"results"`
+		Metadata struct {
+			TotalRecords int `json:"total_records"`
+		} `json:"metadata"`
+		Links map[string]string `json:"links"`
+	}
+	res.decode(t, &env)
+	if len(env.Results) != 1 || env.Results[0].Book.ID != dune.ID || env.Results[0].Book.Language != "english" || env.Results[0].Rank <= 0 {
+		t.Errorf("results = %+v; want Dune", env.Results)
+	}
+	if env.Metadata.TotalRecords != 1 || env.Links["first"] != "/v1/search?q=herbert" {
+		t.Errorf("metadata = %+v, links = %v", env.Metadata, env.Links)
+	}
+
+	res = ts.get(t, "/v1/search?q=hobbit", "")
+	res.decode(t, &env)
+	if len(env.Results) != 1 || env.Results[0].Highlights.Title != "The <mark>Hobbit</mark>" {
+		t.Errorf("results = %+v; want The Hobbit highlighted", env.Results)
+	}
+
+	for _, query := range []string{"", "?q=", "?q=dune&page=0"} {
+		t.Run(query, func(t *testing.T) {
+			checkStatus(t, ts.get(t, "/v1/search"+query, ""), http.StatusUnprocessableEntity)
+		})
+	}
+}
+
+func TestAutocomplete(t *testing.T) {
+	ts := newTestServer(t)
+	_, token := ts.userToken(t, "Alice", "alice@example.com")
+	herbert := ts.seedAuthor(t, "Frank Herbert")
+	tolkien := ts.seedAuthor(t, "Tolkien")
+	ts.createBook(t, token, "Dune", herbert, 1965)
+	ts.createBook(t, token, "The Hobbit", tolkien, 1937)
+	ts.createBook(t, token, "Heretics of Dune", herbert, 1984)
+
+	res := ts.get(t, "/v1/autocomplete?q=he&limit=2", "")
+	checkStatus(t, res, http.StatusOK)
+	var env struct {
+		Suggestions []struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		} `json:"suggestions"`
+	}
+	res.decode(t, &env)
+	if fmt.Sprint(env.Suggestions) != "[{title Heretics of Dune} {author Frank Herbert}]" {
+		t.Errorf("suggestions = %v", env.Suggestions)
+	}
+
+	res = ts.get(t, "/v1/autocomplete?q=hobit", "")
+	res.decode(t, &env)
+	if len(env.Suggestions) != 1 || env.Suggestions[0].Text != "The Hobbit" {
+		t.Errorf("suggestions for a typo = %v", env.Suggestions)
+	}
+
+	for _, query := range []string{"", "?q=+", "?q=dune&limit=0", "?q=dune&limit=26"} {
+		t.Run(query, func(t *testing.T) {
+			checkStatus(t, ts.get(t, "/v1/autocomplete"+query, ""), http.StatusUnprocessableEntity)
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"github.com/rrebeiz/quickbooks/internal/data"
+	"net/http"
+	"testing"
+)
+
+func TestSeries(t *testing.T) {
+	ts := newTestServer(t)
+	_, token := ts.userToken(t, "Alice", "alice@example.com")
+	tolkien := ts.seedAuthor(t, "J.R.R. Tolkien")
+
+	checkStatus(t, ts.do(t, http.MethodPost, "/v1/series", "", map[string]any{"name": "Middle-earth"}), http.StatusBadRequest)
+	checkStatus(t, ts.do(t, http.MethodPost, "/v1/series", token, map[string]any{"name": ""}), http.StatusUnprocessableEntity)
+	res := ts.do(t, http.MethodPost, "/v1/series", token, map[string]any{"name": "The Lord of the Rings", "description": "The War of the Ring"})
+	checkStatus(t, res, http.StatusOK)
+	var created struct {
+		Series data.Series `json:"series"`
+	}
+	res.decode(t, &created)
+	series := created.Series
+	if res.header.Get("Location") != fmt.Sprintf("/v1/series/%d", series.ID) {
+		t.Errorf("Location = %q", res.header.Get("Location"))
+	}
+
+	inSeries := func(title string, year int, position float64) data.Book {
+		res := ts.do(t, http.MethodPost, "/v1/books", token, map[string]any{
+			"title": title, "author_id": tolkien.ID, "publication_year": year, "description": title,
+			"series": []map[string]any{{"series_id": series.ID, "position": position}},
+		})
+		checkStatus(t, res, http.StatusOK)
+		var created struct {
+			Book data.Book `json:"book"`
+		}
+		res.decode(t, &created)
+		return created.Book
+	}
+	king := inSeries("The Return of the King", 1955, 3)
+	inSeries("The Fellowship of the Ring", 1954, 1)
+	inSeries("The Two Towers", 1954, 2)
+	interlude := inSeries("The Tale of Aragorn and Arwen", 1955, 2.5)
+	if len(king.Series) != 1 || king.Series[0].Name != "The Lord of the Rings" || king.Series[0].Position != 3 {
+		t.Errorf("created book series = %+v", king.Series)
+	}
+
+	res = ts.get(t, fmt.Sprintf("/v1/series/%d/books", series.ID), "")
+	checkStatus(t, res, http.StatusOK)
+	var env struct {
+		Series data.Series `json:"series"`
+		Books  []data.Book `json:"books"`
+	}
+	res.decode(t, &env)
+	var titles []string
+	for _, book := range env.Books {
+		titles = append(titles, book.Title)
+	}
+	want := "[The Fellowship of the Ring The Two Towers The Tale of Aragorn and Arwen The Return of the King]"
+	if env.Series.BookCount != 4 || fmt.Sprint(titles) != want {
+		t.Errorf("series books = %d %v; want 4 %s", env.Series.BookCount, titles, want)
+	}
+
+	res = ts.get(t, "/v1/series?name=lord", "")
+	checkStatus(t, res, http.StatusOK)
+	var list struct {
+		Series []data.Series `json:"series"`
+	}
+	res.decode(t, &list)
+	if len(list.Series) != 1 || list.Series[0].ID != series.ID {
+		t.Errorf("series list = %+v", list.Series)
+	}
+
+	path := fmt.Sprintf("/v1/series/%d", series.ID)
+	checkStatus(t, ts.do(t, http.MethodPatch, path, token, map[string]any{"name": "LOTR", "version": series.Version + 1}), http.StatusConflict)
+	res = ts.do(t, http.MethodPatch, path, token, map[string]any{"name": "LOTR", "version": series.Version})
+	checkStatus(t, res, http.StatusOK)
+	res.decode(t, &created)
+	if created.Series.Name != "LOTR" || created.Series.Version != series.Version+1 {
+		t.Errorf("updated series = %+v", created.Series)
+	}
+
+	bookPath := fmt.Sprintf("/v1/books/%d", interlude.ID)
+	checkStatus(t, ts.do(t, http.MethodPatch, bookPath, token, map[string]any{"series": []map[string]any{{"series_id": series.ID + 100, "position": 1}}}), http.StatusUnprocessableEntity)
+	checkStatus(t, ts.do(t, http.MethodPatch, bookPath, token, map[string]any{"series": []map[string]any{{"series_id": series.ID, "position": -1}}}), http.StatusUnprocessableEntity)
+	res = ts.do(t, http.MethodPatch, bookPath, token, map[string]any{"series": []any{}})
+	checkStatus(t, res, http.StatusOK)
+	res = ts.get(t, path, "")
+	checkStatus(t, res, http.StatusOK)
+	res.decode(t, &created)
+	if created.Series.BookCount != 3 {
+		t.Errorf("book count after removing a book = %d; want 3", created.Series.BookCount)
+	}
+
+	checkStatus(t, ts.do(t, http.MethodDelete, path, token, nil), http.StatusOK)
+	checkStatus(t, ts.get(t, path, ""), http.StatusNotFound)
+	checkStatus(t, ts.get(t, path+"/books", ""), http.StatusNotFound)
+}
@@ -1,19 +1,109 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"log/slog"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 )
 
+// serve runs the API server until it receives SIGINT or SIGTERM. In-flight requests are given
+// some time to finish, after which their contexts, and with them any running query, are cancelled.
 func (app *application) serve() error {
+	baseCtx, cancelBase := context.WithCancel(context.Background())
+	defer cancelBase()
+
 	srv := &http.Server{
 		Addr:         fmt.Sprintf(":%d", app.config.port),
 		Handler:      app.routes(),
 		ReadTimeout:  10 * time.Second,
 		WriteTimeout: 30 * time.Second,
 		IdleTimeout:  time.Minute,
+		ErrorLog:     slog.NewLogLogger(app.logger.Handler(), slog.LevelError),
+		BaseContext:  func(net.Listener) context.Context { return baseCtx },
+	}
+	var metricsSrv *http.Server
+	if app.config.metrics.addr != "" {
+		metricsSrv = app.metricsServer()
 	}
 
-	return srv.ListenAndServe()
+	shutdownError := make(chan error)
+	go func() {
+		quit := make(chan os.Signal, 1)
+		signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+		s := <-quit
+
+		app.logger.Info("shutting down server", "signal", s.String())
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+		defer cancel()
+		err := srv.Shutdown(ctx)
+		if metricsSrv != nil {
+			err = errors.Join(err, metricsSrv.Shutdown(ctx))
+		}
+		cancelBase()
+		// Background jobs are stopped rather than waited for, they record that they were interrupted.
+		app.stopJobs()
+		app.jobsWG.Wait()
+		shutdownError <- err
+	}()
+
+	if metricsSrv != nil {
+		go func() {
+			app.logger.Info("starting metrics server", "addr", metricsSrv.Addr)
+			err := metricsSrv.ListenAndServe()
+			if !errors.Is(err, http.ErrServerClosed) {
+				app.logger.Error("metrics server stopped", "error", err)
+			}
+		}()
+	}
+	app.background(app.purgeTrashPeriodically)
+
+	app.logger.Info("starting server", "addr", srv.Addr)
+	err := srv.ListenAndServe()
+	if !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	err = <-shutdownError
+	if err != nil {
+		return err
+	}
+	app.logger.Info("stopped server", "addr", srv.Addr)
+	return nil
+}
+
+// background runs fn in a goroutine of the server. Its context is cancelled when the server shuts
+// down, which then waits for fn to return. A panic in fn is logged instead of crashing the server.
+func (app *application) background(fn func(ctx context.Context)) {
+	app.jobsWG.Add(1)
+	go func() {
+		defer app.jobsWG.Done()
+		defer func() {
+			if err := recover(); err != nil {
+				app.logger.Error("background job panicked", "error", fmt.Sprint(err))
+			}
+		}()
+		fn(app.jobs)
+	}()
+}
+
+// metricsServer exposes /metrics on its own listener, so it can be kept off the public port. It
+// is shut down along with the API server.
+func (app *application) metricsServer() *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", app.metricsHandler())
+	return &http.Server{
+		Addr:         app.config.metrics.addr,
+		Handler:      mux,
+		ReadTimeout:  10 * time.Second,
+		WriteTimeout: 30 * time.Second,
+		IdleTimeout:  time.Minute,
+		ErrorLog:     slog.NewLogLogger(app.logger.Handler(), slog.LevelError),
+	}
 }
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/rrebeiz/quickbooks/internal/blob"
+	"github.com/rrebeiz/quickbooks/internal/data"
+	"golang.org/x/crypto/bcrypt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+const testPepper = "test-pepper"
+
+// newTestApplication returns an application serving the given models, with cheap password
+// hashing and logs thrown away. Tests that look at the logs replace app.logger.
+func newTestApplication(t *testing.T, models data.Models) *application {
+	t.Helper()
+	var cfg config
+	cfg.env = "testing"
+	cfg.db.pepper = testPepper
+	cfg.db.queryTimeout = time.Second
+	cfg.token.ttl = time.Hour
+	cfg.bcrypt.cost = bcrypt.MinCost
+	cfg.cors.trustedOrigins = []string{"https://*", "http://*"}
+	cfg.covers.url = "/covers"
+	cfg.covers.maxBytes = 1 << 20
+	cfg.imports.maxBytes = 1 << 20
+	cfg.trash.retention = 30 * 24 * time.Hour
+	cfg.trash.purgeInterval = time.Hour
+
+	jobs, stopJobs := context.WithCancel(context.Background())
+	app := &application{
+		config:   cfg,
+		logger:   slog.New(slog.NewJSONHandler(io.Discard, nil)),
+		models:   models,
+		metrics:  newMetrics(nil),
+		cursors:  data.NewCursorCodec([]byte("test-cursor-secret")),
+		covers:   blob.NewLocal(t.TempDir(), cfg.covers.url),
+		jobs:     jobs,
+		stopJobs: stopJobs,
+	}
+	t.Cleanup(func() {
+		app.stopJobs()
+		app.jobsWG.Wait()
+	})
+	return app
+}
+
+// testServer serves the application routes over a real HTTP connection.
+type testServer struct {
+	*httptest.Server
+	app *application
+}
+
+// newTestServer starts a server for app.routes() backed by fresh in-memory models.
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	return newTestServerWithModels(t, data.NewMemoryModels())
+}
+
+func newTestServerWithModels(t *testing.T, models data.Models) *testServer {
+	t.Helper()
+	app := newTestApplication(t, models)
+	ts := httptest.NewServer(app.routes())
+	t.Cleanup(ts.Close)
+	// Redirects are responses the tests look at, not followed.
+	ts.Client().CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	return &testServer{Server: ts, app: app}
+}
+
+type testResponse struct {
+	status int
+	header http.Header
+	body   []byte
+}
+
+// decode unmarshals the response body into v, failing the test if it is not JSON.
+func (r testResponse) decode(t *testing.T, v any) {
+	t.Helper()
+	err := json.Unmarshal(r.body, v)
+	if err != nil {
+		t.Fatalf("decoding %q: %v", r.body, err)
+	}
+}
+
+// errorEnvelope decodes the body of an error response.
+func (r testResponse) errorEnvelope(t *testing.T) (message any, requestID string) {
+	t.Helper()
+	var env struct {
+		Error     any    `json:"error"`
+		RequestID string `json:"request_id"`
+	}
+	r.decode(t, &env)
+	return env.Error, env.RequestID
+}
+
+// do sends a request to the server. A non-empty token is sent as a bearer token, and a
+// non-nil body is encoded as JSON unless it is already a string.
+func (ts *testServer) do(t *testing.T, method, path, token string, body any) testResponse {
+	t.Helper()
+	var reader io.Reader
+	switch b := body.(type) {
+	case nil:
+	case string:
+		reader = bytes.NewBufferString(b)
+	default:
+		js, err := json.Marshal(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader = bytes.NewReader(js)
+	}
+
+	req, err := http.NewRequest(method, ts.URL+path, reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	res, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	resBody, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return testResponse{status: res.StatusCode, header: res.Header, body: resBody}
+}
+
+func (ts *testServer) get(t *testing.T, path, token string) testResponse {
+	t.Helper()
+	return ts.do(t, http.MethodGet, path, token, nil)
+}
+
+// checkStatus fails the test when the response does not have the wanted status.
+func checkStatus(t *testing.T, res testResponse, want int) {
+	t.Helper()
+	if res.status != want {
+		t.Fatalf("status = %d; want %d, body %s", res.status, want, res.body)
+	}
+}
+
+// createUser signs up a user through the API.
+func (ts *testServer) createUser(t *testing.T, name, email, password string) data.User {
+	t.Helper()
+	res := ts.do(t, http.MethodPost, "/v1/users", "", map[string]string{"name": name, "email": email, "password": password})
+	checkStatus(t, res, http.StatusOK)
+	var env struct {
+		User data.User `json:"user"`
+	}
+	res.decode(t, &env)
+	return env.User
+}
+
+// login logs the user in and returns the bearer token.
+func (ts *testServer) login(t *testing.T, email, password string) string {
+	t.Helper()
+	res := ts.do(t, http.MethodPost, "/v1/users/login", "", map[string]string{"email": email, "password": password})
+	checkStatus(t, res, http.StatusOK)
+	var env struct {
+		User data.User `json:"user"`
+	}
+	res.decode(t, &env)
+	if env.User.Token.Token == "" {
+		t.Fatalf("login returned no token: %s", res.body)
+	}
+	return env.User.Token.Token
+}
+
+// userToken signs up a regular user and returns it with a bearer token.
+func (ts *testServer) userToken(t *testing.T, name, email string) (data.User, string) {
+	t.Helper()
+	user := ts.createUser(t, name, email, "password")
+	return user, ts.login(t, email, "password")
+}
+
+// adminToken creates an admin account directly in the models, since the API cannot,
+// and returns it with a bearer token.
+func (ts *testServer) adminToken(t *testing.T) (data.User, string) {
+	t.Helper()
+	admin := data.User{Name: "Admin", Email: "admin@example.com", AccountType: "admin"}
+	err := admin.Password.HashPassword("password", ts.app.config.db.pepper, ts.app.config.bcrypt.cost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ts.app.models.Users.Insert(context.Background(), &admin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return admin, ts.login(t, admin.Email, "password")
+}
+
+// seedAuthor adds an author, there is no route for it.
+func (ts *testServer) seedAuthor(t *testing.T, name string) *data.Author {
+	t.Helper()
+	author := &data.Author{AuthorName: name}
+	err := ts.app.models.Books.InsertAuthor(context.Background(), author)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return author
+}
+
+// createBook adds a book through the API.
+func (ts *testServer) createBook(t *testing.T, token, title string, author *data.Author, year int) data.Book {
+	t.Helper()
+	res := ts.do(t, http.MethodPost, "/v1/books", token, map[string]any{
+		"title":            title,
+		"author_id":        author.ID,
+		"publication_year": year,
+		"description":      title + " description",
+	})
+	checkStatus(t, res, http.StatusOK)
+	var env struct {
+		Book data.Book `json:"book"`
+	}
+	res.decode(t, &env)
+	return env.Book
+}
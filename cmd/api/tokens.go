@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"github.com/rrebeiz/quickbooks/internal/data"
 	"github.com/rrebeiz/quickbooks/internal/validator"
@@ -30,8 +31,9 @@ func (app *application) loginHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user, err := app.models.Users.GetByEmail(input.Email)
+	user, err := app.models.Users.GetByEmail(r.Context(), input.Email)
 	if err != nil {
+		app.metrics.loginFailed()
 		app.notfoundResponse(w, r)
 		return
 	}
@@ -43,22 +45,26 @@ func (app *application) loginHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if !checkPassword {
+		app.metrics.loginFailed()
 		app.notAuthorizedResponse(w, r)
 		return
 	}
 
-	token, err := app.models.Tokens.GenerateToken(user.ID, 24*time.Hour)
+	token, err := app.models.Tokens.GenerateToken(user.ID, app.config.token.ttl)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
 	}
 
 	token.Email = user.Email
-	err = app.models.Tokens.InsertToken(token)
+	err = app.models.Tokens.InsertToken(r.Context(), token)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
 	}
+	app.contextSetUserID(r, user.ID)
+	app.metrics.tokenIssued()
+	app.metrics.loginSucceeded()
 
 	user.Token = *token
 	err = app.writeJSON(w, http.StatusOK, envelope{"user": user}, nil)
@@ -75,7 +81,7 @@ func (app *application) authenticateToken(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	tkn, err := app.models.Tokens.GetByToken(*token)
+	tkn, err := app.models.Tokens.GetByToken(r.Context(), *token)
 	if err != nil {
 		app.notAuthorizedResponse(w, r)
 		return
@@ -86,7 +92,7 @@ func (app *application) authenticateToken(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	user, err := app.models.Tokens.GetUserForToken(tkn)
+	user, err := app.models.Tokens.GetUserForToken(r.Context(), tkn)
 	if err != nil {
 		app.notAuthorizedResponse(w, r)
 		return
@@ -103,19 +109,27 @@ func (app *application) authenticateToken(w http.ResponseWriter, r *http.Request
 func (app *application) logoutHandler(w http.ResponseWriter, r *http.Request) {
 	token, err := app.readAuthHeader(r)
 	if err != nil {
-		app.noAuthorizationHeaderResponse(w, r)
+		switch {
+		case errors.Is(err, ErrNoAuthHeader):
+			app.noAuthorizationHeaderResponse(w, r)
+		case errors.Is(err, data.ErrNoRecordFound):
+			app.notAuthorizedResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
 		return
 	}
-	tkn, err := app.models.Tokens.GetByToken(*token)
+	tkn, err := app.models.Tokens.GetByToken(r.Context(), *token)
 	if err != nil {
 		app.notAuthorizedResponse(w, r)
 		return
 	}
 	if tkn.Expiry.Before(time.Now()) {
 		app.notAuthorizedResponse(w, r)
+		return
 	}
 
-	err = app.models.Tokens.DeleteToken(tkn.ID)
+	err = app.models.Tokens.DeleteToken(r.Context(), tkn.ID)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
@@ -135,9 +149,14 @@ func (app *application) adminLogoutHandler(w http.ResponseWriter, r *http.Reques
 		app.notfoundResponse(w, r)
 		return
 	}
-	err = app.models.Tokens.DeleteToken(id)
+	err = app.models.Tokens.DeleteToken(r.Context(), id)
 	if err != nil {
-		app.serverErrorResponse(w, r, err)
+		switch {
+		case errors.Is(err, data.ErrNoRecordFound):
+			app.notfoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
 		return
 	}
 	message := fmt.Sprintf("token with id %d destroyed", id)
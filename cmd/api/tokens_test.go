@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestLogin(t *testing.T) {
+	ts := newTestServer(t)
+	user := ts.createUser(t, "Alice", "alice@example.com", "password")
+
+	tests := []struct {
+		name     string
+		body     any
+		status   int
+		hasToken bool
+	}{
+		{"valid", map[string]string{"email": "alice@example.com", "password": "password"}, http.StatusOK, true},
+		{"wrong password", map[string]string{"email": "alice@example.com", "password": "wrong"}, http.StatusUnauthorized, false},
+		{"unknown email", map[string]string{"email": "bob@example.com", "password": "password"}, http.StatusNotFound, false},
+		{"missing fields", map[string]string{}, http.StatusUnprocessableEntity, false},
+		{"unknown field", map[string]string{"email": "alice@example.com", "password": "password", "admin": "true"}, http.StatusBadRequest, false},
+		{"malformed", `{"email": `, http.StatusBadRequest, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := ts.do(t, http.MethodPost, "/v1/users/login", "", tt.body)
+			checkStatus(t, res, tt.status)
+			if !tt.hasToken {
+				message, _ := res.errorEnvelope(t)
+				if message == nil {
+					t.Errorf("no error message in %s", res.body)
+				}
+				return
+			}
+			var env struct {
+				User struct {
+					ID    int64 `json:"id"`
+					Token struct {
+						UserID int64  `json:"user_id"`
+						Token  string `json:"token"`
+					} `json:"token"`
+				} `json:"user"`
+			}
+			res.decode(t, &env)
+			if env.User.ID != user.ID || env.User.Token.UserID != user.ID || len(env.User.Token.Token) != 26 {
+				t.Errorf("login response = %s; want a token for user %d", res.body, user.ID)
+			}
+		})
+	}
+}
+
+func TestAuthenticateToken(t *testing.T) {
+	ts := newTestServer(t)
+	user, token := ts.userToken(t, "Alice", "alice@example.com")
+
+	res := ts.get(t, "/v1/users/auth", token)
+	checkStatus(t, res, http.StatusOK)
+	var env struct {
+		User struct {
+			ID    int64  `json:"id"`
+			Email string `json:"email"`
+		} `json:"user"`
+	}
+	res.decode(t, &env)
+	if env.User.ID != user.ID || env.User.Email != "alice@example.com" {
+		t.Errorf("user = %+v; want Alice", env.User)
+	}
+
+	// Logging in again replaces the previous token.
+	newToken := ts.login(t, "alice@example.com", "password")
+	checkStatus(t, ts.get(t, "/v1/users/auth", token), http.StatusUnauthorized)
+	checkStatus(t, ts.get(t, "/v1/users/auth", newToken), http.StatusOK)
+}
+
+func TestLogout(t *testing.T) {
+	ts := newTestServer(t)
+	_, token := ts.userToken(t, "Alice", "alice@example.com")
+
+	checkStatus(t, ts.get(t, "/v1/users/logout", ""), http.StatusBadRequest)
+
+	res := ts.get(t, "/v1/users/logout", token)
+	checkStatus(t, res, http.StatusOK)
+	var env struct {
+		Message string `json:"message"`
+	}
+	res.decode(t, &env)
+	if env.Message != "token destroyed" {
+		t.Errorf("message = %q", env.Message)
+	}
+
+	checkStatus(t, ts.get(t, "/v1/users/logout", token), http.StatusUnauthorized)
+	checkStatus(t, ts.get(t, "/v1/users/auth", token), http.StatusUnauthorized)
+}
+
+func TestLogoutExpiredToken(t *testing.T) {
+	ts := newTestServer(t)
+	user := ts.createUser(t, "Alice", "alice@example.com", "password")
+	token := expiredToken(t, ts, user.ID)
+
+	res := ts.get(t, "/v1/users/logout", token)
+	checkStatus(t, res, http.StatusUnauthorized)
+	message, _ := res.errorEnvelope(t)
+	if message != "you are not authorized to view this content" {
+		t.Errorf("error = %v", message)
+	}
+}
+
+// expiredToken stores a token for the user that expired an hour ago.
+func expiredToken(t *testing.T, ts *testServer, userID int64) string {
+	t.Helper()
+	ctx := context.Background()
+	user, err := ts.app.models.Users.GetByID(ctx, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := ts.app.models.Tokens.GenerateToken(userID, -time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token.Email = user.Email
+	err = ts.app.models.Tokens.InsertToken(ctx, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token.Token
+}
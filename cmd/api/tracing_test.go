@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"github.com/rrebeiz/quickbooks/internal/data"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"net/http"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testTracing installs a tracer provider exporting to memory, once for the whole test binary: the
+// tracer of the data package keeps the first provider installed.
+var testTracing = sync.OnceValues(func() (*sdktrace.TracerProvider, *tracetest.InMemoryExporter) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := newTracerProvider(exporter, 1)
+	otel.SetTracerProvider(tp)
+	return tp, exporter
+})
+
+// newSQLiteModels returns the models of a migrated SQLite database in the test's temporary
+// directory, and the database.
+func newSQLiteModels(t *testing.T) (data.Models, *sql.DB) {
+	t.Helper()
+	dialect, dsn, err := data.ParseDSN("sqlite:" + filepath.Join(t.TempDir(), "books.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open(dialect.DriverName(), dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	err = data.Migrate(context.Background(), db, dialect)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data.NewModels(db, dialect, time.Second), db
+}
+
+// exportedSpans returns the spans ended since the last call.
+func exportedSpans(t *testing.T) tracetest.SpanStubs {
+	t.Helper()
+	tp, exporter := testTracing()
+	err := tp.ForceFlush(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	spans := exporter.GetSpans()
+	exporter.Reset()
+	return spans
+}
+
+func spanAttribute(span tracetest.SpanStub, key attribute.Key) attribute.Value {
+	for _, kv := range span.Attributes {
+		if kv.Key == key {
+			return kv.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+func TestTraceRequests(t *testing.T) {
+	testTracing()
+	models, db := newSQLiteModels(t)
+	ts := newTestServerWithModels(t, models)
+	exportedSpans(t)
+
+	checkStatus(t, ts.get(t, "/v1/books/42", ""), http.StatusNotFound)
+	spans := exportedSpans(t)
+	var server tracetest.SpanStub
+	var queries []tracetest.SpanStub
+	for _, span := range spans {
+		switch span.Name {
+		case "GET /v1/books/{id}":
+			server = span
+		case "BookModel.GetByID":
+			queries = append(queries, span)
+		}
+	}
+	if !server.SpanContext.IsValid() {
+		t.Fatalf("spans = %v; want one named after the route", spanNames(spans))
+	}
+	if got := spanAttribute(server, semconv.HTTPResponseStatusCodeKey).AsInt64(); got != http.StatusNotFound {
+		t.Errorf("status code attribute = %d; want 404", got)
+	}
+	if got := spanAttribute(server, semconv.HTTPRouteKey).AsString(); got != "/v1/books/{id}" {
+		t.Errorf("route attribute = %q", got)
+	}
+	if len(queries) == 0 {
+		t.Fatalf("spans = %v; want the query of BookModel.GetByID", spanNames(spans))
+	}
+	for _, query := range queries {
+		if query.SpanContext.TraceID() != server.SpanContext.TraceID() || query.Parent.SpanID() != server.SpanContext.SpanID() {
+			t.Errorf("query span %s is not a child of the request span", query.Name)
+		}
+		if got := spanAttribute(query, semconv.DBSystemKey).AsString(); got != "sqlite" {
+			t.Errorf("db.system = %q; want sqlite", got)
+		}
+		// Not finding the book is not a failure of the query.
+		if query.Status.Code == codes.Error {
+			t.Errorf("query span status = %v; want unset", query.Status)
+		}
+	}
+
+	// A failing query marks its span and the request's as failed.
+	db.Close()
+	checkStatus(t, ts.get(t, "/v1/books", ""), http.StatusInternalServerError)
+	spans = exportedSpans(t)
+	failed := map[string]bool{}
+	for _, span := range spans {
+		if span.Status.Code == codes.Error {
+			failed[span.Name] = true
+		}
+	}
+	if !failed["GET /v1/books"] || !failed["BookModel.GetAll"] {
+		t.Errorf("failed spans = %v; want the request and BookModel.GetAll", failed)
+	}
+	for _, span := range spans {
+		if span.Name == "BookModel.GetAll" && len(span.Events) == 0 {
+			t.Errorf("BookModel.GetAll recorded no error event")
+		}
+	}
+}
+
+func spanNames(spans tracetest.SpanStubs) []string {
+	var names []string
+	for _, span := range spans {
+		names = append(names, span.Name)
+	}
+	return names
+}
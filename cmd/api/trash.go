@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/rrebeiz/quickbooks/internal/data"
+	"github.com/rrebeiz/quickbooks/internal/validator"
+	"net/http"
+	"time"
+)
+
+// getTrashHandler lists the deleted books, reviews and users that can still be restored, the most
+// recently deleted first, with the time each is purged.
+func (app *application) getTrashHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Type string
+		data.Filters
+	}
+	v := validator.NewValidator()
+
+	qs := r.URL.Query()
+	input.Type = app.readString(qs, "type", "")
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Sort = "-deleted_at"
+	input.Filters.SortSafeList = []string{"-deleted_at"}
+
+	v.Check(input.Type == "" || validator.PermittedValue(input.Type, data.TrashTypes...), "type", "must be book, review or user")
+	data.ValidateFilters(v, input.Filters)
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	items, metadata, err := app.models.Trash.GetAll(r.Context(), input.Type, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	for _, item := range items {
+		item.PurgeAt = item.DeletedAt.Add(app.config.trash.retention)
+	}
+	links, headers := app.paginationLinks(r, metadata)
+	err = app.writeJSON(w, http.StatusOK, envelope{"trash": items, "metadata": metadata, "links": links}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+// restoreHandler returns a handler taking the record of the id parameter out of the trash with
+// restore. A record that is not in the trash is not found.
+func (app *application) restoreHandler(kind string, restore func(ctx context.Context, id int64) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := app.readParamID(r)
+		if err != nil || id < 1 {
+			app.notfoundResponse(w, r)
+			return
+		}
+		err = restore(r.Context(), id)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrNoRecordFound):
+				app.notfoundResponse(w, r)
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+		message := fmt.Sprintf("%s with ID %d restored.", kind, id)
+		err = app.writeJSON(w, http.StatusOK, envelope{"message": message}, nil)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+}
+
+func (app *application) restoreBookHandler(w http.ResponseWriter, r *http.Request) {
+	app.restoreHandler(data.TrashBook, app.models.Books.Restore)(w, r)
+}
+
+func (app *application) restoreReviewHandler(w http.ResponseWriter, r *http.Request) {
+	app.restoreHandler(data.TrashReview, app.models.Books.RestoreReview)(w, r)
+}
+
+func (app *application) restoreUserHandler(w http.ResponseWriter, r *http.Request) {
+	app.restoreHandler(data.TrashUser, app.models.Users.Restore)(w, r)
+}
+
+// purgeTrashPeriodically purges the trash of what is older than trash-retention, every
+// trash-purge-interval until ctx is done, starting right away.
+func (app *application) purgeTrashPeriodically(ctx context.Context) {
+	ticker := time.NewTicker(app.config.trash.purgeInterval)
+	defer ticker.Stop()
+	for {
+		err := app.purgeTrash(ctx, time.Now().Add(-app.config.trash.retention))
+		if err != nil && ctx.Err() == nil {
+			app.logger.Error("failed to purge the trash", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// purgeTrash deletes for good what was put in the trash before the time, and the cover images of
+// the books it deletes. Failing to remove the images is only logged.
+func (app *application) purgeTrash(ctx context.Context, before time.Time) error {
+	purged, err := app.models.Trash.Purge(ctx, before)
+	if err != nil {
+		return err
+	}
+	if purged.Books+purged.Reviews+purged.Users == 0 {
+		return nil
+	}
+	app.logger.Info("purged the trash", "books", purged.Books, "reviews", purged.Reviews, "users", purged.Users)
+	var keys []string
+	for _, cover := range purged.Covers {
+		keys = append(keys, cover.Key)
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	err = app.covers.Delete(context.WithoutCancel(ctx), keys...)
+	if err != nil {
+		app.logger.Warn("failed to remove the cover images of the purged books", "keys", keys, "error", err)
+	}
+	return nil
+}
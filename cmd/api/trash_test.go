@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/rrebeiz/quickbooks/internal/data"
+	"net/http"
+	"testing"
+	"time"
+)
+
+type trashEnvelope struct {
+	Trash    []data.TrashItem `json:"trash"`
+	Metadata data.Metadata    `json:"metadata"`
+}
+
+func (ts *testServer) trash(t *testing.T, token, query string) trashEnvelope {
+	t.Helper()
+	res := ts.get(t, "/v1/trash"+query, token)
+	checkStatus(t, res, http.StatusOK)
+	var env trashEnvelope
+	res.decode(t, &env)
+	return env
+}
+
+func TestTrash(t *testing.T) {
+	ts := newTestServer(t)
+	alice, token := ts.userToken(t, "Alice", "alice@example.com")
+	_, adminToken := ts.adminToken(t)
+	book := ts.createBook(t, token, "Dune", ts.seedAuthor(t, "Frank Herbert"), 1965)
+
+	res := ts.do(t, http.MethodPost, "/v1/books/reviews", token, map[string]any{"rating": 5, "review": "Great", "book_id": book.ID, "user_id": alice.ID})
+	checkStatus(t, res, http.StatusOK)
+	var created struct {
+		Review struct {
+			ID int64 `json:"id"`
+		} `json:"review"`
+	}
+	res.decode(t, &created)
+	reviewPath := fmt.Sprintf("/v1/books/reviews/%d", created.Review.ID)
+
+	// Deleting the book puts it in the trash along with its review.
+	bookPath := fmt.Sprintf("/v1/books/%d", book.ID)
+	checkStatus(t, ts.do(t, http.MethodDelete, bookPath, token, nil), http.StatusOK)
+	checkStatus(t, ts.get(t, bookPath, ""), http.StatusNotFound)
+	checkStatus(t, ts.get(t, reviewPath, ""), http.StatusNotFound)
+	res = ts.do(t, http.MethodPost, "/v1/books/reviews", token, map[string]any{"rating": 4, "review": "Good", "book_id": book.ID, "user_id": alice.ID})
+	checkStatus(t, res, http.StatusUnprocessableEntity)
+
+	env := ts.trash(t, adminToken, "")
+	if len(env.Trash) != 2 || env.Metadata.TotalRecords != 2 {
+		t.Fatalf("trash = %+v; want the book and its review", env)
+	}
+	for _, item := range env.Trash {
+		if item.Name != "Dune" || !item.PurgeAt.Equal(item.DeletedAt.Add(ts.app.config.trash.retention)) {
+			t.Errorf("trash item = %+v", item)
+		}
+	}
+	env = ts.trash(t, adminToken, "?type=book")
+	if len(env.Trash) != 1 || env.Trash[0].Type != data.TrashBook || env.Trash[0].ID != book.ID {
+		t.Errorf("trashed books = %+v", env.Trash)
+	}
+	checkStatus(t, ts.get(t, "/v1/trash?type=work", adminToken), http.StatusUnprocessableEntity)
+	checkStatus(t, ts.get(t, "/v1/trash?page=0", adminToken), http.StatusUnprocessableEntity)
+
+	// The review comes back with its book, and can't be restored on its own while the book is
+	// in the trash.
+	checkStatus(t, ts.do(t, http.MethodPost, reviewPath+"/restore", adminToken, nil), http.StatusNotFound)
+	checkStatus(t, ts.do(t, http.MethodPost, bookPath+"/restore", adminToken, nil), http.StatusOK)
+	checkStatus(t, ts.do(t, http.MethodPost, bookPath+"/restore", adminToken, nil), http.StatusNotFound)
+	checkStatus(t, ts.get(t, bookPath, ""), http.StatusOK)
+	checkStatus(t, ts.get(t, reviewPath, ""), http.StatusOK)
+
+	checkStatus(t, ts.do(t, http.MethodDelete, reviewPath, token, nil), http.StatusOK)
+	checkStatus(t, ts.do(t, http.MethodPost, reviewPath+"/restore", adminToken, nil), http.StatusOK)
+	checkStatus(t, ts.get(t, reviewPath, ""), http.StatusOK)
+
+	// Deleting a user logs them out and takes their reviews, restoring them brings both back.
+	userPath := fmt.Sprintf("/v1/users/%d", alice.ID)
+	checkStatus(t, ts.do(t, http.MethodDelete, userPath, adminToken, nil), http.StatusOK)
+	checkStatus(t, ts.get(t, "/v1/users/auth", token), http.StatusUnauthorized)
+	checkStatus(t, ts.get(t, reviewPath, ""), http.StatusNotFound)
+	res = ts.do(t, http.MethodPost, "/v1/users", "", map[string]any{"name": "Alice", "email": "alice@example.com", "password": "password"})
+	checkStatus(t, res, http.StatusBadRequest)
+	checkStatus(t, ts.do(t, http.MethodPost, userPath+"/restore", adminToken, nil), http.StatusOK)
+	checkStatus(t, ts.get(t, userPath, adminToken), http.StatusOK)
+	checkStatus(t, ts.get(t, reviewPath, ""), http.StatusOK)
+	if env := ts.trash(t, adminToken, ""); len(env.Trash) != 0 {
+		t.Errorf("trash after the restores = %+v; want it empty", env.Trash)
+	}
+
+	// Purging deletes for good what was put in the trash before the time.
+	checkStatus(t, ts.do(t, http.MethodDelete, bookPath, adminToken, nil), http.StatusOK)
+	err := ts.app.purgeTrash(context.Background(), time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if env := ts.trash(t, adminToken, ""); len(env.Trash) != 2 {
+		t.Errorf("trash after an early purge = %+v; want the book and its review", env.Trash)
+	}
+	err = ts.app.purgeTrash(context.Background(), time.Now().Add(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if env := ts.trash(t, adminToken, ""); len(env.Trash) != 0 {
+		t.Errorf("trash after the purge = %+v; want it empty", env.Trash)
+	}
+	checkStatus(t, ts.do(t, http.MethodPost, bookPath+"/restore", adminToken, nil), http.StatusNotFound)
+}
@@ -23,7 +23,7 @@ func (app *application) createUserHandler(w http.ResponseWriter, r *http.Request
 	var user data.User
 	user.Name = input.Name
 	user.Email = input.Email
-	err = user.Password.HashPassword(input.Password, app.config.db.pepper)
+	err = user.Password.HashPassword(input.Password, app.config.db.pepper, app.config.bcrypt.cost)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
@@ -36,7 +36,7 @@ func (app *application) createUserHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	err = app.models.Users.Insert(&user)
+	err = app.models.Users.Insert(r.Context(), &user)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrDuplicateEmail):
@@ -74,7 +74,7 @@ func (app *application) updateUserHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	user, err := app.models.Users.GetByID(id)
+	user, err := app.models.Users.GetByID(r.Context(), id)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrNoRecordFound):
@@ -97,7 +97,7 @@ func (app *application) updateUserHandler(w http.ResponseWriter, r *http.Request
 	if input.Password != nil {
 		data.ValidatePassword(v, *input.Password)
 		user.Password.Plaintext = input.Password
-		err := user.Password.HashPassword(*input.Password, app.config.db.pepper)
+		err := user.Password.HashPassword(*input.Password, app.config.db.pepper, app.config.bcrypt.cost)
 		if err != nil {
 			app.serverErrorResponse(w, r, err)
 			return
@@ -109,7 +109,7 @@ func (app *application) updateUserHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	err = app.models.Users.Update(user)
+	err = app.models.Users.Update(r.Context(), user)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrDuplicateEmail):
@@ -134,7 +134,7 @@ func (app *application) deleteUserHandler(w http.ResponseWriter, r *http.Request
 		app.notfoundResponse(w, r)
 		return
 	}
-	err = app.models.Users.Delete(id)
+	err = app.models.Users.Delete(r.Context(), id)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrNoRecordFound):
@@ -158,7 +158,7 @@ func (app *application) getUserHandler(w http.ResponseWriter, r *http.Request) {
 		app.notfoundResponse(w, r)
 		return
 	}
-	user, err := app.models.Users.GetByID(id)
+	user, err := app.models.Users.GetByID(r.Context(), id)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrNoRecordFound):
@@ -176,7 +176,7 @@ func (app *application) getUserHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func (app *application) getAllUsersHandler(w http.ResponseWriter, r *http.Request) {
-	users, err := app.models.Users.GetAll()
+	users, err := app.models.Users.GetAll(r.Context())
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
@@ -189,7 +189,7 @@ func (app *application) getAllUsersHandler(w http.ResponseWriter, r *http.Reques
 }
 
 func (app *application) getAllAuthenticatedUsersHandler(w http.ResponseWriter, r *http.Request) {
-	users, err := app.models.Users.GetAllLoggedIn()
+	users, err := app.models.Users.GetAllLoggedIn(r.Context())
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestCreateUser(t *testing.T) {
+	ts := newTestServer(t)
+
+	user := ts.createUser(t, "Alice", "alice@example.com", "password")
+	if user.ID == 0 || user.Name != "Alice" || user.Email != "alice@example.com" || user.AccountType != "user" {
+		t.Errorf("created user = %+v", user)
+	}
+
+	tests := []struct {
+		name    string
+		body    any
+		status  int
+		message any
+	}{
+		{"duplicate email", map[string]string{"name": "Other", "email": "alice@example.com", "password": "password"}, http.StatusBadRequest, "email address already taken"},
+		{"missing fields", map[string]string{"password": "password"}, http.StatusUnprocessableEntity, map[string]any{"email": "should not be empty", "name": "should not be empty"}},
+		{"two values", `{"name": "Bob"} {"name": "Bob"}`, http.StatusBadRequest, "body must have only a single json value"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := ts.do(t, http.MethodPost, "/v1/users", "", tt.body)
+			checkStatus(t, res, tt.status)
+			message, requestID := res.errorEnvelope(t)
+			if fmt.Sprint(message) != fmt.Sprint(tt.message) {
+				t.Errorf("error = %v; want %v", message, tt.message)
+			}
+			if requestID == "" || requestID != res.header.Get(requestIDHeader) {
+				t.Errorf("request_id = %q; want the %s header %q", requestID, requestIDHeader, res.header.Get(requestIDHeader))
+			}
+		})
+	}
+}
+
+func TestGetUser(t *testing.T) {
+	ts := newTestServer(t)
+	user, token := ts.userToken(t, "Alice", "alice@example.com")
+
+	res := ts.get(t, fmt.Sprintf("/v1/users/%d", user.ID), token)
+	checkStatus(t, res, http.StatusOK)
+	var env struct {
+		User struct {
+			ID   int64  `json:"id"`
+			Name string `json:"name"`
+		} `json:"user"`
+	}
+	res.decode(t, &env)
+	if env.User.ID != user.ID || env.User.Name != "Alice" {
+		t.Errorf("user = %+v", env.User)
+	}
+
+	checkStatus(t, ts.get(t, fmt.Sprintf("/v1/users/%d", user.ID+100), token), http.StatusNotFound)
+	checkStatus(t, ts.get(t, "/v1/users/abc", token), http.StatusNotFound)
+	checkStatus(t, ts.get(t, fmt.Sprintf("/v1/users/%d", user.ID), ""), http.StatusBadRequest)
+}
+
+func TestUpdateUser(t *testing.T) {
+	ts := newTestServer(t)
+	alice, token := ts.userToken(t, "Alice", "alice@example.com")
+	ts.createUser(t, "Bob", "bob@example.com", "password")
+	path := fmt.Sprintf("/v1/users/%d", alice.ID)
+
+	res := ts.do(t, http.MethodPatch, path, token, map[string]string{"name": "Alice Smith", "password": "new-password"})
+	checkStatus(t, res, http.StatusOK)
+	var env struct {
+		User struct {
+			Name  string `json:"name"`
+			Email string `json:"email"`
+		} `json:"user"`
+	}
+	res.decode(t, &env)
+	if env.User.Name != "Alice Smith" || env.User.Email != "alice@example.com" {
+		t.Errorf("updated user = %+v", env.User)
+	}
+	token = ts.login(t, "alice@example.com", "new-password")
+
+	res = ts.do(t, http.MethodPatch, path, token, map[string]string{"email": "bob@example.com"})
+	checkStatus(t, res, http.StatusBadRequest)
+	checkStatus(t, ts.do(t, http.MethodPatch, path, token, map[string]string{"name": ""}), http.StatusUnprocessableEntity)
+	checkStatus(t, ts.do(t, http.MethodPatch, fmt.Sprintf("/v1/users/%d", alice.ID+100), token, map[string]string{"name": "X"}), http.StatusNotFound)
+}
+
+func TestAdminRoutes(t *testing.T) {
+	ts := newTestServer(t)
+	alice, userToken := ts.userToken(t, "Alice", "alice@example.com")
+	_, adminToken := ts.adminToken(t)
+
+	adminRoutes := []struct {
+		method string
+		path   string
+	}{
+		{http.MethodGet, "/v1/users"},
+		{http.MethodGet, "/v1/users/authenticated"},
+		{http.MethodDelete, fmt.Sprintf("/v1/users/%d", alice.ID)},
+		{http.MethodDelete, "/v1/users/logout/1"},
+		{http.MethodPost, fmt.Sprintf("/v1/users/%d/restore", alice.ID)},
+		{http.MethodPost, "/v1/books/1/restore"},
+		{http.MethodPost, "/v1/books/reviews/1/restore"},
+		{http.MethodGet, "/v1/trash"},
+	}
+	for _, route := range adminRoutes {
+		t.Run(route.method+" "+route.path, func(t *testing.T) {
+			checkStatus(t, ts.do(t, route.method, route.path, "", nil), http.StatusBadRequest)
+			checkStatus(t, ts.do(t, route.method, route.path, userToken, nil), http.StatusUnauthorized)
+		})
+	}
+
+	res := ts.get(t, "/v1/users", adminToken)
+	checkStatus(t, res, http.StatusOK)
+	var env struct {
+		Users []struct {
+			ID          int64  `json:"id"`
+			Name        string `json:"name"`
+			AccountType string `json:"account_type"`
+		} `json:"users"`
+	}
+	res.decode(t, &env)
+	if len(env.Users) != 2 || env.Users[0].Name != "Admin" || env.Users[0].AccountType != "admin" || env.Users[1].ID != alice.ID {
+		t.Errorf("users = %+v; want Admin then Alice", env.Users)
+	}
+
+	res = ts.get(t, "/v1/users/authenticated", adminToken)
+	checkStatus(t, res, http.StatusOK)
+	var loggedIn struct {
+		Users []struct {
+			ID    int64 `json:"id"`
+			Token struct {
+				ID int64 `json:"id"`
+			} `json:"token"`
+		} `json:"users"`
+	}
+	res.decode(t, &loggedIn)
+	if len(loggedIn.Users) != 2 {
+		t.Fatalf("authenticated users = %+v; want both", loggedIn.Users)
+	}
+	var aliceTokenID int64
+	for _, u := range loggedIn.Users {
+		if u.ID == alice.ID {
+			aliceTokenID = u.Token.ID
+		}
+	}
+
+	logout := fmt.Sprintf("/v1/users/logout/%d", aliceTokenID)
+	checkStatus(t, ts.do(t, http.MethodDelete, logout, adminToken, nil), http.StatusOK)
+	checkStatus(t, ts.get(t, "/v1/users/auth", userToken), http.StatusUnauthorized)
+	checkStatus(t, ts.do(t, http.MethodDelete, logout, adminToken, nil), http.StatusNotFound)
+
+	path := fmt.Sprintf("/v1/users/%d", alice.ID)
+	checkStatus(t, ts.do(t, http.MethodDelete, path, adminToken, nil), http.StatusOK)
+	checkStatus(t, ts.do(t, http.MethodDelete, path, adminToken, nil), http.StatusNotFound)
+	checkStatus(t, ts.get(t, path, adminToken), http.StatusNotFound)
+}
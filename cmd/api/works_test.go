@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"github.com/rrebeiz/quickbooks/internal/data"
+	"net/http"
+	"testing"
+)
+
+type editionsEnvelope struct {
+	Work     data.Work   `json:"work"`
+	Editions []data.Book `json:"editions"`
+}
+
+func (e editionsEnvelope) ids() []int64 {
+	var ids []int64
+	for _, edition := range e.Editions {
+		ids = append(ids, edition.ID)
+	}
+	return ids
+}
+
+func TestWorks(t *testing.T) {
+	ts := newTestServer(t)
+	_, token := ts.userToken(t, "Alice", "alice@example.com")
+	_, adminToken := ts.adminToken(t)
+	herbert := ts.seedAuthor(t, "Frank Herbert")
+
+	hardcover := ts.createBook(t, token, "Dune", herbert, 1965)
+	res := ts.do(t, http.MethodPost, "/v1/books", token, map[string]any{
+		"title": "Dune", "author_id": herbert.ID, "publication_year": 2005, "description": "Dune",
+		"work_id": hardcover.WorkID, "format": "paperback", "page_count": 896,
+	})
+	checkStatus(t, res, http.StatusOK)
+	var created struct {
+		Book data.Book `json:"book"`
+	}
+	res.decode(t, &created)
+	paperback := created.Book
+	if paperback.WorkID != hardcover.WorkID || paperback.Format != "paperback" || paperback.PageCount != 896 {
+		t.Errorf("created edition = %+v", paperback)
+	}
+	duplicate := ts.createBook(t, token, "Dune (copy)", herbert, 1966)
+
+	res = ts.get(t, fmt.Sprintf("/v1/works/%d/editions", hardcover.WorkID), "")
+	checkStatus(t, res, http.StatusOK)
+	var env editionsEnvelope
+	res.decode(t, &env)
+	if env.Work.Title != "Dune" || env.Work.Editions != 2 || fmt.Sprint(env.ids()) != fmt.Sprint([]int64{hardcover.ID, paperback.ID}) {
+		t.Errorf("editions = %+v", env)
+	}
+
+	merge := fmt.Sprintf("/v1/works/%d/merge", hardcover.WorkID)
+	body := map[string]any{"book_ids": []int64{duplicate.ID}}
+	checkStatus(t, ts.do(t, http.MethodPost, merge, "", body), http.StatusBadRequest)
+	checkStatus(t, ts.do(t, http.MethodPost, merge, token, body), http.StatusUnauthorized)
+	res = ts.do(t, http.MethodPost, merge, adminToken, body)
+	checkStatus(t, res, http.StatusOK)
+	res.decode(t, &env)
+	if env.Work.Editions != 3 || fmt.Sprint(env.ids()) != fmt.Sprint([]int64{hardcover.ID, duplicate.ID, paperback.ID}) {
+		t.Errorf("editions after the merge = %+v", env)
+	}
+	checkStatus(t, ts.get(t, fmt.Sprintf("/v1/works/%d", duplicate.WorkID), ""), http.StatusNotFound)
+
+	checkStatus(t, ts.do(t, http.MethodPost, merge, adminToken, map[string]any{"book_ids": []int64{}}), http.StatusUnprocessableEntity)
+	checkStatus(t, ts.do(t, http.MethodPost, merge, adminToken, map[string]any{"book_ids": []int64{duplicate.ID + 100}}), http.StatusNotFound)
+
+	invalid := []map[string]any{
+		{"work_id": hardcover.WorkID + 100},
+		{"format": "scroll"},
+		{"page_count": -1},
+	}
+	for _, fields := range invalid {
+		body := map[string]any{"title": "Dune", "author_id": herbert.ID, "publication_year": 2005, "description": "Dune"}
+		for key, value := range fields {
+			body[key] = value
+		}
+		checkStatus(t, ts.do(t, http.MethodPost, "/v1/books", token, body), http.StatusUnprocessableEntity)
+	}
+}
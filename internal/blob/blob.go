@@ -0,0 +1,30 @@
+// Package blob stores the files served by the API, such as cover images, outside of the database.
+package blob
+
+import (
+	"context"
+	"errors"
+	"path"
+	"strings"
+)
+
+// Store keeps blobs by key. Keys are slash separated paths like covers/1/abc-small.jpg.
+// Implementations must be safe for concurrent use.
+type Store interface {
+	// Put stores the blob under key, replacing any blob already there.
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	// Delete removes the blobs of the keys, missing keys are ignored.
+	Delete(ctx context.Context, keys ...string) error
+	// URL returns where clients can fetch the blob of key.
+	URL(key string) string
+}
+
+// ErrInvalidKey is returned for keys that are not clean relative paths.
+var ErrInvalidKey = errors.New("blob: invalid key")
+
+func checkKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || path.Clean(key) != key || key == ".." || strings.HasPrefix(key, "../") {
+		return ErrInvalidKey
+	}
+	return nil
+}
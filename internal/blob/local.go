@@ -0,0 +1,102 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Local is a Store keeping blobs as files under a directory. It serves them itself over HTTP, it is
+// meant to be mounted at the path of its base URL.
+type Local struct {
+	dir     string
+	baseURL string
+}
+
+// NewLocal returns a store keeping blobs under dir, whose URLs start with baseURL.
+func NewLocal(dir, baseURL string) *Local {
+	return &Local{dir: dir, baseURL: strings.TrimSuffix(baseURL, "/")}
+}
+
+func (l *Local) Put(ctx context.Context, key string, data []byte, contentType string) error {
+	err := checkKey(key)
+	if err != nil {
+		return err
+	}
+	name := filepath.Join(l.dir, filepath.FromSlash(key))
+	err = os.MkdirAll(filepath.Dir(name), 0o755)
+	if err != nil {
+		return err
+	}
+	// The blob is written next to its final name and renamed, readers never see half of it.
+	tmp, err := os.CreateTemp(filepath.Dir(name), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(data)
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+	err = tmp.Close()
+	if err != nil {
+		return err
+	}
+	err = os.Chmod(tmp.Name(), 0o644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), name)
+}
+
+func (l *Local) Delete(ctx context.Context, keys ...string) error {
+	for _, key := range keys {
+		err := checkKey(key)
+		if err != nil {
+			return err
+		}
+		err = os.Remove(filepath.Join(l.dir, filepath.FromSlash(key)))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (l *Local) URL(key string) string {
+	return l.baseURL + "/" + key
+}
+
+// ServeHTTP serves the blob of the request path, relative to the path of the base URL. Directories
+// are not listed.
+func (l *Local) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	prefix := "/"
+	base, err := url.Parse(l.baseURL)
+	if err == nil {
+		prefix = strings.TrimSuffix(base.Path, "/") + "/"
+	}
+	key, ok := strings.CutPrefix(r.URL.Path, prefix)
+	if !ok || checkKey(key) != nil || strings.HasPrefix(path.Base(key), ".") {
+		http.NotFound(w, r)
+		return
+	}
+	file, err := os.Open(filepath.Join(l.dir, filepath.FromSlash(key)))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil || info.IsDir() {
+		http.NotFound(w, r)
+		return
+	}
+	http.ServeContent(w, r, info.Name(), info.ModTime(), file)
+}
@@ -0,0 +1,312 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"github.com/rrebeiz/quickbooks/internal/data"
+	"golang.org/x/sync/singleflight"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// Books is a read-through cache in front of the GetByID and GetBySlug lookups of a data.Books,
+// every other method goes straight to the wrapped models.
+//
+// Entries are dropped when the book is updated, deleted, restored, given a new cover or merged into
+// another work through Books, and when a review of its work is created, updated, deleted or
+// restored, through Books or along with its user through Users, so that an instance never serves
+// its own stale writes. Changes made elsewhere (another instance with an in-process store, a user
+// renaming themselves, a series or publisher being renamed) are picked up when the entry expires.
+//
+// Concurrent misses for the same key share a single load, so an expired popular book does not
+// send every request to the database at once.
+type Books struct {
+	data.Books
+	store   Store
+	ttl     time.Duration
+	metrics Metrics
+	group   singleflight.Group
+	// generation changes on every invalidation, a load that saw it change does not store its
+	// result, which may have been read before the write.
+	generation atomic.Uint64
+}
+
+// NewBooks wraps next with a cache keeping entries in store for ttl. metrics may be nil.
+func NewBooks(next data.Books, store Store, ttl time.Duration, metrics Metrics) *Books {
+	if metrics == nil {
+		metrics = nopMetrics{}
+	}
+	return &Books{Books: next, store: store, ttl: ttl, metrics: metrics}
+}
+
+func bookIDKey(id int64) string {
+	return "books:id:" + strconv.FormatInt(id, 10)
+}
+
+func bookSlugKey(slug string) string {
+	return "books:slug:" + slug
+}
+
+func (b *Books) GetByID(ctx context.Context, id int64) (*data.Book, error) {
+	return b.get(ctx, "book_by_id", bookIDKey(id), func(ctx context.Context) (*data.Book, error) {
+		return b.Books.GetByID(ctx, id)
+	})
+}
+
+func (b *Books) GetBySlug(ctx context.Context, slug string) (*data.Book, error) {
+	return b.get(ctx, "book_by_slug", bookSlugKey(slug), func(ctx context.Context) (*data.Book, error) {
+		return b.Books.GetBySlug(ctx, slug)
+	})
+}
+
+// Insert drops the entry of the new book's slug, in case it belongs to a book that has been
+// renamed since.
+func (b *Books) Insert(ctx context.Context, book *data.Book) error {
+	err := b.Books.Insert(ctx, book)
+	if err != nil {
+		return err
+	}
+	b.invalidate(ctx, bookSlugKey(book.Slug))
+	return nil
+}
+
+// Update drops the entries of the book, under its ID, under the slug it had when it was read, its
+// new one and every old slug still leading to it.
+func (b *Books) Update(ctx context.Context, book *data.Book) error {
+	oldSlug := book.Slug
+	err := b.Books.Update(ctx, book)
+	if err != nil {
+		return err
+	}
+	keys := append(b.slugKeys(ctx, book.ID, book.Slug), bookIDKey(book.ID), bookSlugKey(oldSlug))
+	b.invalidate(ctx, keys...)
+	return nil
+}
+
+func (b *Books) Delete(ctx context.Context, id int64) error {
+	// The reviews of the book go to the trash with it, the other editions lose them.
+	keys := b.reviewKeys(ctx, id)
+	book, err := b.Books.GetByID(ctx, id)
+	if err == nil {
+		keys = append(keys, b.slugKeys(ctx, id, book.Slug)...)
+	}
+	err = b.Books.Delete(ctx, id)
+	if err != nil {
+		return err
+	}
+	b.invalidate(ctx, keys...)
+	return nil
+}
+
+// Restore drops the entries of the editions of the book's work, which get its reviews back.
+func (b *Books) Restore(ctx context.Context, id int64) error {
+	err := b.Books.Restore(ctx, id)
+	if err != nil {
+		return err
+	}
+	b.invalidate(ctx, b.reviewKeys(ctx, id)...)
+	return nil
+}
+
+// SetCovers drops the entries of the book, under its ID and its slugs.
+func (b *Books) SetCovers(ctx context.Context, bookID int64, covers []data.Cover) ([]data.Cover, error) {
+	keys := []string{bookIDKey(bookID)}
+	book, err := b.Books.GetByID(ctx, bookID)
+	if err == nil {
+		keys = append(keys, b.slugKeys(ctx, bookID, book.Slug)...)
+	}
+	previous, err := b.Books.SetCovers(ctx, bookID, covers)
+	if err != nil {
+		return nil, err
+	}
+	b.invalidate(ctx, keys...)
+	return previous, nil
+}
+
+// MergeWorks drops the entries of every edition of the works merged, their work and reviews change.
+func (b *Books) MergeWorks(ctx context.Context, workID int64, bookIDs []int64) error {
+	keys := b.editionKeys(ctx, workID, true)
+	for _, id := range bookIDs {
+		book, err := b.Books.GetByID(ctx, id)
+		if err == nil {
+			keys = append(keys, b.editionKeys(ctx, book.WorkID, true)...)
+		}
+	}
+	err := b.Books.MergeWorks(ctx, workID, bookIDs)
+	if err != nil {
+		return err
+	}
+	b.invalidate(ctx, keys...)
+	return nil
+}
+
+// The book looked up by slug comes without its reviews, only the entries by ID need dropping when
+// they change. Reviews are shared by the editions of a work, the entries of all of them go.
+
+func (b *Books) InsertReview(ctx context.Context, review *data.Review) error {
+	err := b.Books.InsertReview(ctx, review)
+	if err != nil {
+		return err
+	}
+	b.invalidate(ctx, b.reviewKeys(ctx, review.BookID)...)
+	return nil
+}
+
+func (b *Books) UpdateReview(ctx context.Context, review *data.Review) error {
+	bookID := review.BookID
+	if bookID == 0 {
+		stored, err := b.Books.GetReviewByID(ctx, review.ID)
+		if err == nil {
+			bookID = stored.BookID
+		}
+	}
+	err := b.Books.UpdateReview(ctx, review)
+	if err != nil {
+		return err
+	}
+	b.invalidate(ctx, b.reviewKeys(ctx, bookID)...)
+	return nil
+}
+
+func (b *Books) DeleteReview(ctx context.Context, id int64) error {
+	review, err := b.Books.GetReviewByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	keys := b.reviewKeys(ctx, review.BookID)
+	err = b.Books.DeleteReview(ctx, id)
+	if err != nil {
+		return err
+	}
+	b.invalidate(ctx, keys...)
+	return nil
+}
+
+func (b *Books) RestoreReview(ctx context.Context, id int64) error {
+	err := b.Books.RestoreReview(ctx, id)
+	if err != nil {
+		return err
+	}
+	review, err := b.Books.GetReviewByID(ctx, id)
+	if err == nil {
+		b.invalidate(ctx, b.reviewKeys(ctx, review.BookID)...)
+	}
+	return nil
+}
+
+// reviewKeys returns the keys of the entries by ID of the editions of the book's work, or of the
+// book alone when its work cannot be read.
+func (b *Books) reviewKeys(ctx context.Context, bookID int64) []string {
+	book, err := b.Books.GetByID(ctx, bookID)
+	if err != nil {
+		return []string{bookIDKey(bookID)}
+	}
+	return append(b.editionKeys(ctx, book.WorkID, false), bookIDKey(bookID))
+}
+
+// editionKeys returns the keys of the entries by ID of the editions of the work, and by slugs when
+// withSlugs is set.
+func (b *Books) editionKeys(ctx context.Context, workID int64, withSlugs bool) []string {
+	editions, err := b.Books.GetEditions(ctx, workID)
+	if err != nil {
+		return nil
+	}
+	var keys []string
+	for _, edition := range editions {
+		keys = append(keys, bookIDKey(edition.ID))
+		if withSlugs {
+			keys = append(keys, b.slugKeys(ctx, edition.ID, edition.Slug)...)
+		}
+	}
+	return keys
+}
+
+// slugKeys returns the keys of the entries of the book by its slug and by the old slugs that still
+// lead to it, each of which may hold a copy.
+func (b *Books) slugKeys(ctx context.Context, id int64, slug string) []string {
+	keys := []string{bookSlugKey(slug)}
+	oldSlugs, err := b.Books.GetOldSlugs(ctx, id)
+	if err != nil {
+		return keys
+	}
+	for _, oldSlug := range oldSlugs {
+		keys = append(keys, bookSlugKey(oldSlug))
+	}
+	return keys
+}
+
+// get returns the book cached under key, or loads and caches it. Every caller gets its own copy,
+// the handlers modify the books they are given.
+func (b *Books) get(ctx context.Context, lookup, key string, load func(ctx context.Context) (*data.Book, error)) (*data.Book, error) {
+	cached, ok, err := b.store.Get(ctx, key)
+	if err != nil {
+		b.metrics.CacheError("get", err)
+	}
+	if ok {
+		book, err := decodeBook(cached)
+		if err == nil {
+			b.metrics.CacheHit(lookup)
+			return book, nil
+		}
+		b.metrics.CacheError("decode", err)
+	}
+	b.metrics.CacheMiss(lookup)
+
+	encoded, err, _ := b.group.Do(key, func() (any, error) {
+		// The load is shared with other requests, it must not fail because this one went away.
+		ctx := context.WithoutCancel(ctx)
+		generation := b.generation.Load()
+		book, err := load(ctx)
+		if err != nil {
+			return nil, err
+		}
+		encoded, err := encodeBook(book)
+		if err != nil {
+			return nil, err
+		}
+		if b.generation.Load() == generation {
+			err = b.store.Set(ctx, key, encoded, b.ttl)
+			if err != nil {
+				b.metrics.CacheError("set", err)
+			}
+		}
+		return encoded, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return decodeBook(encoded.([]byte))
+}
+
+func (b *Books) invalidate(ctx context.Context, keys ...string) {
+	b.generation.Add(1)
+	for _, key := range keys {
+		b.group.Forget(key)
+	}
+	err := b.store.Delete(context.WithoutCancel(ctx), keys...)
+	if err != nil {
+		b.metrics.CacheError("delete", err)
+	}
+}
+
+// Books are cached with gob rather than JSON, which would lose the fields hidden from the API.
+
+func encodeBook(book *data.Book) ([]byte, error) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(book)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func decodeBook(encoded []byte) (*data.Book, error) {
+	var book data.Book
+	err := gob.NewDecoder(bytes.NewReader(encoded)).Decode(&book)
+	if err != nil {
+		return nil, err
+	}
+	return &book, nil
+}
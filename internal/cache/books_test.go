@@ -0,0 +1,422 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"github.com/rrebeiz/quickbooks/internal/data"
+	"sync"
+	"testing"
+	"time"
+)
+
+// countingBooks counts the lookups reaching the models. When release is set, GetByID waits for it
+// to be closed.
+type countingBooks struct {
+	data.Books
+	mu      sync.Mutex
+	byID    int
+	bySlug  int
+	release chan struct{}
+}
+
+func (c *countingBooks) GetByID(ctx context.Context, id int64) (*data.Book, error) {
+	c.mu.Lock()
+	c.byID++
+	c.mu.Unlock()
+	if c.release != nil {
+		<-c.release
+	}
+	return c.Books.GetByID(ctx, id)
+}
+
+func (c *countingBooks) GetBySlug(ctx context.Context, slug string) (*data.Book, error) {
+	c.mu.Lock()
+	c.bySlug++
+	c.mu.Unlock()
+	return c.Books.GetBySlug(ctx, slug)
+}
+
+func (c *countingBooks) calls() (byID, bySlug int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.byID, c.bySlug
+}
+
+type recordedMetrics struct {
+	mu     sync.Mutex
+	hits   int
+	misses int
+	errors map[string]int
+}
+
+func (m *recordedMetrics) CacheHit(string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.hits++
+}
+
+func (m *recordedMetrics) CacheMiss(string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.misses++
+}
+
+func (m *recordedMetrics) CacheError(op string, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.errors == nil {
+		m.errors = make(map[string]int)
+	}
+	m.errors[op]++
+}
+
+func (m *recordedMetrics) counts() (hits, misses int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.hits, m.misses
+}
+
+type fixture struct {
+	models  data.Models
+	counter *countingBooks
+	metrics *recordedMetrics
+	books   *Books
+	book    *data.Book
+	user    *data.User
+}
+
+// newFixture returns a cache over in-memory models holding a single book, Dune, and a user.
+func newFixture(t *testing.T, store Store) *fixture {
+	t.Helper()
+	ctx := context.Background()
+	models := data.NewMemoryModels()
+	author := &data.Author{AuthorName: "Frank Herbert"}
+	err := models.Books.InsertAuthor(ctx, author)
+	if err != nil {
+		t.Fatal(err)
+	}
+	book := &data.Book{Title: "Dune", AuthorID: int(author.ID), PublicationYear: 1965, Description: "Spice"}
+	err = models.Books.Insert(ctx, book)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := &data.User{Name: "Alice", Email: "alice@example.com"}
+	err = models.Users.Insert(ctx, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	counter := &countingBooks{Books: models.Books}
+	metrics := &recordedMetrics{}
+	return &fixture{
+		models:  models,
+		counter: counter,
+		metrics: metrics,
+		books:   NewBooks(counter, store, time.Minute, metrics),
+		book:    book,
+		user:    user,
+	}
+}
+
+var stores = map[string]func(t *testing.T) Store{
+	"LRU": func(t *testing.T) Store { return NewLRU(100) },
+	"Redis": func(t *testing.T) Store {
+		store, _ := newFakeRedis(t)
+		return store
+	},
+}
+
+func TestBooksReadThrough(t *testing.T) {
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			f := newFixture(t, newStore(t))
+
+			for i := 0; i < 3; i++ {
+				book, err := f.books.GetByID(ctx, f.book.ID)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if book.Title != "Dune" || book.Author.AuthorName != "Frank Herbert" || book.Slug != "dune" {
+					t.Errorf("GetByID = %+v", book)
+				}
+				// Every caller gets its own copy.
+				book.Title = "changed"
+
+				book, err = f.books.GetBySlug(ctx, "dune")
+				if err != nil || book.ID != f.book.ID {
+					t.Fatalf("GetBySlug = %+v, %v", book, err)
+				}
+			}
+			if byID, bySlug := f.counter.calls(); byID != 1 || bySlug != 1 {
+				t.Errorf("the models were called %d times by ID and %d by slug; want once each", byID, bySlug)
+			}
+			if hits, misses := f.metrics.counts(); hits != 4 || misses != 2 {
+				t.Errorf("%d hits and %d misses; want 4 and 2", hits, misses)
+			}
+
+			for i := 0; i < 2; i++ {
+				_, err := f.books.GetByID(ctx, f.book.ID+100)
+				if !errors.Is(err, data.ErrNoRecordFound) {
+					t.Errorf("GetByID(missing) error = %v", err)
+				}
+			}
+			if byID, _ := f.counter.calls(); byID != 3 {
+				t.Errorf("missing books were looked up %d times; want every time", byID-1)
+			}
+		})
+	}
+}
+
+func TestBooksInvalidation(t *testing.T) {
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			f := newFixture(t, newStore(t))
+
+			book, err := f.books.GetByID(ctx, f.book.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			f.books.GetBySlug(ctx, "dune")
+
+			book.Title = "Dune Messiah"
+			err = f.books.Update(ctx, book)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := f.books.GetByID(ctx, f.book.ID)
+			if err != nil || got.Title != "Dune Messiah" {
+				t.Errorf("GetByID after Update = %+v, %v; want the new title", got, err)
+			}
+			got, err = f.books.GetBySlug(ctx, "dune")
+			if err != nil || got.Slug != "dune-messiah" {
+				t.Errorf("GetBySlug(old slug) after Update = %+v, %v; want the book with its new slug", got, err)
+			}
+			got, err = f.books.GetBySlug(ctx, "dune-messiah")
+			if err != nil || got.ID != f.book.ID {
+				t.Errorf("GetBySlug(new slug) = %+v, %v", got, err)
+			}
+
+			review := &data.Review{Rating: 5, Review: "Great", BookID: f.book.ID, UserID: f.user.ID}
+			err = f.books.InsertReview(ctx, review)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, _ = f.books.GetByID(ctx, f.book.ID)
+			if len(got.Reviews) != 1 || got.Reviews[0].Rating != 5 {
+				t.Fatalf("reviews after InsertReview = %+v", got.Reviews)
+			}
+
+			stored, err := f.books.GetReviewByID(ctx, review.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			stored.Rating = 3
+			err = f.books.UpdateReview(ctx, stored)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, _ = f.books.GetByID(ctx, f.book.ID)
+			if len(got.Reviews) != 1 || got.Reviews[0].Rating != 3 {
+				t.Errorf("reviews after UpdateReview = %+v", got.Reviews)
+			}
+
+			err = f.books.DeleteReview(ctx, review.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, _ = f.books.GetByID(ctx, f.book.ID)
+			if len(got.Reviews) != 0 {
+				t.Errorf("reviews after DeleteReview = %+v", got.Reviews)
+			}
+
+			covers := []data.Cover{{Size: "small", URL: "/covers/small.jpg", Width: 128, Height: 192, Key: "small.jpg"}}
+			_, err = f.books.SetCovers(ctx, f.book.ID, covers)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, _ = f.books.GetByID(ctx, f.book.ID)
+			if len(got.Covers) != 1 || got.Covers[0].URL != "/covers/small.jpg" {
+				t.Errorf("covers after SetCovers = %+v", got.Covers)
+			}
+			got, _ = f.books.GetBySlug(ctx, "dune-messiah")
+			if len(got.Covers) != 1 || got.Covers[0].Key != "small.jpg" {
+				t.Errorf("covers by slug after SetCovers = %+v", got.Covers)
+			}
+
+			err = f.books.Delete(ctx, f.book.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = f.books.GetByID(ctx, f.book.ID)
+			if !errors.Is(err, data.ErrNoRecordFound) {
+				t.Errorf("GetByID after Delete error = %v; want ErrNoRecordFound", err)
+			}
+			_, err = f.books.GetBySlug(ctx, "dune-messiah")
+			if !errors.Is(err, data.ErrNoRecordFound) {
+				t.Errorf("GetBySlug after Delete error = %v; want ErrNoRecordFound", err)
+			}
+		})
+	}
+}
+
+func TestBooksChainedRenames(t *testing.T) {
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			f := newFixture(t, newStore(t))
+
+			// Every slug the book had leads to it, and each may have cached it.
+			for _, title := range []string{"Dune Messiah", "Children of Dune"} {
+				book, err := f.books.GetBySlug(ctx, "dune")
+				if err != nil {
+					t.Fatal(err)
+				}
+				f.books.GetBySlug(ctx, book.Slug)
+				book.Title = title
+				err = f.books.Update(ctx, book)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			for _, slug := range []string{"dune", "dune-messiah", "children-of-dune"} {
+				got, err := f.books.GetBySlug(ctx, slug)
+				if err != nil || got.Title != "Children of Dune" {
+					t.Errorf("GetBySlug(%s) after two renames = %+v, %v; want the last title", slug, got, err)
+				}
+			}
+		})
+	}
+}
+
+func TestBooksWorkInvalidation(t *testing.T) {
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			f := newFixture(t, newStore(t))
+			insert := func(title string, workID int64) *data.Book {
+				book := &data.Book{Title: title, AuthorID: f.book.AuthorID, PublicationYear: 1965, Description: title, WorkID: workID}
+				err := f.models.Books.Insert(ctx, book)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return book
+			}
+			paperback := insert("Dune paperback", f.book.WorkID)
+			duplicate := insert("Dune duplicate", 0)
+			for _, id := range []int64{f.book.ID, paperback.ID, duplicate.ID} {
+				f.books.GetByID(ctx, id)
+			}
+
+			review := &data.Review{Rating: 5, Review: "Great", BookID: f.book.ID, UserID: f.user.ID}
+			err := f.books.InsertReview(ctx, review)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, _ := f.books.GetByID(ctx, paperback.ID)
+			if len(got.Reviews) != 1 {
+				t.Errorf("reviews of the other edition after InsertReview = %+v; want the new one", got.Reviews)
+			}
+
+			err = f.books.MergeWorks(ctx, f.book.WorkID, []int64{duplicate.ID})
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, _ = f.books.GetByID(ctx, duplicate.ID)
+			if got.WorkID != f.book.WorkID || len(got.Reviews) != 1 {
+				t.Errorf("merged book = work %d with %d reviews; want work %d with 1", got.WorkID, len(got.Reviews), f.book.WorkID)
+			}
+		})
+	}
+}
+
+func TestBooksCoalescesMisses(t *testing.T) {
+	f := newFixture(t, NewLRU(100))
+	f.counter.release = make(chan struct{})
+
+	const callers = 10
+	var wg sync.WaitGroup
+	results := make(chan *data.Book, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			book, err := f.books.GetByID(context.Background(), f.book.ID)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			results <- book
+		}()
+	}
+	// Every caller has missed before the first load is let through.
+	for {
+		if _, misses := f.metrics.counts(); misses == callers {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(f.counter.release)
+	wg.Wait()
+	close(results)
+
+	if byID, _ := f.counter.calls(); byID != 1 {
+		t.Errorf("%d concurrent misses loaded the book %d times; want once", callers, byID)
+	}
+	seen := make(map[*data.Book]bool)
+	for book := range results {
+		if seen[book] {
+			t.Error("two callers got the same *Book")
+		}
+		seen[book] = true
+	}
+}
+
+func TestBooksCancelledLeader(t *testing.T) {
+	f := newFixture(t, NewLRU(100))
+	f.counter.release = make(chan struct{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		_, err := f.books.GetByID(ctx, f.book.ID)
+		done <- err
+	}()
+	for {
+		if byID, _ := f.counter.calls(); byID == 1 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	close(f.counter.release)
+	err := <-done
+	if err != nil {
+		t.Errorf("the load failed with %v after the request was cancelled; want it to finish", err)
+	}
+	if _, ok, _ := f.books.store.Get(context.Background(), bookIDKey(f.book.ID)); !ok {
+		t.Error("the book loaded for a cancelled request was not cached")
+	}
+}
+
+func TestBooksStoreDown(t *testing.T) {
+	ctx := context.Background()
+	store, server := newFakeRedis(t)
+	f := newFixture(t, store)
+	server.Close()
+
+	for i := 0; i < 2; i++ {
+		book, err := f.books.GetByID(ctx, f.book.ID)
+		if err != nil || book.Title != "Dune" {
+			t.Fatalf("GetByID with Redis down = %+v, %v; want the book from the models", book, err)
+		}
+	}
+	if byID, _ := f.counter.calls(); byID != 2 {
+		t.Errorf("the models were called %d times; want every time", byID)
+	}
+	if f.metrics.errors["get"] != 2 || f.metrics.errors["set"] != 2 {
+		t.Errorf("errors = %v; want 2 gets and 2 sets", f.metrics.errors)
+	}
+}
@@ -0,0 +1,32 @@
+// Package cache keeps the results of hot model lookups in a Store shared by every request.
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// Store holds encoded values by key. Implementations must be safe for concurrent use.
+type Store interface {
+	// Get returns the value stored under key, ok is false when there is none or it has expired.
+	Get(ctx context.Context, key string) (value []byte, ok bool, err error)
+	// Set stores the value under key for ttl.
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// Delete removes the keys, missing keys are ignored.
+	Delete(ctx context.Context, keys ...string) error
+}
+
+// Metrics is told the outcome of every cached lookup, and about the store failing. A failing store
+// never fails the lookup, it is treated as a miss.
+type Metrics interface {
+	CacheHit(lookup string)
+	CacheMiss(lookup string)
+	CacheError(op string, err error)
+}
+
+// nopMetrics is used when no Metrics are given.
+type nopMetrics struct{}
+
+func (nopMetrics) CacheHit(string)          {}
+func (nopMetrics) CacheMiss(string)         {}
+func (nopMetrics) CacheError(string, error) {}
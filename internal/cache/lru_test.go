@@ -0,0 +1,62 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLRUEviction(t *testing.T) {
+	ctx := context.Background()
+	c := NewLRU(2)
+	c.Set(ctx, "a", []byte("1"), time.Minute)
+	c.Set(ctx, "b", []byte("2"), time.Minute)
+	c.Get(ctx, "a")
+	c.Set(ctx, "c", []byte("3"), time.Minute)
+
+	if _, ok, _ := c.Get(ctx, "b"); ok {
+		t.Error("b is still cached; want it evicted as the least recently used")
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, ok, _ := c.Get(ctx, key); !ok {
+			t.Errorf("%s was evicted", key)
+		}
+	}
+	if c.Len() != 2 {
+		t.Errorf("Len() = %d; want 2", c.Len())
+	}
+}
+
+func TestLRUExpiry(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	c := NewLRU(10)
+	c.now = func() time.Time { return now }
+	c.Set(ctx, "a", []byte("1"), time.Minute)
+
+	now = now.Add(59 * time.Second)
+	if value, ok, _ := c.Get(ctx, "a"); !ok || string(value) != "1" {
+		t.Errorf("Get before the TTL = %q, %v; want 1", value, ok)
+	}
+	now = now.Add(time.Second)
+	if _, ok, _ := c.Get(ctx, "a"); ok {
+		t.Error("Get after the TTL found the entry")
+	}
+	if c.Len() != 0 {
+		t.Errorf("Len() = %d; want the expired entry removed", c.Len())
+	}
+}
+
+func TestLRUSetAndDelete(t *testing.T) {
+	ctx := context.Background()
+	c := NewLRU(10)
+	c.Set(ctx, "a", []byte("1"), time.Minute)
+	c.Set(ctx, "a", []byte("2"), time.Minute)
+	if value, _, _ := c.Get(ctx, "a"); string(value) != "2" {
+		t.Errorf("Get = %q; want the second value", value)
+	}
+	c.Delete(ctx, "a", "missing")
+	if _, ok, _ := c.Get(ctx, "a"); ok || c.Len() != 0 {
+		t.Error("a is still cached after Delete")
+	}
+}
@@ -0,0 +1,58 @@
+package cache
+
+import (
+	"context"
+	"github.com/alicebob/miniredis/v2"
+	"testing"
+	"time"
+)
+
+// newFakeRedis returns a store backed by an in-process fake Redis server.
+func newFakeRedis(t *testing.T) (*Redis, *miniredis.Miniredis) {
+	t.Helper()
+	server := miniredis.RunT(t)
+	store, err := OpenRedis(context.Background(), "redis://"+server.Addr(), "test:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store, server
+}
+
+func TestRedis(t *testing.T) {
+	ctx := context.Background()
+	store, server := newFakeRedis(t)
+
+	err := store.Set(ctx, "a", []byte("1"), time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !server.Exists("test:a") || server.TTL("test:a") != time.Minute {
+		t.Errorf("key test:a exists %v with TTL %v; want a TTL of a minute", server.Exists("test:a"), server.TTL("test:a"))
+	}
+	value, ok, err := store.Get(ctx, "a")
+	if err != nil || !ok || string(value) != "1" {
+		t.Errorf("Get = %q, %v, %v; want 1", value, ok, err)
+	}
+
+	server.FastForward(time.Minute)
+	_, ok, err = store.Get(ctx, "a")
+	if err != nil || ok {
+		t.Errorf("Get after the TTL = %v, %v; want a miss", ok, err)
+	}
+
+	store.Set(ctx, "b", []byte("2"), time.Minute)
+	err = store.Delete(ctx, "b", "missing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if server.Exists("test:b") {
+		t.Error("test:b still exists after Delete")
+	}
+
+	server.Close()
+	_, _, err = store.Get(ctx, "a")
+	if err == nil {
+		t.Error("Get with the server down did not fail")
+	}
+}
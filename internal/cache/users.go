@@ -0,0 +1,51 @@
+package cache
+
+import (
+	"context"
+	"github.com/rrebeiz/quickbooks/internal/data"
+)
+
+// Users drops the cached books a user reviewed when the user is deleted or restored, their reviews
+// go to the trash or come back with them. Every other method goes straight to the wrapped models.
+type Users struct {
+	data.Users
+	books *Books
+}
+
+// NewUsers wraps next, invalidating the entries of books.
+func NewUsers(next data.Users, books *Books) *Users {
+	return &Users{Users: next, books: books}
+}
+
+func (u *Users) Delete(ctx context.Context, id int64) error {
+	keys := u.reviewKeys(ctx, id)
+	err := u.Users.Delete(ctx, id)
+	if err != nil {
+		return err
+	}
+	u.books.invalidate(ctx, keys...)
+	return nil
+}
+
+func (u *Users) Restore(ctx context.Context, id int64) error {
+	err := u.Users.Restore(ctx, id)
+	if err != nil {
+		return err
+	}
+	u.books.invalidate(ctx, u.reviewKeys(ctx, id)...)
+	return nil
+}
+
+// reviewKeys returns the keys of the entries by ID of the editions of the books the user has live
+// reviews of.
+func (u *Users) reviewKeys(ctx context.Context, userID int64) []string {
+	ids, err := u.books.Books.GetReviewedBookIDs(ctx, userID)
+	if err != nil {
+		return nil
+	}
+	var keys []string
+	for _, id := range ids {
+		keys = append(keys, u.books.reviewKeys(ctx, id)...)
+	}
+	return keys
+}
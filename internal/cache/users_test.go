@@ -0,0 +1,49 @@
+package cache
+
+import (
+	"context"
+	"github.com/rrebeiz/quickbooks/internal/data"
+	"testing"
+)
+
+func TestUsersInvalidation(t *testing.T) {
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			f := newFixture(t, newStore(t))
+			users := NewUsers(f.models.Users, f.books)
+
+			reviews := func() int {
+				t.Helper()
+				book, err := f.books.GetByID(ctx, f.book.ID)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return len(book.Reviews)
+			}
+			err := f.models.Books.InsertReview(ctx, &data.Review{Rating: 5, Review: "Great", BookID: f.book.ID, UserID: f.user.ID})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n := reviews(); n != 1 {
+				t.Fatalf("%d reviews cached; want 1", n)
+			}
+
+			// The reviews of the user go to the trash and come back with them.
+			err = users.Delete(ctx, f.user.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n := reviews(); n != 0 {
+				t.Errorf("%d reviews after deleting the user; want 0", n)
+			}
+			err = users.Restore(ctx, f.user.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n := reviews(); n != 1 {
+				t.Errorf("%d reviews after restoring the user; want 1", n)
+			}
+		})
+	}
+}
@@ -0,0 +1,124 @@
+// Package covers turns uploaded cover images into the JPEG renditions served for a book.
+package covers
+
+import (
+	"bytes"
+	"errors"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+	"image"
+	"image/color"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"net/http"
+)
+
+// Size is a rendition of the cover, scaled down to Width unless the image is narrower.
+type Size struct {
+	Name  string
+	Width int
+}
+
+// Sizes are the renditions made of every cover, in increasing width.
+var Sizes = []Size{{"small", 128}, {"medium", 320}, {"large", 640}}
+
+// ContentTypes are the types of the images accepted, as sniffed from their content. The decoders
+// of the ones other than JPEG are registered by the blank imports.
+var ContentTypes = []string{"image/jpeg", "image/png", "image/gif", "image/webp"}
+
+const (
+	// MaxPixels bounds the size of the decoded image, a small file can claim huge dimensions.
+	MaxPixels = 40_000_000
+	// jpegQuality is the quality the renditions are encoded with.
+	jpegQuality = 85
+)
+
+var (
+	// ErrUnsupportedType is returned for content that is not one of the ContentTypes.
+	ErrUnsupportedType = errors.New("covers: unsupported image type")
+	// ErrTooManyPixels is returned for images of more than MaxPixels.
+	ErrTooManyPixels = errors.New("covers: image dimensions too large")
+	// ErrInvalidImage is returned for content of a supported type that does not decode.
+	ErrInvalidImage = errors.New("covers: invalid image")
+)
+
+// Rendition is a cover image encoded as a JPEG.
+type Rendition struct {
+	Size   string
+	Width  int
+	Height int
+	Data   []byte
+}
+
+// Sniff returns the content type of the image, ErrUnsupportedType when it is not one of the
+// ContentTypes. The type the client claims is not trusted.
+func Sniff(content []byte) (string, error) {
+	contentType := http.DetectContentType(content)
+	for _, supported := range ContentTypes {
+		if contentType == supported {
+			return contentType, nil
+		}
+	}
+	return "", ErrUnsupportedType
+}
+
+// Render decodes the image and returns a rendition of it for each of the Sizes. Images are
+// never scaled up, and transparent areas are made white.
+func Render(content []byte) ([]Rendition, error) {
+	_, err := Sniff(content)
+	if err != nil {
+		return nil, err
+	}
+	config, _, err := image.DecodeConfig(bytes.NewReader(content))
+	if err != nil {
+		return nil, ErrInvalidImage
+	}
+	if config.Width <= 0 || config.Height <= 0 {
+		return nil, ErrInvalidImage
+	}
+	if config.Width*config.Height > MaxPixels {
+		return nil, ErrTooManyPixels
+	}
+	src, _, err := image.Decode(bytes.NewReader(content))
+	if err != nil {
+		return nil, ErrInvalidImage
+	}
+
+	// Only the first frame of an animated GIF is kept, and the alpha channel is dropped against a
+	// white background as JPEG has none.
+	bounds := src.Bounds()
+	flat := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(flat, flat.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(flat, flat.Bounds(), src, bounds.Min, draw.Over)
+
+	renditions := make([]Rendition, 0, len(Sizes))
+	for _, size := range Sizes {
+		width, height := fit(bounds.Dx(), bounds.Dy(), size.Width)
+		var scaled image.Image = flat
+		if width != bounds.Dx() {
+			dst := image.NewRGBA(image.Rect(0, 0, width, height))
+			draw.CatmullRom.Scale(dst, dst.Bounds(), flat, flat.Bounds(), draw.Src, nil)
+			scaled = dst
+		}
+		var buf bytes.Buffer
+		err = jpeg.Encode(&buf, scaled, &jpeg.Options{Quality: jpegQuality})
+		if err != nil {
+			return nil, err
+		}
+		renditions = append(renditions, Rendition{Size: size.Name, Width: width, Height: height, Data: buf.Bytes()})
+	}
+	return renditions, nil
+}
+
+// fit returns the dimensions of an image scaled down to maxWidth, keeping its aspect ratio.
+func fit(width, height, maxWidth int) (int, int) {
+	if width <= maxWidth {
+		return width, height
+	}
+	scaled := (height*maxWidth + width/2) / width
+	if scaled < 1 {
+		scaled = 1
+	}
+	return maxWidth, scaled
+}
@@ -0,0 +1,100 @@
+package covers
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+func encodePNG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	err := png.Encode(&buf, img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestRender(t *testing.T) {
+	// A transparent portrait PNG is scaled to every size and flattened against white.
+	img := image.NewNRGBA(image.Rect(0, 0, 1000, 1500))
+	renditions, err := Render(encodePNG(t, img))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Rendition{{Size: "small", Width: 128, Height: 192}, {Size: "medium", Width: 320, Height: 480}, {Size: "large", Width: 640, Height: 960}}
+	if len(renditions) != len(want) {
+		t.Fatalf("got %d renditions; want %d", len(renditions), len(want))
+	}
+	for i, r := range renditions {
+		if r.Size != want[i].Size || r.Width != want[i].Width || r.Height != want[i].Height {
+			t.Errorf("rendition %d = %s %dx%d; want %s %dx%d", i, r.Size, r.Width, r.Height, want[i].Size, want[i].Width, want[i].Height)
+		}
+		decoded, err := jpeg.Decode(bytes.NewReader(r.Data))
+		if err != nil {
+			t.Fatalf("rendition %s is not a JPEG: %v", r.Size, err)
+		}
+		if decoded.Bounds().Dx() != r.Width || decoded.Bounds().Dy() != r.Height {
+			t.Errorf("rendition %s is %v; want %dx%d", r.Size, decoded.Bounds(), r.Width, r.Height)
+		}
+		red, green, blue, _ := decoded.At(r.Width/2, r.Height/2).RGBA()
+		if red>>8 < 250 || green>>8 < 250 || blue>>8 < 250 {
+			t.Errorf("rendition %s transparent pixel = %d %d %d; want white", r.Size, red>>8, green>>8, blue>>8)
+		}
+	}
+}
+
+func TestRenderDoesNotScaleUp(t *testing.T) {
+	img := image.NewPaletted(image.Rect(0, 0, 100, 50), []color.Color{color.Black, color.White})
+	var buf bytes.Buffer
+	err := gif.Encode(&buf, img, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	renditions, err := Render(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range renditions {
+		if r.Width != 100 || r.Height != 50 {
+			t.Errorf("rendition %s = %dx%d; want 100x50", r.Size, r.Width, r.Height)
+		}
+	}
+}
+
+func TestRenderRejects(t *testing.T) {
+	small := encodePNG(t, image.NewGray(image.Rect(0, 0, 10, 10)))
+
+	// A valid PNG header claiming 100000x100000 pixels, decoding it would need 40GB.
+	bomb := append([]byte(nil), small...)
+	binary.BigEndian.PutUint32(bomb[16:], 100_000)
+	binary.BigEndian.PutUint32(bomb[20:], 100_000)
+	binary.BigEndian.PutUint32(bomb[29:], crc32.ChecksumIEEE(bomb[12:29]))
+
+	tests := []struct {
+		name    string
+		content []byte
+		want    error
+	}{
+		{"text", []byte("not an image at all"), ErrUnsupportedType},
+		{"svg", []byte(`<svg xmlns="http://www.w3.org/2000/svg"></svg>`), ErrUnsupportedType},
+		{"truncated", small[:40], ErrInvalidImage},
+		{"too many pixels", bomb, ErrTooManyPixels},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Render(tt.content)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("Render error = %v; want %v", err, tt.want)
+			}
+		})
+	}
+}
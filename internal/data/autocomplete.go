@@ -0,0 +1,169 @@
+package data
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"modernc.org/sqlite"
+	"sort"
+	"strings"
+)
+
+// Suggestion is a book title or an author name completing what is being typed in a search box.
+type Suggestion struct {
+	Type  string  `json:"type"`
+	ID    int64   `json:"id"`
+	Text  string  `json:"text"`
+	Score float64 `json:"score"`
+}
+
+// similarityThreshold is the lowest word similarity of a suggestion that does not start with the
+// search, the default of pg_trgm.word_similarity_threshold.
+const similarityThreshold = 0.6
+
+func init() {
+	// SQLite has no pg_trgm, word_similarity is provided to it in Go.
+	err := sqlite.RegisterDeterministicScalarFunction("word_similarity", 2, func(ctx *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+		search, _ := args[0].(string)
+		text, _ := args[1].(string)
+		return wordSimilarity(search, text), nil
+	})
+	if err != nil {
+		panic(err)
+	}
+}
+
+// similar returns the condition keeping the texts in column similar enough to the search in param.
+// In Postgres the <% operator uses the trigram indexes.
+func (d Dialect) similar(param, column string) string {
+	switch d {
+	case SQLite:
+		return fmt.Sprintf(`word_similarity(%s, %s) >= %v`, param, column, similarityThreshold)
+	default:
+		return fmt.Sprintf(`%s <%% %s`, param, column)
+	}
+}
+
+// autocompleteArgs returns the search, lower cased, then the like patterns of the texts starting
+// with it and of the texts with a word starting with it.
+func autocompleteArgs(search string) (string, string, string) {
+	search = strings.ToLower(strings.TrimSpace(search))
+	escaped := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(search)
+	return search, escaped + "%", "% " + escaped + "%"
+}
+
+// Autocomplete suggests up to limit book titles and author names for a search being typed. Texts
+// starting with the search come first, then the ones with a word starting with it, then the ones
+// with a word close enough to it to be a typo, each by trigram similarity.
+func (b BookModel) Autocomplete(ctx context.Context, search string, limit int) (_ []*Suggestion, err error) {
+	score := func(column string) string {
+		return fmt.Sprintf(`(case when lower(%[1]s) like $2 escape '\' then 2 when lower(%[1]s) like $3 escape '\' then 1 else 0 end) + word_similarity($1, lower(%[1]s))`, column)
+	}
+	matches := func(column string) string {
+		return fmt.Sprintf(`lower(%[1]s) like $2 escape '\' or lower(%[1]s) like $3 escape '\' or %[2]s`, column, b.Dialect.similar("$1", "lower("+column+")"))
+	}
+	query := fmt.Sprintf(`select type, id, text, score from (
+			select 'title' as type, id, title as text, %s as score from books where deleted_at is null and (%s)
+			union all
+			select 'author' as type, id, author_name as text, %s as score from authors where %s
+		) s order by score desc, text asc, id asc limit $4`,
+		score("title"), matches("title"), score("author_name"), matches("author_name"))
+	ctx, cancel := context.WithTimeout(ctx, b.QueryTimeout)
+	defer cancel()
+	ctx, span := startSpan(ctx, b.Dialect, "BookModel.Autocomplete", query)
+	defer endSpan(span, &err)
+
+	lower, prefix, wordPrefix := autocompleteArgs(search)
+	rows, err := b.DB.QueryContext(ctx, query, lower, prefix, wordPrefix, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var suggestions []*Suggestion
+	for rows.Next() {
+		var suggestion Suggestion
+		err := rows.Scan(&suggestion.Type, &suggestion.ID, &suggestion.Text, &suggestion.Score)
+		if err != nil {
+			return nil, err
+		}
+		suggestions = append(suggestions, &suggestion)
+	}
+	return suggestions, rows.Err()
+}
+
+func (b MemoryBookModel) Autocomplete(ctx context.Context, search string, limit int) ([]*Suggestion, error) {
+	b.store.mu.RLock()
+	defer b.store.mu.RUnlock()
+
+	search = strings.ToLower(strings.TrimSpace(search))
+	var suggestions []*Suggestion
+	add := func(kind string, id int64, text string) {
+		lower := strings.ToLower(text)
+		score := wordSimilarity(search, lower)
+		switch {
+		case strings.HasPrefix(lower, search):
+			score += 2
+		case strings.Contains(lower, " "+search):
+			score += 1
+		case score < similarityThreshold:
+			return
+		}
+		suggestions = append(suggestions, &Suggestion{Type: kind, ID: id, Text: text, Score: score})
+	}
+	for _, row := range b.store.books {
+		add("title", row.book.ID, row.book.Title)
+	}
+	for _, author := range b.store.authors {
+		add("author", author.ID, author.AuthorName)
+	}
+	sort.Slice(suggestions, func(i, j int) bool {
+		x, y := suggestions[i], suggestions[j]
+		if x.Score != y.Score {
+			return x.Score > y.Score
+		}
+		if x.Text != y.Text {
+			return x.Text < y.Text
+		}
+		return x.ID < y.ID
+	})
+	if len(suggestions) > limit {
+		suggestions = suggestions[:limit]
+	}
+	return suggestions, nil
+}
+
+// wordSimilarity approximates pg_trgm's word_similarity: the share of the trigrams of search found
+// in the closest run of consecutive words of text.
+func wordSimilarity(search, text string) float64 {
+	searchTrigrams := trigrams(words(search))
+	if len(searchTrigrams) == 0 {
+		return 0
+	}
+	textWords := words(text)
+	best := 0
+	for start := range textWords {
+		for end := start + 1; end <= len(textWords); end++ {
+			common := 0
+			for trigram := range trigrams(textWords[start:end]) {
+				if searchTrigrams[trigram] {
+					common++
+				}
+			}
+			best = max(best, common)
+		}
+	}
+	return float64(best) / float64(len(searchTrigrams))
+}
+
+// trigrams returns the trigrams of the words the way pg_trgm extracts them, every word padded with
+// two spaces in front and one behind.
+func trigrams(words []string) map[string]bool {
+	set := make(map[string]bool)
+	for _, word := range words {
+		padded := []rune("  " + word + " ")
+		for i := 0; i+3 <= len(padded); i++ {
+			set[string(padded[i:i+3])] = true
+		}
+	}
+	return set
+}
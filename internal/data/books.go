@@ -5,32 +5,58 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
-	"github.com/mozillazg/go-slugify"
 	"github.com/rrebeiz/quickbooks/internal/validator"
+	"strings"
 	"time"
 )
 
 type Book struct {
-	ID              int64     `json:"id"`
-	Title           string    `json:"title"`
-	AuthorID        int       `json:"author_id"`
-	PublicationYear int       `json:"publication_year"`
-	Slug            string    `json:"slug"`
-	Author          Author    `json:"author"`
-	Description     string    `json:"description"`
-	Genres          []string  `json:"genres"`
-	Reviews         []*Review `json:"reviews,omitempty"`
-	CreatedAt       time.Time `json:"-"`
-	UpdatedAt       time.Time `json:"-"`
+	ID              int64         `json:"id"`
+	Title           string        `json:"title"`
+	AuthorID        int           `json:"author_id"`
+	PublicationYear int           `json:"publication_year"`
+	Slug            string        `json:"slug"`
+	Author          Author        `json:"author"`
+	Description     string        `json:"description"`
+	Genres          []string      `json:"genres"`
+	Contributors    []Contributor `json:"contributors"`
+	Series          []SeriesEntry `json:"series"`
+	Language        string        `json:"language"`
+	ISBN10          string        `json:"isbn_10,omitempty"`
+	ISBN13          string        `json:"isbn_13,omitempty"`
+	WorkID          int64         `json:"work_id"`
+	Format          string        `json:"format,omitempty"`
+	PublisherID     int64         `json:"publisher_id,omitempty"`
+	Publisher       *Publisher    `json:"publisher,omitempty"`
+	PageCount       int           `json:"page_count,omitempty"`
+	Covers          []Cover       `json:"covers,omitempty"`
+	Reviews         []*Review     `json:"reviews,omitempty"`
+	CreatedAt       time.Time     `json:"-"`
+	UpdatedAt       time.Time     `json:"-"`
 }
 type Author struct {
 	ID         int64     `json:"id"`
 	AuthorName string    `json:"author_name"`
+	BookCount  int       `json:"book_count,omitempty"`
 	CreatedAt  time.Time `json:"-"`
 	UpdatedAt  time.Time `json:"-"`
 	Version    int       `json:"-"`
 }
 
+// Contributor is an author credited on a book with a role. The contributors of a book are in the
+// order they are credited, the first one is the book's AuthorID.
+type Contributor struct {
+	AuthorID   int64  `json:"author_id"`
+	AuthorName string `json:"author_name"`
+	Role       string `json:"role"`
+}
+
+// ContributorRoles are the roles a contributor can have on a book.
+var ContributorRoles = []string{"author", "editor", "translator", "illustrator"}
+
+// Genres are the genres a book can have, the ones the migrations create.
+var Genres = []string{"Science Fiction", "Fantasy", "Romance", "Thriller", "Mystery", "Horror", "Classic", "Self-help"}
+
 type Genre struct {
 	ID        int64     `json:"id"`
 	GenreName string    `json:"genre_name"`
@@ -51,49 +77,118 @@ type Review struct {
 }
 
 type Books interface {
-	GetAll(title string, filters Filters) ([]*Book, Metadata, error)
-	GetByID(id int64) (*Book, error)
-	GetBySlug(slug string) (*Book, error)
-	Insert(book *Book) error
-	Update(book *Book) error
-	Delete(id int64) error
-	GetAllAuthors(author string, filters Filters) ([]*Author, Metadata, error)
-	GetAllReviewsByUser(user string, filters Filters) ([]*Review, Metadata, error)
-	GetReviewByID(id int64) (*Review, error)
-	InsertReview(review *Review) error
-	UpdateReview(review *Review) error
-	DeleteReview(id int64) error
+	GetAll(ctx context.Context, title string, publisherID int64, filters Filters) ([]*Book, Metadata, error)
+	GetFacets(ctx context.Context, title string, publisherID int64, filters Filters) (*Facets, error)
+	GetByID(ctx context.Context, id int64) (*Book, error)
+	GetBySlug(ctx context.Context, slug string) (*Book, error)
+	GetOldSlugs(ctx context.Context, id int64) ([]string, error)
+	GetByISBN(ctx context.Context, isbn13 string) (*Book, error)
+	GetByTitleAndAuthor(ctx context.Context, title, author string) (*Book, error)
+	GetWork(ctx context.Context, id int64) (*Work, error)
+	GetEditions(ctx context.Context, workID int64) ([]*Book, error)
+	MergeWorks(ctx context.Context, workID int64, bookIDs []int64) error
+	Search(ctx context.Context, search string, filters Filters) ([]*SearchResult, Metadata, error)
+	Autocomplete(ctx context.Context, search string, limit int) ([]*Suggestion, error)
+	Insert(ctx context.Context, book *Book) error
+	Update(ctx context.Context, book *Book) error
+	Delete(ctx context.Context, id int64) error
+	Restore(ctx context.Context, id int64) error
+	GetAllAuthors(ctx context.Context, author string, filters Filters) ([]*Author, Metadata, error)
+	GetAuthorByID(ctx context.Context, id int64) (*Author, error)
+	GetAuthorByName(ctx context.Context, name string) (*Author, error)
+	InsertAuthor(ctx context.Context, author *Author) error
+	GetAllReviewsByUser(ctx context.Context, user string, filters Filters) ([]*Review, Metadata, error)
+	GetReviewByID(ctx context.Context, id int64) (*Review, error)
+	GetReviewedBookIDs(ctx context.Context, userID int64) ([]int64, error)
+	InsertReview(ctx context.Context, review *Review) error
+	UpdateReview(ctx context.Context, review *Review) error
+	DeleteReview(ctx context.Context, id int64) error
+	RestoreReview(ctx context.Context, id int64) error
+	SetCovers(ctx context.Context, bookID int64, covers []Cover) ([]Cover, error)
 }
 
 type BookModel struct {
-	DB *sql.DB
+	DB           *sql.DB
+	Dialect      Dialect
+	QueryTimeout time.Duration
 }
 
-func NewBookModel(db *sql.DB) BookModel {
-	return BookModel{DB: db}
+func NewBookModel(db *sql.DB, dialect Dialect, queryTimeout time.Duration) BookModel {
+	return BookModel{DB: db, Dialect: dialect, QueryTimeout: queryTimeout}
 }
 
 func ValidateBook(v *validator.Validator, book *Book) {
 	v.Check(book.Title != "", "title", "should not be empty")
 	v.Check(book.Description != "", "description", "should not be empty")
 	v.Check(book.PublicationYear > 0, "publication_year", "should not be empty")
+	v.Check(book.AuthorID > 0 || len(book.Contributors) > 0, "author_id", "should not be empty")
+	v.Check(book.Language == "" || validator.PermittedValue(book.Language, Languages...), "language", "must be one of "+strings.Join(Languages, ", "))
+	validateISBNs(v, book)
+	v.Check(book.Format == "" || validator.PermittedValue(book.Format, Formats...), "format", "must be one of "+strings.Join(Formats, ", "))
+	v.Check(book.PageCount >= 0, "page_count", "must not be negative")
+	validateSeriesEntries(v, book.Series)
+	seen := make(map[Contributor]bool)
+	for _, contributor := range book.Contributors {
+		v.Check(contributor.AuthorID > 0, "contributors", "must all have an author_id")
+		v.Check(validator.PermittedValue(contributor.Role, ContributorRoles...), "contributors", "roles must be one of "+strings.Join(ContributorRoles, ", "))
+		key := Contributor{AuthorID: contributor.AuthorID, Role: contributor.Role}
+		v.Check(!seen[key], "contributors", "must not credit an author twice with the same role")
+		seen[key] = true
+	}
 }
 
+// setContributors makes the book's author its only contributor when it has none, or else its
+// first contributor its author.
+func (book *Book) setContributors() {
+	if len(book.Contributors) == 0 {
+		book.Contributors = []Contributor{{AuthorID: int64(book.AuthorID), Role: "author"}}
+	}
+	book.AuthorID = int(book.Contributors[0].AuthorID)
+}
+
+// Languages are the text search configurations a book can be indexed with, the ones Postgres ships.
+// simple does no stemming at all and is the default.
+var Languages = []string{"simple", "danish", "dutch", "english", "finnish", "french", "german", "hungarian", "italian",
+	"norwegian", "portuguese", "romanian", "russian", "spanish", "swedish", "turkish"}
+
+// ValidateGenres checks that the genres are all Genres, and are not repeated.
+func ValidateGenres(v *validator.Validator, genres []string) {
+	v.Check(validator.Unique(genres), "genres", "values must be unique")
+	for _, genre := range genres {
+		v.Check(validator.PermittedValue(genre, Genres...), "genres", fmt.Sprintf("please use the following genres %s", Genres))
+	}
+}
+
+// ValidateReview checks a review written through the API, which has a rating and text.
 func ValidateReview(v *validator.Validator, review *Review) {
-	v.Check(review.Rating > 0, "rating", "should not be empty")
-	v.Check(review.Rating <= 5, "rating", "should not be bigger than 5")
+	ValidateRating(v, review.Rating)
 	v.Check(review.Review != "", "review", "should not be empty")
 }
 
-func (b BookModel) GetAll(title string, filters Filters) ([]*Book, Metadata, error) {
-	query := fmt.Sprintf(`select count (*) over(), b.id, b.title, b.author_id, b.publication_year, b.slug, b.description, b.created_at, 
-						b.updated_at, a.id, a.author_name, a.created_at, a.updated_at, a.version from books b left join authors a on (b.author_id = a.id) 
-						where (to_tsvector('simple', title) @@ plainto_tsquery('simple', $1) OR $1 = '')  
-						order by b.%s %s, b.id ASC limit $2 offset $3`, filters.sortColumn(), filters.sortDirection())
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+// ValidateRating checks the rating of a review. The reviews imported from a library can be a
+// rating alone, they only need this.
+func ValidateRating(v *validator.Validator, rating int) {
+	v.Check(rating > 0, "rating", "should not be empty")
+	v.Check(rating <= 5, "rating", "should not be bigger than 5")
+}
+
+// GetAll lists the books matching the title, of the publisher unless publisherID is 0.
+func (b BookModel) GetAll(ctx context.Context, title string, publisherID int64, filters Filters) (_ []*Book, _ Metadata, err error) {
+	keys, err := filters.keyset("books", "b."+filters.sortColumn(), "b.id")
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	where, tail, pageArgs := keys.clauses(3)
+	query := fmt.Sprintf(`select %s, b.id, b.title, b.author_id, b.publication_year, b.slug, b.description, cast(b.language as text), coalesce(b.isbn, ''), coalesce(b.work_id, 0), b.format, coalesce(b.publisher_id, 0), coalesce(p.name, ''), b.page_count, b.created_at, 
+						b.updated_at, a.id, a.author_name, a.created_at, a.updated_at, a.version from books b left join authors a on (b.author_id = a.id) left join publishers p on (b.publisher_id = p.id) 
+						where b.deleted_at is null and %s and ($2 = 0 or b.publisher_id = $2) and %s 
+						%s`, keys.countColumn(), b.Dialect.textSearch("books", "b", "title", "$1"), where, tail)
+	ctx, cancel := context.WithTimeout(ctx, b.QueryTimeout)
 	defer cancel()
+	ctx, span := startSpan(ctx, b.Dialect, "BookModel.GetAll", query)
+	defer endSpan(span, &err)
 	var books []*Book
-	args := []interface{}{title, filters.limit(), filters.offset()}
+	args := append([]interface{}{b.Dialect.searchArg(title), publisherID}, pageArgs...)
 
 	rows, err := b.DB.QueryContext(ctx, query, args...)
 	if err != nil {
@@ -104,21 +199,37 @@ func (b BookModel) GetAll(title string, filters Filters) ([]*Book, Metadata, err
 			return nil, Metadata{}, err
 		}
 	}
+	defer rows.Close()
 	totalRecords := 0
 
 	for rows.Next() {
 		var book Book
-		err := rows.Scan(&totalRecords, &book.ID, &book.Title, &book.AuthorID, &book.PublicationYear, &book.Slug, &book.Description, &book.CreatedAt,
+		var publisherName string
+		err := rows.Scan(&totalRecords, &book.ID, &book.Title, &book.AuthorID, &book.PublicationYear, &book.Slug, &book.Description, &book.Language, &book.ISBN13, &book.WorkID, &book.Format, &book.PublisherID, &publisherName, &book.PageCount, &book.CreatedAt,
 			&book.UpdatedAt, &book.Author.ID, &book.Author.AuthorName, &book.Author.CreatedAt, &book.Author.UpdatedAt, &book.Author.Version)
 		if err != nil {
 			return nil, Metadata{}, err
 		}
 
-		genres, err := b.genresByBook(book.ID)
+		genres, err := b.genresByBook(ctx, book.ID)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		book.setISBNs()
+		book.setPublisher(publisherName)
+		book.Contributors, err = b.contributorsByBook(ctx, book.ID)
 		if err != nil {
 			return nil, Metadata{}, err
 		}
-		reviews, err := b.reviewsByBook(book.ID)
+		book.Series, err = b.seriesByBook(ctx, book.ID)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		book.Covers, err = b.coversByBook(ctx, book.ID)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		reviews, err := b.reviewsByBook(ctx, book.ID)
 		if err != nil {
 			return nil, Metadata{}, err
 		}
@@ -132,18 +243,23 @@ func (b BookModel) GetAll(title string, filters Filters) ([]*Book, Metadata, err
 	if err != nil {
 		return nil, Metadata{}, err
 	}
-	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	books, metadata := finish(keys, books, totalRecords, func(book *Book) []any {
+		return []any{bookSortKey(book, filters.sortColumn()), book.ID}
+	})
 	return books, metadata, nil
 }
 
-func (b BookModel) GetByID(id int64) (*Book, error) {
-	query := `select b.id, b.title, b.author_id, b.publication_year, b.slug, b.description, b.created_at, b.updated_at, a.id, 
-       a.author_name, a.created_at, a.updated_at, a.version from books b left join authors a on (b.author_id = a.id) where b.id = $1`
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+func (b BookModel) GetByID(ctx context.Context, id int64) (_ *Book, err error) {
+	query := `select b.id, b.title, b.author_id, b.publication_year, b.slug, b.description, cast(b.language as text), coalesce(b.isbn, ''), coalesce(b.work_id, 0), b.format, coalesce(b.publisher_id, 0), coalesce(p.name, ''), b.page_count, b.created_at, b.updated_at, a.id, 
+       a.author_name, a.created_at, a.updated_at, a.version from books b left join authors a on (b.author_id = a.id) left join publishers p on (b.publisher_id = p.id) where b.id = $1 and b.deleted_at is null`
+	ctx, cancel := context.WithTimeout(ctx, b.QueryTimeout)
 	defer cancel()
+	ctx, span := startSpan(ctx, b.Dialect, "BookModel.GetByID", query)
+	defer endSpan(span, &err)
 	var book Book
-	err := b.DB.QueryRowContext(ctx, query, id).Scan(&book.ID, &book.Title, &book.AuthorID, &book.PublicationYear, &book.Slug,
-		&book.Description, &book.CreatedAt, &book.UpdatedAt, &book.Author.ID, &book.Author.AuthorName, &book.Author.CreatedAt, &book.Author.UpdatedAt, &book.Author.Version)
+	var publisherName string
+	err = b.DB.QueryRowContext(ctx, query, id).Scan(&book.ID, &book.Title, &book.AuthorID, &book.PublicationYear, &book.Slug,
+		&book.Description, &book.Language, &book.ISBN13, &book.WorkID, &book.Format, &book.PublisherID, &publisherName, &book.PageCount, &book.CreatedAt, &book.UpdatedAt, &book.Author.ID, &book.Author.AuthorName, &book.Author.CreatedAt, &book.Author.UpdatedAt, &book.Author.Version)
 	if err != nil {
 		switch {
 		case errors.Is(err, sql.ErrNoRows):
@@ -152,11 +268,25 @@ func (b BookModel) GetByID(id int64) (*Book, error) {
 			return nil, err
 		}
 	}
-	genres, err := b.genresByBook(book.ID)
+	genres, err := b.genresByBook(ctx, book.ID)
 	if err != nil {
 		return nil, err
 	}
-	reviews, err := b.reviewsByBook(book.ID)
+	book.setISBNs()
+	book.setPublisher(publisherName)
+	book.Contributors, err = b.contributorsByBook(ctx, book.ID)
+	if err != nil {
+		return nil, err
+	}
+	book.Series, err = b.seriesByBook(ctx, book.ID)
+	if err != nil {
+		return nil, err
+	}
+	book.Covers, err = b.coversByBook(ctx, book.ID)
+	if err != nil {
+		return nil, err
+	}
+	reviews, err := b.reviewsByBook(ctx, book.ID)
 	if err != nil {
 		return nil, err
 	}
@@ -169,14 +299,78 @@ func (b BookModel) GetByID(id int64) (*Book, error) {
 	return &book, nil
 }
 
-func (b BookModel) GetBySlug(slug string) (*Book, error) {
-	query := `select b.id, b.title, b.author_id, b.publication_year, b.slug, b.description, b.created_at, b.updated_at, a.id, 
-              a.author_name, a.created_at, a.updated_at, a.version from books b left join authors a on (b.author_id = a.id) where b.slug = $1`
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+// GetBySlug returns the book with the slug, or the book that had it before being renamed. The
+// slug of the book returned is its current one.
+func (b BookModel) GetBySlug(ctx context.Context, slug string) (_ *Book, err error) {
+	query := `select b.id, b.title, b.author_id, b.publication_year, b.slug, b.description, cast(b.language as text), coalesce(b.isbn, ''), coalesce(b.work_id, 0), b.format, coalesce(b.publisher_id, 0), coalesce(p.name, ''), b.page_count, b.created_at, b.updated_at, a.id, 
+              a.author_name, a.created_at, a.updated_at, a.version from books b left join authors a on (b.author_id = a.id) left join publishers p on (b.publisher_id = p.id) where b.slug = $1 and b.deleted_at is null`
+	ctx, cancel := context.WithTimeout(ctx, b.QueryTimeout)
+	defer cancel()
+	ctx, span := startSpan(ctx, b.Dialect, "BookModel.GetBySlug", query)
+	defer endSpan(span, &err)
+	var book Book
+	var publisherName string
+	err = b.DB.QueryRowContext(ctx, query, slug).Scan(&book.ID, &book.Title, &book.AuthorID, &book.PublicationYear,
+		&book.Slug, &book.Description, &book.Language, &book.ISBN13, &book.WorkID, &book.Format, &book.PublisherID, &publisherName, &book.PageCount, &book.CreatedAt, &book.UpdatedAt, &book.Author.ID, &book.Author.AuthorName, &book.Author.CreatedAt, &book.Author.UpdatedAt, &book.Author.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return b.getByOldSlug(ctx, slug)
+		default:
+			return nil, err
+		}
+	}
+	genres, err := b.genresByBook(ctx, book.ID)
+	if err != nil {
+		return nil, err
+	}
+	book.setISBNs()
+	book.setPublisher(publisherName)
+	book.Contributors, err = b.contributorsByBook(ctx, book.ID)
+	if err != nil {
+		return nil, err
+	}
+	book.Series, err = b.seriesByBook(ctx, book.ID)
+	if err != nil {
+		return nil, err
+	}
+	book.Covers, err = b.coversByBook(ctx, book.ID)
+	if err != nil {
+		return nil, err
+	}
+	var bookGenres []string
+	for _, x := range genres {
+		bookGenres = append(bookGenres, x.GenreName)
+	}
+	book.Genres = bookGenres
+	return &book, nil
+}
+
+func (b BookModel) getByOldSlug(ctx context.Context, slug string) (*Book, error) {
+	id, err := b.slugOwner(ctx, slug)
+	if err != nil {
+		return nil, err
+	}
+	book, err := b.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	book.Reviews = nil
+	return book, nil
+}
+
+// GetByISBN returns the book with the ISBN-13, ParseISBN converts ISBN-10s.
+func (b BookModel) GetByISBN(ctx context.Context, isbn13 string) (_ *Book, err error) {
+	query := `select b.id, b.title, b.author_id, b.publication_year, b.slug, b.description, cast(b.language as text), coalesce(b.isbn, ''), coalesce(b.work_id, 0), b.format, coalesce(b.publisher_id, 0), coalesce(p.name, ''), b.page_count, b.created_at, b.updated_at, a.id, 
+              a.author_name, a.created_at, a.updated_at, a.version from books b left join authors a on (b.author_id = a.id) left join publishers p on (b.publisher_id = p.id) where b.isbn = $1 and b.deleted_at is null`
+	ctx, cancel := context.WithTimeout(ctx, b.QueryTimeout)
 	defer cancel()
+	ctx, span := startSpan(ctx, b.Dialect, "BookModel.GetByISBN", query)
+	defer endSpan(span, &err)
 	var book Book
-	err := b.DB.QueryRowContext(ctx, query, slug).Scan(&book.ID, &book.Title, &book.AuthorID, &book.PublicationYear,
-		&book.Slug, &book.Description, &book.CreatedAt, &book.UpdatedAt, &book.Author.ID, &book.Author.AuthorName, &book.Author.CreatedAt, &book.Author.UpdatedAt, &book.Author.Version)
+	var publisherName string
+	err = b.DB.QueryRowContext(ctx, query, isbn13).Scan(&book.ID, &book.Title, &book.AuthorID, &book.PublicationYear,
+		&book.Slug, &book.Description, &book.Language, &book.ISBN13, &book.WorkID, &book.Format, &book.PublisherID, &publisherName, &book.PageCount, &book.CreatedAt, &book.UpdatedAt, &book.Author.ID, &book.Author.AuthorName, &book.Author.CreatedAt, &book.Author.UpdatedAt, &book.Author.Version)
 	if err != nil {
 		switch {
 		case errors.Is(err, sql.ErrNoRows):
@@ -185,7 +379,25 @@ func (b BookModel) GetBySlug(slug string) (*Book, error) {
 			return nil, err
 		}
 	}
-	genres, err := b.genresByBook(book.ID)
+	genres, err := b.genresByBook(ctx, book.ID)
+	if err != nil {
+		return nil, err
+	}
+	book.setISBNs()
+	book.setPublisher(publisherName)
+	book.Contributors, err = b.contributorsByBook(ctx, book.ID)
+	if err != nil {
+		return nil, err
+	}
+	book.Series, err = b.seriesByBook(ctx, book.ID)
+	if err != nil {
+		return nil, err
+	}
+	book.Covers, err = b.coversByBook(ctx, book.ID)
+	if err != nil {
+		return nil, err
+	}
+	reviews, err := b.reviewsByBook(ctx, book.ID)
 	if err != nil {
 		return nil, err
 	}
@@ -194,56 +406,228 @@ func (b BookModel) GetBySlug(slug string) (*Book, error) {
 		bookGenres = append(bookGenres, x.GenreName)
 	}
 	book.Genres = bookGenres
+	book.Reviews = reviews
 	return &book, nil
 }
 
-func (b BookModel) Insert(book *Book) error {
-	query := `insert into books (title, author_id, publication_year, slug, description) values ($1, $2, $3, $4, $5) returning id`
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+// GetByTitleAndAuthor returns the book with the title whose author has the name, both compared
+// case-insensitively. The book added first wins when there are several editions.
+func (b BookModel) GetByTitleAndAuthor(ctx context.Context, title, author string) (_ *Book, err error) {
+	query := `select b.id from books b join authors a on (b.author_id = a.id) where lower(b.title) = lower($1) and lower(a.author_name) = lower($2)
+			and b.deleted_at is null order by b.id limit 1`
+	queryCtx, cancel := context.WithTimeout(ctx, b.QueryTimeout)
 	defer cancel()
-	args := []interface{}{book.Title, book.AuthorID, book.PublicationYear, slugify.Slugify(book.Title), book.Description}
-	return b.DB.QueryRowContext(ctx, query, args...).Scan(&book.ID)
+	queryCtx, span := startSpan(queryCtx, b.Dialect, "BookModel.GetByTitleAndAuthor", query)
+	defer endSpan(span, &err)
+	var id int64
+	err = b.DB.QueryRowContext(queryCtx, query, title, author).Scan(&id)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNoRecordFound
+		default:
+			return nil, err
+		}
+	}
+	return b.GetByID(ctx, id)
 }
 
-func (b BookModel) Update(book *Book) error {
-	query := `update books set title = $1, author_id = $2, publication_year = $3, slug = $4, description = $5, updated_at = now() where id = $6 returning updated_at`
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+func (b BookModel) Insert(ctx context.Context, book *Book) (err error) {
+	query := `insert into books (title, author_id, publication_year, slug, description, language, isbn, work_id, format, publisher_id, page_count) 
+                 values ($1, $2, $3, $4, $5, $6, nullif($7, ''), $8, $9, nullif($10, 0), $11) returning id`
+	ctx, cancel := context.WithTimeout(ctx, b.QueryTimeout)
 	defer cancel()
-	args := []interface{}{book.Title, book.AuthorID, book.PublicationYear, slugify.Slugify(book.Title), book.Description, book.ID}
-	err := b.DB.QueryRowContext(ctx, query, args...).Scan(&book.UpdatedAt)
+	ctx, span := startSpan(ctx, b.Dialect, "BookModel.Insert", query)
+	defer endSpan(span, &err)
+	if book.Language == "" {
+		book.Language = "simple"
+	}
+	book.setContributors()
+	book.setISBNs()
+	tx, err := b.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if book.WorkID == 0 {
+		// A book without a work is the first edition of a new one.
+		err = tx.QueryRowContext(ctx, `insert into works (title) values ($1) returning id`, book.Title).Scan(&book.WorkID)
+		if err != nil {
+			return err
+		}
+	}
+	err = b.saveWithSlug(ctx, tx, book, "", func() error {
+		args := []interface{}{book.Title, book.AuthorID, book.PublicationYear, book.Slug, book.Description, book.Language, book.ISBN13,
+			book.WorkID, book.Format, book.PublisherID, book.PageCount}
+		return tx.QueryRowContext(ctx, query, args...).Scan(&book.ID)
+	})
+	if err != nil {
+		switch {
+		case b.Dialect.isUniqueViolation(err, "books", "isbn"):
+			return ErrDuplicateISBN
+		default:
+			return err
+		}
+	}
+	err = b.replaceContributors(ctx, tx, book)
+	if err != nil {
+		return err
+	}
+	if book.Series != nil {
+		err = b.replaceSeries(ctx, tx, book)
+		if err != nil {
+			return err
+		}
+	}
+	if len(book.Genres) > 0 {
+		err = b.replaceGenres(ctx, tx, book)
+		if err != nil {
+			return err
+		}
+	}
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+	book.Contributors, err = b.contributorsByBook(ctx, book.ID)
+	if err != nil {
+		return err
+	}
+	book.Series, err = b.seriesByBook(ctx, book.ID)
 	if err != nil {
 		return err
 	}
+	publisherName, err := b.publisherName(ctx, book.PublisherID)
+	if err != nil {
+		return err
+	}
+	book.setPublisher(publisherName)
+	return nil
+}
 
+func (b BookModel) Update(ctx context.Context, book *Book) (err error) {
+	query := `update books set title = $1, author_id = $2, publication_year = $3, slug = $4, description = $5, language = $6, isbn = nullif($7, ''), 
+                 format = $8, publisher_id = nullif($9, 0), page_count = $10, updated_at = current_timestamp where id = $11 returning updated_at`
+	ctx, cancel := context.WithTimeout(ctx, b.QueryTimeout)
+	defer cancel()
+	ctx, span := startSpan(ctx, b.Dialect, "BookModel.Update", query)
+	defer endSpan(span, &err)
+	if book.Language == "" {
+		book.Language = "simple"
+	}
+	book.setContributors()
+	book.setISBNs()
+	tx, err := b.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	var previous string
+	err = tx.QueryRowContext(ctx, `select slug from books where id = $1 and deleted_at is null`, book.ID).Scan(&previous)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrNoRecordFound
+		default:
+			return err
+		}
+	}
+	err = b.saveWithSlug(ctx, tx, book, previous, func() error {
+		args := []interface{}{book.Title, book.AuthorID, book.PublicationYear, book.Slug, book.Description, book.Language, book.ISBN13,
+			book.Format, book.PublisherID, book.PageCount, book.ID}
+		return tx.QueryRowContext(ctx, query, args...).Scan(&book.UpdatedAt)
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrNoRecordFound
+		case b.Dialect.isUniqueViolation(err, "books", "isbn"):
+			return ErrDuplicateISBN
+		default:
+			return err
+		}
+	}
+	err = b.keepSlug(ctx, tx, book, previous)
+	if err != nil {
+		return err
+	}
+	err = b.replaceContributors(ctx, tx, book)
+	if err != nil {
+		return err
+	}
+	if book.Series != nil {
+		err = b.replaceSeries(ctx, tx, book)
+		if err != nil {
+			return err
+		}
+	}
 	if len(book.Genres) > 0 {
-		query = `delete from books_genres where book_id = $1`
-		_, err := b.DB.ExecContext(ctx, query, book.ID)
+		err = b.replaceGenres(ctx, tx, book)
 		if err != nil {
-			return fmt.Errorf("failed to delete genres %s", err.Error())
+			return err
 		}
-		for i := range book.Genres {
-			query = `select id from genres where genre_name = $1`
-			var genre Genre
-			err = b.DB.QueryRowContext(ctx, query, book.Genres[i]).Scan(&genre.ID)
-			if err != nil {
-				return err
-			}
-			query = `insert into books_genres (book_id, genre_id) values ($1, $2)`
-			_, err := b.DB.ExecContext(ctx, query, book.ID, genre.ID)
-			if err != nil {
-				return fmt.Errorf("failed to delete genres %s", err.Error())
-			}
+	}
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+	book.Contributors, err = b.contributorsByBook(ctx, book.ID)
+	if err != nil {
+		return err
+	}
+	book.Series, err = b.seriesByBook(ctx, book.ID)
+	if err != nil {
+		return err
+	}
+	publisherName, err := b.publisherName(ctx, book.PublisherID)
+	if err != nil {
+		return err
+	}
+	book.setPublisher(publisherName)
+	return nil
+}
 
+// replaceGenres sets the genres of the book to its Genres.
+func (b BookModel) replaceGenres(ctx context.Context, tx *sql.Tx, book *Book) (err error) {
+	query := `delete from books_genres where book_id = $1`
+	ctx, span := startSpan(ctx, b.Dialect, "BookModel.replaceGenres", query)
+	defer endSpan(span, &err)
+	_, err = tx.ExecContext(ctx, query, book.ID)
+	if err != nil {
+		return err
+	}
+	query = `insert into books_genres (book_id, genre_id) select $1, id from genres where genre_name = $2`
+	for _, name := range book.Genres {
+		result, err := tx.ExecContext(ctx, query, book.ID, name)
+		if err != nil {
+			return err
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if n != 1 {
+			return fmt.Errorf("genre %q does not exist", name)
 		}
 	}
 	return nil
 }
 
-func (b BookModel) Delete(id int64) error {
-	query := `delete from books where id = $1`
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+// Delete moves the book to the trash with its reviews. It keeps its slug, ISBN, covers and work
+// until it is purged.
+func (b BookModel) Delete(ctx context.Context, id int64) (err error) {
+	query := `update books set deleted_at = $1 where id = $2 and deleted_at is null`
+	ctx, cancel := context.WithTimeout(ctx, b.QueryTimeout)
 	defer cancel()
-	result, err := b.DB.ExecContext(ctx, query, id)
+	ctx, span := startSpan(ctx, b.Dialect, "BookModel.Delete", query)
+	defer endSpan(span, &err)
+	tx, err := b.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	deletedAt := time.Now().UTC().Truncate(time.Second)
+	result, err := tx.ExecContext(ctx, query, deletedAt, id)
 	if err != nil {
 		return err
 	}
@@ -252,18 +636,60 @@ func (b BookModel) Delete(id int64) error {
 		return err
 	}
 	if row != 1 {
+		return ErrNoRecordFound
+	}
+	err = trashReviews(ctx, tx, TrashBook, id, deletedAt)
+	if err != nil {
 		return err
 	}
-	return nil
+	return tx.Commit()
+}
+
+// Restore takes the book out of the trash, with the reviews deleted along with it.
+func (b BookModel) Restore(ctx context.Context, id int64) (err error) {
+	query := `update books set deleted_at = null where id = $1 and deleted_at is not null`
+	ctx, cancel := context.WithTimeout(ctx, b.QueryTimeout)
+	defer cancel()
+	ctx, span := startSpan(ctx, b.Dialect, "BookModel.Restore", query)
+	defer endSpan(span, &err)
+	tx, err := b.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	result, err := tx.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+	row, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if row != 1 {
+		return ErrNoRecordFound
+	}
+	err = restoreReviews(ctx, tx, TrashBook, id)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
-func (b BookModel) GetAllAuthors(author string, filters Filters) ([]*Author, Metadata, error) {
-	query := fmt.Sprintf(`select count(*) over(), id, author_name from authors where (to_tsvector('simple', author_name) @@ plainto_tsquery('simple', $1) OR $1 = '') 
-			order by %s %s, id asc limit $2 offset $3`, filters.sortColumn(), filters.sortDirection())
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+func (b BookModel) GetAllAuthors(ctx context.Context, author string, filters Filters) (_ []*Author, _ Metadata, err error) {
+	keys, err := filters.keyset("authors", "a."+filters.sortColumn(), "a.id")
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	where, tail, pageArgs := keys.clauses(2)
+	query := fmt.Sprintf(`select %s, id, author_name, (select count(distinct bc.book_id) from book_contributors bc join books b on b.id = bc.book_id where bc.author_id = a.id and b.deleted_at is null) 
+			from authors a where %s and %s 
+			%s`, keys.countColumn(), b.Dialect.textSearch("authors", "a", "author_name", "$1"), where, tail)
+	ctx, cancel := context.WithTimeout(ctx, b.QueryTimeout)
 	defer cancel()
+	ctx, span := startSpan(ctx, b.Dialect, "BookModel.GetAllAuthors", query)
+	defer endSpan(span, &err)
 	var authors []*Author
-	args := []interface{}{author, filters.limit(), filters.offset()}
+	args := append([]interface{}{b.Dialect.searchArg(author)}, pageArgs...)
 	rows, err := b.DB.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, Metadata{}, err
@@ -272,22 +698,88 @@ func (b BookModel) GetAllAuthors(author string, filters Filters) ([]*Author, Met
 	totalRecords := 0
 	for rows.Next() {
 		var author Author
-		err := rows.Scan(&totalRecords, &author.ID, &author.AuthorName)
+		err := rows.Scan(&totalRecords, &author.ID, &author.AuthorName, &author.BookCount)
 		if err != nil {
 			return nil, Metadata{}, err
 		}
 		authors = append(authors, &author)
 	}
-	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	err = rows.Err()
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	authors, metadata := finish(keys, authors, totalRecords, func(author *Author) []any {
+		return []any{authorSortKey(author, filters.sortColumn()), author.ID}
+	})
 	return authors, metadata, nil
 
 }
-func (b BookModel) GetAllReviewsByUser(user string, filters Filters) ([]*Review, Metadata, error) {
-	query := fmt.Sprintf(`select count(*) over(), u.id, u.name, b.id, b.title, b.author_id, b.publication_year, r.id, r.rating, r.review, r.user_id, r.book_id from users u join reviews r on u.id = r.user_id join books b on b.id = r.book_id where (to_tsvector('simple', name) @@ plainto_tsquery('simple', $1) OR $1 = '') order by u.%s %s, u.id asc limit $2 offset $3`, filters.sortColumn(), filters.sortDirection())
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+
+func (b BookModel) GetAuthorByID(ctx context.Context, id int64) (_ *Author, err error) {
+	query := `select id, author_name, created_at, updated_at, version from authors where id = $1`
+	ctx, cancel := context.WithTimeout(ctx, b.QueryTimeout)
+	defer cancel()
+	ctx, span := startSpan(ctx, b.Dialect, "BookModel.GetAuthorByID", query)
+	defer endSpan(span, &err)
+	var author Author
+	err = b.DB.QueryRowContext(ctx, query, id).Scan(&author.ID, &author.AuthorName, &author.CreatedAt, &author.UpdatedAt, &author.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNoRecordFound
+		default:
+			return nil, err
+		}
+	}
+	return &author, nil
+}
+
+// GetAuthorByName returns the author with the name, ignoring case. The first one added wins when
+// several authors have it.
+func (b BookModel) GetAuthorByName(ctx context.Context, name string) (_ *Author, err error) {
+	query := `select id, author_name, created_at, updated_at, version from authors where lower(author_name) = lower($1) order by id limit 1`
+	ctx, cancel := context.WithTimeout(ctx, b.QueryTimeout)
+	defer cancel()
+	ctx, span := startSpan(ctx, b.Dialect, "BookModel.GetAuthorByName", query)
+	defer endSpan(span, &err)
+	var author Author
+	err = b.DB.QueryRowContext(ctx, query, name).Scan(&author.ID, &author.AuthorName, &author.CreatedAt, &author.UpdatedAt, &author.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNoRecordFound
+		default:
+			return nil, err
+		}
+	}
+	return &author, nil
+}
+
+func (b BookModel) InsertAuthor(ctx context.Context, author *Author) (err error) {
+	query := `insert into authors (author_name) values ($1) returning id, created_at, updated_at, version`
+	ctx, cancel := context.WithTimeout(ctx, b.QueryTimeout)
+	defer cancel()
+	ctx, span := startSpan(ctx, b.Dialect, "BookModel.InsertAuthor", query)
+	defer endSpan(span, &err)
+	return b.DB.QueryRowContext(ctx, query, author.AuthorName).Scan(&author.ID, &author.CreatedAt, &author.UpdatedAt, &author.Version)
+}
+
+// reviewSortColumns maps the sort values accepted for reviews to their columns.
+var reviewSortColumns = map[string]string{"id": "u.id", "user": "u.name"}
+
+func (b BookModel) GetAllReviewsByUser(ctx context.Context, user string, filters Filters) (_ []*Review, _ Metadata, err error) {
+	keys, err := filters.keyset("reviews", reviewSortColumns[filters.sortColumn()], "u.id", "r.id")
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	where, tail, pageArgs := keys.clauses(2)
+	query := fmt.Sprintf(`select %s, u.id, u.name, b.id, b.title, b.author_id, b.publication_year, r.id, r.rating, r.review, r.user_id, r.book_id from users u join reviews r on u.id = r.user_id join books b on b.id = r.book_id where r.deleted_at is null and %s and %s %s`, keys.countColumn(), b.Dialect.textSearch("users", "u", "name", "$1"), where, tail)
+	ctx, cancel := context.WithTimeout(ctx, b.QueryTimeout)
 	defer cancel()
+	ctx, span := startSpan(ctx, b.Dialect, "BookModel.GetAllReviewsByUser", query)
+	defer endSpan(span, &err)
 	var reviews []*Review
-	args := []interface{}{user, filters.limit(), filters.offset()}
+	args := append([]interface{}{b.Dialect.searchArg(user)}, pageArgs...)
 	rows, err := b.DB.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, Metadata{}, err
@@ -307,16 +799,80 @@ func (b BookModel) GetAllReviewsByUser(user string, filters Filters) ([]*Review,
 		reviews = append(reviews, &review)
 
 	}
-	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	err = rows.Err()
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	reviews, metadata := finish(keys, reviews, totalRecords, func(review *Review) []any {
+		return []any{reviewSortKey(review, filters.sortColumn()), review.UserID, review.ID}
+	})
 	return reviews, metadata, nil
 }
 
-func (b BookModel) GetReviewByID(id int64) (*Review, error) {
-	query := `select id, rating, review, book_id, user_id, version, created_at, updated_at from reviews where id = $1`
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+// The sort keys of the listings, in the order of the SQL columns they are read from. They are
+// the values cursors are made of.
+
+func bookSortKey(book *Book, column string) any {
+	switch column {
+	case "title":
+		return book.Title
+	case "publication_year":
+		return int64(book.PublicationYear)
+	default:
+		return book.ID
+	}
+}
+
+func authorSortKey(author *Author, column string) any {
+	switch column {
+	case "author_name":
+		return author.AuthorName
+	default:
+		return author.ID
+	}
+}
+
+func reviewSortKey(review *Review, column string) any {
+	switch column {
+	case "user":
+		return review.User
+	default:
+		return review.UserID
+	}
+}
+
+// GetReviewedBookIDs returns the books the user has reviewed, in order of ID.
+func (b BookModel) GetReviewedBookIDs(ctx context.Context, userID int64) (_ []int64, err error) {
+	query := `select distinct book_id from reviews where user_id = $1 and deleted_at is null order by book_id`
+	ctx, cancel := context.WithTimeout(ctx, b.QueryTimeout)
+	defer cancel()
+	ctx, span := startSpan(ctx, b.Dialect, "BookModel.GetReviewedBookIDs", query)
+	defer endSpan(span, &err)
+	rows, err := b.DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		err := rows.Scan(&id)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+func (b BookModel) GetReviewByID(ctx context.Context, id int64) (_ *Review, err error) {
+	query := `select id, rating, review, book_id, user_id, version, created_at, updated_at from reviews where id = $1 and deleted_at is null`
+	ctx, cancel := context.WithTimeout(ctx, b.QueryTimeout)
 	defer cancel()
+	ctx, span := startSpan(ctx, b.Dialect, "BookModel.GetReviewByID", query)
+	defer endSpan(span, &err)
 	var review Review
-	err := b.DB.QueryRowContext(ctx, query, id).Scan(&review.ID, &review.Rating, &review.Review, &review.BookID, &review.UserID, &review.Version, &review.CreatedAt, &review.UpdatedAt)
+	err = b.DB.QueryRowContext(ctx, query, id).Scan(&review.ID, &review.Rating, &review.Review, &review.BookID, &review.UserID, &review.Version, &review.CreatedAt, &review.UpdatedAt)
 	if err != nil {
 		switch {
 		case errors.Is(err, sql.ErrNoRows):
@@ -328,19 +884,43 @@ func (b BookModel) GetReviewByID(id int64) (*Review, error) {
 	return &review, nil
 }
 
-func (b BookModel) InsertReview(review *Review) error {
+// InsertReview adds the review, ErrNoRecordFound means its book or user does not exist or is in
+// the trash.
+func (b BookModel) InsertReview(ctx context.Context, review *Review) (err error) {
 	query := `insert into reviews (rating, review, book_id, user_id) values ($1, $2, $3, $4) returning id, version`
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, b.QueryTimeout)
 	defer cancel()
+	ctx, span := startSpan(ctx, b.Dialect, "BookModel.InsertReview", query)
+	defer endSpan(span, &err)
+	tx, err := b.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	var live bool
+	err = tx.QueryRowContext(ctx, `select exists (select 1 from books where id = $1 and deleted_at is null)
+			and exists (select 1 from users where id = $2 and deleted_at is null)`, review.BookID, review.UserID).Scan(&live)
+	if err != nil {
+		return err
+	}
+	if !live {
+		return ErrNoRecordFound
+	}
 	args := []interface{}{review.Rating, review.Review, review.BookID, review.UserID}
-	return b.DB.QueryRowContext(ctx, query, args...).Scan(&review.ID, &review.Version)
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&review.ID, &review.Version)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
-func (b BookModel) UpdateReview(review *Review) error {
-	query := `update reviews set rating = $1, review = $2, updated_at = now(), version = version + 1 where id = $3 and version = $4 returning version`
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+func (b BookModel) UpdateReview(ctx context.Context, review *Review) (err error) {
+	query := `update reviews set rating = $1, review = $2, updated_at = current_timestamp, version = version + 1 where id = $3 and version = $4 and deleted_at is null returning version`
+	ctx, cancel := context.WithTimeout(ctx, b.QueryTimeout)
 	defer cancel()
-	err := b.DB.QueryRowContext(ctx, query, review.Rating, review.Review, review.ID, review.Version).Scan(&review.Version)
+	ctx, span := startSpan(ctx, b.Dialect, "BookModel.UpdateReview", query)
+	defer endSpan(span, &err)
+	err = b.DB.QueryRowContext(ctx, query, review.Rating, review.Review, review.ID, review.Version).Scan(&review.Version)
 	if err != nil {
 		switch {
 		case errors.Is(err, sql.ErrNoRows):
@@ -352,18 +932,39 @@ func (b BookModel) UpdateReview(review *Review) error {
 	return nil
 }
 
-func (b BookModel) DeleteReview(id int64) error {
-	query := `delete from reviews where id = $1`
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+// DeleteReview moves the review to the trash.
+func (b BookModel) DeleteReview(ctx context.Context, id int64) (err error) {
+	query := `update reviews set deleted_at = $1 where id = $2 and deleted_at is null`
+	ctx, cancel := context.WithTimeout(ctx, b.QueryTimeout)
 	defer cancel()
+	ctx, span := startSpan(ctx, b.Dialect, "BookModel.DeleteReview", query)
+	defer endSpan(span, &err)
+	result, err := b.DB.ExecContext(ctx, query, time.Now().UTC().Truncate(time.Second), id)
+	if err != nil {
+		return err
+	}
+	row, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if row != 1 {
+		return ErrNoRecordFound
+	}
+	return nil
+}
+
+// RestoreReview takes the review out of the trash. A review whose book or user is in the trash
+// comes back with them, not on its own.
+func (b BookModel) RestoreReview(ctx context.Context, id int64) (err error) {
+	query := `update reviews set deleted_at = null, deleted_with = null where id = $1 and deleted_at is not null
+			and book_id in (select id from books where deleted_at is null) and user_id in (select id from users where deleted_at is null)`
+	ctx, cancel := context.WithTimeout(ctx, b.QueryTimeout)
+	defer cancel()
+	ctx, span := startSpan(ctx, b.Dialect, "BookModel.RestoreReview", query)
+	defer endSpan(span, &err)
 	result, err := b.DB.ExecContext(ctx, query, id)
 	if err != nil {
-		switch {
-		case errors.Is(err, sql.ErrNoRows):
-			return ErrNoRecordFound
-		default:
-			return err
-		}
+		return err
 	}
 	row, err := result.RowsAffected()
 	if err != nil {
@@ -375,10 +976,10 @@ func (b BookModel) DeleteReview(id int64) error {
 	return nil
 }
 
-func (b BookModel) genresByBook(id int64) ([]*Genre, error) {
+func (b BookModel) genresByBook(ctx context.Context, id int64) (_ []*Genre, err error) {
 	query := `select id, genre_name, created_at, updated_at from genres where id in (select genre_id from books_genres where book_id = $1) order by genre_name`
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
+	ctx, span := startSpan(ctx, b.Dialect, "BookModel.genresByBook", query)
+	defer endSpan(span, &err)
 	var genres []*Genre
 	rows, err := b.DB.QueryContext(ctx, query, id)
 	if err != nil {
@@ -389,6 +990,7 @@ func (b BookModel) genresByBook(id int64) ([]*Genre, error) {
 			return nil, err
 		}
 	}
+	defer rows.Close()
 	for rows.Next() {
 		var genre Genre
 		err := rows.Scan(&genre.ID, &genre.GenreName, &genre.CreatedAt, &genre.UpdatedAt)
@@ -400,11 +1002,54 @@ func (b BookModel) genresByBook(id int64) ([]*Genre, error) {
 	return genres, nil
 }
 
-func (b BookModel) reviewsByBook(id int64) ([]*Review, error) {
+// replaceContributors replaces the contributors of the book with book.Contributors, in their order.
+func (b BookModel) replaceContributors(ctx context.Context, tx *sql.Tx, book *Book) (err error) {
+	query := `delete from book_contributors where book_id = $1`
+	ctx, span := startSpan(ctx, b.Dialect, "BookModel.replaceContributors", query)
+	defer endSpan(span, &err)
+	_, err = tx.ExecContext(ctx, query, book.ID)
+	if err != nil {
+		return err
+	}
+	query = `insert into book_contributors (book_id, author_id, role, position) values ($1, $2, $3, $4)`
+	for i, contributor := range book.Contributors {
+		_, err := tx.ExecContext(ctx, query, book.ID, contributor.AuthorID, contributor.Role, i+1)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b BookModel) contributorsByBook(ctx context.Context, id int64) (_ []Contributor, err error) {
+	query := `select a.id, a.author_name, bc.role from book_contributors bc join authors a on a.id = bc.author_id where bc.book_id = $1 order by bc.position`
+	ctx, span := startSpan(ctx, b.Dialect, "BookModel.contributorsByBook", query)
+	defer endSpan(span, &err)
+	rows, err := b.DB.QueryContext(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var contributors []Contributor
+	for rows.Next() {
+		var contributor Contributor
+		err := rows.Scan(&contributor.AuthorID, &contributor.AuthorName, &contributor.Role)
+		if err != nil {
+			return nil, err
+		}
+		contributors = append(contributors, contributor)
+	}
+	return contributors, rows.Err()
+}
+
+// reviewsByBook returns the reviews of every edition of the book's work, reviews are about the
+// work rather than one printing of it.
+func (b BookModel) reviewsByBook(ctx context.Context, id int64) (_ []*Review, err error) {
 	query := `select u.id, u.name, r.id, r.rating, r.review, r.book_id, r.user_id, 
-       r.version, r.created_at, r.updated_at from users u join reviews r on u.id = r.user_id where book_id = $1`
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
+       r.version, r.created_at, r.updated_at from users u join reviews r on u.id = r.user_id 
+       where r.deleted_at is null and r.book_id in (select e.id from books e join books b on b.work_id = e.work_id where b.id = $1) order by r.id`
+	ctx, span := startSpan(ctx, b.Dialect, "BookModel.reviewsByBook", query)
+	defer endSpan(span, &err)
 	var reviews []*Review
 
 	rows, err := b.DB.QueryContext(ctx, query, id)
@@ -0,0 +1,2061 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newModelsFunc returns empty models for a single test, with only the default genres in place.
+type newModelsFunc func(t *testing.T) Models
+
+// runConformance checks that a Models implementation behaves the way the handlers expect.
+// Every backend runs the same suite, so they can be used interchangeably.
+func runConformance(t *testing.T, newModels newModelsFunc) {
+	t.Run("Authors", func(t *testing.T) { testAuthors(t, newModels(t)) })
+	t.Run("Books", func(t *testing.T) { testBooks(t, newModels(t)) })
+	t.Run("Slugs", func(t *testing.T) { testSlugs(t, newModels(t)) })
+	t.Run("Covers", func(t *testing.T) { testCovers(t, newModels(t)) })
+	t.Run("Contributors", func(t *testing.T) { testContributors(t, newModels(t)) })
+	t.Run("ISBN", func(t *testing.T) { testISBN(t, newModels(t)) })
+	t.Run("Works", func(t *testing.T) { testWorks(t, newModels(t)) })
+	t.Run("Series", func(t *testing.T) { testSeries(t, newModels(t)) })
+	t.Run("Publishers", func(t *testing.T) { testPublishers(t, newModels(t)) })
+	t.Run("BookListing", func(t *testing.T) { testBookListing(t, newModels(t)) })
+	t.Run("CursorPagination", func(t *testing.T) { testCursorPagination(t, newModels(t)) })
+	t.Run("Facets", func(t *testing.T) { testFacets(t, newModels(t)) })
+	t.Run("Search", func(t *testing.T) { testSearch(t, newModels(t)) })
+	t.Run("Autocomplete", func(t *testing.T) { testAutocomplete(t, newModels(t)) })
+	t.Run("Reviews", func(t *testing.T) { testReviews(t, newModels(t)) })
+	t.Run("Users", func(t *testing.T) { testUsers(t, newModels(t)) })
+	t.Run("Tokens", func(t *testing.T) { testTokens(t, newModels(t)) })
+	t.Run("Imports", func(t *testing.T) { testImports(t, newModels(t)) })
+	t.Run("LibraryImports", func(t *testing.T) { testLibraryImports(t, newModels(t)) })
+	t.Run("Trash", func(t *testing.T) { testTrash(t, newModels(t)) })
+}
+
+func filters(page, pageSize int, sort string, safeList ...string) Filters {
+	return Filters{Page: page, PageSize: pageSize, Sort: sort, SortSafeList: safeList}
+}
+
+var (
+	bookSorts   = []string{"id", "title", "publication_year", "-id", "-title", "-publication_year"}
+	authorSorts = []string{"id", "author_name", "-id", "-author_name"}
+	reviewSorts = []string{"id", "user", "-id", "-user"}
+)
+
+func insertAuthor(t *testing.T, m Models, name string) *Author {
+	t.Helper()
+	author := &Author{AuthorName: name}
+	err := m.Books.InsertAuthor(context.Background(), author)
+	if err != nil {
+		t.Fatalf("InsertAuthor(%q): %v", name, err)
+	}
+	return author
+}
+
+func insertBook(t *testing.T, m Models, title string, author *Author, year int) *Book {
+	t.Helper()
+	book := &Book{Title: title, AuthorID: int(author.ID), PublicationYear: year, Description: title + " description"}
+	err := m.Books.Insert(context.Background(), book)
+	if err != nil {
+		t.Fatalf("Insert(%q): %v", title, err)
+	}
+	return book
+}
+
+func insertUser(t *testing.T, m Models, name, email string) *User {
+	t.Helper()
+	user := &User{Name: name, Email: email}
+	err := user.Password.HashPassword("password", "pepper", 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = m.Users.Insert(context.Background(), user)
+	if err != nil {
+		t.Fatalf("Insert(%q): %v", email, err)
+	}
+	return user
+}
+
+func insertReview(t *testing.T, m Models, book *Book, user *User, rating int) *Review {
+	t.Helper()
+	review := &Review{Rating: rating, Review: "review", BookID: book.ID, UserID: user.ID}
+	err := m.Books.InsertReview(context.Background(), review)
+	if err != nil {
+		t.Fatalf("InsertReview: %v", err)
+	}
+	return review
+}
+
+func insertToken(t *testing.T, m Models, user *User) *Token {
+	t.Helper()
+	token, err := m.Tokens.GenerateToken(user.ID, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token.Email = user.Email
+	err = m.Tokens.InsertToken(context.Background(), token)
+	if err != nil {
+		t.Fatalf("InsertToken: %v", err)
+	}
+	return token
+}
+
+// purgeTrash deletes everything in the trash for good. The cutoff is given west of UTC, like
+// the local time of a server there.
+func purgeTrash(t *testing.T, m Models) *Purged {
+	t.Helper()
+	purged, err := m.Trash.Purge(context.Background(), time.Now().Add(time.Second).In(time.FixedZone("UTC-5", -5*60*60)))
+	if err != nil {
+		t.Fatalf("Purge: %v", err)
+	}
+	return purged
+}
+
+func bookIDs(books []*Book) []int64 {
+	var ids []int64
+	for _, book := range books {
+		ids = append(ids, book.ID)
+	}
+	return ids
+}
+
+func equalIDs(a, b []int64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func testAuthors(t *testing.T, m Models) {
+	ctx := context.Background()
+	tolkien := insertAuthor(t, m, "Tolkien")
+	herbert := insertAuthor(t, m, "Frank Herbert")
+	insertAuthor(t, m, "Brian Herbert")
+
+	if tolkien.ID == 0 || tolkien.Version != 1 {
+		t.Errorf("InsertAuthor set id %d version %d; want an id and version 1", tolkien.ID, tolkien.Version)
+	}
+
+	authors, metadata, err := m.Books.GetAllAuthors(ctx, "herbert", filters(1, 20, "-author_name", authorSorts...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(authors) != 2 || authors[0].ID != herbert.ID || authors[0].AuthorName != "Frank Herbert" {
+		t.Errorf("GetAllAuthors(herbert) = %+v; want Frank Herbert then Brian Herbert", authors)
+	}
+	want := Metadata{CurrentPage: 1, PageSize: 20, FirstPage: 1, LastPage: 1, TotalRecords: 2}
+	if metadata != want {
+		t.Errorf("metadata = %+v; want %+v", metadata, want)
+	}
+
+	authors, metadata, err = m.Books.GetAllAuthors(ctx, "", filters(2, 2, "id", authorSorts...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(authors) != 1 || metadata.LastPage != 2 || metadata.TotalRecords != 3 {
+		t.Errorf("page 2 = %d authors, %+v; want 1 author of 3 on the last of 2 pages", len(authors), metadata)
+	}
+
+	authors, metadata, err = m.Books.GetAllAuthors(ctx, "", filters(3, 2, "id", authorSorts...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(authors) != 0 || metadata != (Metadata{}) {
+		t.Errorf("page past the end = %d authors, %+v; want none and empty metadata", len(authors), metadata)
+	}
+
+	insertAuthor(t, m, "FRANK HERBERT")
+	author, err := m.Books.GetAuthorByName(ctx, "frank herbert")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if author.ID != herbert.ID || author.AuthorName != "Frank Herbert" {
+		t.Errorf("GetAuthorByName(frank herbert) = %+v; want the first Frank Herbert", author)
+	}
+	_, err = m.Books.GetAuthorByName(ctx, "Herbert")
+	if !errors.Is(err, ErrNoRecordFound) {
+		t.Errorf("GetAuthorByName(part of a name) error = %v; want ErrNoRecordFound", err)
+	}
+	author, err = m.Books.GetAuthorByID(ctx, herbert.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if author.AuthorName != "Frank Herbert" {
+		t.Errorf("GetAuthorByID = %+v; want Frank Herbert", author)
+	}
+	_, err = m.Books.GetAuthorByID(ctx, herbert.ID+100)
+	if !errors.Is(err, ErrNoRecordFound) {
+		t.Errorf("GetAuthorByID(missing) error = %v; want ErrNoRecordFound", err)
+	}
+}
+
+func testBooks(t *testing.T, m Models) {
+	ctx := context.Background()
+	author := insertAuthor(t, m, "Frank Herbert")
+
+	err := m.Books.Insert(ctx, &Book{Title: "Orphan", AuthorID: int(author.ID) + 100, PublicationYear: 2000, Description: "d"})
+	if err == nil {
+		t.Error("Insert with an unknown author succeeded")
+	}
+
+	book := insertBook(t, m, "Dune Messiah", author, 1969)
+	if book.Slug != "dune-messiah" {
+		t.Errorf("Insert set slug %q; want dune-messiah", book.Slug)
+	}
+	got, err := m.Books.GetByID(ctx, book.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Title != "Dune Messiah" || got.Slug != "dune-messiah" || got.PublicationYear != 1969 || got.Author.AuthorName != "Frank Herbert" {
+		t.Errorf("GetByID = %+v", got)
+	}
+	if got.Genres != nil || got.Reviews != nil {
+		t.Errorf("new book has genres %v and reviews %v; want none", got.Genres, got.Reviews)
+	}
+
+	err = m.Books.Insert(ctx, &Book{Title: "Poems", AuthorID: int(author.ID), PublicationYear: 2000, Description: "d", Genres: []string{"Poetry"}})
+	if err == nil {
+		t.Error("Insert with an unknown genre succeeded")
+	}
+	withGenres := &Book{Title: "Chapterhouse", AuthorID: int(author.ID), PublicationYear: 1985, Description: "d", Genres: []string{"Science Fiction", "Classic"}}
+	err = m.Books.Insert(ctx, withGenres)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chapterhouse, err := m.Books.GetByID(ctx, withGenres.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(chapterhouse.Genres) != "[Classic Science Fiction]" {
+		t.Errorf("Insert with genres saved %v; want [Classic Science Fiction]", chapterhouse.Genres)
+	}
+
+	_, err = m.Books.GetByID(ctx, book.ID+100)
+	if !errors.Is(err, ErrNoRecordFound) {
+		t.Errorf("GetByID(missing) error = %v; want ErrNoRecordFound", err)
+	}
+
+	got.Title = "Dune"
+	got.Genres = []string{"Science Fiction", "Classic"}
+	err = m.Books.Update(ctx, got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.UpdatedAt.IsZero() || got.Slug != "dune" {
+		t.Errorf("Update set UpdatedAt %v and slug %q; want a time and dune", got.UpdatedAt, got.Slug)
+	}
+	got, err = m.Books.GetBySlug(ctx, "dune")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != book.ID || len(got.Genres) != 2 || got.Genres[0] != "Classic" || got.Genres[1] != "Science Fiction" {
+		t.Errorf("GetBySlug after update = %+v; want the book with sorted genres", got)
+	}
+	got, err = m.Books.GetBySlug(ctx, "dune-messiah")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != book.ID || got.Slug != "dune" {
+		t.Errorf("GetBySlug(old slug) = %+v; want the book with its new slug", got)
+	}
+
+	got.Genres = nil
+	err = m.Books.Update(ctx, got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err = m.Books.GetByID(ctx, book.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Genres) != 2 {
+		t.Errorf("Update without genres changed the genres to %v", got.Genres)
+	}
+
+	err = m.Books.Update(ctx, &Book{ID: book.ID + 100, Title: "Missing", AuthorID: int(author.ID), PublicationYear: 1, Description: "d"})
+	if !errors.Is(err, ErrNoRecordFound) {
+		t.Errorf("Update(missing) error = %v; want ErrNoRecordFound", err)
+	}
+
+	user := insertUser(t, m, "Alice", "alice@example.com")
+	review := insertReview(t, m, book, user, 5)
+	err = m.Books.Delete(ctx, book.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = m.Books.GetByID(ctx, book.ID)
+	if !errors.Is(err, ErrNoRecordFound) {
+		t.Errorf("GetByID after Delete error = %v; want ErrNoRecordFound", err)
+	}
+	_, err = m.Books.GetReviewByID(ctx, review.ID)
+	if !errors.Is(err, ErrNoRecordFound) {
+		t.Errorf("review of a deleted book: error = %v; want ErrNoRecordFound", err)
+	}
+	err = m.Books.Delete(ctx, book.ID)
+	if !errors.Is(err, ErrNoRecordFound) {
+		t.Errorf("Delete(deleted) error = %v; want ErrNoRecordFound", err)
+	}
+}
+
+func testBookListing(t *testing.T, m Models) {
+	ctx := context.Background()
+	herbert := insertAuthor(t, m, "Frank Herbert")
+	tolkien := insertAuthor(t, m, "Tolkien")
+	dune := insertBook(t, m, "Dune", herbert, 1965)
+	messiah := insertBook(t, m, "Dune Messiah", herbert, 1969)
+	hobbit := insertBook(t, m, "The Hobbit", tolkien, 1937)
+	user := insertUser(t, m, "Alice", "alice@example.com")
+	insertReview(t, m, dune, user, 5)
+
+	books, metadata, err := m.Books.GetAll(ctx, "", 0, filters(1, 20, "-publication_year", bookSorts...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids := bookIDs(books); !equalIDs(ids, []int64{messiah.ID, dune.ID, hobbit.ID}) {
+		t.Errorf("GetAll sorted by -publication_year = %v", ids)
+	}
+	if metadata.TotalRecords != 3 || metadata.LastPage != 1 {
+		t.Errorf("metadata = %+v", metadata)
+	}
+	if books[1].Author.AuthorName != "Frank Herbert" || len(books[1].Reviews) != 1 || books[1].Reviews[0].User != "Alice" {
+		t.Errorf("listed book = %+v; want its author and reviews", books[1])
+	}
+
+	books, _, err = m.Books.GetAll(ctx, "DUNE", 0, filters(1, 20, "title", bookSorts...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids := bookIDs(books); !equalIDs(ids, []int64{dune.ID, messiah.ID}) {
+		t.Errorf("GetAll(DUNE) = %v; want both Dune books", ids)
+	}
+
+	books, _, err = m.Books.GetAll(ctx, "dune hobbit", 0, filters(1, 20, "id", bookSorts...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(books) != 0 {
+		t.Errorf("GetAll(dune hobbit) = %v; want every word to match", bookIDs(books))
+	}
+
+	books, metadata, err = m.Books.GetAll(ctx, "", 0, filters(2, 2, "id", bookSorts...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Metadata{CurrentPage: 2, PageSize: 2, FirstPage: 1, LastPage: 2, TotalRecords: 3}
+	if ids := bookIDs(books); !equalIDs(ids, []int64{hobbit.ID}) || metadata != want {
+		t.Errorf("page 2 = %v, %+v; want [%d], %+v", ids, metadata, hobbit.ID, want)
+	}
+}
+
+func testReviews(t *testing.T, m Models) {
+	ctx := context.Background()
+	author := insertAuthor(t, m, "Frank Herbert")
+	book := insertBook(t, m, "Dune", author, 1965)
+	bob := insertUser(t, m, "Bob", "bob@example.com")
+	alice := insertUser(t, m, "Alice", "alice@example.com")
+
+	err := m.Books.InsertReview(ctx, &Review{Rating: 1, Review: "r", BookID: book.ID + 100, UserID: bob.ID})
+	if err == nil {
+		t.Error("InsertReview for an unknown book succeeded")
+	}
+
+	first := insertReview(t, m, book, bob, 4)
+	second := insertReview(t, m, book, alice, 2)
+	third := insertReview(t, m, book, bob, 3)
+	if first.ID == 0 || first.Version != 1 {
+		t.Errorf("InsertReview set id %d version %d; want an id and version 1", first.ID, first.Version)
+	}
+
+	review, err := m.Books.GetReviewByID(ctx, first.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if review.Rating != 4 || review.BookID != book.ID || review.UserID != bob.ID || review.Version != 1 {
+		t.Errorf("GetReviewByID = %+v", review)
+	}
+
+	review.Rating = 5
+	err = m.Books.UpdateReview(ctx, review)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if review.Version != 2 {
+		t.Errorf("UpdateReview set version %d; want 2", review.Version)
+	}
+	stale := *review
+	stale.Version = 1
+	err = m.Books.UpdateReview(ctx, &stale)
+	if !errors.Is(err, ErrNoRecordFound) {
+		t.Errorf("UpdateReview with a stale version: error = %v; want ErrNoRecordFound", err)
+	}
+
+	reviews, metadata, err := m.Books.GetAllReviewsByUser(ctx, "", filters(1, 20, "user", reviewSorts...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []int64
+	for _, r := range reviews {
+		ids = append(ids, r.ID)
+	}
+	if !equalIDs(ids, []int64{second.ID, first.ID, third.ID}) || metadata.TotalRecords != 3 {
+		t.Errorf("GetAllReviewsByUser sorted by user = %v, %+v", ids, metadata)
+	}
+	if reviews[0].User != "Alice" || reviews[0].Book != "Dune" {
+		t.Errorf("listed review = %+v; want the user and book names", reviews[0])
+	}
+
+	reviews, _, err = m.Books.GetAllReviewsByUser(ctx, "bob", filters(1, 20, "-id", reviewSorts...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reviews) != 2 || reviews[0].Rating != 5 {
+		t.Errorf("GetAllReviewsByUser(bob) = %+v; want Bob's two reviews", reviews)
+	}
+
+	err = m.Books.DeleteReview(ctx, first.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = m.Books.DeleteReview(ctx, first.ID)
+	if !errors.Is(err, ErrNoRecordFound) {
+		t.Errorf("DeleteReview(deleted) error = %v; want ErrNoRecordFound", err)
+	}
+}
+
+func testUsers(t *testing.T, m Models) {
+	ctx := context.Background()
+	bob := insertUser(t, m, "Bob", "bob@example.com")
+	alice := insertUser(t, m, "Alice", "alice@example.com")
+	if bob.ID == 0 || bob.Version != 1 || bob.CreatedAt.IsZero() {
+		t.Errorf("Insert set %+v; want id, version 1 and created_at", bob)
+	}
+
+	err := m.Users.Insert(ctx, &User{Name: "Bobby", Email: "bob@example.com", Password: bob.Password})
+	if !errors.Is(err, ErrDuplicateEmail) {
+		t.Errorf("Insert(duplicate email) error = %v; want ErrDuplicateEmail", err)
+	}
+
+	admin := &User{Name: "Admin", Email: "admin@example.com", Password: bob.Password, AccountType: "admin"}
+	err = m.Users.Insert(ctx, admin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	admin, err = m.Users.GetByID(ctx, admin.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if admin.AccountType != "admin" {
+		t.Errorf("AccountType = %q; want admin", admin.AccountType)
+	}
+	err = m.Users.Delete(ctx, admin.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user, err := m.Users.GetByEmail(ctx, "bob@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.ID != bob.ID || user.AccountType != "user" {
+		t.Errorf("GetByEmail = %+v", user)
+	}
+	ok, err := user.Password.CheckPassword("password", "pepper")
+	if err != nil || !ok {
+		t.Errorf("CheckPassword = %v, %v; want the stored hash to match", ok, err)
+	}
+	_, err = m.Users.GetByEmail(ctx, "nobody@example.com")
+	if !errors.Is(err, ErrNoRecordFound) {
+		t.Errorf("GetByEmail(missing) error = %v; want ErrNoRecordFound", err)
+	}
+	_, err = m.Users.GetByID(ctx, 0)
+	if !errors.Is(err, ErrNoRecordFound) {
+		t.Errorf("GetByID(0) error = %v; want ErrNoRecordFound", err)
+	}
+
+	user.Email = "alice@example.com"
+	err = m.Users.Update(ctx, user)
+	if !errors.Is(err, ErrDuplicateEmail) {
+		t.Errorf("Update(duplicate email) error = %v; want ErrDuplicateEmail", err)
+	}
+	user.Name = "Robert"
+	user.Email = "robert@example.com"
+	err = m.Users.Update(ctx, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user, err = m.Users.GetByID(ctx, bob.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Name != "Robert" || user.Email != "robert@example.com" || user.Version != 2 {
+		t.Errorf("GetByID after Update = %+v", user)
+	}
+	err = m.Users.Update(ctx, &User{ID: bob.ID + 100, Name: "x", Email: "x@example.com"})
+	if !errors.Is(err, ErrNoRecordFound) {
+		t.Errorf("Update(missing) error = %v; want ErrNoRecordFound", err)
+	}
+
+	token := insertToken(t, m, alice)
+	users, err := m.Users.GetAll(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 2 || users[0].Name != "Alice" || users[0].Token.Token != token.Token || users[1].Token.ID != 0 {
+		t.Errorf("GetAll = %+v; want users by name with their tokens", users)
+	}
+	users, err = m.Users.GetAllLoggedIn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 1 || users[0].ID != alice.ID || users[0].Token.ID != token.ID {
+		t.Errorf("GetAllLoggedIn = %+v; want only Alice", users)
+	}
+
+	err = m.Users.Delete(ctx, alice.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = m.Tokens.GetByToken(ctx, token.Token)
+	if !errors.Is(err, ErrNoRecordFound) {
+		t.Errorf("token of a deleted user: error = %v; want ErrNoRecordFound", err)
+	}
+	err = m.Users.Delete(ctx, alice.ID)
+	if !errors.Is(err, ErrNoRecordFound) {
+		t.Errorf("Delete(deleted) error = %v; want ErrNoRecordFound", err)
+	}
+}
+
+func testTokens(t *testing.T, m Models) {
+	ctx := context.Background()
+	user := insertUser(t, m, "Alice", "alice@example.com")
+
+	first := insertToken(t, m, user)
+	if len(first.Token) != 26 || len(first.TokenHash) != 32 || first.ID == 0 {
+		t.Errorf("token = %+v; want a 26 character token, its hash and an id", first)
+	}
+
+	second := insertToken(t, m, user)
+	_, err := m.Tokens.GetByToken(ctx, first.Token)
+	if !errors.Is(err, ErrNoRecordFound) {
+		t.Errorf("GetByToken(replaced token) error = %v; want ErrNoRecordFound", err)
+	}
+
+	token, err := m.Tokens.GetByToken(ctx, second.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.ID != second.ID || token.UserID != user.ID || token.Email != user.Email {
+		t.Errorf("GetByToken = %+v", token)
+	}
+	if token.Expiry.Before(time.Now()) {
+		t.Errorf("expiry %v is in the past", token.Expiry)
+	}
+
+	owner, err := m.Tokens.GetUserForToken(ctx, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if owner.ID != user.ID || owner.Token.Token != second.Token || owner.AccountType != "user" {
+		t.Errorf("GetUserForToken = %+v", owner)
+	}
+
+	err = m.Tokens.DeleteToken(ctx, token.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = m.Tokens.GetUserForToken(ctx, token)
+	if !errors.Is(err, ErrNoRecordFound) {
+		t.Errorf("GetUserForToken after logout: error = %v; want ErrNoRecordFound", err)
+	}
+	err = m.Tokens.DeleteToken(ctx, token.ID)
+	if !errors.Is(err, ErrNoRecordFound) {
+		t.Errorf("DeleteToken(deleted) error = %v; want ErrNoRecordFound", err)
+	}
+}
+
+// walk lists every page of a listing by following the next cursors from the first page, then goes
+// back from the last page with the prev cursors. It returns the IDs in the order they were listed.
+func walk[T any](t *testing.T, list func(Filters) ([]T, Metadata, error), f Filters, id func(T) int64) (forward, backward []int64) {
+	t.Helper()
+	var last []int64
+	var prev string
+	for pages := 0; ; pages++ {
+		if pages > 20 {
+			t.Fatal("the next cursors do not come to an end")
+		}
+		rows, metadata, err := list(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		last = nil
+		for _, row := range rows {
+			last = append(last, id(row))
+		}
+		forward = append(forward, last...)
+		prev = metadata.Prev
+		if metadata.Next == "" {
+			break
+		}
+		f.Page, f.Cursor = 1, metadata.Next
+	}
+
+	for pages := 0; prev != ""; pages++ {
+		if pages > 20 {
+			t.Fatal("the prev cursors do not come to an end")
+		}
+		f.Cursor = prev
+		rows, metadata, err := list(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ids []int64
+		for _, row := range rows {
+			ids = append(ids, id(row))
+		}
+		backward = append(ids, backward...)
+		prev = metadata.Prev
+	}
+	return forward, append(backward, last...)
+}
+
+func testCursorPagination(t *testing.T, m Models) {
+	ctx := context.Background()
+	codec := NewCursorCodec([]byte("test-secret"))
+	herbert := insertAuthor(t, m, "Frank Herbert")
+	insertAuthor(t, m, "Frank Herbert")
+	insertAuthor(t, m, "Tolkien")
+	insertAuthor(t, m, "Le Guin")
+	insertAuthor(t, m, "Asimov")
+	// Equal titles and years, so that the pages split ties.
+	for _, title := range []string{"Dune", "Dune", "Emma", "Dune", "Arrival", "Emma", "Zen"} {
+		insertBook(t, m, title, herbert, 1965+len(title)%2)
+	}
+	alice := insertUser(t, m, "Alice", "alice@example.com")
+	bob := insertUser(t, m, "Bob", "bob@example.com")
+	books, _, err := m.Books.GetAll(ctx, "", 0, filters(1, 100, "id", bookSorts...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, book := range books {
+		insertReview(t, m, book, bob, 1)
+		if i%2 == 0 {
+			insertReview(t, m, book, alice, 2)
+		}
+	}
+
+	paged := func(sort string, safeList []string) Filters {
+		f := filters(1, 2, sort, safeList...)
+		f.Cursors = codec
+		return f
+	}
+	listBooks := func(f Filters) ([]*Book, Metadata, error) { return m.Books.GetAll(ctx, "", 0, f) }
+	for _, sort := range bookSorts {
+		all, _, err := m.Books.GetAll(ctx, "", 0, filters(1, 100, sort, bookSorts...))
+		if err != nil {
+			t.Fatal(err)
+		}
+		forward, backward := walk(t, listBooks, paged(sort, bookSorts), func(b *Book) int64 { return b.ID })
+		if !equalIDs(forward, bookIDs(all)) || !equalIDs(backward, bookIDs(all)) {
+			t.Errorf("books sorted by %s: forward %v, backward %v; want %v", sort, forward, backward, bookIDs(all))
+		}
+	}
+
+	listAuthors := func(f Filters) ([]*Author, Metadata, error) { return m.Books.GetAllAuthors(ctx, "", f) }
+	for _, sort := range authorSorts {
+		all, _, err := listAuthors(filters(1, 100, sort, authorSorts...))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var want []int64
+		for _, a := range all {
+			want = append(want, a.ID)
+		}
+		forward, backward := walk(t, listAuthors, paged(sort, authorSorts), func(a *Author) int64 { return a.ID })
+		if !equalIDs(forward, want) || !equalIDs(backward, want) {
+			t.Errorf("authors sorted by %s: forward %v, backward %v; want %v", sort, forward, backward, want)
+		}
+	}
+
+	listReviews := func(f Filters) ([]*Review, Metadata, error) { return m.Books.GetAllReviewsByUser(ctx, "", f) }
+	for _, sort := range reviewSorts {
+		all, _, err := listReviews(filters(1, 100, sort, reviewSorts...))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var want []int64
+		for _, r := range all {
+			want = append(want, r.ID)
+		}
+		forward, backward := walk(t, listReviews, paged(sort, reviewSorts), func(r *Review) int64 { return r.ID })
+		if !equalIDs(forward, want) || !equalIDs(backward, want) {
+			t.Errorf("reviews sorted by %s: forward %v, backward %v; want %v", sort, forward, backward, want)
+		}
+	}
+
+	// A page of a cursor does not shift when rows are added before it.
+	f := paged("title", bookSorts)
+	first, metadata, err := m.Books.GetAll(ctx, "", 0, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if metadata.Next == "" || metadata.Prev != "" || metadata.TotalRecords != 7 {
+		t.Errorf("first page metadata = %+v; want a next cursor and the total", metadata)
+	}
+	insertBook(t, m, "Aaa", herbert, 2000)
+	f.Cursor = metadata.Next
+	second, metadata, err := m.Books.GetAll(ctx, "", 0, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(second) != 2 || second[0].ID == first[1].ID || second[0].Title != "Dune" {
+		t.Errorf("page after the cursor = %+v; want the two books following the first page", second)
+	}
+	if metadata.TotalRecords != 0 || metadata.CurrentPage != 0 || metadata.PageSize != 2 {
+		t.Errorf("cursor page metadata = %+v; want no page numbers", metadata)
+	}
+
+	for name, f := range map[string]Filters{
+		"tampered":    {Page: 1, PageSize: 2, Sort: "title", SortSafeList: bookSorts, Cursors: codec, Cursor: f.Cursor + "x"},
+		"other sort":  {Page: 1, PageSize: 2, Sort: "id", SortSafeList: bookSorts, Cursors: codec, Cursor: f.Cursor},
+		"other codec": {Page: 1, PageSize: 2, Sort: "title", SortSafeList: bookSorts, Cursors: NewCursorCodec([]byte("other")), Cursor: f.Cursor},
+	} {
+		_, _, err := m.Books.GetAll(ctx, "", 0, f)
+		if !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("GetAll with a %s cursor: error = %v; want ErrInvalidCursor", name, err)
+		}
+	}
+	_, _, err = m.Books.GetAllAuthors(ctx, "", Filters{Page: 1, PageSize: 2, Sort: "title", SortSafeList: []string{"title"}, Cursors: codec, Cursor: f.Cursor})
+	if !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("GetAllAuthors with a cursor of the books: error = %v; want ErrInvalidCursor", err)
+	}
+}
+
+func testSearch(t *testing.T, m Models) {
+	ctx := context.Background()
+	herbert := insertAuthor(t, m, "Frank Herbert")
+	tolkien := insertAuthor(t, m, "Tolkien")
+	describe := func(book *Book, description string, genres ...string) {
+		t.Helper()
+		book.Description = description
+		book.Genres = genres
+		err := m.Books.Update(ctx, book)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	dune := insertBook(t, m, "Dune", herbert, 1965)
+	describe(dune, "A desert planet and its spice", "Science Fiction")
+	children := insertBook(t, m, "Children of Dune", herbert, 1976)
+	describe(children, "The twins of Paul Atreides", "Science Fiction")
+	hobbit := insertBook(t, m, "The Hobbit", tolkien, 1937)
+	describe(hobbit, "Bilbo crosses <b>mountains</b> & forests,\n\nand a dune or two", "Fantasy")
+
+	search := func(query string) []int64 {
+		t.Helper()
+		results, _, err := m.Books.Search(ctx, query, filters(1, 20, "rank", "rank"))
+		if err != nil {
+			t.Fatalf("Search(%q): %v", query, err)
+		}
+		var ids []int64
+		for _, result := range results {
+			ids = append(ids, result.Book.ID)
+		}
+		return ids
+	}
+
+	results, metadata, err := m.Books.Search(ctx, "dune", filters(1, 20, "rank", "rank"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 3 || results[2].Book.ID != hobbit.ID || metadata.TotalRecords != 3 {
+		t.Fatalf("Search(dune) = %v, %+v; want the titles before the description", search("dune"), metadata)
+	}
+	if results[0].Rank <= results[2].Rank {
+		t.Errorf("ranks = %v, %v; want a title match to rank higher", results[0].Rank, results[2].Rank)
+	}
+	if results[0].Book.Author.AuthorName != "Frank Herbert" || len(results[0].Book.Genres) != 1 || results[0].Book.Language != "simple" {
+		t.Errorf("result book = %+v; want its author, genres and language", results[0].Book)
+	}
+	if !strings.Contains(results[2].Highlights.Description, "<mark>dune</mark>") || results[2].Highlights.Title != "The Hobbit" {
+		t.Errorf("highlights = %+v; want the match in the description marked", results[2].Highlights)
+	}
+	if description := results[2].Highlights.Description; strings.Contains(description, "<b>") ||
+		!strings.Contains(description, "&lt;b&gt;mountains&lt;/b&gt; &amp; forests,\n\nand") {
+		t.Errorf("description highlight = %q; want it escaped with its whitespace", description)
+	}
+	var childrenResult *SearchResult
+	for _, result := range results {
+		if result.Book.ID == children.ID {
+			childrenResult = result
+		}
+	}
+	if childrenResult == nil || childrenResult.Highlights.Title != "Children of <mark>Dune</mark>" {
+		t.Errorf("highlights = %+v; want the match in the title marked", childrenResult)
+	}
+
+	tests := []struct {
+		query string
+		want  []int64
+	}{
+		{"herbert", []int64{dune.ID, children.ID}},
+		{"fantasy", []int64{hobbit.ID}},
+		{"spice herbert", []int64{dune.ID}},
+		{"spice tolkien", nil},
+		{"silmarillion", nil},
+		// Every dialect searches the words alone, whatever surrounds them.
+		{`"SPICE" -herbert`, []int64{dune.ID}},
+		{"dune or hobbit", []int64{hobbit.ID}},
+	}
+	for _, tt := range tests {
+		if ids := search(tt.query); !equalIDs(ids, tt.want) {
+			t.Errorf("Search(%q) = %v; want %v", tt.query, ids, tt.want)
+		}
+	}
+
+	_, metadata, err = m.Books.Search(ctx, "dune", filters(2, 2, "rank", "rank"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Metadata{CurrentPage: 2, PageSize: 2, FirstPage: 1, LastPage: 2, TotalRecords: 3}
+	if metadata != want {
+		t.Errorf("page 2 metadata = %+v; want %+v", metadata, want)
+	}
+
+	dune.Title = "Arrakis"
+	err = m.Books.Update(ctx, dune)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = m.Books.Delete(ctx, hobbit.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids := search("dune"); !equalIDs(ids, []int64{children.ID}) {
+		t.Errorf("Search(dune) after an update and a delete = %v; want [%d]", ids, children.ID)
+	}
+	if ids := search("arrakis"); !equalIDs(ids, []int64{dune.ID}) {
+		t.Errorf("Search(arrakis) = %v; want the new title to be searched", ids)
+	}
+
+	english := &Book{Title: "Running", AuthorID: int(tolkien.ID), PublicationYear: 2000, Description: "d", Language: "english"}
+	err = m.Books.Insert(ctx, english)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := m.Books.GetByID(ctx, english.ID)
+	if err != nil || got.Language != "english" {
+		t.Errorf("GetByID = %+v, %v; want the language", got, err)
+	}
+}
+
+func testAutocomplete(t *testing.T, m Models) {
+	ctx := context.Background()
+	herbert := insertAuthor(t, m, "Frank Herbert")
+	tolkien := insertAuthor(t, m, "J.R.R. Tolkien")
+	dune := insertBook(t, m, "Dune", herbert, 1965)
+	children := insertBook(t, m, "Children of Dune", herbert, 1976)
+	hobbit := insertBook(t, m, "The Hobbit", tolkien, 1937)
+	insertBook(t, m, "100% Pure_Fiction", tolkien, 2000)
+
+	type suggestion struct {
+		kind string
+		id   int64
+	}
+	complete := func(search string, limit int) []suggestion {
+		t.Helper()
+		suggestions, err := m.Books.Autocomplete(ctx, search, limit)
+		if err != nil {
+			t.Fatalf("Autocomplete(%q): %v", search, err)
+		}
+		var got []suggestion
+		for _, s := range suggestions {
+			got = append(got, suggestion{s.Type, s.ID})
+		}
+		return got
+	}
+
+	tests := []struct {
+		search string
+		limit  int
+		want   []suggestion
+	}{
+		// Titles starting with the search come before a word of a title starting with it.
+		{"du", 10, []suggestion{{"title", dune.ID}, {"title", children.ID}}},
+		{"DUNE", 1, []suggestion{{"title", dune.ID}}},
+		{"herb", 10, []suggestion{{"author", herbert.ID}}},
+		{"tolk", 10, []suggestion{{"author", tolkien.ID}}},
+		// Typos.
+		{"hobit", 10, []suggestion{{"title", hobbit.ID}}},
+		{"tolkin", 10, []suggestion{{"author", tolkien.ID}}},
+		// Like wildcards are matched literally.
+		{"%", 10, nil},
+		{"_", 10, nil},
+		{"xyz", 10, nil},
+	}
+	for _, tt := range tests {
+		if got := complete(tt.search, tt.limit); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("Autocomplete(%q) = %v; want %v", tt.search, got, tt.want)
+		}
+	}
+
+	suggestions, err := m.Books.Autocomplete(ctx, "dune", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(suggestions) != 2 || suggestions[0].Text != "Dune" || suggestions[0].Score <= suggestions[1].Score {
+		t.Errorf("Autocomplete(dune) = %+v; want Dune scored first", suggestions)
+	}
+}
+
+func testContributors(t *testing.T, m Models) {
+	ctx := context.Background()
+	gaiman := insertAuthor(t, m, "Neil Gaiman")
+	pratchett := insertAuthor(t, m, "Terry Pratchett")
+	kidby := insertAuthor(t, m, "Paul Kidby")
+	insertAuthor(t, m, "Nobody")
+
+	single := insertBook(t, m, "Coraline", gaiman, 2002)
+	if len(single.Contributors) != 1 || single.Contributors[0] != (Contributor{AuthorID: gaiman.ID, AuthorName: "Neil Gaiman", Role: "author"}) {
+		t.Errorf("Insert without contributors set %+v; want the author as the only contributor", single.Contributors)
+	}
+
+	omens := &Book{Title: "Good Omens", PublicationYear: 1990, Description: "Good Omens description", Contributors: []Contributor{
+		{AuthorID: pratchett.ID, Role: "author"}, {AuthorID: gaiman.ID, Role: "author"}, {AuthorID: kidby.ID, Role: "illustrator"},
+	}}
+	err := m.Books.Insert(ctx, omens)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if omens.AuthorID != int(pratchett.ID) {
+		t.Errorf("Insert set author_id %d; want the first contributor %d", omens.AuthorID, pratchett.ID)
+	}
+
+	got, err := m.Books.GetByID(ctx, omens.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Contributor{
+		{AuthorID: pratchett.ID, AuthorName: "Terry Pratchett", Role: "author"},
+		{AuthorID: gaiman.ID, AuthorName: "Neil Gaiman", Role: "author"},
+		{AuthorID: kidby.ID, AuthorName: "Paul Kidby", Role: "illustrator"},
+	}
+	if fmt.Sprint(got.Contributors) != fmt.Sprint(want) || got.Author.ID != pratchett.ID {
+		t.Errorf("GetByID contributors = %+v, author %d; want %+v in order", got.Contributors, got.Author.ID, want)
+	}
+
+	results, _, err := m.Books.Search(ctx, "gaiman", filters(1, 20, "rank"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var found []int64
+	for _, result := range results {
+		found = append(found, result.Book.ID)
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i] < found[j] })
+	if !equalIDs(found, []int64{single.ID, omens.ID}) {
+		t.Errorf("Search(gaiman) = %v; want the books Gaiman contributed to", found)
+	}
+
+	authors, _, err := m.Books.GetAllAuthors(ctx, "", filters(1, 20, "id", authorSorts...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	counts := make(map[string]int)
+	for _, author := range authors {
+		counts[author.AuthorName] = author.BookCount
+	}
+	wantCounts := map[string]int{"Neil Gaiman": 2, "Terry Pratchett": 1, "Paul Kidby": 1, "Nobody": 0}
+	if fmt.Sprint(counts) != fmt.Sprint(wantCounts) {
+		t.Errorf("GetAllAuthors book counts = %v; want %v", counts, wantCounts)
+	}
+
+	got.Contributors = []Contributor{{AuthorID: gaiman.ID, Role: "editor"}, {AuthorID: gaiman.ID, Role: "translator"}}
+	err = m.Books.Update(ctx, got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err = m.Books.GetByID(ctx, omens.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []Contributor{
+		{AuthorID: gaiman.ID, AuthorName: "Neil Gaiman", Role: "editor"},
+		{AuthorID: gaiman.ID, AuthorName: "Neil Gaiman", Role: "translator"},
+	}
+	if fmt.Sprint(got.Contributors) != fmt.Sprint(want) || got.AuthorID != int(gaiman.ID) {
+		t.Errorf("after Update contributors = %+v, author_id %d; want %+v", got.Contributors, got.AuthorID, want)
+	}
+
+	facets, err := m.Books.GetFacets(ctx, "", 0, filters(1, 20, "id", bookSorts...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(facets.Authors) != 1 || facets.Authors[0].ID != gaiman.ID || facets.Authors[0].Count != 2 {
+		t.Errorf("authors facet = %+v; want Gaiman counted once per book", facets.Authors)
+	}
+}
+
+func testISBN(t *testing.T, m Models) {
+	ctx := context.Background()
+	author := insertAuthor(t, m, "Douglas Adams")
+
+	book := &Book{Title: "The Hitchhiker's Guide", AuthorID: int(author.ID), PublicationYear: 1979, Description: "Towels", ISBN10: "0-330-25864-8"}
+	err := m.Books.Insert(ctx, book)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if book.ISBN10 != "0330258648" || book.ISBN13 != "9780330258647" {
+		t.Errorf("Insert set ISBNs %q and %q; want both forms without hyphens", book.ISBN10, book.ISBN13)
+	}
+	none := insertBook(t, m, "Mostly Harmless", author, 1992)
+	insertBook(t, m, "So Long", author, 1984)
+
+	got, err := m.Books.GetByISBN(ctx, "9780330258647")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != book.ID || got.ISBN10 != "0330258648" {
+		t.Errorf("GetByISBN = book %d with ISBN-10 %q; want %d", got.ID, got.ISBN10, book.ID)
+	}
+	got, err = m.Books.GetByID(ctx, book.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ISBN13 != "9780330258647" || got.ISBN10 != "0330258648" {
+		t.Errorf("GetByID ISBNs = %q and %q", got.ISBN13, got.ISBN10)
+	}
+	_, err = m.Books.GetByISBN(ctx, "9780306406157")
+	if !errors.Is(err, ErrNoRecordFound) {
+		t.Errorf("GetByISBN(unknown) error = %v; want ErrNoRecordFound", err)
+	}
+
+	duplicate := &Book{Title: "Copy", AuthorID: int(author.ID), PublicationYear: 1979, Description: "Copy", ISBN13: "978-0-330-25864-7"}
+	err = m.Books.Insert(ctx, duplicate)
+	if !errors.Is(err, ErrDuplicateISBN) {
+		t.Errorf("Insert(duplicate ISBN) error = %v; want ErrDuplicateISBN", err)
+	}
+	none.ISBN13 = "9780330258647"
+	err = m.Books.Update(ctx, none)
+	if !errors.Is(err, ErrDuplicateISBN) {
+		t.Errorf("Update(duplicate ISBN) error = %v; want ErrDuplicateISBN", err)
+	}
+
+	got.ISBN10, got.ISBN13 = "", "9791000000008"
+	err = m.Books.Update(ctx, got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ISBN10 != "" {
+		t.Errorf("a 979 ISBN-13 got the ISBN-10 %q; want none", got.ISBN10)
+	}
+	_, err = m.Books.GetByISBN(ctx, "9780330258647")
+	if !errors.Is(err, ErrNoRecordFound) {
+		t.Errorf("GetByISBN(old ISBN) error = %v; want ErrNoRecordFound", err)
+	}
+}
+
+func testWorks(t *testing.T, m Models) {
+	ctx := context.Background()
+	author := insertAuthor(t, m, "Frank Herbert")
+	user := insertUser(t, m, "Alice", "alice@example.com")
+
+	hardcover := insertBook(t, m, "Dune", author, 1965)
+	if hardcover.WorkID == 0 {
+		t.Fatal("Insert did not create a work")
+	}
+	ace := &Publisher{Name: "Ace"}
+	err := m.Publishers.Insert(ctx, ace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chronicles := &Series{Name: "Dune Chronicles"}
+	err = m.Series.Insert(ctx, chronicles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	editor := insertAuthor(t, m, "Brian Herbert")
+	ebook := &Book{Title: "Dune", AuthorID: int(author.ID), PublicationYear: 2005, Description: "Dune", WorkID: hardcover.WorkID,
+		Format: "ebook", PublisherID: ace.ID, PageCount: 896, Language: "english", Genres: []string{"Science Fiction", "Classic"},
+		Contributors: []Contributor{{AuthorID: author.ID, Role: "author"}, {AuthorID: editor.ID, Role: "editor"}},
+		Series:       []SeriesEntry{{SeriesID: chronicles.ID, Position: 1}}}
+	err = m.Books.Insert(ctx, ebook)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = m.Books.SetCovers(ctx, ebook.ID, []Cover{
+		{Size: "large", URL: "/covers/dune-large.jpg", Width: 512, Height: 768, Key: "dune-large.jpg"},
+		{Size: "small", URL: "/covers/dune-small.jpg", Width: 128, Height: 192, Key: "dune-small.jpg"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	duplicate := insertBook(t, m, "Dune (French)", author, 1970)
+	other := insertBook(t, m, "Children of Dune", author, 1976)
+	if duplicate.WorkID == hardcover.WorkID {
+		t.Error("a book inserted without a work joined another one")
+	}
+
+	work, err := m.Books.GetWork(ctx, hardcover.WorkID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if work.Title != "Dune" || work.Editions != 2 {
+		t.Errorf("GetWork = %+v; want Dune with 2 editions", work)
+	}
+	editions, err := m.Books.GetEditions(ctx, hardcover.WorkID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equalIDs(bookIDs(editions), []int64{hardcover.ID, ebook.ID}) {
+		t.Errorf("GetEditions = %v; want %v", bookIDs(editions), []int64{hardcover.ID, ebook.ID})
+	}
+	if e := editions[1]; e.Format != "ebook" || e.Publisher == nil || e.Publisher.Name != "Ace" || e.PageCount != 896 || e.Language != "english" {
+		t.Errorf("edition = %+v; want its format, publisher, page count and language", e)
+	}
+
+	insertReview(t, m, duplicate, user, 2)
+	insertReview(t, m, hardcover, user, 4)
+	got, err := m.Books.GetByID(ctx, ebook.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Reviews) != 1 || got.Reviews[0].Rating != 4 {
+		t.Errorf("reviews of the ebook = %+v; want the review of the hardcover", got.Reviews)
+	}
+
+	err = m.Books.MergeWorks(ctx, hardcover.WorkID, []int64{duplicate.ID, hardcover.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err = m.Books.GetByID(ctx, ebook.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Reviews) != 2 {
+		t.Errorf("reviews of the ebook after the merge = %+v; want those of every edition", got.Reviews)
+	}
+	_, err = m.Books.GetWork(ctx, duplicate.WorkID)
+	if !errors.Is(err, ErrNoRecordFound) {
+		t.Errorf("GetWork(merged work) error = %v; want ErrNoRecordFound", err)
+	}
+	editions, err = m.Books.GetEditions(ctx, hardcover.WorkID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equalIDs(bookIDs(editions), []int64{hardcover.ID, duplicate.ID, ebook.ID}) {
+		t.Errorf("GetEditions after the merge = %v", bookIDs(editions))
+	}
+	for _, edition := range editions {
+		want, err := m.Books.GetByID(ctx, edition.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want.Reviews = nil
+		if !reflect.DeepEqual(edition, want) {
+			t.Errorf("edition = %+v; want %+v, what GetByID returns without the reviews", edition, want)
+		}
+	}
+
+	err = m.Books.MergeWorks(ctx, hardcover.WorkID, []int64{other.ID + 100})
+	if !errors.Is(err, ErrNoRecordFound) {
+		t.Errorf("MergeWorks(missing book) error = %v; want ErrNoRecordFound", err)
+	}
+	err = m.Books.MergeWorks(ctx, other.WorkID+100, []int64{other.ID})
+	if !errors.Is(err, ErrNoRecordFound) {
+		t.Errorf("MergeWorks(missing work) error = %v; want ErrNoRecordFound", err)
+	}
+
+	err = m.Books.Delete(ctx, other.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = m.Books.GetWork(ctx, other.WorkID)
+	if err != nil {
+		t.Errorf("GetWork with its only edition in the trash error = %v; want the work", err)
+	}
+	purgeTrash(t, m)
+	_, err = m.Books.GetWork(ctx, other.WorkID)
+	if !errors.Is(err, ErrNoRecordFound) {
+		t.Errorf("GetWork after purging its only edition error = %v; want ErrNoRecordFound", err)
+	}
+}
+
+func testSlugs(t *testing.T, m Models) {
+	ctx := context.Background()
+	author := insertAuthor(t, m, "Stephen King")
+	it := insertBook(t, m, "It", author, 1986)
+	remake := insertBook(t, m, "IT", author, 2017)
+	third := insertBook(t, m, "It!", author, 2019)
+	symbols := insertBook(t, m, "???", author, 2000)
+	slugs := fmt.Sprint([]string{it.Slug, remake.Slug, third.Slug, symbols.Slug})
+	if slugs != "[it it-2 it-3 book]" {
+		t.Errorf("slugs = %s; want [it it-2 it-3 book]", slugs)
+	}
+
+	// Renaming keeps the old slug leading to the book, and no other book can take it.
+	remake.Title = "It Chapter One"
+	err := m.Books.Update(ctx, remake)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if remake.Slug != "it-chapter-one" {
+		t.Errorf("Update set slug %q; want it-chapter-one", remake.Slug)
+	}
+	got, err := m.Books.GetBySlug(ctx, "it-2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != remake.ID || got.Slug != "it-chapter-one" {
+		t.Errorf("GetBySlug(old slug) = %d %q; want %d it-chapter-one", got.ID, got.Slug, remake.ID)
+	}
+	oldSlugs, err := m.Books.GetOldSlugs(ctx, remake.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(oldSlugs) != "[it-2]" {
+		t.Errorf("GetOldSlugs = %v; want [it-2]", oldSlugs)
+	}
+	another := insertBook(t, m, "It", author, 2020)
+	if another.Slug != "it-4" {
+		t.Errorf("slug of a new book = %q; want it-4, it-2 is an old slug", another.Slug)
+	}
+
+	// Renaming back gives the book its old slug again.
+	remake.Title = "It"
+	err = m.Books.Update(ctx, remake)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if remake.Slug != "it-2" {
+		t.Errorf("Update back set slug %q; want it-2", remake.Slug)
+	}
+	got, err = m.Books.GetBySlug(ctx, "it-chapter-one")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != remake.ID || got.Slug != "it-2" {
+		t.Errorf("GetBySlug(it-chapter-one) = %d %q; want %d it-2", got.ID, got.Slug, remake.ID)
+	}
+	oldSlugs, err = m.Books.GetOldSlugs(ctx, remake.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(oldSlugs) != "[it-chapter-one]" {
+		t.Errorf("GetOldSlugs after renaming back = %v; want [it-chapter-one]", oldSlugs)
+	}
+
+	// A book keeps its slug while its title gives the same one, even when a lower suffix is free.
+	err = m.Books.Delete(ctx, it.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	third.Description = "Updated"
+	err = m.Books.Update(ctx, third)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if third.Slug != "it-3" {
+		t.Errorf("Update changed slug to %q; want it-3", third.Slug)
+	}
+	_, err = m.Books.GetBySlug(ctx, "it")
+	if !errors.Is(err, ErrNoRecordFound) {
+		t.Errorf("GetBySlug(slug of a deleted book) error = %v; want ErrNoRecordFound", err)
+	}
+}
+
+func testCovers(t *testing.T, m Models) {
+	ctx := context.Background()
+	author := insertAuthor(t, m, "Frank Herbert")
+	book := insertBook(t, m, "Dune", author, 1965)
+
+	covers := []Cover{
+		{Size: "large", URL: "/covers/a-large.jpg", Width: 640, Height: 960, Key: "a-large.jpg"},
+		{Size: "small", URL: "/covers/a-small.jpg", Width: 128, Height: 192, Key: "a-small.jpg"},
+	}
+	previous, err := m.Books.SetCovers(ctx, book.ID, covers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(previous) != 0 {
+		t.Errorf("first SetCovers returned %+v; want no previous covers", previous)
+	}
+	got, err := m.Books.GetByID(ctx, book.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(got.Covers) != fmt.Sprint([]Cover{covers[1], covers[0]}) {
+		t.Errorf("covers = %+v; want small then large", got.Covers)
+	}
+	bySlug, err := m.Books.GetBySlug(ctx, book.Slug)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bySlug.Covers) != 2 {
+		t.Errorf("covers by slug = %+v; want 2", bySlug.Covers)
+	}
+	books, _, err := m.Books.GetAll(ctx, "", 0, filters(1, 10, "id", bookSorts...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(books) != 1 || len(books[0].Covers) != 2 {
+		t.Errorf("GetAll covers = %+v; want 2", books)
+	}
+
+	// Updating the book leaves its covers alone.
+	got.Description = "Updated"
+	err = m.Books.Update(ctx, got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replacement := []Cover{{Size: "small", URL: "/covers/b-small.jpg", Width: 128, Height: 200, Key: "b-small.jpg"}}
+	previous, err = m.Books.SetCovers(ctx, book.ID, replacement)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(previous) != fmt.Sprint([]Cover{covers[1], covers[0]}) {
+		t.Errorf("SetCovers returned %+v; want the covers it replaced", previous)
+	}
+	got, err = m.Books.GetByID(ctx, book.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(got.Covers) != fmt.Sprint(replacement) {
+		t.Errorf("covers after replacing = %+v; want %+v", got.Covers, replacement)
+	}
+
+	_, err = m.Books.SetCovers(ctx, book.ID+100, replacement)
+	if !errors.Is(err, ErrNoRecordFound) {
+		t.Errorf("SetCovers of a missing book error = %v; want ErrNoRecordFound", err)
+	}
+}
+
+func testSeries(t *testing.T, m Models) {
+	ctx := context.Background()
+	author := insertAuthor(t, m, "Robin Hobb")
+	farseer := &Series{Name: "Farseer Trilogy", Description: "Fitz"}
+	err := m.Series.Insert(ctx, farseer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if farseer.ID == 0 || farseer.Version != 1 {
+		t.Errorf("Insert set id %d version %d; want an id and version 1", farseer.ID, farseer.Version)
+	}
+	elderlings := &Series{Name: "Realm of the Elderlings"}
+	err = m.Series.Insert(ctx, elderlings)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inSeries := func(title string, year int, entries ...SeriesEntry) *Book {
+		book := &Book{Title: title, AuthorID: int(author.ID), PublicationYear: year, Description: title, Series: entries}
+		err := m.Books.Insert(ctx, book)
+		if err != nil {
+			t.Fatalf("Insert(%q): %v", title, err)
+		}
+		return book
+	}
+	quest := inSeries("Assassin's Quest", 1997, SeriesEntry{SeriesID: farseer.ID, Position: 3}, SeriesEntry{SeriesID: elderlings.ID, Position: 3})
+	apprentice := inSeries("Assassin's Apprentice", 1995, SeriesEntry{SeriesID: farseer.ID, Position: 1})
+	novella := inSeries("The Willful Princess", 2013, SeriesEntry{SeriesID: farseer.ID, Position: 0.5})
+	inSeries("Royal Assassin", 1996, SeriesEntry{SeriesID: farseer.ID, Position: 2})
+	insertBook(t, m, "Standalone", author, 2000)
+
+	books, err := m.Series.GetBooks(ctx, farseer.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var titles []string
+	for _, book := range books {
+		titles = append(titles, book.Title)
+	}
+	want := "[The Willful Princess Assassin's Apprentice Royal Assassin Assassin's Quest]"
+	if fmt.Sprint(titles) != want {
+		t.Errorf("GetBooks = %v; want %s", titles, want)
+	}
+
+	got, err := m.Books.GetByID(ctx, quest.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantEntries := []SeriesEntry{{SeriesID: farseer.ID, Name: "Farseer Trilogy", Position: 3}, {SeriesID: elderlings.ID, Name: "Realm of the Elderlings", Position: 3}}
+	if fmt.Sprint(got.Series) != fmt.Sprint(wantEntries) {
+		t.Errorf("GetByID series = %+v; want %+v", got.Series, wantEntries)
+	}
+	got, err = m.Books.GetByID(ctx, novella.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Series) != 1 || got.Series[0].Position != 0.5 {
+		t.Errorf("the novella's series = %+v; want position 0.5", got.Series)
+	}
+
+	// Updating a book without touching its series keeps them, an empty list removes them.
+	got.Title = "The Willful Princess and the Piebald Prince"
+	err = m.Books.Update(ctx, got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found, err := m.Series.GetByID(ctx, farseer.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found.BookCount != 4 || found.Name != "Farseer Trilogy" {
+		t.Errorf("GetByID = %+v; want the series with 4 books", found)
+	}
+	got.Series = []SeriesEntry{}
+	err = m.Books.Update(ctx, got)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	all, metadata, err := m.Series.GetAll(ctx, "", filters(1, 20, "-name", "id", "name", "-id", "-name"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 2 || all[0].ID != elderlings.ID || all[1].BookCount != 3 || metadata.TotalRecords != 2 {
+		t.Errorf("GetAll = %+v, %+v; want the Elderlings then the Farseer with 3 books", all, metadata)
+	}
+	all, _, err = m.Series.GetAll(ctx, "farseer", filters(1, 20, "id", "id"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 1 || all[0].ID != farseer.ID {
+		t.Errorf("GetAll(farseer) = %+v", all)
+	}
+
+	stale := *found
+	found.Name = "The Farseer Trilogy"
+	err = m.Series.Update(ctx, found)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found.Version != 2 {
+		t.Errorf("Update set version %d; want 2", found.Version)
+	}
+	err = m.Series.Update(ctx, &stale)
+	if !errors.Is(err, ErrNoRecordFound) {
+		t.Errorf("Update(stale version) error = %v; want ErrNoRecordFound", err)
+	}
+
+	err = m.Series.Delete(ctx, farseer.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = m.Series.Delete(ctx, farseer.ID)
+	if !errors.Is(err, ErrNoRecordFound) {
+		t.Errorf("Delete twice error = %v; want ErrNoRecordFound", err)
+	}
+	got, err = m.Books.GetByID(ctx, apprentice.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Series) != 0 {
+		t.Errorf("series of a book after deleting its series = %+v; want none", got.Series)
+	}
+}
+
+func testPublishers(t *testing.T, m Models) {
+	ctx := context.Background()
+	author := insertAuthor(t, m, "Frank Herbert")
+	chilton := &Publisher{Name: "Chilton Books"}
+	err := m.Publishers.Insert(ctx, chilton)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ace := &Publisher{Name: "Ace"}
+	err = m.Publishers.Insert(ctx, ace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = m.Publishers.Insert(ctx, &Publisher{Name: "Ace"})
+	if !errors.Is(err, ErrDuplicatePublisher) {
+		t.Errorf("Insert(duplicate name) error = %v; want ErrDuplicatePublisher", err)
+	}
+
+	published := func(title string, year int, publisher *Publisher) *Book {
+		book := &Book{Title: title, AuthorID: int(author.ID), PublicationYear: year, Description: title, PublisherID: publisher.ID}
+		err := m.Books.Insert(ctx, book)
+		if err != nil {
+			t.Fatalf("Insert(%q): %v", title, err)
+		}
+		return book
+	}
+	dune := published("Dune", 1965, chilton)
+	messiah := published("Dune Messiah", 1969, ace)
+	children := published("Children of Dune", 1976, ace)
+	unpublished := insertBook(t, m, "Dune Notes", author, 1990)
+	if dune.Publisher == nil || dune.Publisher.Name != "Chilton Books" {
+		t.Errorf("Insert set publisher %+v; want Chilton Books", dune.Publisher)
+	}
+
+	got, err := m.Books.GetByID(ctx, messiah.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.PublisherID != ace.ID || got.Publisher == nil || *got.Publisher != (Publisher{ID: ace.ID, Name: "Ace"}) {
+		t.Errorf("GetByID publisher = %d %+v; want Ace", got.PublisherID, got.Publisher)
+	}
+	got, err = m.Books.GetByID(ctx, unpublished.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.PublisherID != 0 || got.Publisher != nil {
+		t.Errorf("GetByID publisher of a book without one = %d %+v", got.PublisherID, got.Publisher)
+	}
+
+	books, metadata, err := m.Books.GetAll(ctx, "", ace.ID, filters(1, 20, "id", bookSorts...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equalIDs(bookIDs(books), []int64{messiah.ID, children.ID}) || metadata.TotalRecords != 2 {
+		t.Errorf("GetAll(publisher) = %v, %d records; want %v", bookIDs(books), metadata.TotalRecords, []int64{messiah.ID, children.ID})
+	}
+	books, _, err = m.Books.GetAll(ctx, "children", ace.ID, filters(1, 20, "id", bookSorts...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equalIDs(bookIDs(books), []int64{children.ID}) {
+		t.Errorf("GetAll(title, publisher) = %v; want %v", bookIDs(books), []int64{children.ID})
+	}
+	facets, err := m.Books.GetFacets(ctx, "", ace.ID, filters(1, 20, "id", bookSorts...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(facets.Decades) != fmt.Sprint([]FacetCount{{Value: "1960s", Count: 1}, {Value: "1970s", Count: 1}}) {
+		t.Errorf("GetFacets(publisher) decades = %+v", facets.Decades)
+	}
+
+	// Moving a book to another publisher and removing the publisher of another.
+	got.PublisherID = chilton.ID
+	err = m.Books.Update(ctx, got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Publisher == nil || got.Publisher.Name != "Chilton Books" {
+		t.Errorf("Update set publisher %+v; want Chilton Books", got.Publisher)
+	}
+	children.PublisherID = 0
+	err = m.Books.Update(ctx, children)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if children.Publisher != nil {
+		t.Errorf("Update kept publisher %+v; want none", children.Publisher)
+	}
+
+	all, metadata, err := m.Publishers.GetAll(ctx, "", filters(1, 20, "name", "id", "name", "-id", "-name"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 2 || all[0].Name != "Ace" || all[0].BookCount != 1 || all[1].BookCount != 2 || metadata.TotalRecords != 2 {
+		t.Errorf("GetAll = %+v; want Ace with 1 book then Chilton Books with 2", all)
+	}
+	all, _, err = m.Publishers.GetAll(ctx, "chilton", filters(1, 20, "id", "id"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 1 || all[0].ID != chilton.ID {
+		t.Errorf("GetAll(chilton) = %+v", all)
+	}
+
+	found, err := m.Publishers.GetByID(ctx, ace.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale := *found
+	found.Name = "Ace Books"
+	err = m.Publishers.Update(ctx, found)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = m.Publishers.Update(ctx, &stale)
+	if !errors.Is(err, ErrNoRecordFound) {
+		t.Errorf("Update(stale version) error = %v; want ErrNoRecordFound", err)
+	}
+	found.Name = "Chilton Books"
+	err = m.Publishers.Update(ctx, found)
+	if !errors.Is(err, ErrDuplicatePublisher) {
+		t.Errorf("Update(duplicate name) error = %v; want ErrDuplicatePublisher", err)
+	}
+
+	err = m.Publishers.Delete(ctx, chilton.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = m.Publishers.Delete(ctx, chilton.ID)
+	if !errors.Is(err, ErrNoRecordFound) {
+		t.Errorf("Delete twice error = %v; want ErrNoRecordFound", err)
+	}
+	got, err = m.Books.GetByID(ctx, dune.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.PublisherID != 0 || got.Publisher != nil {
+		t.Errorf("publisher of a book after deleting it = %d %+v; want none", got.PublisherID, got.Publisher)
+	}
+}
+
+func testFacets(t *testing.T, m Models) {
+	ctx := context.Background()
+	herbert := insertAuthor(t, m, "Frank Herbert")
+	tolkien := insertAuthor(t, m, "Tolkien")
+	withGenres := func(book *Book, genres ...string) *Book {
+		t.Helper()
+		book.Genres = genres
+		err := m.Books.Update(ctx, book)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return book
+	}
+	dune := withGenres(insertBook(t, m, "Dune", herbert, 1965), "Science Fiction", "Classic")
+	messiah := withGenres(insertBook(t, m, "Dune Messiah", herbert, 1969), "Science Fiction")
+	children := insertBook(t, m, "Children of Dune", herbert, 1976)
+	hobbit := withGenres(insertBook(t, m, "The Hobbit", tolkien, 1937), "Fantasy", "Classic")
+	alice := insertUser(t, m, "Alice", "alice@example.com")
+	bob := insertUser(t, m, "Bob", "bob@example.com")
+	insertReview(t, m, dune, alice, 5)
+	insertReview(t, m, dune, bob, 4)
+	insertReview(t, m, messiah, alice, 2)
+	insertReview(t, m, hobbit, bob, 3)
+	insertReview(t, m, children, bob, 1)
+
+	facets, err := m.Books.GetFacets(ctx, "", 0, filters(2, 1, "-title", bookSorts...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	counts := func(facet []FacetCount) string {
+		var values []string
+		for _, count := range facet {
+			values = append(values, fmt.Sprintf("%s:%d", count.Value, count.Count))
+		}
+		return strings.Join(values, ", ")
+	}
+	tests := []struct {
+		name string
+		got  []FacetCount
+		want string
+	}{
+		{"genres", facets.Genres, "Classic:2, Science Fiction:2, Fantasy:1"},
+		{"authors", facets.Authors, "Frank Herbert:3, Tolkien:1"},
+		{"decades", facets.Decades, "1930s:1, 1960s:2, 1970s:1"},
+		{"ratings", facets.Ratings, "1-2:1, 2-3:1, 3-4:1, 4-5:1"},
+	}
+	for _, tt := range tests {
+		if counts(tt.got) != tt.want {
+			t.Errorf("%s = %v; want %s", tt.name, tt.got, tt.want)
+		}
+	}
+	if facets.Authors[0].ID != herbert.ID || facets.Genres[0].ID == 0 {
+		t.Errorf("facets = %+v; want the IDs of the authors and genres", facets)
+	}
+
+	facets, err = m.Books.GetFacets(ctx, "dune", 0, filters(1, 20, "id", bookSorts...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if counts(facets.Genres) != "Science Fiction:2, Classic:1" || counts(facets.Decades) != "1960s:2, 1970s:1" {
+		t.Errorf("facets of dune = %+v; want only the Dune books counted", facets)
+	}
+	insertBook(t, m, "Dune Encyclopedia", tolkien, 1984)
+	facets, err = m.Books.GetFacets(ctx, "encyclopedia", 0, filters(1, 20, "id", bookSorts...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(facets.Genres) != 0 || counts(facets.Ratings) != "unrated:1" {
+		t.Errorf("facets of a book without genres or reviews = %+v", facets)
+	}
+}
+
+func testImports(t *testing.T, m Models) {
+	ctx := context.Background()
+	user := insertUser(t, m, "Admin", "admin@example.com")
+
+	imp := &Import{UserID: user.ID, Format: "csv", Total: 3}
+	err := m.Imports.Insert(ctx, imp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if imp.ID == 0 || imp.Status != ImportQueued || imp.CreatedAt.IsZero() {
+		t.Errorf("Insert set %+v; want an id, the queued status and a creation time", imp)
+	}
+
+	err = m.Imports.InsertErrors(ctx, imp.ID, []ImportRowError{
+		{Line: 4, Title: "Dune", Errors: map[string]string{"publication_year": "should not be empty"}},
+		{Line: 2, Errors: map[string]string{"row": "wrong number of fields"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	finished := time.Now().Truncate(time.Second)
+	imp.Status = ImportCompleted
+	imp.Processed, imp.Created, imp.Failed = 3, 1, 2
+	imp.FinishedAt = &finished
+	err = m.Imports.Update(ctx, imp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := m.Imports.GetByID(ctx, imp.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.UserID != user.ID || got.Format != "csv" || got.Status != ImportCompleted || got.Total != 3 || got.Processed != 3 ||
+		got.Created != 1 || got.Failed != 2 || got.FinishedAt == nil || !got.FinishedAt.Equal(finished) {
+		t.Errorf("GetByID = %+v; want the completed import", got)
+	}
+	rowErrors, err := m.Imports.GetErrors(ctx, imp.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(rowErrors) != "[{2  map[row:wrong number of fields]} {4 Dune map[publication_year:should not be empty]}]" {
+		t.Errorf("GetErrors = %v; want lines 2 and 4", rowErrors)
+	}
+
+	// The import is kept when its user goes.
+	err = m.Users.Delete(ctx, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	purgeTrash(t, m)
+	got, err = m.Imports.GetByID(ctx, imp.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.UserID != 0 {
+		t.Errorf("UserID after deleting the user = %d; want 0", got.UserID)
+	}
+
+	_, err = m.Imports.GetByID(ctx, imp.ID+1)
+	if !errors.Is(err, ErrNoRecordFound) {
+		t.Errorf("GetByID(missing) error = %v; want ErrNoRecordFound", err)
+	}
+	err = m.Imports.Update(ctx, &Import{ID: imp.ID + 1, Status: ImportRunning})
+	if !errors.Is(err, ErrNoRecordFound) {
+		t.Errorf("Update(missing) error = %v; want ErrNoRecordFound", err)
+	}
+}
+
+func testLibraryImports(t *testing.T, m Models) {
+	ctx := context.Background()
+	user := insertUser(t, m, "Alice", "alice@example.com")
+	herbert := insertAuthor(t, m, "Frank Herbert")
+	dune := insertBook(t, m, "Dune", herbert, 1965)
+	insertBook(t, m, "Dune", herbert, 1990)
+	messiah := insertBook(t, m, "Dune Messiah", herbert, 1969)
+
+	got, err := m.Books.GetByTitleAndAuthor(ctx, "DUNE", "frank herbert")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != dune.ID || got.Author.AuthorName != "Frank Herbert" {
+		t.Errorf("GetByTitleAndAuthor(DUNE, frank herbert) = book %d; want the first Dune, %d", got.ID, dune.ID)
+	}
+	for _, args := range [][2]string{{"Dune", "Brian Herbert"}, {"Dun", "Frank Herbert"}} {
+		_, err = m.Books.GetByTitleAndAuthor(ctx, args[0], args[1])
+		if !errors.Is(err, ErrNoRecordFound) {
+			t.Errorf("GetByTitleAndAuthor(%q, %q) error = %v; want ErrNoRecordFound", args[0], args[1], err)
+		}
+	}
+	insertReview(t, m, messiah, user, 3)
+	insertReview(t, m, dune, user, 5)
+	insertReview(t, m, dune, user, 4)
+	ids, err := m.Books.GetReviewedBookIDs(ctx, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(ids) != fmt.Sprint([]int64{dune.ID, messiah.ID}) {
+		t.Errorf("GetReviewedBookIDs = %v; want Dune and Dune Messiah once each", ids)
+	}
+
+	imp := &LibraryImport{UserID: user.ID, Source: "goodreads", Rows: []LibraryImportRow{
+		{Line: 3, Title: "Hyperion", Author: "Dan Simmons", Year: 1989, Rating: 4, Match: MatchCandidate, Status: LibraryRowPending},
+		{Line: 2, Title: "Dune", Author: "Frank Herbert", ISBN: "9780441172719", Rating: 5, Review: "Spice", Match: MatchISBN,
+			BookID: dune.ID, Status: LibraryRowPending},
+		{Line: 4, Title: "Unknown", Match: MatchNone, Status: LibraryRowSkipped, Reason: "has no author"},
+	}}
+	err = m.LibraryImports.Insert(ctx, imp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if imp.ID == 0 || imp.Status != LibraryImportPreviewed || imp.Version != 1 || imp.CreatedAt.IsZero() {
+		t.Errorf("Insert set %+v; want an id, the previewed status, version 1 and a creation time", imp)
+	}
+
+	read, err := m.LibraryImports.GetByID(ctx, imp.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if read.UserID != user.ID || read.Source != "goodreads" || read.ConfirmedAt != nil || len(read.Rows) != 3 {
+		t.Fatalf("GetByID = %+v; want the previewed import and its 3 rows", read)
+	}
+	if read.Rows[0] != imp.Rows[1] || read.Rows[1].Line != 3 || read.Rows[2].Reason != "has no author" {
+		t.Errorf("rows = %+v; want them in order of line", read.Rows)
+	}
+
+	confirmed := time.Now().Truncate(time.Second)
+	read.Status = LibraryImportConfirmed
+	read.ConfirmedAt = &confirmed
+	read.Rows[0].Status = LibraryRowImported
+	read.Rows[1].BookID, read.Rows[1].Status = messiah.ID, LibraryRowImported
+	err = m.LibraryImports.Update(ctx, read)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if read.Version != 2 {
+		t.Errorf("version after Update = %d; want 2", read.Version)
+	}
+	got2, err := m.LibraryImports.GetByID(ctx, imp.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got2.Status != LibraryImportConfirmed || got2.ConfirmedAt == nil || !got2.ConfirmedAt.Equal(confirmed) ||
+		got2.Rows[0].Status != LibraryRowImported || got2.Rows[1].BookID != messiah.ID || got2.Rows[2].Status != LibraryRowSkipped {
+		t.Errorf("GetByID after Update = %+v; want the confirmed import", got2)
+	}
+
+	// An import read before the last update is stale.
+	imp.Status = LibraryImportFailed
+	err = m.LibraryImports.Update(ctx, imp)
+	if !errors.Is(err, ErrNoRecordFound) {
+		t.Errorf("Update(stale version) error = %v; want ErrNoRecordFound", err)
+	}
+
+	// Purging a book unmatches its rows, purging the user deletes their imports.
+	err = m.Books.Delete(ctx, messiah.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	purgeTrash(t, m)
+	got2, err = m.LibraryImports.GetByID(ctx, imp.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got2.Rows[1].BookID != 0 {
+		t.Errorf("book of the row after deleting it = %d; want 0", got2.Rows[1].BookID)
+	}
+	err = m.Users.Delete(ctx, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	purgeTrash(t, m)
+	_, err = m.LibraryImports.GetByID(ctx, imp.ID)
+	if !errors.Is(err, ErrNoRecordFound) {
+		t.Errorf("GetByID after deleting the user error = %v; want ErrNoRecordFound", err)
+	}
+}
+
+func testTrash(t *testing.T, m Models) {
+	ctx := context.Background()
+	author := insertAuthor(t, m, "Frank Herbert")
+	dune := insertBook(t, m, "Dune", author, 1965)
+	messiah := insertBook(t, m, "Dune Messiah", author, 1969)
+	alice := insertUser(t, m, "Alice", "alice@example.com")
+	bob := insertUser(t, m, "Bob", "bob@example.com")
+	aliceDune := insertReview(t, m, dune, alice, 5)
+	bobDune := insertReview(t, m, dune, bob, 4)
+	aliceMessiah := insertReview(t, m, messiah, alice, 3)
+	cover := Cover{Size: "small", URL: "/covers/dune-small.jpg", Width: 128, Height: 192, Key: "dune-small.jpg"}
+	_, err := m.Books.SetCovers(ctx, dune.ID, []Cover{cover})
+	if err != nil {
+		t.Fatal(err)
+	}
+	reviewIDs := func(book *Book) []int64 {
+		t.Helper()
+		got, err := m.Books.GetByID(ctx, book.ID)
+		if err != nil {
+			t.Fatalf("GetByID(%q): %v", book.Title, err)
+		}
+		var ids []int64
+		for _, review := range got.Reviews {
+			ids = append(ids, review.ID)
+		}
+		return ids
+	}
+	reviewed := func(user *User) []int64 {
+		t.Helper()
+		ids, err := m.Books.GetReviewedBookIDs(ctx, user.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ids
+	}
+
+	// Bob's review is deleted on its own, then Dune with Alice's.
+	err = m.Books.DeleteReview(ctx, bobDune.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = m.Books.Delete(ctx, dune.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	books, _, err := m.Books.GetAll(ctx, "", 0, filters(1, 10, "id", bookSorts...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equalIDs(bookIDs(books), []int64{messiah.ID}) {
+		t.Errorf("GetAll with Dune in the trash = %v; want Dune Messiah", bookIDs(books))
+	}
+	_, err = m.Books.GetBySlug(ctx, dune.Slug)
+	if !errors.Is(err, ErrNoRecordFound) {
+		t.Errorf("GetBySlug of a book in the trash error = %v; want ErrNoRecordFound", err)
+	}
+	_, err = m.Books.GetReviewByID(ctx, aliceDune.ID)
+	if !errors.Is(err, ErrNoRecordFound) {
+		t.Errorf("GetReviewByID of a review in the trash error = %v; want ErrNoRecordFound", err)
+	}
+	err = m.Books.InsertReview(ctx, &Review{Rating: 1, Review: "review", BookID: dune.ID, UserID: bob.ID})
+	if !errors.Is(err, ErrNoRecordFound) {
+		t.Errorf("InsertReview of a book in the trash error = %v; want ErrNoRecordFound", err)
+	}
+	if ids := reviewed(alice); !equalIDs(ids, []int64{messiah.ID}) {
+		t.Errorf("GetReviewedBookIDs with Dune in the trash = %v; want Dune Messiah", ids)
+	}
+	// The book in the trash keeps its slug.
+	remake := insertBook(t, m, "Dune", author, 2021)
+	if remake.Slug == dune.Slug {
+		t.Errorf("new book has the slug %q of the book in the trash", remake.Slug)
+	}
+
+	items, metadata, err := m.Trash.GetAll(ctx, "", Filters{Page: 1, PageSize: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, item := range items {
+		got = append(got, fmt.Sprintf("%s %d %s", item.Type, item.ID, item.Name))
+		if item.DeletedAt.IsZero() {
+			t.Errorf("%s %d has no deletion time", item.Type, item.ID)
+		}
+	}
+	want := []string{fmt.Sprintf("book %d Dune", dune.ID), fmt.Sprintf("review %d Dune", aliceDune.ID), fmt.Sprintf("review %d Dune", bobDune.ID)}
+	if strings.Join(got, ", ") != strings.Join(want, ", ") || metadata.TotalRecords != 3 {
+		t.Errorf("trash = %v, %+v; want %v", got, metadata, want)
+	}
+	items, metadata, err = m.Trash.GetAll(ctx, TrashReview, Filters{Page: 2, PageSize: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 || items[0].ID != bobDune.ID || metadata.TotalRecords != 2 || metadata.LastPage != 2 {
+		t.Errorf("second page of the reviews in the trash = %+v, %+v; want Bob's review", items, metadata)
+	}
+
+	// Restoring Dune brings back the review deleted with it, not Bob's.
+	err = m.Books.Restore(ctx, dune.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids := reviewIDs(dune); !equalIDs(ids, []int64{aliceDune.ID}) {
+		t.Errorf("reviews of the restored book = %v; want Alice's", ids)
+	}
+	err = m.Books.Restore(ctx, dune.ID)
+	if !errors.Is(err, ErrNoRecordFound) {
+		t.Errorf("Restore(live book) error = %v; want ErrNoRecordFound", err)
+	}
+	err = m.Books.RestoreReview(ctx, bobDune.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = m.Books.RestoreReview(ctx, bobDune.ID)
+	if !errors.Is(err, ErrNoRecordFound) {
+		t.Errorf("RestoreReview(live review) error = %v; want ErrNoRecordFound", err)
+	}
+
+	// A review deleted with its book whose user is deleted since comes back with the user.
+	token := insertToken(t, m, alice)
+	err = m.Books.Delete(ctx, dune.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = m.Users.Delete(ctx, alice.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = m.Users.GetByEmail(ctx, alice.Email)
+	if !errors.Is(err, ErrNoRecordFound) {
+		t.Errorf("GetByEmail of a user in the trash error = %v; want ErrNoRecordFound", err)
+	}
+	err = m.Users.Insert(ctx, &User{Name: "Alice", Email: alice.Email, Password: alice.Password})
+	if !errors.Is(err, ErrDuplicateEmail) {
+		t.Errorf("Insert with the email of a user in the trash error = %v; want ErrDuplicateEmail", err)
+	}
+	err = m.Books.RestoreReview(ctx, aliceMessiah.ID)
+	if !errors.Is(err, ErrNoRecordFound) {
+		t.Errorf("RestoreReview of a user in the trash error = %v; want ErrNoRecordFound", err)
+	}
+	err = m.Books.Restore(ctx, dune.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids := reviewIDs(dune); !equalIDs(ids, []int64{bobDune.ID}) {
+		t.Errorf("reviews of the restored book = %v; want Bob's", ids)
+	}
+	err = m.Users.Restore(ctx, alice.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids := reviewed(alice); !equalIDs(ids, []int64{dune.ID, messiah.ID}) {
+		t.Errorf("GetReviewedBookIDs of the restored user = %v; want Dune and Dune Messiah", ids)
+	}
+	_, err = m.Tokens.GetByToken(ctx, token.Token)
+	if !errors.Is(err, ErrNoRecordFound) {
+		t.Errorf("token of the restored user: error = %v; want ErrNoRecordFound", err)
+	}
+	err = m.Users.Restore(ctx, alice.ID)
+	if !errors.Is(err, ErrNoRecordFound) {
+		t.Errorf("Restore(live user) error = %v; want ErrNoRecordFound", err)
+	}
+
+	// Purging deletes for good what is older than the retention.
+	err = m.Books.Delete(ctx, dune.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = m.Users.Delete(ctx, bob.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// An hour ago east of UTC is still later than now in UTC as text.
+	purged, err := m.Trash.Purge(ctx, time.Now().Add(-time.Hour).In(time.FixedZone("UTC+5", 5*60*60)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if purged.Books != 0 || purged.Reviews != 0 || purged.Users != 0 {
+		t.Errorf("Purge of an hour ago = %+v; want nothing", purged)
+	}
+	purged = purgeTrash(t, m)
+	if purged.Books != 1 || purged.Reviews != 2 || purged.Users != 1 || fmt.Sprint(purged.Covers) != fmt.Sprint([]Cover{cover}) {
+		t.Errorf("Purge = %+v; want Dune, its 2 reviews, Bob and the cover of Dune", purged)
+	}
+	err = m.Books.Restore(ctx, dune.ID)
+	if !errors.Is(err, ErrNoRecordFound) {
+		t.Errorf("Restore(purged book) error = %v; want ErrNoRecordFound", err)
+	}
+	_, err = m.Books.GetWork(ctx, dune.WorkID)
+	if !errors.Is(err, ErrNoRecordFound) {
+		t.Errorf("GetWork of the purged book error = %v; want ErrNoRecordFound", err)
+	}
+	items, _, err = m.Trash.GetAll(ctx, "", Filters{Page: 1, PageSize: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 0 {
+		t.Errorf("trash after the purge = %+v; want it empty", items)
+	}
+	if ids := reviewed(alice); !equalIDs(ids, []int64{messiah.ID}) {
+		t.Errorf("GetReviewedBookIDs after the purge = %v; want Dune Messiah", ids)
+	}
+}
@@ -0,0 +1,88 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+// Cover is a rendition of a book's cover image, the renditions of a book are in increasing width.
+type Cover struct {
+	Size   string `json:"size"`
+	URL    string `json:"url"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+	// Key is where the image is in the blob store.
+	Key string `json:"-"`
+}
+
+// SetCovers replaces the cover renditions of the book, and returns the ones it replaces so that
+// their images can be removed.
+func (b BookModel) SetCovers(ctx context.Context, bookID int64, covers []Cover) (_ []Cover, err error) {
+	query := `insert into book_covers (book_id, size, key, url, width, height) values ($1, $2, $3, $4, $5, $6)`
+	ctx, cancel := context.WithTimeout(ctx, b.QueryTimeout)
+	defer cancel()
+	ctx, span := startSpan(ctx, b.Dialect, "BookModel.SetCovers", query)
+	defer endSpan(span, &err)
+	tx, err := b.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	var id int64
+	err = tx.QueryRowContext(ctx, `select id from books where id = $1 and deleted_at is null`, bookID).Scan(&id)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNoRecordFound
+		default:
+			return nil, err
+		}
+	}
+	previous, err := coversByBook(ctx, tx, bookID)
+	if err != nil {
+		return nil, err
+	}
+	_, err = tx.ExecContext(ctx, `delete from book_covers where book_id = $1`, bookID)
+	if err != nil {
+		return nil, err
+	}
+	for _, cover := range covers {
+		_, err = tx.ExecContext(ctx, query, bookID, cover.Size, cover.Key, cover.URL, cover.Width, cover.Height)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return previous, tx.Commit()
+}
+
+func (b BookModel) coversByBook(ctx context.Context, id int64) (_ []Cover, err error) {
+	ctx, span := startSpan(ctx, b.Dialect, "BookModel.coversByBook", coversQuery)
+	defer endSpan(span, &err)
+	return coversByBook(ctx, b.DB, id)
+}
+
+const coversQuery = `select size, key, url, width, height from book_covers where book_id = $1 order by width, size`
+
+// querier is what coversByBook needs of a *sql.DB or a *sql.Tx.
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+func coversByBook(ctx context.Context, db querier, id int64) ([]Cover, error) {
+	rows, err := db.QueryContext(ctx, coversQuery, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var covers []Cover
+	for rows.Next() {
+		var cover Cover
+		err := rows.Scan(&cover.Size, &cover.Key, &cover.URL, &cover.Width, &cover.Height)
+		if err != nil {
+			return nil, err
+		}
+		covers = append(covers, cover)
+	}
+	return covers, rows.Err()
+}
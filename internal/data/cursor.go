@@ -0,0 +1,265 @@
+package data
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrInvalidCursor is returned for a cursor that was not issued for the listing and sort it is used with.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor is the position of a page in a listing: the sort keys of the row it starts after, or of the
+// row it ends before when Before is set.
+type Cursor struct {
+	Listing string `json:"l"`
+	Sort    string `json:"s"`
+	Keys    []any  `json:"k"`
+	Before  bool   `json:"b,omitempty"`
+}
+
+// CursorCodec turns cursors into opaque tokens and back. The tokens are signed, the keys in them end
+// up in the queries and must not be forged.
+type CursorCodec struct {
+	secret []byte
+}
+
+func NewCursorCodec(secret []byte) *CursorCodec {
+	return &CursorCodec{secret: secret}
+}
+
+func (c *CursorCodec) Encode(cursor Cursor) string {
+	payload, err := json.Marshal(cursor)
+	if err != nil {
+		panic(fmt.Sprintf("encoding cursor: %v", err))
+	}
+	return base64.RawURLEncoding.EncodeToString(payload) + "." + base64.RawURLEncoding.EncodeToString(c.sign(payload))
+}
+
+// Decode returns the cursor of token, or ErrInvalidCursor if it is malformed or was not signed by c.
+// The keys come back as int64 and string.
+func (c *CursorCodec) Decode(token string) (Cursor, error) {
+	if c == nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+	encodedPayload, encodedSignature, ok := strings.Cut(token, ".")
+	if !ok {
+		return Cursor{}, ErrInvalidCursor
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(encodedSignature)
+	if err != nil || !hmac.Equal(signature, c.sign(payload)) {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	var cursor Cursor
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.UseNumber()
+	err = decoder.Decode(&cursor)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+	for i, key := range cursor.Keys {
+		switch key := key.(type) {
+		case json.Number:
+			cursor.Keys[i], err = key.Int64()
+			if err != nil {
+				return Cursor{}, ErrInvalidCursor
+			}
+		case string:
+		default:
+			return Cursor{}, ErrInvalidCursor
+		}
+	}
+	return cursor, nil
+}
+
+func (c *CursorCodec) sign(payload []byte) []byte {
+	mac := hmac.New(sha256.New, c.secret)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
+
+// keyset is the order of a page of a listing: the sort column of the filters, then the columns
+// breaking its ties in ascending order. In cursor mode the page is the rows following (or preceding)
+// the cursor in that order rather than an offset.
+type keyset struct {
+	listing string
+	filters Filters
+	columns []string
+	cursor  *Cursor
+}
+
+// keyset returns the order of the listing for the filters, with columns the SQL expressions of
+// the sort column and of the tiebreaks. It fails with ErrInvalidCursor if the cursor of the
+// filters belongs to another listing or sort.
+func (f Filters) keyset(listing string, columns ...string) (keyset, error) {
+	k := keyset{listing: listing, filters: f, columns: columns}
+	if f.Cursor == "" {
+		return k, nil
+	}
+	cursor, err := f.Cursors.Decode(f.Cursor)
+	if err != nil {
+		return keyset{}, err
+	}
+	if cursor.Listing != listing || cursor.Sort != f.Sort || len(cursor.Keys) != len(columns) {
+		return keyset{}, ErrInvalidCursor
+	}
+	k.cursor = &cursor
+	return k, nil
+}
+
+// descending reports whether column i is walked in descending order, which is reversed when going
+// back from a cursor.
+func (k keyset) descending(i int) bool {
+	desc := i == 0 && k.filters.sortDirection() == "DESC"
+	if k.cursor != nil && k.cursor.Before {
+		return !desc
+	}
+	return desc
+}
+
+// countColumn is the column counting the rows of the listing. Cursor pages do not count them,
+// counting would read every row the cursor skips.
+func (k keyset) countColumn() string {
+	if k.cursor != nil {
+		return "0"
+	}
+	return "count(*) over()"
+}
+
+// clauses returns the condition selecting the rows of the page, and the order by, limit and offset
+// clauses to append to the query. The parameters are numbered from first, args are their values. A
+// cursor page reads one more row than it returns, to know if there is more.
+func (k keyset) clauses(first int) (where, tail string, args []any) {
+	var order []string
+	for i, column := range k.columns {
+		direction := "asc"
+		if k.descending(i) {
+			direction = "desc"
+		}
+		order = append(order, column+" "+direction)
+	}
+	tail = fmt.Sprintf("order by %s limit $%d offset $%d", strings.Join(order, ", "), first, first+1)
+	if k.cursor == nil {
+		return "1 = 1", tail, []any{k.filters.limit(), k.filters.offset()}
+	}
+
+	// (c0 > $0 or (c0 = $0 and (c1 > $1 or (c1 = $1 and c2 > $2)))), from the last column out.
+	params := make([]string, len(k.columns))
+	for i := range k.columns {
+		params[i] = fmt.Sprintf("$%d", first+2+i)
+	}
+	last := len(k.columns) - 1
+	where = fmt.Sprintf("%s %s %s", k.columns[last], k.operator(last), params[last])
+	for i := last - 1; i >= 0; i-- {
+		where = fmt.Sprintf("(%s %s %s or (%s = %s and %s))", k.columns[i], k.operator(i), params[i], k.columns[i], params[i], where)
+	}
+	args = append([]any{k.filters.limit() + 1, 0}, k.cursor.Keys...)
+	return where, tail, args
+}
+
+func (k keyset) operator(i int) string {
+	if k.descending(i) {
+		return "<"
+	}
+	return ">"
+}
+
+// after reports whether keys come after the cursor in the order of the page, the in-memory
+// equivalent of the condition of clauses.
+func (k keyset) after(keys []any) bool {
+	for i, key := range keys {
+		c := compareKeys(key, k.cursor.Keys[i])
+		if c == 0 {
+			continue
+		}
+		if k.descending(i) {
+			return c < 0
+		}
+		return c > 0
+	}
+	return false
+}
+
+func compareKeys(a, b any) int {
+	switch a := a.(type) {
+	case int64:
+		b, _ := b.(int64)
+		switch {
+		case a < b:
+			return -1
+		case a > b:
+			return 1
+		}
+	case string:
+		b, _ := b.(string)
+		return strings.Compare(a, b)
+	}
+	return 0
+}
+
+// finish turns the rows read for the page into the page and its metadata. keys returns the values of
+// the keyset columns of a row. total is the count of the listing's rows in offset mode.
+func finish[T any](k keyset, rows []T, total int, keys func(T) []any) ([]T, Metadata) {
+	codec := k.filters.Cursors
+	cursorAt := func(row T, before bool) string {
+		if codec == nil {
+			return ""
+		}
+		return codec.Encode(Cursor{Listing: k.listing, Sort: k.filters.Sort, Keys: keys(row), Before: before})
+	}
+
+	if k.cursor == nil {
+		metadata := calculateMetadata(total, k.filters.Page, k.filters.PageSize)
+		if len(rows) > 0 {
+			if k.filters.offset()+len(rows) < total {
+				metadata.Next = cursorAt(rows[len(rows)-1], false)
+			}
+			if k.filters.Page > 1 {
+				metadata.Prev = cursorAt(rows[0], true)
+			}
+		}
+		return rows, metadata
+	}
+
+	metadata := Metadata{PageSize: k.filters.PageSize}
+	more := len(rows) > k.filters.limit()
+	if more {
+		rows = rows[:k.filters.limit()]
+	}
+	if len(rows) == 0 {
+		// Nothing past the cursor, it is still the way back.
+		back := *k.cursor
+		back.Before = !back.Before
+		if back.Before {
+			metadata.Prev = codec.Encode(back)
+		} else {
+			metadata.Next = codec.Encode(back)
+		}
+		return nil, metadata
+	}
+	if k.cursor.Before {
+		for i, j := 0, len(rows)-1; i < j; i, j = i+1, j-1 {
+			rows[i], rows[j] = rows[j], rows[i]
+		}
+		metadata.Next = cursorAt(rows[len(rows)-1], false)
+		if more {
+			metadata.Prev = cursorAt(rows[0], true)
+		}
+	} else {
+		metadata.Prev = cursorAt(rows[0], true)
+		if more {
+			metadata.Next = cursorAt(rows[len(rows)-1], false)
+		}
+	}
+	return rows, metadata
+}
@@ -0,0 +1,120 @@
+package data
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jackc/pgconn"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+	"strings"
+)
+
+// Dialect is the flavour of SQL spoken by the database behind the models. The queries are
+// written for Postgres, and only the parts SQLite has no equivalent for depend on the dialect.
+type Dialect int
+
+const (
+	Postgres Dialect = iota
+	SQLite
+)
+
+func (d Dialect) String() string {
+	switch d {
+	case SQLite:
+		return "sqlite"
+	default:
+		return "postgres"
+	}
+}
+
+// DriverName is the database/sql driver to open the dialect's DSNs with.
+func (d Dialect) DriverName() string {
+	switch d {
+	case SQLite:
+		return "sqlite"
+	default:
+		return "pgx"
+	}
+}
+
+// sqlitePragmas are set on every SQLite connection: foreign keys are off by default, and the
+// cascades depend on them.
+var sqlitePragmas = []string{"foreign_keys(1)", "busy_timeout(5000)", "journal_mode(WAL)"}
+
+// ParseDSN picks the dialect from the scheme of the DSN, and returns the data source name to
+// open with the dialect's driver. postgres:// and postgresql:// DSNs are passed on as they are,
+// sqlite:path/to/books.db and sqlite:///abs/path/books.db open (or create) a SQLite file.
+func ParseDSN(dsn string) (Dialect, string, error) {
+	scheme, rest, ok := strings.Cut(dsn, ":")
+	if !ok {
+		return 0, "", errors.New("the DSN has no scheme, expected postgres:// or sqlite:")
+	}
+	switch strings.ToLower(scheme) {
+	case "postgres", "postgresql":
+		return Postgres, dsn, nil
+	case "sqlite", "sqlite3":
+		path := strings.TrimPrefix(rest, "//")
+		if path == "" || strings.HasPrefix(path, "?") {
+			return 0, "", errors.New("the SQLite DSN has no file path")
+		}
+		if strings.Contains(path, ":memory:") || strings.Contains(path, "mode=memory") {
+			return 0, "", errors.New("in-memory SQLite databases do not outlive their connection, use a file")
+		}
+		separator := "?"
+		if strings.Contains(path, "?") {
+			separator = "&"
+		}
+		for _, pragma := range sqlitePragmas {
+			path += separator + "_pragma=" + pragma
+			separator = "&"
+		}
+		return SQLite, path, nil
+	default:
+		return 0, "", fmt.Errorf("unsupported DSN scheme %q, expected postgres:// or sqlite:", scheme)
+	}
+}
+
+// textSearch returns the condition matching column, of the table aliased as alias, against the words
+// of the search in param. An empty search matches every row. The search has to be passed through
+// searchArg first.
+func (d Dialect) textSearch(table, alias, column, param string) string {
+	switch d {
+	case SQLite:
+		// Every searchable column has a <table>_fts FTS5 table kept in sync by triggers. An empty
+		// match is a syntax error, the case keeps it from being evaluated.
+		return fmt.Sprintf(`(case when %s = '' then 1 else %s.id in (select rowid from %s_fts where %s_fts match %s) end)`, param, alias, table, table, param)
+	default:
+		return fmt.Sprintf(`(to_tsvector('simple', %s.%s) @@ plainto_tsquery('simple', %s) or %s = '')`, alias, column, param, param)
+	}
+}
+
+// searchArg converts a search to the argument expected by textSearch. For SQLite it becomes an FTS5
+// query where every word has to match, like plainto_tsquery does. A search without any word matches
+// nothing.
+func (d Dialect) searchArg(search string) string {
+	if d != SQLite || search == "" {
+		return search
+	}
+	var quoted []string
+	for _, word := range words(search) {
+		quoted = append(quoted, `"`+word+`"`)
+	}
+	if len(quoted) == 0 {
+		return `""`
+	}
+	return strings.Join(quoted, " ")
+}
+
+// isUniqueViolation reports whether err is the database rejecting a row because of the unique
+// constraint on table.column, named <table>_<column>_key in Postgres.
+func (d Dialect) isUniqueViolation(err error, table, column string) bool {
+	switch d {
+	case SQLite:
+		var sqliteErr *sqlite.Error
+		return errors.As(err, &sqliteErr) && sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE &&
+			strings.Contains(sqliteErr.Error(), "UNIQUE constraint failed: "+table+"."+column)
+	default:
+		var pgErr *pgconn.PgError
+		return errors.As(err, &pgErr) && pgErr.Code == "23505" && pgErr.ConstraintName == table+"_"+column+"_key"
+	}
+}
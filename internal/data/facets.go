@@ -0,0 +1,179 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// Facets count the books of a listing by genre, contributor, decade of publication and average rating,
+// so that clients can show how a search breaks down.
+type Facets struct {
+	Genres  []FacetCount `json:"genres"`
+	Authors []FacetCount `json:"authors"`
+	Decades []FacetCount `json:"decades"`
+	Ratings []FacetCount `json:"ratings"`
+}
+
+// FacetCount is the number of books with a value of a facet. ID is set for genres and authors.
+type FacetCount struct {
+	ID    int64  `json:"id,omitempty"`
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// maxAuthorFacets caps the authors facet, the authors with the most books are kept.
+const maxAuthorFacets = 20
+
+// ratingBuckets are the labels of the buckets of average ratings, the bucket of an average is the
+// index of its label. Books are rated by the reviews of their work, books without any are unrated.
+var ratingBuckets = []string{"unrated", "1-2", "2-3", "3-4", "4-5"}
+
+func ratingBucket(reviews []*Review) int {
+	if len(reviews) == 0 {
+		return 0
+	}
+	sum := 0
+	for _, review := range reviews {
+		sum += review.Rating
+	}
+	average := float64(sum) / float64(len(reviews))
+	return min(max(int(average), 1), 4)
+}
+
+func decade(year int) string {
+	return strconv.Itoa(year/10*10) + "s"
+}
+
+// GetFacets returns the facets of the books GetAll lists for the same title, publisher and filters,
+// over every page. The page and sort of the filters make no difference.
+func (b BookModel) GetFacets(ctx context.Context, title string, publisherID int64, filters Filters) (_ *Facets, err error) {
+	matching := fmt.Sprintf(`with matching as (select b.id, b.work_id, b.publication_year from books b where b.deleted_at is null and ($2 = 0 or b.publisher_id = $2) and %s) `,
+		b.Dialect.textSearch("books", "b", "title", "$1"))
+	ctx, cancel := context.WithTimeout(ctx, b.QueryTimeout)
+	defer cancel()
+	ctx, span := startSpan(ctx, b.Dialect, "BookModel.GetFacets", matching)
+	defer endSpan(span, &err)
+	search := b.Dialect.searchArg(title)
+
+	var facets Facets
+	facets.Genres, err = b.facetCounts(ctx, matching+`select g.id, g.genre_name, count(*) from matching m
+		join books_genres bg on bg.book_id = m.id join genres g on g.id = bg.genre_id
+		group by g.id, g.genre_name order by count(*) desc, g.genre_name asc`, search, publisherID)
+	if err != nil {
+		return nil, err
+	}
+	facets.Authors, err = b.facetCounts(ctx, matching+`select a.id, a.author_name, count(distinct m.id) from matching m
+		join book_contributors bc on bc.book_id = m.id join authors a on a.id = bc.author_id
+		group by a.id, a.author_name order by count(distinct m.id) desc, a.author_name asc, a.id asc limit $3`, search, publisherID, maxAuthorFacets)
+	if err != nil {
+		return nil, err
+	}
+	decades, err := b.facetCounts(ctx, matching+`select m.publication_year / 10 * 10, '', count(*) from matching m
+		group by m.publication_year / 10 * 10 order by 1`, search, publisherID)
+	if err != nil {
+		return nil, err
+	}
+	for _, count := range decades {
+		facets.Decades = append(facets.Decades, FacetCount{Value: decade(int(count.ID)), Count: count.Count})
+	}
+	ratings, err := b.facetCounts(ctx, matching+`select bucket, '', count(*) from (
+			select case when avg(r.rating) is null then 0 when avg(r.rating) < 2 then 1 when avg(r.rating) < 3 then 2
+				when avg(r.rating) < 4 then 3 else 4 end as bucket
+			from matching m left join books e on e.work_id = m.work_id left join reviews r on r.book_id = e.id and r.deleted_at is null group by m.id
+		) ratings group by bucket order by bucket`, search, publisherID)
+	if err != nil {
+		return nil, err
+	}
+	for _, count := range ratings {
+		facets.Ratings = append(facets.Ratings, FacetCount{Value: ratingBuckets[count.ID], Count: count.Count})
+	}
+	return &facets, nil
+}
+
+// facetCounts runs a facet query returning the id, value and count of each row.
+func (b BookModel) facetCounts(ctx context.Context, query string, args ...any) ([]FacetCount, error) {
+	rows, err := b.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var counts []FacetCount
+	for rows.Next() {
+		var count FacetCount
+		err := rows.Scan(&count.ID, &count.Value, &count.Count)
+		if err != nil {
+			return nil, err
+		}
+		counts = append(counts, count)
+	}
+	return counts, rows.Err()
+}
+
+func (b MemoryBookModel) GetFacets(ctx context.Context, title string, publisherID int64, filters Filters) (*Facets, error) {
+	b.store.mu.RLock()
+	defer b.store.mu.RUnlock()
+
+	genres := make(map[int64]int)
+	authors := make(map[int64]int)
+	decades := make(map[int]int)
+	ratings := make(map[int]int)
+	for _, row := range b.store.books {
+		if !matchesText(row.book.Title, title) || (publisherID != 0 && row.book.PublisherID != publisherID) {
+			continue
+		}
+		for _, id := range row.genreIDs {
+			genres[id]++
+		}
+		credited := make(map[int64]bool)
+		for _, contributor := range row.contributors {
+			if !credited[contributor.AuthorID] {
+				authors[contributor.AuthorID]++
+			}
+			credited[contributor.AuthorID] = true
+		}
+		decades[row.book.PublicationYear/10*10]++
+		ratings[ratingBucket(b.store.reviewsByBook(row.book.ID))]++
+	}
+
+	var facets Facets
+	for id, count := range genres {
+		facets.Genres = append(facets.Genres, FacetCount{ID: id, Value: b.store.genres[id].GenreName, Count: count})
+	}
+	for id, count := range authors {
+		facets.Authors = append(facets.Authors, FacetCount{ID: id, Value: b.store.authors[id].AuthorName, Count: count})
+	}
+	byCount := func(counts []FacetCount) {
+		sort.Slice(counts, func(i, j int) bool {
+			x, y := counts[i], counts[j]
+			if x.Count != y.Count {
+				return x.Count > y.Count
+			}
+			if x.Value != y.Value {
+				return x.Value < y.Value
+			}
+			return x.ID < y.ID
+		})
+	}
+	byCount(facets.Genres)
+	byCount(facets.Authors)
+	if len(facets.Authors) > maxAuthorFacets {
+		facets.Authors = facets.Authors[:maxAuthorFacets]
+	}
+
+	var years []int
+	for year := range decades {
+		years = append(years, year)
+	}
+	sort.Ints(years)
+	for _, year := range years {
+		facets.Decades = append(facets.Decades, FacetCount{Value: decade(year), Count: decades[year]})
+	}
+	for bucket, label := range ratingBuckets {
+		if ratings[bucket] > 0 {
+			facets.Ratings = append(facets.Ratings, FacetCount{Value: label, Count: ratings[bucket]})
+		}
+	}
+	return &facets, nil
+}
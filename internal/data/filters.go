@@ -11,14 +11,21 @@ type Filters struct {
 	PageSize     int
 	Sort         string
 	SortSafeList []string
+	// Cursor is a next or prev token from the metadata of a previous page, the page it points to is
+	// returned instead of Page.
+	Cursor string
+	// Cursors signs the cursor tokens, listings have no cursors without it.
+	Cursors *CursorCodec
 }
 
 type Metadata struct {
-	CurrentPage  int `json:"current_page,omitempty"`
-	PageSize     int `json:"page_size,omitempty"`
-	FirstPage    int `json:"first_page,omitempty"`
-	LastPage     int `json:"last_page,omitempty"`
-	TotalRecords int `json:"total_records,omitempty"`
+	CurrentPage  int    `json:"current_page,omitempty"`
+	PageSize     int    `json:"page_size,omitempty"`
+	FirstPage    int    `json:"first_page,omitempty"`
+	LastPage     int    `json:"last_page,omitempty"`
+	TotalRecords int    `json:"total_records,omitempty"`
+	Next         string `json:"next,omitempty"`
+	Prev         string `json:"prev,omitempty"`
 }
 
 func ValidateFilters(v *validator.Validator, f Filters) {
@@ -28,6 +35,13 @@ func ValidateFilters(v *validator.Validator, f Filters) {
 	v.Check(f.PageSize <= 100, "page_size", "must not be more than 100")
 
 	v.Check(validator.PermittedValue(f.Sort, f.SortSafeList...), "sort", "invalid sort value")
+
+	if f.Cursor != "" {
+		v.Check(f.Page == 1, "page", "must not be combined with a cursor")
+		cursor, err := f.Cursors.Decode(f.Cursor)
+		v.Check(err == nil, "cursor", "is invalid")
+		v.Check(err != nil || cursor.Sort == f.Sort, "sort", "must be the sort the cursor was issued for")
+	}
 }
 
 func (f Filters) sortColumn() string {
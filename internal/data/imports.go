@@ -0,0 +1,173 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// The statuses of an import. A failed import stopped before its last row, the rows processed
+// until then were imported.
+const (
+	ImportQueued    = "queued"
+	ImportRunning   = "running"
+	ImportCompleted = "completed"
+	ImportFailed    = "failed"
+)
+
+// Import is a bulk import of books. Its counts are updated while it runs, the rows that could not
+// be imported are kept as ImportRowErrors.
+type Import struct {
+	ID         int64      `json:"id"`
+	UserID     int64      `json:"user_id,omitempty"`
+	Format     string     `json:"format"`
+	Status     string     `json:"status"`
+	Total      int        `json:"total_rows"`
+	Processed  int        `json:"processed_rows"`
+	Created    int        `json:"created_rows"`
+	Failed     int        `json:"failed_rows"`
+	Error      string     `json:"error,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// ImportRowError is a row of an import that could not be imported, with the errors by field.
+type ImportRowError struct {
+	Line   int               `json:"line"`
+	Title  string            `json:"title,omitempty"`
+	Errors map[string]string `json:"errors"`
+}
+
+type Imports interface {
+	GetByID(ctx context.Context, id int64) (*Import, error)
+	Insert(ctx context.Context, imp *Import) error
+	Update(ctx context.Context, imp *Import) error
+	InsertErrors(ctx context.Context, id int64, rowErrors []ImportRowError) error
+	GetErrors(ctx context.Context, id int64) ([]ImportRowError, error)
+}
+
+type ImportModel struct {
+	DB           *sql.DB
+	Dialect      Dialect
+	QueryTimeout time.Duration
+}
+
+func NewImportModel(db *sql.DB, dialect Dialect, queryTimeout time.Duration) ImportModel {
+	return ImportModel{DB: db, Dialect: dialect, QueryTimeout: queryTimeout}
+}
+
+func (m ImportModel) GetByID(ctx context.Context, id int64) (_ *Import, err error) {
+	query := `select id, coalesce(user_id, 0), format, status, total_rows, processed_rows, created_rows, failed_rows, error, created_at, updated_at, finished_at
+			from imports where id = $1`
+	ctx, cancel := context.WithTimeout(ctx, m.QueryTimeout)
+	defer cancel()
+	ctx, span := startSpan(ctx, m.Dialect, "ImportModel.GetByID", query)
+	defer endSpan(span, &err)
+	var imp Import
+	var finishedAt sql.NullTime
+	err = m.DB.QueryRowContext(ctx, query, id).Scan(&imp.ID, &imp.UserID, &imp.Format, &imp.Status, &imp.Total, &imp.Processed,
+		&imp.Created, &imp.Failed, &imp.Error, &imp.CreatedAt, &imp.UpdatedAt, &finishedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNoRecordFound
+		default:
+			return nil, err
+		}
+	}
+	if finishedAt.Valid {
+		imp.FinishedAt = &finishedAt.Time
+	}
+	return &imp, nil
+}
+
+// Insert adds the import, queued unless it has a status.
+func (m ImportModel) Insert(ctx context.Context, imp *Import) (err error) {
+	query := `insert into imports (user_id, format, status, total_rows) values (nullif($1, 0), $2, $3, $4) returning id, created_at, updated_at`
+	ctx, cancel := context.WithTimeout(ctx, m.QueryTimeout)
+	defer cancel()
+	ctx, span := startSpan(ctx, m.Dialect, "ImportModel.Insert", query)
+	defer endSpan(span, &err)
+	if imp.Status == "" {
+		imp.Status = ImportQueued
+	}
+	return m.DB.QueryRowContext(ctx, query, imp.UserID, imp.Format, imp.Status, imp.Total).Scan(&imp.ID, &imp.CreatedAt, &imp.UpdatedAt)
+}
+
+// Update saves the status, counts, error and finish time of the import.
+func (m ImportModel) Update(ctx context.Context, imp *Import) (err error) {
+	query := `update imports set status = $1, total_rows = $2, processed_rows = $3, created_rows = $4, failed_rows = $5, error = $6,
+			finished_at = $7, updated_at = current_timestamp where id = $8 returning updated_at`
+	ctx, cancel := context.WithTimeout(ctx, m.QueryTimeout)
+	defer cancel()
+	ctx, span := startSpan(ctx, m.Dialect, "ImportModel.Update", query)
+	defer endSpan(span, &err)
+	err = m.DB.QueryRowContext(ctx, query, imp.Status, imp.Total, imp.Processed, imp.Created, imp.Failed, imp.Error, imp.FinishedAt,
+		imp.ID).Scan(&imp.UpdatedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrNoRecordFound
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+// InsertErrors adds rows that could not be imported to the report of the import.
+func (m ImportModel) InsertErrors(ctx context.Context, id int64, rowErrors []ImportRowError) (err error) {
+	query := `insert into import_errors (import_id, line, title, errors) values ($1, $2, $3, $4)`
+	ctx, cancel := context.WithTimeout(ctx, m.QueryTimeout)
+	defer cancel()
+	ctx, span := startSpan(ctx, m.Dialect, "ImportModel.InsertErrors", query)
+	defer endSpan(span, &err)
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, rowError := range rowErrors {
+		encoded, err := json.Marshal(rowError.Errors)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, query, id, rowError.Line, rowError.Title, string(encoded))
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// GetErrors returns the rows of the import that could not be imported, in the order of the file.
+func (m ImportModel) GetErrors(ctx context.Context, id int64) (_ []ImportRowError, err error) {
+	query := `select line, title, errors from import_errors where import_id = $1 order by line`
+	ctx, cancel := context.WithTimeout(ctx, m.QueryTimeout)
+	defer cancel()
+	ctx, span := startSpan(ctx, m.Dialect, "ImportModel.GetErrors", query)
+	defer endSpan(span, &err)
+	rows, err := m.DB.QueryContext(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var rowErrors []ImportRowError
+	for rows.Next() {
+		var rowError ImportRowError
+		var encoded string
+		err := rows.Scan(&rowError.Line, &rowError.Title, &encoded)
+		if err != nil {
+			return nil, err
+		}
+		err = json.Unmarshal([]byte(encoded), &rowError.Errors)
+		if err != nil {
+			return nil, err
+		}
+		rowErrors = append(rowErrors, rowError)
+	}
+	return rowErrors, rows.Err()
+}
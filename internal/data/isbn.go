@@ -0,0 +1,125 @@
+package data
+
+import (
+	"errors"
+	"github.com/rrebeiz/quickbooks/internal/validator"
+	"strings"
+)
+
+var ErrDuplicateISBN = errors.New("duplicate isbn found")
+
+// cleanISBN removes the hyphens and spaces ISBNs are printed with, and upper cases the X check
+// digit of ISBN-10s.
+func cleanISBN(isbn string) string {
+	return strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(strings.TrimSpace(isbn)))
+}
+
+// ValidISBN10 reports whether isbn, hyphens allowed, is 9 digits followed by a check digit or X
+// making the sum of the digits weighted 10 down to 1 a multiple of 11.
+func ValidISBN10(isbn string) bool {
+	isbn = cleanISBN(isbn)
+	if len(isbn) != 10 {
+		return false
+	}
+	sum := 0
+	for i, c := range isbn {
+		digit := int(c - '0')
+		switch {
+		case c == 'X' && i == 9:
+			digit = 10
+		case c < '0' || c > '9':
+			return false
+		}
+		sum += (10 - i) * digit
+	}
+	return sum%11 == 0
+}
+
+// ValidISBN13 reports whether isbn, hyphens allowed, is 13 digits starting with 978 or 979 whose
+// last one is the EAN-13 check digit of the others.
+func ValidISBN13(isbn string) bool {
+	isbn = cleanISBN(isbn)
+	if len(isbn) != 13 || !(strings.HasPrefix(isbn, "978") || strings.HasPrefix(isbn, "979")) {
+		return false
+	}
+	for _, c := range isbn {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return isbn13CheckDigit(isbn[:12]) == isbn[12]
+}
+
+func isbn13CheckDigit(first12 string) byte {
+	sum := 0
+	for i, c := range first12 {
+		weight := 1
+		if i%2 == 1 {
+			weight = 3
+		}
+		sum += weight * int(c-'0')
+	}
+	return byte('0' + (10-sum%10)%10)
+}
+
+func isbn10CheckDigit(first9 string) byte {
+	sum := 0
+	for i, c := range first9 {
+		sum += (10 - i) * int(c-'0')
+	}
+	check := (11 - sum%11) % 11
+	if check == 10 {
+		return 'X'
+	}
+	return byte('0' + check)
+}
+
+// ISBN10To13 converts a valid ISBN-10 to its ISBN-13, the same digits behind 978 with a new check
+// digit.
+func ISBN10To13(isbn10 string) string {
+	first12 := "978" + cleanISBN(isbn10)[:9]
+	return first12 + string(isbn13CheckDigit(first12))
+}
+
+// ISBN13To10 converts a valid ISBN-13 to its ISBN-10. Only ISBN-13s starting with 978 have one.
+func ISBN13To10(isbn13 string) (string, bool) {
+	isbn13 = cleanISBN(isbn13)
+	if !strings.HasPrefix(isbn13, "978") {
+		return "", false
+	}
+	first9 := isbn13[3:12]
+	return first9 + string(isbn10CheckDigit(first9)), true
+}
+
+// ParseISBN returns the ISBN-13 of isbn, an ISBN-10 or ISBN-13 with or without hyphens. Books
+// are stored and looked up by their ISBN-13.
+func ParseISBN(isbn string) (string, bool) {
+	switch {
+	case ValidISBN13(isbn):
+		return cleanISBN(isbn), true
+	case ValidISBN10(isbn):
+		return ISBN10To13(isbn), true
+	default:
+		return "", false
+	}
+}
+
+// setISBNs cleans the ISBNs of the book and fills in the one derived from the other. The ISBN-13
+// wins when both are set, ValidateBook checks that they agree.
+func (book *Book) setISBNs() {
+	book.ISBN10, book.ISBN13 = cleanISBN(book.ISBN10), cleanISBN(book.ISBN13)
+	if book.ISBN13 == "" && book.ISBN10 != "" {
+		book.ISBN13 = ISBN10To13(book.ISBN10)
+	}
+	if book.ISBN13 != "" {
+		book.ISBN10, _ = ISBN13To10(book.ISBN13)
+	}
+}
+
+func validateISBNs(v *validator.Validator, book *Book) {
+	v.Check(book.ISBN10 == "" || ValidISBN10(book.ISBN10), "isbn_10", "must be a valid ISBN-10")
+	v.Check(book.ISBN13 == "" || ValidISBN13(book.ISBN13), "isbn_13", "must be a valid ISBN-13")
+	if book.ISBN10 != "" && book.ISBN13 != "" && ValidISBN10(book.ISBN10) && ValidISBN13(book.ISBN13) {
+		v.Check(ISBN10To13(book.ISBN10) == cleanISBN(book.ISBN13), "isbn_10", "must be the ISBN-10 of isbn_13")
+	}
+}
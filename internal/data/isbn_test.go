@@ -0,0 +1,39 @@
+package data
+
+import "testing"
+
+func TestISBN(t *testing.T) {
+	tests := []struct {
+		isbn   string
+		valid  bool
+		isbn13 string
+		isbn10 string
+	}{
+		{isbn: "0-306-40615-2", valid: true, isbn13: "9780306406157", isbn10: "0306406152"},
+		{isbn: "978-0-306-40615-7", valid: true, isbn13: "9780306406157", isbn10: "0306406152"},
+		{isbn: "0-8044-2957-x", valid: true, isbn13: "9780804429573", isbn10: "080442957X"},
+		{isbn: "979 1000000008", valid: true, isbn13: "9791000000008"},
+		{isbn: "0-306-40615-3"},
+		{isbn: "978-0-306-40615-8"},
+		{isbn: "977-0-306-40615-2"},
+		{isbn: "X306406152"},
+		{isbn: "030640615"},
+		{isbn: ""},
+	}
+	for _, test := range tests {
+		isbn13, ok := ParseISBN(test.isbn)
+		if ok != test.valid || isbn13 != test.isbn13 {
+			t.Errorf("ParseISBN(%q) = %q, %t; want %q, %t", test.isbn, isbn13, ok, test.isbn13, test.valid)
+		}
+		if !ok {
+			continue
+		}
+		isbn10, ok := ISBN13To10(isbn13)
+		if isbn10 != test.isbn10 || ok != (test.isbn10 != "") {
+			t.Errorf("ISBN13To10(%q) = %q, %t; want %q", isbn13, isbn10, ok, test.isbn10)
+		}
+		if ok && ISBN10To13(isbn10) != isbn13 {
+			t.Errorf("ISBN10To13(%q) = %q; want %q", isbn10, ISBN10To13(isbn10), isbn13)
+		}
+	}
+}
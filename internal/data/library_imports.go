@@ -0,0 +1,188 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// The statuses of a library import. It is previewed until the user confirms it, and confirming
+// while the rows are being imported. A failed import stopped part way, its rows say how far and
+// confirming it again imports the rows still pending.
+const (
+	LibraryImportPreviewed  = "previewed"
+	LibraryImportConfirming = "confirming"
+	LibraryImportConfirmed  = "confirmed"
+	LibraryImportFailed     = "failed"
+)
+
+// How a row of a library import was matched to a book: by ISBN, by title and author, not at all
+// but with enough details to add the book (a candidate), or not at all.
+const (
+	MatchISBN        = "isbn"
+	MatchTitleAuthor = "title_author"
+	MatchCandidate   = "candidate"
+	MatchNone        = "none"
+)
+
+// The statuses of a row of a library import. Pending rows are imported when the import is
+// confirmed, skipped rows have the reason they are not.
+const (
+	LibraryRowPending  = "pending"
+	LibraryRowSkipped  = "skipped"
+	LibraryRowImported = "imported"
+	LibraryRowFailed   = "failed"
+)
+
+// LibraryImport is a user's reading history imported from another site, each row a book they
+// rated and possibly reviewed.
+type LibraryImport struct {
+	ID          int64              `json:"id"`
+	UserID      int64              `json:"-"`
+	Source      string             `json:"source"`
+	Status      string             `json:"status"`
+	Version     int                `json:"version"`
+	Rows        []LibraryImportRow `json:"rows"`
+	CreatedAt   time.Time          `json:"created_at"`
+	UpdatedAt   time.Time          `json:"updated_at"`
+	ConfirmedAt *time.Time         `json:"confirmed_at,omitempty"`
+}
+
+// LibraryImportRow is a row of the imported file, with the book it was matched to.
+type LibraryImportRow struct {
+	Line   int    `json:"line"`
+	Title  string `json:"title"`
+	Author string `json:"author"`
+	ISBN   string `json:"isbn,omitempty"`
+	Year   int    `json:"year,omitempty"`
+	Rating int    `json:"rating"`
+	Review string `json:"review,omitempty"`
+	Match  string `json:"match"`
+	BookID int64  `json:"book_id,omitempty"`
+	Status string `json:"status"`
+	Reason string `json:"reason,omitempty"`
+}
+
+type LibraryImports interface {
+	GetByID(ctx context.Context, id int64) (*LibraryImport, error)
+	Insert(ctx context.Context, imp *LibraryImport) error
+	Update(ctx context.Context, imp *LibraryImport) error
+}
+
+type LibraryImportModel struct {
+	DB           *sql.DB
+	Dialect      Dialect
+	QueryTimeout time.Duration
+}
+
+func NewLibraryImportModel(db *sql.DB, dialect Dialect, queryTimeout time.Duration) LibraryImportModel {
+	return LibraryImportModel{DB: db, Dialect: dialect, QueryTimeout: queryTimeout}
+}
+
+// GetByID returns the import with its rows, in the order of the file.
+func (m LibraryImportModel) GetByID(ctx context.Context, id int64) (_ *LibraryImport, err error) {
+	query := `select id, user_id, source, status, version, created_at, updated_at, confirmed_at from library_imports where id = $1`
+	rowsQuery := `select line, title, author, isbn, year, rating, review, match, coalesce(book_id, 0), status, reason
+			from library_import_rows where library_import_id = $1 order by line`
+	ctx, cancel := context.WithTimeout(ctx, m.QueryTimeout)
+	defer cancel()
+	ctx, span := startSpan(ctx, m.Dialect, "LibraryImportModel.GetByID", query)
+	defer endSpan(span, &err)
+	var imp LibraryImport
+	var confirmedAt sql.NullTime
+	err = m.DB.QueryRowContext(ctx, query, id).Scan(&imp.ID, &imp.UserID, &imp.Source, &imp.Status, &imp.Version, &imp.CreatedAt,
+		&imp.UpdatedAt, &confirmedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNoRecordFound
+		default:
+			return nil, err
+		}
+	}
+	if confirmedAt.Valid {
+		imp.ConfirmedAt = &confirmedAt.Time
+	}
+
+	rows, err := m.DB.QueryContext(ctx, rowsQuery, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	imp.Rows = []LibraryImportRow{}
+	for rows.Next() {
+		var row LibraryImportRow
+		err := rows.Scan(&row.Line, &row.Title, &row.Author, &row.ISBN, &row.Year, &row.Rating, &row.Review, &row.Match, &row.BookID,
+			&row.Status, &row.Reason)
+		if err != nil {
+			return nil, err
+		}
+		imp.Rows = append(imp.Rows, row)
+	}
+	return &imp, rows.Err()
+}
+
+// Insert adds the import and its rows, previewed unless it has a status.
+func (m LibraryImportModel) Insert(ctx context.Context, imp *LibraryImport) (err error) {
+	query := `insert into library_imports (user_id, source, status) values ($1, $2, $3) returning id, version, created_at, updated_at`
+	rowQuery := `insert into library_import_rows (library_import_id, line, title, author, isbn, year, rating, review, match, book_id, status, reason)
+			values ($1, $2, $3, $4, $5, $6, $7, $8, $9, nullif($10, 0), $11, $12)`
+	ctx, cancel := context.WithTimeout(ctx, m.QueryTimeout)
+	defer cancel()
+	ctx, span := startSpan(ctx, m.Dialect, "LibraryImportModel.Insert", query)
+	defer endSpan(span, &err)
+	if imp.Status == "" {
+		imp.Status = LibraryImportPreviewed
+	}
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	err = tx.QueryRowContext(ctx, query, imp.UserID, imp.Source, imp.Status).Scan(&imp.ID, &imp.Version, &imp.CreatedAt, &imp.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	for _, row := range imp.Rows {
+		_, err = tx.ExecContext(ctx, rowQuery, imp.ID, row.Line, row.Title, row.Author, row.ISBN, row.Year, row.Rating, row.Review, row.Match,
+			row.BookID, row.Status, row.Reason)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Update saves the status and confirmation time of the import, and the book, status and reason
+// of the rows it has, which can be some of the rows only. It returns ErrNoRecordFound when the import was changed since it was read.
+func (m LibraryImportModel) Update(ctx context.Context, imp *LibraryImport) (err error) {
+	query := `update library_imports set status = $1, confirmed_at = $2, version = version + 1, updated_at = current_timestamp
+			where id = $3 and version = $4 returning version, updated_at`
+	rowQuery := `update library_import_rows set book_id = nullif($1, 0), status = $2, reason = $3 where library_import_id = $4 and line = $5`
+	ctx, cancel := context.WithTimeout(ctx, m.QueryTimeout)
+	defer cancel()
+	ctx, span := startSpan(ctx, m.Dialect, "LibraryImportModel.Update", query)
+	defer endSpan(span, &err)
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	err = tx.QueryRowContext(ctx, query, imp.Status, imp.ConfirmedAt, imp.ID, imp.Version).Scan(&imp.Version, &imp.UpdatedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrNoRecordFound
+		default:
+			return err
+		}
+	}
+	for _, row := range imp.Rows {
+		_, err = tx.ExecContext(ctx, rowQuery, row.BookID, row.Status, row.Reason, imp.ID, row.Line)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,188 @@
+package data
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// memoryStore holds the tables shared by the in-memory models, so that deleting a user or a book
+// cascades to its reviews and tokens like the foreign keys do in Postgres.
+type memoryStore struct {
+	mu         sync.RWMutex
+	authors    map[int64]*Author
+	genres     map[int64]*Genre
+	works      map[int64]*Work
+	series     map[int64]*Series
+	publishers map[int64]*Publisher
+	books      map[int64]*memoryBook
+	// slugs are the slugs books had before being renamed, with the book that had them.
+	slugs   map[string]int64
+	reviews map[int64]*Review
+	users   map[int64]*User
+	tokens  map[int64]*Token
+	imports map[int64]*memoryImport
+	// libraryImports keep their rows, which stand in for library_import_rows.
+	libraryImports map[int64]*LibraryImport
+	// trashedBooks, trashedReviews and trashedUsers are the records in the trash, kept out of the
+	// tables above so that the models only see the live ones.
+	trashedBooks   map[int64]trashed[*memoryBook]
+	trashedReviews map[int64]trashed[*Review]
+	trashedUsers   map[int64]trashed[*User]
+	lastID         map[string]int64
+}
+
+// trashed is a record in the trash, with the time it was deleted. The with of a review is the
+// deleted_with column.
+type trashed[T any] struct {
+	record    T
+	deletedAt time.Time
+	with      string
+}
+
+// memoryBook is a row of the books table, its genres stand in for books_genres.
+type memoryBook struct {
+	book         Book
+	genreIDs     []int64
+	contributors []Contributor
+	series       []SeriesEntry
+	covers       []Cover
+}
+
+func newMemoryStore() *memoryStore {
+	s := &memoryStore{
+		authors:        make(map[int64]*Author),
+		genres:         make(map[int64]*Genre),
+		works:          make(map[int64]*Work),
+		series:         make(map[int64]*Series),
+		publishers:     make(map[int64]*Publisher),
+		books:          make(map[int64]*memoryBook),
+		slugs:          make(map[string]int64),
+		reviews:        make(map[int64]*Review),
+		users:          make(map[int64]*User),
+		tokens:         make(map[int64]*Token),
+		imports:        make(map[int64]*memoryImport),
+		libraryImports: make(map[int64]*LibraryImport),
+		trashedBooks:   make(map[int64]trashed[*memoryBook]),
+		trashedReviews: make(map[int64]trashed[*Review]),
+		trashedUsers:   make(map[int64]trashed[*User]),
+		lastID:         make(map[string]int64),
+	}
+	for _, name := range Genres {
+		id := s.nextID("genres")
+		created := now()
+		s.genres[id] = &Genre{ID: id, GenreName: name, CreatedAt: created, UpdatedAt: created}
+	}
+	return s
+}
+
+// NewMemoryModels returns models keeping everything in memory, with the same behaviour as the
+// Postgres ones. They are meant for tests and for trying the API out without a database.
+func NewMemoryModels() Models {
+	store := newMemoryStore()
+	return Models{
+		Books:          MemoryBookModel{store: store},
+		Series:         MemorySeriesModel{store: store},
+		Publishers:     MemoryPublisherModel{store: store},
+		Users:          MemoryUserModel{store: store},
+		Tokens:         MemoryTokenModel{store: store},
+		Imports:        MemoryImportModel{store: store},
+		LibraryImports: MemoryLibraryImportModel{store: store},
+		Trash:          MemoryTrashModel{store: store},
+	}
+}
+
+// nextID works like a serial column, the caller must hold the write lock.
+func (s *memoryStore) nextID(table string) int64 {
+	s.lastID[table]++
+	return s.lastID[table]
+}
+
+// now matches the timestamp(0) columns, which only keep whole seconds.
+func now() time.Time {
+	return time.Now().Truncate(time.Second)
+}
+
+// page picks the page of the filters from the sorted records, and computes the metadata the same
+// way the count(*) over() queries do: no rows on an offset page means no metadata. keys returns the
+// values of the keyset columns of a record.
+func page[T any](records []T, k keyset, keys func(T) []any) ([]T, Metadata) {
+	if k.cursor == nil {
+		total := len(records)
+		start := k.filters.offset()
+		if start >= total {
+			return finish(k, nil, 0, keys)
+		}
+		end := start + k.filters.limit()
+		if end > total {
+			end = total
+		}
+		return finish(k, records[start:end], total, keys)
+	}
+
+	ordered := records
+	if k.cursor.Before {
+		ordered = make([]T, len(records))
+		for i, record := range records {
+			ordered[len(records)-1-i] = record
+		}
+	}
+	var rows []T
+	for _, record := range ordered {
+		if len(rows) > k.filters.limit() {
+			break
+		}
+		if k.after(keys(record)) {
+			rows = append(rows, record)
+		}
+	}
+	return finish(k, rows, 0, keys)
+}
+
+// sortRecords sorts by the filters' sort column, less compares two records on that column.
+// Ties are broken by tiebreak, the equivalent of the trailing "id asc" of the SQL queries.
+func sortRecords[T any](records []T, filters Filters, less func(a, b T, column string) bool, tiebreak func(a, b T) bool) {
+	column := filters.sortColumn()
+	desc := filters.sortDirection() == "DESC"
+	sort.SliceStable(records, func(i, j int) bool {
+		a, b := records[i], records[j]
+		switch {
+		case less(a, b, column):
+			return !desc
+		case less(b, a, column):
+			return desc
+		default:
+			return tiebreak(a, b)
+		}
+	})
+}
+
+// matchesText mimics the to_tsvector('simple', ...) @@ plainto_tsquery('simple', ...) searches: every
+// word of the query has to be one of the words of the text, ignoring case. An empty query matches everything.
+func matchesText(text, query string) bool {
+	if query == "" {
+		return true
+	}
+	queryWords := words(query)
+	if len(queryWords) == 0 {
+		return false
+	}
+	textWords := make(map[string]bool)
+	for _, word := range words(text) {
+		textWords[word] = true
+	}
+	for _, word := range queryWords {
+		if !textWords[word] {
+			return false
+		}
+	}
+	return true
+}
+
+func words(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
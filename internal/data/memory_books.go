@@ -0,0 +1,715 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// MemoryBookModel is the in-memory implementation of Books.
+type MemoryBookModel struct {
+	store *memoryStore
+}
+
+func (b MemoryBookModel) GetAll(ctx context.Context, title string, publisherID int64, filters Filters) ([]*Book, Metadata, error) {
+	keys, err := filters.keyset("books", filters.sortColumn(), "id")
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	b.store.mu.RLock()
+	defer b.store.mu.RUnlock()
+
+	var books []*Book
+	for _, row := range b.store.books {
+		if matchesText(row.book.Title, title) && (publisherID == 0 || row.book.PublisherID == publisherID) {
+			books = append(books, b.store.bookWithJoins(row, true))
+		}
+	}
+	sortRecords(books, filters, func(x, y *Book, column string) bool {
+		switch column {
+		case "title":
+			return x.Title < y.Title
+		case "publication_year":
+			return x.PublicationYear < y.PublicationYear
+		default:
+			return x.ID < y.ID
+		}
+	}, func(x, y *Book) bool {
+		return x.ID < y.ID
+	})
+	books, metadata := page(books, keys, func(book *Book) []any {
+		return []any{bookSortKey(book, filters.sortColumn()), book.ID}
+	})
+	return books, metadata, nil
+}
+
+func (b MemoryBookModel) GetByID(ctx context.Context, id int64) (*Book, error) {
+	b.store.mu.RLock()
+	defer b.store.mu.RUnlock()
+
+	row, ok := b.store.books[id]
+	if !ok {
+		return nil, ErrNoRecordFound
+	}
+	return b.store.bookWithJoins(row, true), nil
+}
+
+func (b MemoryBookModel) GetBySlug(ctx context.Context, slug string) (*Book, error) {
+	b.store.mu.RLock()
+	defer b.store.mu.RUnlock()
+
+	for _, row := range b.store.books {
+		if row.book.Slug == slug {
+			return b.store.bookWithJoins(row, false), nil
+		}
+	}
+	if row, ok := b.store.books[b.store.slugs[slug]]; ok {
+		return b.store.bookWithJoins(row, false), nil
+	}
+	return nil, ErrNoRecordFound
+}
+
+func (b MemoryBookModel) GetByISBN(ctx context.Context, isbn13 string) (*Book, error) {
+	b.store.mu.RLock()
+	defer b.store.mu.RUnlock()
+
+	for _, row := range b.store.books {
+		if row.book.ISBN13 != "" && row.book.ISBN13 == isbn13 {
+			return b.store.bookWithJoins(row, true), nil
+		}
+	}
+	return nil, ErrNoRecordFound
+}
+
+func (b MemoryBookModel) GetByTitleAndAuthor(ctx context.Context, title, author string) (*Book, error) {
+	b.store.mu.RLock()
+	defer b.store.mu.RUnlock()
+
+	var found *memoryBook
+	for _, row := range b.store.books {
+		a, ok := b.store.authors[int64(row.book.AuthorID)]
+		if !ok || !strings.EqualFold(row.book.Title, title) || !strings.EqualFold(a.AuthorName, author) {
+			continue
+		}
+		if found == nil || row.book.ID < found.book.ID {
+			found = row
+		}
+	}
+	if found == nil {
+		return nil, ErrNoRecordFound
+	}
+	return b.store.bookWithJoins(found, true), nil
+}
+
+func (b MemoryBookModel) Insert(ctx context.Context, book *Book) error {
+	b.store.mu.Lock()
+	defer b.store.mu.Unlock()
+
+	book.setContributors()
+	contributors, err := b.store.contributors(book.Contributors)
+	if err != nil {
+		return err
+	}
+	series, err := b.store.seriesEntries(book.Series)
+	if err != nil {
+		return err
+	}
+	if _, ok := b.store.publishers[book.PublisherID]; book.PublisherID != 0 && !ok {
+		return fmt.Errorf("publisher %d does not exist", book.PublisherID)
+	}
+	book.setISBNs()
+	if b.store.isbnTaken(book.ISBN13, 0) {
+		return ErrDuplicateISBN
+	}
+	genreIDs, err := b.store.genreIDs(book.Genres)
+	if err != nil {
+		return err
+	}
+	created := now()
+	workID := book.WorkID
+	if workID == 0 {
+		workID = b.store.nextID("works")
+		b.store.works[workID] = &Work{ID: workID, Title: book.Title, CreatedAt: created, UpdatedAt: created}
+	} else if _, ok := b.store.works[workID]; !ok {
+		return fmt.Errorf("work %d does not exist", workID)
+	}
+	row := &memoryBook{book: Book{
+		ID:              b.store.nextID("books"),
+		Title:           book.Title,
+		AuthorID:        book.AuthorID,
+		PublicationYear: book.PublicationYear,
+		Slug:            b.store.slug(book.Title, 0, ""),
+		Description:     book.Description,
+		Language:        book.Language,
+		ISBN10:          book.ISBN10,
+		ISBN13:          book.ISBN13,
+		WorkID:          workID,
+		Format:          book.Format,
+		PublisherID:     book.PublisherID,
+		PageCount:       book.PageCount,
+		CreatedAt:       created,
+		UpdatedAt:       created,
+	}, contributors: contributors, series: series, genreIDs: genreIDs}
+	if row.book.Language == "" {
+		row.book.Language = "simple"
+	}
+	b.store.books[row.book.ID] = row
+	book.ID = row.book.ID
+	book.WorkID = row.book.WorkID
+	book.Language = row.book.Language
+	book.Slug = row.book.Slug
+	joined := b.store.bookWithJoins(row, false)
+	book.Contributors = joined.Contributors
+	book.Series = joined.Series
+	book.Publisher = joined.Publisher
+	return nil
+}
+
+func (b MemoryBookModel) Update(ctx context.Context, book *Book) error {
+	b.store.mu.Lock()
+	defer b.store.mu.Unlock()
+
+	row, ok := b.store.books[book.ID]
+	if !ok {
+		return ErrNoRecordFound
+	}
+	book.setContributors()
+	contributors, err := b.store.contributors(book.Contributors)
+	if err != nil {
+		return err
+	}
+	series, err := b.store.seriesEntries(book.Series)
+	if err != nil {
+		return err
+	}
+	if _, ok := b.store.publishers[book.PublisherID]; book.PublisherID != 0 && !ok {
+		return fmt.Errorf("publisher %d does not exist", book.PublisherID)
+	}
+	book.setISBNs()
+	if b.store.isbnTaken(book.ISBN13, book.ID) {
+		return ErrDuplicateISBN
+	}
+	genreIDs, err := b.store.genreIDs(book.Genres)
+	if err != nil {
+		return err
+	}
+
+	row.book.Title = book.Title
+	row.book.AuthorID = book.AuthorID
+	row.book.PublicationYear = book.PublicationYear
+	previous := row.book.Slug
+	row.book.Slug = b.store.slug(book.Title, book.ID, previous)
+	b.store.keepSlug(book.ID, previous, row.book.Slug)
+	row.book.Description = book.Description
+	row.book.Language = book.Language
+	row.book.ISBN10 = book.ISBN10
+	row.book.ISBN13 = book.ISBN13
+	row.book.Format = book.Format
+	row.book.PublisherID = book.PublisherID
+	row.book.PageCount = book.PageCount
+	if row.book.Language == "" {
+		row.book.Language = "simple"
+	}
+	row.book.UpdatedAt = now()
+	if len(genreIDs) > 0 {
+		row.genreIDs = genreIDs
+	}
+	row.contributors = contributors
+	if book.Series != nil {
+		row.series = series
+	}
+	book.Slug = row.book.Slug
+	book.Language = row.book.Language
+	book.UpdatedAt = row.book.UpdatedAt
+	joined := b.store.bookWithJoins(row, false)
+	book.Contributors = joined.Contributors
+	book.Series = joined.Series
+	book.Publisher = joined.Publisher
+	return nil
+}
+
+func (b MemoryBookModel) Delete(ctx context.Context, id int64) error {
+	b.store.mu.Lock()
+	defer b.store.mu.Unlock()
+
+	row, ok := b.store.books[id]
+	if !ok {
+		return ErrNoRecordFound
+	}
+	deletedAt := now()
+	delete(b.store.books, id)
+	b.store.trashedBooks[id] = trashed[*memoryBook]{record: row, deletedAt: deletedAt}
+	b.store.trashReviews(TrashBook, id, deletedAt)
+	return nil
+}
+
+func (b MemoryBookModel) Restore(ctx context.Context, id int64) error {
+	b.store.mu.Lock()
+	defer b.store.mu.Unlock()
+
+	t, ok := b.store.trashedBooks[id]
+	if !ok {
+		return ErrNoRecordFound
+	}
+	delete(b.store.trashedBooks, id)
+	b.store.books[id] = t.record
+	b.store.restoreReviews(TrashBook, id)
+	return nil
+}
+
+func (b MemoryBookModel) GetAllAuthors(ctx context.Context, author string, filters Filters) ([]*Author, Metadata, error) {
+	keys, err := filters.keyset("authors", filters.sortColumn(), "id")
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	b.store.mu.RLock()
+	defer b.store.mu.RUnlock()
+
+	var authors []*Author
+	for _, a := range b.store.authors {
+		if matchesText(a.AuthorName, author) {
+			authors = append(authors, &Author{ID: a.ID, AuthorName: a.AuthorName, BookCount: b.store.bookCount(a.ID)})
+		}
+	}
+	sortRecords(authors, filters, func(x, y *Author, column string) bool {
+		switch column {
+		case "author_name":
+			return x.AuthorName < y.AuthorName
+		default:
+			return x.ID < y.ID
+		}
+	}, func(x, y *Author) bool {
+		return x.ID < y.ID
+	})
+	authors, metadata := page(authors, keys, func(author *Author) []any {
+		return []any{authorSortKey(author, filters.sortColumn()), author.ID}
+	})
+	return authors, metadata, nil
+}
+
+func (b MemoryBookModel) GetAuthorByID(ctx context.Context, id int64) (*Author, error) {
+	b.store.mu.RLock()
+	defer b.store.mu.RUnlock()
+
+	found, ok := b.store.authors[id]
+	if !ok {
+		return nil, ErrNoRecordFound
+	}
+	author := *found
+	return &author, nil
+}
+
+func (b MemoryBookModel) GetAuthorByName(ctx context.Context, name string) (*Author, error) {
+	b.store.mu.RLock()
+	defer b.store.mu.RUnlock()
+
+	var found *Author
+	for _, author := range b.store.authors {
+		if strings.EqualFold(author.AuthorName, name) && (found == nil || author.ID < found.ID) {
+			found = author
+		}
+	}
+	if found == nil {
+		return nil, ErrNoRecordFound
+	}
+	author := *found
+	return &author, nil
+}
+
+func (b MemoryBookModel) InsertAuthor(ctx context.Context, author *Author) error {
+	b.store.mu.Lock()
+	defer b.store.mu.Unlock()
+
+	created := now()
+	author.ID = b.store.nextID("authors")
+	author.CreatedAt = created
+	author.UpdatedAt = created
+	author.Version = 1
+	a := *author
+	b.store.authors[a.ID] = &a
+	return nil
+}
+
+func (b MemoryBookModel) GetAllReviewsByUser(ctx context.Context, user string, filters Filters) ([]*Review, Metadata, error) {
+	keys, err := filters.keyset("reviews", filters.sortColumn(), "user_id", "id")
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	b.store.mu.RLock()
+	defer b.store.mu.RUnlock()
+
+	var reviews []*Review
+	for _, r := range b.store.reviews {
+		u, ok := b.store.users[r.UserID]
+		if !ok || !matchesText(u.Name, user) {
+			continue
+		}
+		row, ok := b.store.books[r.BookID]
+		if !ok {
+			continue
+		}
+		reviews = append(reviews, &Review{
+			ID:     r.ID,
+			Rating: r.Rating,
+			Review: r.Review,
+			BookID: r.BookID,
+			Book:   row.book.Title,
+			UserID: r.UserID,
+			User:   u.Name,
+		})
+	}
+	sortRecords(reviews, filters, func(x, y *Review, column string) bool {
+		switch column {
+		case "user":
+			return x.User < y.User
+		default:
+			return x.UserID < y.UserID
+		}
+	}, func(x, y *Review) bool {
+		if x.UserID != y.UserID {
+			return x.UserID < y.UserID
+		}
+		return x.ID < y.ID
+	})
+	reviews, metadata := page(reviews, keys, func(review *Review) []any {
+		return []any{reviewSortKey(review, filters.sortColumn()), review.UserID, review.ID}
+	})
+	return reviews, metadata, nil
+}
+
+func (b MemoryBookModel) GetReviewByID(ctx context.Context, id int64) (*Review, error) {
+	b.store.mu.RLock()
+	defer b.store.mu.RUnlock()
+
+	r, ok := b.store.reviews[id]
+	if !ok {
+		return nil, ErrNoRecordFound
+	}
+	review := *r
+	return &review, nil
+}
+
+func (b MemoryBookModel) GetReviewedBookIDs(ctx context.Context, userID int64) ([]int64, error) {
+	b.store.mu.RLock()
+	defer b.store.mu.RUnlock()
+
+	seen := make(map[int64]bool)
+	var ids []int64
+	for _, review := range b.store.reviews {
+		if review.UserID == userID && !seen[review.BookID] {
+			seen[review.BookID] = true
+			ids = append(ids, review.BookID)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i] < ids[j]
+	})
+	return ids, nil
+}
+
+func (b MemoryBookModel) InsertReview(ctx context.Context, review *Review) error {
+	b.store.mu.Lock()
+	defer b.store.mu.Unlock()
+
+	if _, ok := b.store.books[review.BookID]; !ok {
+		return ErrNoRecordFound
+	}
+	if _, ok := b.store.users[review.UserID]; !ok {
+		return ErrNoRecordFound
+	}
+	created := now()
+	review.ID = b.store.nextID("reviews")
+	review.Version = 1
+	b.store.reviews[review.ID] = &Review{
+		ID:        review.ID,
+		Rating:    review.Rating,
+		Review:    review.Review,
+		BookID:    review.BookID,
+		UserID:    review.UserID,
+		Version:   review.Version,
+		CreatedAt: created,
+		UpdatedAt: created,
+	}
+	return nil
+}
+
+func (b MemoryBookModel) UpdateReview(ctx context.Context, review *Review) error {
+	b.store.mu.Lock()
+	defer b.store.mu.Unlock()
+
+	r, ok := b.store.reviews[review.ID]
+	if !ok || r.Version != review.Version {
+		return ErrNoRecordFound
+	}
+	r.Rating = review.Rating
+	r.Review = review.Review
+	r.UpdatedAt = now()
+	r.Version++
+	review.Version = r.Version
+	return nil
+}
+
+func (b MemoryBookModel) DeleteReview(ctx context.Context, id int64) error {
+	b.store.mu.Lock()
+	defer b.store.mu.Unlock()
+
+	review, ok := b.store.reviews[id]
+	if !ok {
+		return ErrNoRecordFound
+	}
+	delete(b.store.reviews, id)
+	b.store.trashedReviews[id] = trashed[*Review]{record: review, deletedAt: now()}
+	return nil
+}
+
+func (b MemoryBookModel) RestoreReview(ctx context.Context, id int64) error {
+	b.store.mu.Lock()
+	defer b.store.mu.Unlock()
+
+	t, ok := b.store.trashedReviews[id]
+	if !ok {
+		return ErrNoRecordFound
+	}
+	_, bookFound := b.store.books[t.record.BookID]
+	_, userFound := b.store.users[t.record.UserID]
+	if !bookFound || !userFound {
+		return ErrNoRecordFound
+	}
+	delete(b.store.trashedReviews, id)
+	b.store.reviews[id] = t.record
+	return nil
+}
+
+// trashReviews is the trashReviews of the SQL models, the caller must hold the write lock.
+func (s *memoryStore) trashReviews(parent string, id int64, deletedAt time.Time) {
+	for reviewID, review := range s.reviews {
+		if reviewParent(review, parent) == id {
+			delete(s.reviews, reviewID)
+			s.trashedReviews[reviewID] = trashed[*Review]{record: review, deletedAt: deletedAt, with: parent}
+		}
+	}
+}
+
+// restoreReviews is the restoreReviews of the SQL models, the caller must hold the write lock.
+func (s *memoryStore) restoreReviews(parent string, id int64) {
+	for reviewID, t := range s.trashedReviews {
+		if t.with != parent || reviewParent(t.record, parent) != id {
+			continue
+		}
+		_, bookFound := s.books[t.record.BookID]
+		_, userFound := s.users[t.record.UserID]
+		switch {
+		case bookFound && userFound:
+			delete(s.trashedReviews, reviewID)
+			s.reviews[reviewID] = t.record
+		case parent == TrashBook:
+			s.trashedReviews[reviewID] = trashed[*Review]{record: t.record, deletedAt: s.trashedUsers[t.record.UserID].deletedAt, with: TrashUser}
+		default:
+			s.trashedReviews[reviewID] = trashed[*Review]{record: t.record, deletedAt: s.trashedBooks[t.record.BookID].deletedAt, with: TrashBook}
+		}
+	}
+}
+
+// reviewParent is the ID of the book or user of the review.
+func reviewParent(review *Review, parent string) int64 {
+	if parent == TrashBook {
+		return review.BookID
+	}
+	return review.UserID
+}
+
+func (b MemoryBookModel) SetCovers(ctx context.Context, bookID int64, covers []Cover) ([]Cover, error) {
+	b.store.mu.Lock()
+	defer b.store.mu.Unlock()
+
+	row, ok := b.store.books[bookID]
+	if !ok {
+		return nil, ErrNoRecordFound
+	}
+	previous := row.covers
+	row.covers = append([]Cover(nil), covers...)
+	sort.SliceStable(row.covers, func(i, j int) bool {
+		if row.covers[i].Width != row.covers[j].Width {
+			return row.covers[i].Width < row.covers[j].Width
+		}
+		return row.covers[i].Size < row.covers[j].Size
+	})
+	return previous, nil
+}
+
+// bookWithJoins returns a copy of the book with its author and genres, and its reviews
+// when withReviews is set, the way the book queries return it.
+func (s *memoryStore) bookWithJoins(row *memoryBook, withReviews bool) *Book {
+	book := row.book
+	if author, ok := s.authors[int64(book.AuthorID)]; ok {
+		book.Author = *author
+	}
+	for _, id := range row.genreIDs {
+		book.Genres = append(book.Genres, s.genres[id].GenreName)
+	}
+	book.Contributors = nil
+	for _, contributor := range row.contributors {
+		contributor.AuthorName = s.authors[contributor.AuthorID].AuthorName
+		book.Contributors = append(book.Contributors, contributor)
+	}
+	book.Series = nil
+	for _, entry := range row.series {
+		entry.Name = s.series[entry.SeriesID].Name
+		book.Series = append(book.Series, entry)
+	}
+	sort.Slice(book.Series, func(i, j int) bool {
+		if book.Series[i].Name != book.Series[j].Name {
+			return book.Series[i].Name < book.Series[j].Name
+		}
+		return book.Series[i].SeriesID < book.Series[j].SeriesID
+	})
+	book.Covers = append([]Cover(nil), row.covers...)
+	book.Publisher = nil
+	if publisher, ok := s.publishers[book.PublisherID]; ok {
+		book.Publisher = &Publisher{ID: publisher.ID, Name: publisher.Name}
+	}
+	sort.Strings(book.Genres)
+	if withReviews {
+		book.Reviews = s.reviewsByBook(book.ID)
+	}
+	return &book
+}
+
+// contributors checks that the authors of the contributors exist and returns a copy of them
+// without the author names, which bookWithJoins reads from the authors.
+func (s *memoryStore) contributors(contributors []Contributor) ([]Contributor, error) {
+	var copied []Contributor
+	for _, contributor := range contributors {
+		if _, ok := s.authors[contributor.AuthorID]; !ok {
+			return nil, fmt.Errorf("author %d does not exist", contributor.AuthorID)
+		}
+		copied = append(copied, Contributor{AuthorID: contributor.AuthorID, Role: contributor.Role})
+	}
+	return copied, nil
+}
+
+// slug is BookModel.setSlug for the book with id, 0 for a new one, and its current slug.
+func (s *memoryStore) slug(title string, id int64, current string) string {
+	base := baseSlug(title)
+	if current != "" && derivesFrom(current, base) {
+		return current
+	}
+	return uniqueSlug(base, func(slug string) bool {
+		for _, row := range s.books {
+			if row.book.ID != id && row.book.Slug == slug {
+				return true
+			}
+		}
+		for _, t := range s.trashedBooks {
+			if t.record.book.Slug == slug {
+				return true
+			}
+		}
+		owner, ok := s.slugs[slug]
+		return ok && owner != id
+	})
+}
+
+func (b MemoryBookModel) GetOldSlugs(ctx context.Context, id int64) ([]string, error) {
+	b.store.mu.RLock()
+	defer b.store.mu.RUnlock()
+
+	var slugs []string
+	for slug, owner := range b.store.slugs {
+		if owner == id {
+			slugs = append(slugs, slug)
+		}
+	}
+	sort.Strings(slugs)
+	return slugs, nil
+}
+
+// keepSlug is BookModel.keepSlug.
+func (s *memoryStore) keepSlug(id int64, previous, slug string) {
+	if previous == slug {
+		return
+	}
+	if _, ok := s.slugs[previous]; !ok {
+		s.slugs[previous] = id
+	}
+	if owner, ok := s.slugs[slug]; ok && owner == id {
+		delete(s.slugs, slug)
+	}
+}
+
+// isbnTaken reports whether a book other than the one with id has the ISBN-13, the books in the
+// trash included.
+func (s *memoryStore) isbnTaken(isbn13 string, id int64) bool {
+	for _, row := range s.books {
+		if isbn13 != "" && row.book.ISBN13 == isbn13 && row.book.ID != id {
+			return true
+		}
+	}
+	for _, t := range s.trashedBooks {
+		if isbn13 != "" && t.record.book.ISBN13 == isbn13 {
+			return true
+		}
+	}
+	return false
+}
+
+// bookCount is the number of books the author is a contributor of, whatever the roles.
+func (s *memoryStore) bookCount(authorID int64) int {
+	count := 0
+	for _, row := range s.books {
+		for _, contributor := range row.contributors {
+			if contributor.AuthorID == authorID {
+				count++
+				break
+			}
+		}
+	}
+	return count
+}
+
+// reviewsByBook returns the reviews of every edition of the book's work.
+func (s *memoryStore) reviewsByBook(id int64) []*Review {
+	row, ok := s.books[id]
+	if !ok {
+		return nil
+	}
+	var reviews []*Review
+	for _, r := range s.reviews {
+		u, ok := s.users[r.UserID]
+		edition, found := s.books[r.BookID]
+		if !ok || !found || edition.book.WorkID != row.book.WorkID {
+			continue
+		}
+		review := *r
+		review.User = u.Name
+		reviews = append(reviews, &review)
+	}
+	sort.Slice(reviews, func(i, j int) bool {
+		return reviews[i].ID < reviews[j].ID
+	})
+	return reviews
+}
+
+func (s *memoryStore) genreID(name string) (int64, bool) {
+	for id, genre := range s.genres {
+		if genre.GenreName == name {
+			return id, true
+		}
+	}
+	return 0, false
+}
+
+// genreIDs returns the IDs of the genres with the names.
+func (s *memoryStore) genreIDs(names []string) ([]int64, error) {
+	var ids []int64
+	for _, name := range names {
+		id, ok := s.genreID(name)
+		if !ok {
+			return nil, fmt.Errorf("genre %q does not exist", name)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
@@ -0,0 +1,114 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// MemoryImportModel is the in-memory implementation of Imports.
+type MemoryImportModel struct {
+	store *memoryStore
+}
+
+// memoryImport is a row of the imports table, its errors stand in for import_errors.
+type memoryImport struct {
+	Import
+	errors []ImportRowError
+}
+
+func (m MemoryImportModel) GetByID(ctx context.Context, id int64) (*Import, error) {
+	m.store.mu.RLock()
+	defer m.store.mu.RUnlock()
+
+	stored, ok := m.store.imports[id]
+	if !ok {
+		return nil, ErrNoRecordFound
+	}
+	imp := stored.Import
+	if imp.FinishedAt != nil {
+		finishedAt := *imp.FinishedAt
+		imp.FinishedAt = &finishedAt
+	}
+	return &imp, nil
+}
+
+func (m MemoryImportModel) Insert(ctx context.Context, imp *Import) error {
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	created := now()
+	imp.ID = m.store.nextID("imports")
+	if imp.Status == "" {
+		imp.Status = ImportQueued
+	}
+	imp.CreatedAt = created
+	imp.UpdatedAt = created
+	m.store.imports[imp.ID] = &memoryImport{Import: Import{ID: imp.ID, UserID: imp.UserID, Format: imp.Format, Status: imp.Status,
+		Total: imp.Total, CreatedAt: created, UpdatedAt: created}}
+	return nil
+}
+
+func (m MemoryImportModel) Update(ctx context.Context, imp *Import) error {
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	stored, ok := m.store.imports[imp.ID]
+	if !ok {
+		return ErrNoRecordFound
+	}
+	stored.Status = imp.Status
+	stored.Total = imp.Total
+	stored.Processed = imp.Processed
+	stored.Created = imp.Created
+	stored.Failed = imp.Failed
+	stored.Error = imp.Error
+	stored.FinishedAt = nil
+	if imp.FinishedAt != nil {
+		finishedAt := imp.FinishedAt.Truncate(time.Second)
+		stored.FinishedAt = &finishedAt
+	}
+	stored.UpdatedAt = now()
+	imp.UpdatedAt = stored.UpdatedAt
+	return nil
+}
+
+func (m MemoryImportModel) InsertErrors(ctx context.Context, id int64, rowErrors []ImportRowError) error {
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	stored, ok := m.store.imports[id]
+	if !ok {
+		return fmt.Errorf("import %d does not exist", id)
+	}
+	for _, rowError := range rowErrors {
+		for _, existing := range stored.errors {
+			if existing.Line == rowError.Line {
+				return fmt.Errorf("line %d of import %d already has errors", rowError.Line, id)
+			}
+		}
+		copied := rowError
+		copied.Errors = make(map[string]string, len(rowError.Errors))
+		for field, message := range rowError.Errors {
+			copied.Errors[field] = message
+		}
+		stored.errors = append(stored.errors, copied)
+	}
+	return nil
+}
+
+func (m MemoryImportModel) GetErrors(ctx context.Context, id int64) ([]ImportRowError, error) {
+	m.store.mu.RLock()
+	defer m.store.mu.RUnlock()
+
+	stored, ok := m.store.imports[id]
+	if !ok {
+		return nil, nil
+	}
+	rowErrors := append([]ImportRowError(nil), stored.errors...)
+	sort.Slice(rowErrors, func(i, j int) bool {
+		return rowErrors[i].Line < rowErrors[j].Line
+	})
+	return rowErrors, nil
+}
@@ -0,0 +1,99 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// MemoryLibraryImportModel is the in-memory implementation of LibraryImports.
+type MemoryLibraryImportModel struct {
+	store *memoryStore
+}
+
+func (m MemoryLibraryImportModel) GetByID(ctx context.Context, id int64) (*LibraryImport, error) {
+	m.store.mu.RLock()
+	defer m.store.mu.RUnlock()
+
+	stored, ok := m.store.libraryImports[id]
+	if !ok {
+		return nil, ErrNoRecordFound
+	}
+	imp := *stored
+	imp.Rows = append([]LibraryImportRow{}, stored.Rows...)
+	if stored.ConfirmedAt != nil {
+		confirmedAt := *stored.ConfirmedAt
+		imp.ConfirmedAt = &confirmedAt
+	}
+	return &imp, nil
+}
+
+func (m MemoryLibraryImportModel) Insert(ctx context.Context, imp *LibraryImport) error {
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	if _, ok := m.store.users[imp.UserID]; !ok {
+		return fmt.Errorf("user %d does not exist", imp.UserID)
+	}
+	for i, row := range imp.Rows {
+		if _, ok := m.store.books[row.BookID]; row.BookID != 0 && !ok {
+			return fmt.Errorf("book %d does not exist", row.BookID)
+		}
+		for _, other := range imp.Rows[:i] {
+			if other.Line == row.Line {
+				return fmt.Errorf("line %d of the library import is repeated", row.Line)
+			}
+		}
+	}
+	created := now()
+	imp.ID = m.store.nextID("library_imports")
+	if imp.Status == "" {
+		imp.Status = LibraryImportPreviewed
+	}
+	imp.Version = 1
+	imp.CreatedAt = created
+	imp.UpdatedAt = created
+	rows := append([]LibraryImportRow{}, imp.Rows...)
+	sort.Slice(rows, func(i, j int) bool {
+		return rows[i].Line < rows[j].Line
+	})
+	m.store.libraryImports[imp.ID] = &LibraryImport{ID: imp.ID, UserID: imp.UserID, Source: imp.Source, Status: imp.Status,
+		Version: imp.Version, Rows: rows, CreatedAt: created, UpdatedAt: created}
+	return nil
+}
+
+func (m MemoryLibraryImportModel) Update(ctx context.Context, imp *LibraryImport) error {
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	stored, ok := m.store.libraryImports[imp.ID]
+	if !ok || stored.Version != imp.Version {
+		return ErrNoRecordFound
+	}
+	for _, row := range imp.Rows {
+		if _, ok := m.store.books[row.BookID]; row.BookID != 0 && !ok {
+			return fmt.Errorf("book %d does not exist", row.BookID)
+		}
+	}
+	for _, row := range imp.Rows {
+		for i := range stored.Rows {
+			if stored.Rows[i].Line == row.Line {
+				stored.Rows[i].BookID = row.BookID
+				stored.Rows[i].Status = row.Status
+				stored.Rows[i].Reason = row.Reason
+			}
+		}
+	}
+	stored.Status = imp.Status
+	stored.ConfirmedAt = nil
+	if imp.ConfirmedAt != nil {
+		confirmedAt := imp.ConfirmedAt.Truncate(time.Second)
+		stored.ConfirmedAt = &confirmedAt
+	}
+	stored.Version++
+	stored.UpdatedAt = now()
+	imp.Version = stored.Version
+	imp.UpdatedAt = stored.UpdatedAt
+	return nil
+}
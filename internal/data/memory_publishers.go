@@ -0,0 +1,125 @@
+package data
+
+import (
+	"context"
+)
+
+// MemoryPublisherModel is the in-memory implementation of Publishers.
+type MemoryPublisherModel struct {
+	store *memoryStore
+}
+
+func (p MemoryPublisherModel) GetAll(ctx context.Context, name string, filters Filters) ([]*Publisher, Metadata, error) {
+	keys, err := filters.keyset("publishers", filters.sortColumn(), "id")
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	p.store.mu.RLock()
+	defer p.store.mu.RUnlock()
+
+	var publishers []*Publisher
+	for _, publisher := range p.store.publishers {
+		if matchesText(publisher.Name, name) {
+			publishers = append(publishers, p.store.publisherWithCount(publisher))
+		}
+	}
+	sortRecords(publishers, filters, func(x, y *Publisher, column string) bool {
+		switch column {
+		case "name":
+			return x.Name < y.Name
+		default:
+			return x.ID < y.ID
+		}
+	}, func(x, y *Publisher) bool {
+		return x.ID < y.ID
+	})
+	publishers, metadata := page(publishers, keys, func(publisher *Publisher) []any {
+		return []any{publisherSortKey(publisher, filters.sortColumn()), publisher.ID}
+	})
+	return publishers, metadata, nil
+}
+
+func (p MemoryPublisherModel) GetByID(ctx context.Context, id int64) (*Publisher, error) {
+	p.store.mu.RLock()
+	defer p.store.mu.RUnlock()
+
+	publisher, ok := p.store.publishers[id]
+	if !ok {
+		return nil, ErrNoRecordFound
+	}
+	return p.store.publisherWithCount(publisher), nil
+}
+
+func (p MemoryPublisherModel) Insert(ctx context.Context, publisher *Publisher) error {
+	p.store.mu.Lock()
+	defer p.store.mu.Unlock()
+
+	if p.store.publisherTaken(publisher.Name, 0) {
+		return ErrDuplicatePublisher
+	}
+	created := now()
+	publisher.ID = p.store.nextID("publishers")
+	publisher.CreatedAt = created
+	publisher.UpdatedAt = created
+	publisher.Version = 1
+	stored := *publisher
+	p.store.publishers[publisher.ID] = &stored
+	return nil
+}
+
+func (p MemoryPublisherModel) Update(ctx context.Context, publisher *Publisher) error {
+	p.store.mu.Lock()
+	defer p.store.mu.Unlock()
+
+	stored, ok := p.store.publishers[publisher.ID]
+	if !ok || stored.Version != publisher.Version {
+		return ErrNoRecordFound
+	}
+	if p.store.publisherTaken(publisher.Name, publisher.ID) {
+		return ErrDuplicatePublisher
+	}
+	stored.Name = publisher.Name
+	stored.UpdatedAt = now()
+	stored.Version++
+	publisher.UpdatedAt = stored.UpdatedAt
+	publisher.Version = stored.Version
+	return nil
+}
+
+func (p MemoryPublisherModel) Delete(ctx context.Context, id int64) error {
+	p.store.mu.Lock()
+	defer p.store.mu.Unlock()
+
+	if _, ok := p.store.publishers[id]; !ok {
+		return ErrNoRecordFound
+	}
+	delete(p.store.publishers, id)
+	for _, row := range p.store.books {
+		if row.book.PublisherID == id {
+			row.book.PublisherID = 0
+		}
+	}
+	return nil
+}
+
+// publisherWithCount returns a copy of the publisher with the number of its books.
+func (s *memoryStore) publisherWithCount(publisher *Publisher) *Publisher {
+	found := *publisher
+	found.BookCount = 0
+	for _, row := range s.books {
+		if row.book.PublisherID == publisher.ID {
+			found.BookCount++
+		}
+	}
+	return &found
+}
+
+// publisherTaken reports whether a publisher other than the one with id has the name.
+func (s *memoryStore) publisherTaken(name string, id int64) bool {
+	for _, publisher := range s.publishers {
+		if publisher.Name == name && publisher.ID != id {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,164 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// MemorySeriesModel is the in-memory implementation of SeriesStore.
+type MemorySeriesModel struct {
+	store *memoryStore
+}
+
+func (s MemorySeriesModel) GetAll(ctx context.Context, name string, filters Filters) ([]*Series, Metadata, error) {
+	keys, err := filters.keyset("series", filters.sortColumn(), "id")
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	s.store.mu.RLock()
+	defer s.store.mu.RUnlock()
+
+	var all []*Series
+	for _, series := range s.store.series {
+		if matchesText(series.Name, name) {
+			all = append(all, s.store.seriesWithCount(series))
+		}
+	}
+	sortRecords(all, filters, func(x, y *Series, column string) bool {
+		switch column {
+		case "name":
+			return x.Name < y.Name
+		default:
+			return x.ID < y.ID
+		}
+	}, func(x, y *Series) bool {
+		return x.ID < y.ID
+	})
+	all, metadata := page(all, keys, func(series *Series) []any {
+		return []any{seriesSortKey(series, filters.sortColumn()), series.ID}
+	})
+	return all, metadata, nil
+}
+
+func (s MemorySeriesModel) GetByID(ctx context.Context, id int64) (*Series, error) {
+	s.store.mu.RLock()
+	defer s.store.mu.RUnlock()
+
+	series, ok := s.store.series[id]
+	if !ok {
+		return nil, ErrNoRecordFound
+	}
+	return s.store.seriesWithCount(series), nil
+}
+
+func (s MemorySeriesModel) GetBooks(ctx context.Context, id int64) ([]*Book, error) {
+	s.store.mu.RLock()
+	defer s.store.mu.RUnlock()
+
+	type entry struct {
+		book     *Book
+		position float64
+	}
+	var entries []entry
+	for _, row := range s.store.books {
+		for _, e := range row.series {
+			if e.SeriesID == id {
+				entries = append(entries, entry{s.store.bookWithJoins(row, false), e.Position})
+			}
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		x, y := entries[i], entries[j]
+		if x.position != y.position {
+			return x.position < y.position
+		}
+		if x.book.Title != y.book.Title {
+			return x.book.Title < y.book.Title
+		}
+		return x.book.ID < y.book.ID
+	})
+	var books []*Book
+	for _, e := range entries {
+		books = append(books, e.book)
+	}
+	return books, nil
+}
+
+func (s MemorySeriesModel) Insert(ctx context.Context, series *Series) error {
+	s.store.mu.Lock()
+	defer s.store.mu.Unlock()
+
+	created := now()
+	series.ID = s.store.nextID("series")
+	series.CreatedAt = created
+	series.UpdatedAt = created
+	series.Version = 1
+	stored := *series
+	s.store.series[series.ID] = &stored
+	return nil
+}
+
+func (s MemorySeriesModel) Update(ctx context.Context, series *Series) error {
+	s.store.mu.Lock()
+	defer s.store.mu.Unlock()
+
+	stored, ok := s.store.series[series.ID]
+	if !ok || stored.Version != series.Version {
+		return ErrNoRecordFound
+	}
+	stored.Name = series.Name
+	stored.Description = series.Description
+	stored.UpdatedAt = now()
+	stored.Version++
+	series.UpdatedAt = stored.UpdatedAt
+	series.Version = stored.Version
+	return nil
+}
+
+func (s MemorySeriesModel) Delete(ctx context.Context, id int64) error {
+	s.store.mu.Lock()
+	defer s.store.mu.Unlock()
+
+	if _, ok := s.store.series[id]; !ok {
+		return ErrNoRecordFound
+	}
+	delete(s.store.series, id)
+	for _, row := range s.store.books {
+		var kept []SeriesEntry
+		for _, entry := range row.series {
+			if entry.SeriesID != id {
+				kept = append(kept, entry)
+			}
+		}
+		row.series = kept
+	}
+	return nil
+}
+
+// seriesWithCount returns a copy of the series with the number of its books.
+func (s *memoryStore) seriesWithCount(series *Series) *Series {
+	found := *series
+	found.BookCount = 0
+	for _, row := range s.books {
+		for _, entry := range row.series {
+			if entry.SeriesID == series.ID {
+				found.BookCount++
+			}
+		}
+	}
+	return &found
+}
+
+// seriesEntries checks that the series of the entries exist and returns a copy of them without
+// the series names, which bookWithJoins reads from the series.
+func (s *memoryStore) seriesEntries(entries []SeriesEntry) ([]SeriesEntry, error) {
+	var copied []SeriesEntry
+	for _, entry := range entries {
+		if _, ok := s.series[entry.SeriesID]; !ok {
+			return nil, fmt.Errorf("series %d does not exist", entry.SeriesID)
+		}
+		copied = append(copied, SeriesEntry{SeriesID: entry.SeriesID, Position: entry.Position})
+	}
+	return copied, nil
+}
@@ -0,0 +1,49 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestMemoryConformance(t *testing.T) {
+	runConformance(t, func(t *testing.T) Models {
+		return NewMemoryModels()
+	})
+}
+
+func TestMemoryConcurrentAccess(t *testing.T) {
+	m := NewMemoryModels()
+	author := insertAuthor(t, m, "Frank Herbert")
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			book := &Book{Title: fmt.Sprintf("Book %d", i), AuthorID: int(author.ID), PublicationYear: 2000, Description: "d"}
+			if err := m.Books.Insert(ctx, book); err != nil {
+				t.Error(err)
+				return
+			}
+			book.Genres = []string{"Fantasy"}
+			if err := m.Books.Update(ctx, book); err != nil {
+				t.Error(err)
+			}
+			if _, _, err := m.Books.GetAll(ctx, "", 0, filters(1, 100, "id", bookSorts...)); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	_, metadata, err := m.Books.GetAll(ctx, "book", 0, filters(1, 100, "id", bookSorts...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if metadata.TotalRecords != 20 {
+		t.Errorf("TotalRecords = %d; want 20", metadata.TotalRecords)
+	}
+}
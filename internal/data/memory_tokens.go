@@ -0,0 +1,80 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// MemoryTokenModel is the in-memory implementation of Tokens.
+type MemoryTokenModel struct {
+	store *memoryStore
+}
+
+func (t MemoryTokenModel) GetByToken(ctx context.Context, plainText string) (*Token, error) {
+	t.store.mu.RLock()
+	defer t.store.mu.RUnlock()
+
+	for _, token := range t.store.tokens {
+		if token.Token == plainText {
+			found := *token
+			return &found, nil
+		}
+	}
+	return nil, ErrNoRecordFound
+}
+
+func (t MemoryTokenModel) GetUserForToken(ctx context.Context, token *Token) (*User, error) {
+	t.store.mu.RLock()
+	defer t.store.mu.RUnlock()
+
+	stored, ok := t.store.users[token.UserID]
+	if !ok {
+		return nil, ErrNoRecordFound
+	}
+	userToken, ok := t.store.tokenForUser(stored.ID)
+	if !ok {
+		return nil, ErrNoRecordFound
+	}
+	user := *stored
+	user.UpdatedAt = time.Time{}
+	user.Token = *userToken
+	return &user, nil
+}
+
+func (t MemoryTokenModel) GenerateToken(userID int64, ttl time.Duration) (*Token, error) {
+	return generateToken(userID, ttl)
+}
+
+func (t MemoryTokenModel) InsertToken(ctx context.Context, token *Token) error {
+	t.store.mu.Lock()
+	defer t.store.mu.Unlock()
+
+	if _, ok := t.store.users[token.UserID]; !ok {
+		return fmt.Errorf("user %d does not exist", token.UserID)
+	}
+	for id, stored := range t.store.tokens {
+		if stored.UserID == token.UserID {
+			delete(t.store.tokens, id)
+		}
+	}
+	created := now()
+	token.ID = t.store.nextID("tokens")
+	token.CreatedAt = created
+	token.UpdatedAt = created
+	stored := *token
+	stored.Expiry = token.Expiry.Truncate(time.Second)
+	t.store.tokens[stored.ID] = &stored
+	return nil
+}
+
+func (t MemoryTokenModel) DeleteToken(ctx context.Context, id int64) error {
+	t.store.mu.Lock()
+	defer t.store.mu.Unlock()
+
+	if _, ok := t.store.tokens[id]; !ok {
+		return ErrNoRecordFound
+	}
+	delete(t.store.tokens, id)
+	return nil
+}
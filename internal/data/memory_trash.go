@@ -0,0 +1,149 @@
+package data
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+// MemoryTrashModel is the in-memory implementation of Trash.
+type MemoryTrashModel struct {
+	store *memoryStore
+}
+
+func (t MemoryTrashModel) GetAll(ctx context.Context, itemType string, filters Filters) ([]*TrashItem, Metadata, error) {
+	t.store.mu.RLock()
+	defer t.store.mu.RUnlock()
+
+	items := []*TrashItem{}
+	add := func(kind string, id int64, name string, deletedAt time.Time) {
+		if itemType == "" || itemType == kind {
+			items = append(items, &TrashItem{Type: kind, ID: id, Name: name, DeletedAt: deletedAt})
+		}
+	}
+	for id, book := range t.store.trashedBooks {
+		add(TrashBook, id, book.record.book.Title, book.deletedAt)
+	}
+	for id, review := range t.store.trashedReviews {
+		add(TrashReview, id, t.store.bookTitle(review.record.BookID), review.deletedAt)
+	}
+	for id, user := range t.store.trashedUsers {
+		add(TrashUser, id, user.record.Name, user.deletedAt)
+	}
+	sort.Slice(items, func(i, j int) bool {
+		a, b := items[i], items[j]
+		switch {
+		case !a.DeletedAt.Equal(b.DeletedAt):
+			return a.DeletedAt.After(b.DeletedAt)
+		case a.Type != b.Type:
+			return a.Type < b.Type
+		default:
+			return a.ID < b.ID
+		}
+	})
+	total := len(items)
+	start := filters.offset()
+	if start >= total {
+		return []*TrashItem{}, Metadata{}, nil
+	}
+	end := start + filters.limit()
+	if end > total {
+		end = total
+	}
+	return items[start:end], calculateMetadata(total, filters.Page, filters.PageSize), nil
+}
+
+// bookTitle is the title of the book, live or in the trash.
+func (s *memoryStore) bookTitle(id int64) string {
+	if row, ok := s.books[id]; ok {
+		return row.book.Title
+	}
+	return s.trashedBooks[id].record.book.Title
+}
+
+func (t MemoryTrashModel) Purge(ctx context.Context, before time.Time) (*Purged, error) {
+	t.store.mu.Lock()
+	defer t.store.mu.Unlock()
+
+	var purged Purged
+	for id, review := range t.store.trashedReviews {
+		if review.deletedAt.Before(before) {
+			delete(t.store.trashedReviews, id)
+			purged.Reviews++
+		}
+	}
+	var bookIDs []int64
+	for id, book := range t.store.trashedBooks {
+		if book.deletedAt.Before(before) {
+			bookIDs = append(bookIDs, id)
+		}
+	}
+	sort.Slice(bookIDs, func(i, j int) bool {
+		return bookIDs[i] < bookIDs[j]
+	})
+	for _, id := range bookIDs {
+		row := t.store.trashedBooks[id].record
+		delete(t.store.trashedBooks, id)
+		purged.Books++
+		purged.Covers = append(purged.Covers, row.covers...)
+		t.store.purgeBook(row)
+	}
+	for id, user := range t.store.trashedUsers {
+		if user.deletedAt.Before(before) {
+			delete(t.store.trashedUsers, id)
+			purged.Users++
+			t.store.purgeUser(id)
+		}
+	}
+	return &purged, nil
+}
+
+// purgeBook does what the foreign keys do when a book is deleted for good, and deletes its work
+// if it was the last edition.
+func (s *memoryStore) purgeBook(row *memoryBook) {
+	id := row.book.ID
+	for slug, owner := range s.slugs {
+		if owner == id {
+			delete(s.slugs, slug)
+		}
+	}
+	for reviewID, review := range s.trashedReviews {
+		if review.record.BookID == id {
+			delete(s.trashedReviews, reviewID)
+		}
+	}
+	for _, imp := range s.libraryImports {
+		for i := range imp.Rows {
+			if imp.Rows[i].BookID == id {
+				imp.Rows[i].BookID = 0
+			}
+		}
+	}
+	for _, t := range s.trashedBooks {
+		if t.record.book.WorkID == row.book.WorkID {
+			return
+		}
+	}
+	if len(s.editions(row.book.WorkID)) == 0 {
+		delete(s.works, row.book.WorkID)
+	}
+}
+
+// purgeUser does what the foreign keys do when a user is deleted for good.
+func (s *memoryStore) purgeUser(id int64) {
+	for reviewID, review := range s.trashedReviews {
+		if review.record.UserID == id {
+			delete(s.trashedReviews, reviewID)
+		}
+	}
+	for _, imp := range s.imports {
+		if imp.UserID == id {
+			imp.UserID = 0
+		}
+	}
+	for importID, imp := range s.libraryImports {
+		if imp.UserID == id {
+			delete(s.libraryImports, importID)
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package data
+
+import (
+	"context"
+	"sort"
+)
+
+// MemoryUserModel is the in-memory implementation of Users.
+type MemoryUserModel struct {
+	store *memoryStore
+}
+
+func (u MemoryUserModel) GetByEmail(ctx context.Context, email string) (*User, error) {
+	u.store.mu.RLock()
+	defer u.store.mu.RUnlock()
+
+	for _, user := range u.store.users {
+		if user.Email == email {
+			found := *user
+			return &found, nil
+		}
+	}
+	return nil, ErrNoRecordFound
+}
+
+func (u MemoryUserModel) GetByID(ctx context.Context, id int64) (*User, error) {
+	u.store.mu.RLock()
+	defer u.store.mu.RUnlock()
+
+	user, ok := u.store.users[id]
+	if !ok {
+		return nil, ErrNoRecordFound
+	}
+	found := *user
+	return &found, nil
+}
+
+func (u MemoryUserModel) Insert(ctx context.Context, user *User) error {
+	u.store.mu.Lock()
+	defer u.store.mu.Unlock()
+
+	if u.store.emailTaken(user.Email, 0) {
+		return ErrDuplicateEmail
+	}
+	if user.AccountType == "" {
+		user.AccountType = "user"
+	}
+	created := now()
+	user.ID = u.store.nextID("users")
+	user.CreatedAt = created
+	user.UpdatedAt = created
+	user.Version = 1
+	u.store.users[user.ID] = &User{
+		ID:          user.ID,
+		Name:        user.Name,
+		Email:       user.Email,
+		Password:    Password{Hash: user.Password.Hash},
+		CreatedAt:   user.CreatedAt,
+		UpdatedAt:   user.UpdatedAt,
+		Version:     user.Version,
+		AccountType: user.AccountType,
+	}
+	return nil
+}
+
+func (u MemoryUserModel) Delete(ctx context.Context, id int64) error {
+	u.store.mu.Lock()
+	defer u.store.mu.Unlock()
+
+	user, ok := u.store.users[id]
+	if !ok {
+		return ErrNoRecordFound
+	}
+	deletedAt := now()
+	delete(u.store.users, id)
+	u.store.trashedUsers[id] = trashed[*User]{record: user, deletedAt: deletedAt}
+	u.store.trashReviews(TrashUser, id, deletedAt)
+	for tokenID, token := range u.store.tokens {
+		if token.UserID == id {
+			delete(u.store.tokens, tokenID)
+		}
+	}
+	return nil
+}
+
+func (u MemoryUserModel) Restore(ctx context.Context, id int64) error {
+	u.store.mu.Lock()
+	defer u.store.mu.Unlock()
+
+	t, ok := u.store.trashedUsers[id]
+	if !ok {
+		return ErrNoRecordFound
+	}
+	delete(u.store.trashedUsers, id)
+	u.store.users[id] = t.record
+	u.store.restoreReviews(TrashUser, id)
+	return nil
+}
+
+func (u MemoryUserModel) Update(ctx context.Context, user *User) error {
+	u.store.mu.Lock()
+	defer u.store.mu.Unlock()
+
+	stored, ok := u.store.users[user.ID]
+	if !ok {
+		return ErrNoRecordFound
+	}
+	if u.store.emailTaken(user.Email, user.ID) {
+		return ErrDuplicateEmail
+	}
+	stored.Name = user.Name
+	stored.Email = user.Email
+	stored.Password = Password{Hash: user.Password.Hash}
+	stored.UpdatedAt = now()
+	stored.Version++
+	user.UpdatedAt = stored.UpdatedAt
+	return nil
+}
+
+func (u MemoryUserModel) GetAll(ctx context.Context) ([]*User, error) {
+	u.store.mu.RLock()
+	defer u.store.mu.RUnlock()
+
+	var users []*User
+	for _, stored := range u.store.users {
+		user := *stored
+		if token, ok := u.store.tokenForUser(user.ID); ok {
+			user.Token = *token
+		}
+		users = append(users, &user)
+	}
+	sortByName(users)
+	return users, nil
+}
+
+func (u MemoryUserModel) GetAllLoggedIn(ctx context.Context) ([]*User, error) {
+	u.store.mu.RLock()
+	defer u.store.mu.RUnlock()
+
+	var users []*User
+	for _, stored := range u.store.users {
+		token, ok := u.store.tokenForUser(stored.ID)
+		if !ok {
+			continue
+		}
+		user := *stored
+		user.Token = *token
+		users = append(users, &user)
+	}
+	sortByName(users)
+	return users, nil
+}
+
+func sortByName(users []*User) {
+	sort.Slice(users, func(i, j int) bool {
+		if users[i].Name != users[j].Name {
+			return users[i].Name < users[j].Name
+		}
+		return users[i].ID < users[j].ID
+	})
+}
+
+// emailTaken reports whether another user than exceptID already uses email, like the users_email_key constraint.
+// The users in the trash keep their email.
+func (s *memoryStore) emailTaken(email string, exceptID int64) bool {
+	for _, user := range s.users {
+		if user.Email == email && user.ID != exceptID {
+			return true
+		}
+	}
+	for _, t := range s.trashedUsers {
+		if t.record.Email == email {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *memoryStore) tokenForUser(userID int64) (*Token, bool) {
+	for _, token := range s.tokens {
+		if token.UserID == userID {
+			return token, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,117 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// migrations holds the schema changes of each dialect, in files named <version>_<description>.sql.
+//
+//go:embed migrations
+var migrations embed.FS
+
+// migrationLockID is the Postgres advisory lock held while migrating, so that several instances
+// starting at once apply every migration once.
+const migrationLockID = 7_353_826_411
+
+type migration struct {
+	version int64
+	name    string
+	sql     string
+}
+
+// Migrate brings the schema of db up to date, applying the migrations of the dialect that are not
+// recorded in schema_migrations yet. Everything runs in a single transaction, a failing migration
+// leaves the schema as it was.
+func Migrate(ctx context.Context, db *sql.DB, dialect Dialect) error {
+	pending, err := loadMigrations(dialect)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if dialect == Postgres {
+		_, err = tx.ExecContext(ctx, `select pg_advisory_xact_lock($1)`, migrationLockID)
+		if err != nil {
+			return err
+		}
+	}
+	_, err = tx.ExecContext(ctx, `create table if not exists schema_migrations (version bigint primary key, applied_at timestamp not null default current_timestamp)`)
+	if err != nil {
+		return err
+	}
+	applied, err := appliedMigrations(ctx, tx)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range pending {
+		if applied[m.version] {
+			continue
+		}
+		_, err = tx.ExecContext(ctx, m.sql)
+		if err != nil {
+			return fmt.Errorf("migration %s: %w", m.name, err)
+		}
+		_, err = tx.ExecContext(ctx, `insert into schema_migrations (version) values ($1)`, m.version)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func loadMigrations(dialect Dialect) ([]migration, error) {
+	dir := path.Join("migrations", dialect.String())
+	entries, err := fs.ReadDir(migrations, dir)
+	if err != nil {
+		return nil, err
+	}
+	var list []migration
+	for _, entry := range entries {
+		prefix, _, ok := strings.Cut(entry.Name(), "_")
+		version, err := strconv.ParseInt(prefix, 10, 64)
+		if !ok || err != nil || !strings.HasSuffix(entry.Name(), ".sql") {
+			return nil, fmt.Errorf("migration %s is not named <version>_<description>.sql", entry.Name())
+		}
+		contents, err := fs.ReadFile(migrations, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, migration{version: version, name: entry.Name(), sql: string(contents)})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].version < list[j].version
+	})
+	return list, nil
+}
+
+func appliedMigrations(ctx context.Context, tx *sql.Tx) (map[int64]bool, error) {
+	rows, err := tx.QueryContext(ctx, `select version from schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	applied := make(map[int64]bool)
+	for rows.Next() {
+		var version int64
+		err := rows.Scan(&version)
+		if err != nil {
+			return nil, err
+		}
+		applied[version] = true
+	}
+	return applied, rows.Err()
+}
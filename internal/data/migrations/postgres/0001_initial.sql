@@ -0,0 +1,75 @@
+-- The schema of database/go_books_schema_only.sql. Databases created from the dump already
+-- have every table, so nothing here fails or changes anything when it runs on them.
+
+create table if not exists authors (
+    id bigserial primary key,
+    author_name character varying(512) not null,
+    created_at timestamp(0) with time zone not null default now(),
+    updated_at timestamp(0) with time zone not null default now(),
+    version integer not null default 1
+);
+
+create table if not exists books (
+    id bigserial primary key,
+    title character varying(512) not null,
+    author_id integer not null references authors (id) on update cascade on delete cascade,
+    publication_year integer not null,
+    created_at timestamp(0) with time zone not null default now(),
+    updated_at timestamp(0) with time zone not null default now(),
+    slug character varying(512) not null,
+    description text not null
+);
+
+create index if not exists books_title_idx on books using gin (to_tsvector('simple', title));
+
+create table if not exists genres (
+    id bigserial primary key,
+    genre_name character varying(255) not null,
+    created_at timestamp(0) with time zone not null default now(),
+    updated_at timestamp(0) with time zone not null default now()
+);
+
+create table if not exists books_genres (
+    id bigserial primary key,
+    book_id integer not null references books (id) on update cascade on delete cascade,
+    genre_id integer not null references genres (id) on update cascade on delete cascade,
+    created_at timestamp(0) with time zone not null default now(),
+    updated_at timestamp(0) with time zone not null default now()
+);
+
+create table if not exists users (
+    id bigserial primary key,
+    created_at timestamp(0) with time zone not null default now(),
+    name text not null,
+    email text not null constraint users_email_key unique,
+    password_hash bytea not null,
+    version integer not null default 1,
+    account_type character varying(255) not null default 'user',
+    updated_at timestamp(0) with time zone not null default now()
+);
+
+create table if not exists reviews (
+    id bigserial primary key,
+    rating integer not null default 1,
+    review text not null,
+    book_id bigint not null constraint reviews_books_book_id_fkey references books (id) on update cascade on delete cascade,
+    user_id bigint not null constraint reviews_users_user_id_fkey references users (id) on update cascade on delete cascade,
+    version integer not null default 1,
+    created_at timestamp(0) with time zone not null default now(),
+    updated_at timestamp(0) with time zone not null default now()
+);
+
+create table if not exists tokens (
+    id bigserial primary key,
+    user_id bigint not null references users (id) on update cascade on delete cascade,
+    email text not null,
+    token character varying(255) not null,
+    token_hash bytea not null,
+    created_at timestamp(0) with time zone not null default now(),
+    updated_at timestamp(0) with time zone not null default now(),
+    expiry timestamp(0) with time zone not null
+);
+
+insert into genres (genre_name)
+select genre_name from (values ('Science Fiction'), ('Fantasy'), ('Romance'), ('Thriller'), ('Mystery'), ('Horror'), ('Classic'), ('Self-help')) as g (genre_name)
+where not exists (select 1 from genres);
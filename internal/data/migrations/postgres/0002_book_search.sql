@@ -0,0 +1,63 @@
+-- Full-text search of the books. The title and description are indexed in the language of the book,
+-- with the name of its author and its genres, which live in other tables. The search vector is kept
+-- up to date by triggers on all of them, so that books_search_idx answers the search.
+
+alter table books add column if not exists language regconfig not null default 'simple';
+
+create or replace function book_search_document(book bigint, lang regconfig, title text, description text) returns tsvector
+language sql stable as $$
+    select setweight(to_tsvector(lang, title), 'A')
+        || setweight(to_tsvector(lang, coalesce((select a.author_name
+            from books b join authors a on a.id = b.author_id where b.id = book), '')), 'B')
+        || setweight(to_tsvector(lang, coalesce((select string_agg(g.genre_name, ' ')
+            from books_genres bg join genres g on g.id = bg.genre_id where bg.book_id = book), '')), 'C')
+        || setweight(to_tsvector(lang, description), 'D')
+$$;
+
+-- refresh_book_search recomputes the search vector of the books whose genres changed, or whose
+-- author or genre was renamed.
+create or replace function refresh_book_search() returns trigger
+language plpgsql as $$
+declare
+    changed record;
+begin
+    if tg_op = 'DELETE' then
+        changed := old;
+    else
+        changed := new;
+    end if;
+    if tg_table_name = 'authors' then
+        update books set search = book_search_document(id, language, title, description) where author_id = changed.id;
+    elsif tg_table_name = 'genres' then
+        update books set search = book_search_document(id, language, title, description)
+        where id in (select bg.book_id from books_genres bg where bg.genre_id = changed.id);
+    else
+        update books set search = book_search_document(id, language, title, description) where id = changed.book_id;
+    end if;
+    return null;
+end
+$$;
+
+create or replace function set_book_search() returns trigger
+language plpgsql as $$
+begin
+    new.search := book_search_document(new.id, new.language, new.title, new.description);
+    return new;
+end
+$$;
+
+alter table books add column if not exists search tsvector not null default '';
+update books set search = book_search_document(id, language, title, description);
+create index if not exists books_search_idx on books using gin (search);
+
+create trigger books_search_document before insert or update of title, description, language, author_id on books
+for each row execute function set_book_search();
+
+create trigger books_genres_search after insert or update or delete on books_genres
+for each row execute function refresh_book_search();
+
+create trigger authors_search after update of author_name on authors
+for each row execute function refresh_book_search();
+
+create trigger genres_search after update of genre_name on genres
+for each row execute function refresh_book_search();
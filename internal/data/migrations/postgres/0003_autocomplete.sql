@@ -0,0 +1,9 @@
+-- Trigram indexes for the autocomplete, they answer both the like prefix patterns and the <%
+-- similarity operator. Creating the extension needs a role allowed to, pg_trgm is a trusted
+-- extension since Postgres 13 and the owner of the database can.
+
+create extension if not exists pg_trgm;
+
+create index if not exists books_title_trgm_idx on books using gin (lower(title) gin_trgm_ops);
+
+create index if not exists authors_author_name_trgm_idx on authors using gin (lower(author_name) gin_trgm_ops);
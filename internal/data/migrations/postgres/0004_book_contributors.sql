@@ -0,0 +1,54 @@
+-- Books can have several contributors, each with a role, in the order they are credited.
+-- books.author_id stays as the first contributor, the one shown as the book's author.
+
+create table if not exists book_contributors (
+    book_id bigint not null references books (id) on update cascade on delete cascade,
+    author_id bigint not null references authors (id) on update cascade on delete cascade,
+    role text not null default 'author' check (role in ('author', 'editor', 'translator', 'illustrator')),
+    position integer not null,
+    primary key (book_id, author_id, role)
+);
+
+create index if not exists book_contributors_author_id_idx on book_contributors (author_id);
+
+insert into book_contributors (book_id, author_id, role, position)
+select id, author_id, 'author', 1 from books
+on conflict do nothing;
+
+-- The names of all the contributors go into books.search, in the order they are credited. The
+-- books have their author as only contributor so far, their search vectors stay the same.
+create or replace function book_search_document(book bigint, lang regconfig, title text, description text) returns tsvector
+language sql stable as $$
+    select setweight(to_tsvector(lang, title), 'A')
+        || setweight(to_tsvector(lang, coalesce((select string_agg(a.author_name, ' ' order by bc.position)
+            from book_contributors bc join authors a on a.id = bc.author_id where bc.book_id = book), '')), 'B')
+        || setweight(to_tsvector(lang, coalesce((select string_agg(g.genre_name, ' ')
+            from books_genres bg join genres g on g.id = bg.genre_id where bg.book_id = book), '')), 'C')
+        || setweight(to_tsvector(lang, description), 'D')
+$$;
+
+create or replace function refresh_book_search() returns trigger
+language plpgsql as $$
+declare
+    changed record;
+begin
+    if tg_op = 'DELETE' then
+        changed := old;
+    else
+        changed := new;
+    end if;
+    if tg_table_name = 'authors' then
+        update books set search = book_search_document(id, language, title, description)
+        where id in (select bc.book_id from book_contributors bc where bc.author_id = changed.id);
+    elsif tg_table_name = 'genres' then
+        update books set search = book_search_document(id, language, title, description)
+        where id in (select bg.book_id from books_genres bg where bg.genre_id = changed.id);
+    else
+        update books set search = book_search_document(id, language, title, description) where id = changed.book_id;
+    end if;
+    return null;
+end
+$$;
+
+create trigger book_contributors_search after insert or update or delete on book_contributors
+for each row execute function refresh_book_search();
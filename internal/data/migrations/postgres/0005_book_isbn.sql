@@ -0,0 +1,4 @@
+-- Books are stored with their ISBN-13, the ISBN-10 is derived from it. Books without an ISBN have
+-- none, the unique constraint lets any number of them be null.
+
+alter table books add column if not exists isbn text constraint books_isbn_key unique;
@@ -0,0 +1,26 @@
+-- A work groups the editions of a book: printings, formats and translations. Every book is an
+-- edition of a work, the existing ones each become the only edition of a work with their id.
+
+create table if not exists works (
+    id bigserial primary key,
+    title character varying(512) not null,
+    created_at timestamp(0) with time zone not null default now(),
+    updated_at timestamp(0) with time zone not null default now()
+);
+
+alter table books add column if not exists work_id bigint references works (id) on update cascade;
+alter table books add column if not exists format text not null default '' check (format in ('', 'hardcover', 'paperback', 'ebook', 'audiobook'));
+alter table books add column if not exists publisher text not null default '';
+alter table books add column if not exists page_count integer not null default 0 check (page_count >= 0);
+
+insert into works (id, title, created_at, updated_at)
+select id, title, created_at, updated_at from books where work_id is null
+on conflict do nothing;
+
+update books set work_id = id where work_id is null;
+
+select setval(pg_get_serial_sequence('works', 'id'), coalesce((select max(id) from works), 0) + 1, false);
+
+alter table books alter column work_id set not null;
+
+create index if not exists books_work_id_idx on books (work_id);
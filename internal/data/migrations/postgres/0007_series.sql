@@ -0,0 +1,22 @@
+-- Series of books. A book can be in several series, at a position that can be a decimal so that
+-- a novella can sit at 2.5 between the second and third books.
+
+create table if not exists series (
+    id bigserial primary key,
+    name character varying(512) not null,
+    description text not null default '',
+    created_at timestamp(0) with time zone not null default now(),
+    updated_at timestamp(0) with time zone not null default now(),
+    version integer not null default 1
+);
+
+create index if not exists series_name_idx on series using gin (to_tsvector('simple', name));
+
+create table if not exists books_series (
+    book_id bigint not null references books (id) on update cascade on delete cascade,
+    series_id bigint not null references series (id) on update cascade on delete cascade,
+    position double precision not null check (position >= 0),
+    primary key (book_id, series_id)
+);
+
+create index if not exists books_series_series_id_idx on books_series (series_id, position);
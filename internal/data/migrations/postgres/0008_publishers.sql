@@ -0,0 +1,25 @@
+-- Publishers of editions. The publisher names entered on books become publishers, and books refer
+-- to them by id.
+
+create table if not exists publishers (
+    id bigserial primary key,
+    name character varying(512) not null,
+    created_at timestamp(0) with time zone not null default now(),
+    updated_at timestamp(0) with time zone not null default now(),
+    version integer not null default 1,
+    constraint publishers_name_key unique (name)
+);
+
+create index if not exists publishers_name_idx on publishers using gin (to_tsvector('simple', name));
+
+alter table books add column if not exists publisher_id bigint references publishers (id) on update cascade on delete set null;
+
+insert into publishers (name)
+select distinct publisher from books where publisher <> ''
+on conflict do nothing;
+
+update books b set publisher_id = p.id from publishers p where p.name = b.publisher;
+
+alter table books drop column if exists publisher;
+
+create index if not exists books_publisher_id_idx on books (publisher_id);
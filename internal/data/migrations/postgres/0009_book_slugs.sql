@@ -0,0 +1,17 @@
+-- Slugs are unique, books with the same title get a numbered suffix. The slugs a book had before
+-- being renamed are kept so that their URLs keep resolving to it.
+
+update books set slug = 'book' where slug = '';
+
+update books b set slug = b.slug || '-' || b.id
+where exists (select 1 from books o where o.slug = b.slug and o.id < b.id);
+
+create unique index if not exists books_slug_key on books (slug);
+
+create table if not exists book_slugs (
+    slug text primary key,
+    book_id bigint not null references books (id) on update cascade on delete cascade,
+    created_at timestamp(0) with time zone not null default now()
+);
+
+create index if not exists book_slugs_book_id_idx on book_slugs (book_id);
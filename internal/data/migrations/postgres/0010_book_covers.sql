@@ -0,0 +1,13 @@
+-- The cover of a book, one row per rendition. The images themselves are in the blob store under
+-- key, url is where clients fetch them.
+
+create table if not exists book_covers (
+    book_id bigint not null references books (id) on update cascade on delete cascade,
+    size text not null,
+    key text not null,
+    url text not null,
+    width integer not null check (width > 0),
+    height integer not null check (height > 0),
+    created_at timestamp(0) with time zone not null default now(),
+    primary key (book_id, size)
+);
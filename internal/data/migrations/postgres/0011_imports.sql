@@ -0,0 +1,25 @@
+-- Bulk imports of books, run in the background. The counts are updated as the rows are processed,
+-- and the rows that could not be imported are kept with their errors.
+
+create table if not exists imports (
+    id bigserial primary key,
+    user_id bigint references users (id) on update cascade on delete set null,
+    format text not null,
+    status text not null default 'queued',
+    total_rows integer not null default 0,
+    processed_rows integer not null default 0,
+    created_rows integer not null default 0,
+    failed_rows integer not null default 0,
+    error text not null default '',
+    created_at timestamp(0) with time zone not null default now(),
+    updated_at timestamp(0) with time zone not null default now(),
+    finished_at timestamp(0) with time zone
+);
+
+create table if not exists import_errors (
+    import_id bigint not null references imports (id) on update cascade on delete cascade,
+    line integer not null,
+    title text not null default '',
+    errors text not null,
+    primary key (import_id, line)
+);
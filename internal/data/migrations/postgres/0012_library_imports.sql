@@ -0,0 +1,29 @@
+-- Imports of a user's reading history from a Goodreads export. The rows are matched to books
+-- when the file is uploaded, and only turned into reviews once the user confirms the preview.
+
+create table if not exists library_imports (
+    id bigserial primary key,
+    user_id bigint not null references users (id) on update cascade on delete cascade,
+    source text not null,
+    status text not null default 'previewed',
+    version integer not null default 1,
+    created_at timestamp(0) with time zone not null default now(),
+    updated_at timestamp(0) with time zone not null default now(),
+    confirmed_at timestamp(0) with time zone
+);
+
+create table if not exists library_import_rows (
+    library_import_id bigint not null references library_imports (id) on update cascade on delete cascade,
+    line integer not null,
+    title text not null default '',
+    author text not null default '',
+    isbn text not null default '',
+    year integer not null default 0,
+    rating integer not null default 0,
+    review text not null default '',
+    match text not null,
+    book_id bigint references books (id) on update cascade on delete set null,
+    status text not null,
+    reason text not null default '',
+    primary key (library_import_id, line)
+);
@@ -0,0 +1,117 @@
+-- The same tables as the Postgres schema. Timestamps are stored as text in UTC, and every column
+-- searched with textSearch gets an FTS5 table standing in for the to_tsvector('simple', ...) index.
+
+create table authors (
+    id integer primary key autoincrement,
+    author_name text not null,
+    created_at timestamp not null default current_timestamp,
+    updated_at timestamp not null default current_timestamp,
+    version integer not null default 1
+);
+
+create table books (
+    id integer primary key autoincrement,
+    title text not null,
+    author_id integer not null references authors (id) on update cascade on delete cascade,
+    publication_year integer not null,
+    created_at timestamp not null default current_timestamp,
+    updated_at timestamp not null default current_timestamp,
+    slug text not null,
+    description text not null
+);
+
+create table genres (
+    id integer primary key autoincrement,
+    genre_name text not null,
+    created_at timestamp not null default current_timestamp,
+    updated_at timestamp not null default current_timestamp
+);
+
+create table books_genres (
+    id integer primary key autoincrement,
+    book_id integer not null references books (id) on update cascade on delete cascade,
+    genre_id integer not null references genres (id) on update cascade on delete cascade,
+    created_at timestamp not null default current_timestamp,
+    updated_at timestamp not null default current_timestamp
+);
+
+create table users (
+    id integer primary key autoincrement,
+    created_at timestamp not null default current_timestamp,
+    name text not null,
+    email text not null unique,
+    password_hash blob not null,
+    version integer not null default 1,
+    account_type text not null default 'user',
+    updated_at timestamp not null default current_timestamp
+);
+
+create table reviews (
+    id integer primary key autoincrement,
+    rating integer not null default 1,
+    review text not null,
+    book_id integer not null references books (id) on update cascade on delete cascade,
+    user_id integer not null references users (id) on update cascade on delete cascade,
+    version integer not null default 1,
+    created_at timestamp not null default current_timestamp,
+    updated_at timestamp not null default current_timestamp
+);
+
+create table tokens (
+    id integer primary key autoincrement,
+    user_id integer not null references users (id) on update cascade on delete cascade,
+    email text not null,
+    token text not null,
+    token_hash blob not null,
+    created_at timestamp not null default current_timestamp,
+    updated_at timestamp not null default current_timestamp,
+    expiry timestamp not null
+);
+
+-- unicode61 without removing diacritics splits and folds words the way the 'simple' configuration does.
+create virtual table books_fts using fts5 (title, content = 'books', content_rowid = 'id', tokenize = 'unicode61 remove_diacritics 0');
+
+create trigger books_fts_insert after insert on books begin
+    insert into books_fts (rowid, title) values (new.id, new.title);
+end;
+
+create trigger books_fts_delete after delete on books begin
+    insert into books_fts (books_fts, rowid, title) values ('delete', old.id, old.title);
+end;
+
+create trigger books_fts_update after update of title on books begin
+    insert into books_fts (books_fts, rowid, title) values ('delete', old.id, old.title);
+    insert into books_fts (rowid, title) values (new.id, new.title);
+end;
+
+create virtual table authors_fts using fts5 (author_name, content = 'authors', content_rowid = 'id', tokenize = 'unicode61 remove_diacritics 0');
+
+create trigger authors_fts_insert after insert on authors begin
+    insert into authors_fts (rowid, author_name) values (new.id, new.author_name);
+end;
+
+create trigger authors_fts_delete after delete on authors begin
+    insert into authors_fts (authors_fts, rowid, author_name) values ('delete', old.id, old.author_name);
+end;
+
+create trigger authors_fts_update after update of author_name on authors begin
+    insert into authors_fts (authors_fts, rowid, author_name) values ('delete', old.id, old.author_name);
+    insert into authors_fts (rowid, author_name) values (new.id, new.author_name);
+end;
+
+create virtual table users_fts using fts5 (name, content = 'users', content_rowid = 'id', tokenize = 'unicode61 remove_diacritics 0');
+
+create trigger users_fts_insert after insert on users begin
+    insert into users_fts (rowid, name) values (new.id, new.name);
+end;
+
+create trigger users_fts_delete after delete on users begin
+    insert into users_fts (users_fts, rowid, name) values ('delete', old.id, old.name);
+end;
+
+create trigger users_fts_update after update of name on users begin
+    insert into users_fts (users_fts, rowid, name) values ('delete', old.id, old.name);
+    insert into users_fts (rowid, name) values (new.id, new.name);
+end;
+
+insert into genres (genre_name) values ('Science Fiction'), ('Fantasy'), ('Romance'), ('Thriller'), ('Mystery'), ('Horror'), ('Classic'), ('Self-help');
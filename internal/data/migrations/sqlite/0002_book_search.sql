@@ -0,0 +1,41 @@
+-- Full-text search of the books. FTS5 has no per-language stemming, every book is indexed with the
+-- English porter stemmer whatever its language. books_search keeps its own copy of the author name and
+-- genres, the triggers below follow them.
+
+alter table books add column language text not null default 'simple';
+
+create virtual table books_search using fts5 (title, author_name, genres, description, tokenize = 'porter unicode61 remove_diacritics 0');
+
+insert into books_search (rowid, title, author_name, genres, description)
+select b.id, b.title, coalesce(a.author_name, ''),
+       coalesce((select group_concat(g.genre_name, ' ') from books_genres bg join genres g on g.id = bg.genre_id where bg.book_id = b.id), ''),
+       b.description
+from books b left join authors a on a.id = b.author_id;
+
+create trigger books_search_insert after insert on books begin
+    insert into books_search (rowid, title, author_name, genres, description)
+    values (new.id, new.title, coalesce((select author_name from authors where id = new.author_id), ''), '', new.description);
+end;
+
+create trigger books_search_update after update of title, author_id, description on books begin
+    update books_search set title = new.title, author_name = coalesce((select author_name from authors where id = new.author_id), ''),
+        description = new.description where rowid = new.id;
+end;
+
+create trigger books_search_delete after delete on books begin
+    delete from books_search where rowid = old.id;
+end;
+
+create trigger books_search_author after update of author_name on authors begin
+    update books_search set author_name = new.author_name where rowid in (select id from books where author_id = new.id);
+end;
+
+create trigger books_search_genre_insert after insert on books_genres begin
+    update books_search set genres = coalesce((select group_concat(g.genre_name, ' ') from books_genres bg join genres g on g.id = bg.genre_id where bg.book_id = new.book_id), '')
+    where rowid = new.book_id;
+end;
+
+create trigger books_search_genre_delete after delete on books_genres begin
+    update books_search set genres = coalesce((select group_concat(g.genre_name, ' ') from books_genres bg join genres g on g.id = bg.genre_id where bg.book_id = old.book_id), '')
+    where rowid = old.book_id;
+end;
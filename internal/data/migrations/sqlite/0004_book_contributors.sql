@@ -0,0 +1,44 @@
+-- Books can have several contributors, each with a role, in the order they are credited.
+-- books.author_id stays as the first contributor, the one shown as the book's author. The author
+-- name indexed in books_search becomes the names of every contributor.
+
+create table book_contributors (
+    book_id integer not null references books (id) on update cascade on delete cascade,
+    author_id integer not null references authors (id) on update cascade on delete cascade,
+    role text not null default 'author' check (role in ('author', 'editor', 'translator', 'illustrator')),
+    position integer not null,
+    primary key (book_id, author_id, role)
+);
+
+create index book_contributors_author_id_idx on book_contributors (author_id);
+
+insert into book_contributors (book_id, author_id, role, position)
+select id, author_id, 'author', 1 from books;
+
+drop trigger books_search_update;
+drop trigger books_search_author;
+
+create trigger books_search_update after update of title, description on books begin
+    update books_search set title = new.title, description = new.description where rowid = new.id;
+end;
+
+create trigger books_search_author after update of author_name on authors begin
+    update books_search set author_name = coalesce((select group_concat(author_name, ' ') from (
+        select a.author_name from book_contributors bc join authors a on a.id = bc.author_id where bc.book_id = books_search.rowid order by bc.position
+    )), '')
+    where rowid in (select book_id from book_contributors where author_id = new.id);
+end;
+
+create trigger books_search_contributor_insert after insert on book_contributors begin
+    update books_search set author_name = coalesce((select group_concat(author_name, ' ') from (
+        select a.author_name from book_contributors bc join authors a on a.id = bc.author_id where bc.book_id = new.book_id order by bc.position
+    )), '')
+    where rowid = new.book_id;
+end;
+
+create trigger books_search_contributor_delete after delete on book_contributors begin
+    update books_search set author_name = coalesce((select group_concat(author_name, ' ') from (
+        select a.author_name from book_contributors bc join authors a on a.id = bc.author_id where bc.book_id = old.book_id order by bc.position
+    )), '')
+    where rowid = old.book_id;
+end;
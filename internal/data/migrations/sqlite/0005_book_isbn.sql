@@ -0,0 +1,7 @@
+-- Books are stored with their ISBN-13, the ISBN-10 is derived from it. Books without an ISBN have
+-- none, the unique index lets any number of them be null. SQLite cannot add a unique column, the
+-- index is named like the Postgres constraint.
+
+alter table books add column isbn text;
+
+create unique index books_isbn_key on books (isbn);
@@ -0,0 +1,22 @@
+-- A work groups the editions of a book: printings, formats and translations. Every book is an
+-- edition of a work, the existing ones each become the only edition of a work with their id.
+-- SQLite cannot add a not null column without a default, the models always set work_id.
+
+create table works (
+    id integer primary key autoincrement,
+    title text not null,
+    created_at timestamp not null default current_timestamp,
+    updated_at timestamp not null default current_timestamp
+);
+
+alter table books add column work_id integer references works (id) on update cascade;
+alter table books add column format text not null default '' check (format in ('', 'hardcover', 'paperback', 'ebook', 'audiobook'));
+alter table books add column publisher text not null default '';
+alter table books add column page_count integer not null default 0 check (page_count >= 0);
+
+insert into works (id, title, created_at, updated_at)
+select id, title, created_at, updated_at from books;
+
+update books set work_id = id;
+
+create index books_work_id_idx on books (work_id);
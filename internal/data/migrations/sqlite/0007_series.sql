@@ -0,0 +1,35 @@
+-- Series of books. A book can be in several series, at a position that can be a decimal so that
+-- a novella can sit at 2.5 between the second and third books.
+
+create table series (
+    id integer primary key autoincrement,
+    name text not null,
+    description text not null default '',
+    created_at timestamp not null default current_timestamp,
+    updated_at timestamp not null default current_timestamp,
+    version integer not null default 1
+);
+
+create virtual table series_fts using fts5 (name, content = 'series', content_rowid = 'id', tokenize = 'unicode61 remove_diacritics 0');
+
+create trigger series_fts_insert after insert on series begin
+    insert into series_fts (rowid, name) values (new.id, new.name);
+end;
+
+create trigger series_fts_delete after delete on series begin
+    insert into series_fts (series_fts, rowid, name) values ('delete', old.id, old.name);
+end;
+
+create trigger series_fts_update after update of name on series begin
+    insert into series_fts (series_fts, rowid, name) values ('delete', old.id, old.name);
+    insert into series_fts (rowid, name) values (new.id, new.name);
+end;
+
+create table books_series (
+    book_id integer not null references books (id) on update cascade on delete cascade,
+    series_id integer not null references series (id) on update cascade on delete cascade,
+    position real not null check (position >= 0),
+    primary key (book_id, series_id)
+);
+
+create index books_series_series_id_idx on books_series (series_id, position);
@@ -0,0 +1,36 @@
+-- Publishers of editions. The publisher names entered on books become publishers, and books refer
+-- to them by id.
+
+create table publishers (
+    id integer primary key autoincrement,
+    name text not null unique,
+    created_at timestamp not null default current_timestamp,
+    updated_at timestamp not null default current_timestamp,
+    version integer not null default 1
+);
+
+create virtual table publishers_fts using fts5 (name, content = 'publishers', content_rowid = 'id', tokenize = 'unicode61 remove_diacritics 0');
+
+create trigger publishers_fts_insert after insert on publishers begin
+    insert into publishers_fts (rowid, name) values (new.id, new.name);
+end;
+
+create trigger publishers_fts_delete after delete on publishers begin
+    insert into publishers_fts (publishers_fts, rowid, name) values ('delete', old.id, old.name);
+end;
+
+create trigger publishers_fts_update after update of name on publishers begin
+    insert into publishers_fts (publishers_fts, rowid, name) values ('delete', old.id, old.name);
+    insert into publishers_fts (rowid, name) values (new.id, new.name);
+end;
+
+alter table books add column publisher_id integer references publishers (id) on update cascade on delete set null;
+
+insert into publishers (name)
+select distinct publisher from books where publisher <> '' order by publisher;
+
+update books set publisher_id = (select p.id from publishers p where p.name = books.publisher);
+
+alter table books drop column publisher;
+
+create index books_publisher_id_idx on books (publisher_id);
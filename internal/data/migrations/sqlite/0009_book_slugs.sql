@@ -0,0 +1,17 @@
+-- Slugs are unique, books with the same title get a numbered suffix. The slugs a book had before
+-- being renamed are kept so that their URLs keep resolving to it.
+
+update books set slug = 'book' where slug = '';
+
+update books set slug = slug || '-' || id
+where exists (select 1 from books o where o.slug = books.slug and o.id < books.id);
+
+create unique index books_slug_key on books (slug);
+
+create table book_slugs (
+    slug text primary key,
+    book_id integer not null references books (id) on update cascade on delete cascade,
+    created_at timestamp not null default current_timestamp
+);
+
+create index book_slugs_book_id_idx on book_slugs (book_id);
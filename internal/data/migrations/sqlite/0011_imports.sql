@@ -0,0 +1,25 @@
+-- Bulk imports of books, run in the background. The counts are updated as the rows are processed,
+-- and the rows that could not be imported are kept with their errors.
+
+create table imports (
+    id integer primary key autoincrement,
+    user_id integer references users (id) on update cascade on delete set null,
+    format text not null,
+    status text not null default 'queued',
+    total_rows integer not null default 0,
+    processed_rows integer not null default 0,
+    created_rows integer not null default 0,
+    failed_rows integer not null default 0,
+    error text not null default '',
+    created_at timestamp not null default current_timestamp,
+    updated_at timestamp not null default current_timestamp,
+    finished_at timestamp
+);
+
+create table import_errors (
+    import_id integer not null references imports (id) on update cascade on delete cascade,
+    line integer not null,
+    title text not null default '',
+    errors text not null,
+    primary key (import_id, line)
+);
@@ -0,0 +1,29 @@
+-- Imports of a user's reading history from a Goodreads export. The rows are matched to books
+-- when the file is uploaded, and only turned into reviews once the user confirms the preview.
+
+create table library_imports (
+    id integer primary key autoincrement,
+    user_id integer not null references users (id) on update cascade on delete cascade,
+    source text not null,
+    status text not null default 'previewed',
+    version integer not null default 1,
+    created_at timestamp not null default current_timestamp,
+    updated_at timestamp not null default current_timestamp,
+    confirmed_at timestamp
+);
+
+create table library_import_rows (
+    library_import_id integer not null references library_imports (id) on update cascade on delete cascade,
+    line integer not null,
+    title text not null default '',
+    author text not null default '',
+    isbn text not null default '',
+    year integer not null default 0,
+    rating integer not null default 0,
+    review text not null default '',
+    match text not null,
+    book_id integer references books (id) on update cascade on delete set null,
+    status text not null,
+    reason text not null default '',
+    primary key (library_import_id, line)
+);
@@ -0,0 +1,13 @@
+-- Deleted books, reviews and users are kept in the trash until they are purged, so that they can
+-- be restored. Every query of the live records filters on deleted_at being null. The reviews
+-- deleted along with their book or user have it in deleted_with, 'book' or 'user', and come back
+-- with it.
+
+alter table books add column deleted_at timestamp;
+alter table reviews add column deleted_at timestamp;
+alter table reviews add column deleted_with text;
+alter table users add column deleted_at timestamp;
+
+create index books_deleted_at_idx on books (deleted_at) where deleted_at is not null;
+create index reviews_deleted_at_idx on reviews (deleted_at) where deleted_at is not null;
+create index users_deleted_at_idx on users (deleted_at) where deleted_at is not null;
@@ -1,17 +1,32 @@
 package data
 
-import "database/sql"
+import (
+	"database/sql"
+	"time"
+)
 
 type Models struct {
-	Books  Books
-	Users  Users
-	Tokens Tokens
+	Books          Books
+	Series         SeriesStore
+	Publishers     Publishers
+	Users          Users
+	Tokens         Tokens
+	Imports        Imports
+	LibraryImports LibraryImports
+	Trash          Trash
 }
 
-func NewModels(db *sql.DB) Models {
+// NewModels returns the models backed by db, which speaks the given dialect. Every query is
+// cancelled when the caller's context is done or after queryTimeout, whichever comes first.
+func NewModels(db *sql.DB, dialect Dialect, queryTimeout time.Duration) Models {
 	return Models{
-		Books:  NewBookModel(db),
-		Users:  NewUserModel(db),
-		Tokens: NewTokenModel(db),
+		Books:          NewBookModel(db, dialect, queryTimeout),
+		Series:         NewSeriesModel(db, dialect, queryTimeout),
+		Publishers:     NewPublisherModel(db, dialect, queryTimeout),
+		Users:          NewUserModel(db, dialect, queryTimeout),
+		Tokens:         NewTokenModel(db, dialect, queryTimeout),
+		Imports:        NewImportModel(db, dialect, queryTimeout),
+		LibraryImports: NewLibraryImportModel(db, dialect, queryTimeout),
+		Trash:          NewTrashModel(db, dialect, queryTimeout),
 	}
 }
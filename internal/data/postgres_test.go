@@ -0,0 +1,93 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	_ "github.com/jackc/pgx/v4/stdlib"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newPostgresDB returns the database in QUICKBOOKS_TEST_DSN after applying the migrations, the test
+// is skipped when it is not set.
+func newPostgresDB(t *testing.T) *sql.DB {
+	t.Helper()
+	dsn := os.Getenv("QUICKBOOKS_TEST_DSN")
+	if dsn == "" {
+		t.Skip("QUICKBOOKS_TEST_DSN is not set")
+	}
+	db, err := sql.Open("pgx", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	err = Migrate(context.Background(), db, Postgres)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// TestPostgresConformance runs the conformance suite against the database in QUICKBOOKS_TEST_DSN.
+// Every table is truncated before each test, never point it at a database you care about.
+func TestPostgresConformance(t *testing.T) {
+	db := newPostgresDB(t)
+	runConformance(t, func(t *testing.T) Models {
+		resetPostgres(t, db)
+		return NewModels(db, Postgres, 3*time.Second)
+	})
+}
+
+// TestPostgresSearchUsesIndex checks that the search query can be answered by books_search_idx
+// rather than by reading every book. Sequential scans are turned off as the test tables are too
+// small for the planner to prefer the index otherwise.
+func TestPostgresSearchUsesIndex(t *testing.T) {
+	db := newPostgresDB(t)
+	ctx := context.Background()
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	_, err = tx.ExecContext(ctx, `set local enable_seqscan = off`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, err := tx.QueryContext(ctx, "explain "+postgresSearchQuery, "dune -messiah", 20, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var plan []string
+	for rows.Next() {
+		var line string
+		err := rows.Scan(&line)
+		if err != nil {
+			t.Fatal(err)
+		}
+		plan = append(plan, line)
+	}
+	if rows.Err() != nil {
+		t.Fatal(rows.Err())
+	}
+	if !strings.Contains(strings.Join(plan, "\n"), "books_search_idx") {
+		t.Errorf("the search does not use books_search_idx:\n%s", strings.Join(plan, "\n"))
+	}
+}
+
+func resetPostgres(t *testing.T, db *sql.DB) {
+	t.Helper()
+	ctx := context.Background()
+	_, err := db.ExecContext(ctx, `truncate tokens, reviews, books_genres, books, works, series, publishers, genres, authors, users, imports, library_imports, library_import_rows restart identity cascade`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, genre := range Genres {
+		_, err := db.ExecContext(ctx, `insert into genres (genre_name) values ($1)`, genre)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
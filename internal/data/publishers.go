@@ -0,0 +1,196 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/rrebeiz/quickbooks/internal/validator"
+	"time"
+)
+
+var ErrDuplicatePublisher = errors.New("duplicate publisher")
+
+// Publisher publishes editions, a book refers to its publisher with PublisherID. Books only carry
+// the ID and Name of their publisher.
+type Publisher struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	BookCount int       `json:"book_count,omitempty"`
+	CreatedAt time.Time `json:"-"`
+	UpdatedAt time.Time `json:"-"`
+	Version   int       `json:"version,omitempty"`
+}
+
+type Publishers interface {
+	GetAll(ctx context.Context, name string, filters Filters) ([]*Publisher, Metadata, error)
+	GetByID(ctx context.Context, id int64) (*Publisher, error)
+	Insert(ctx context.Context, publisher *Publisher) error
+	Update(ctx context.Context, publisher *Publisher) error
+	Delete(ctx context.Context, id int64) error
+}
+
+type PublisherModel struct {
+	DB           *sql.DB
+	Dialect      Dialect
+	QueryTimeout time.Duration
+}
+
+func NewPublisherModel(db *sql.DB, dialect Dialect, queryTimeout time.Duration) PublisherModel {
+	return PublisherModel{DB: db, Dialect: dialect, QueryTimeout: queryTimeout}
+}
+
+func ValidatePublisher(v *validator.Validator, publisher *Publisher) {
+	v.Check(publisher.Name != "", "name", "should not be empty")
+	v.Check(len(publisher.Name) <= 512, "name", "should not be more than 512 bytes long")
+}
+
+// setPublisher sets the publisher of the book from its PublisherID and the name read with it.
+func (book *Book) setPublisher(name string) {
+	book.Publisher = nil
+	if book.PublisherID != 0 {
+		book.Publisher = &Publisher{ID: book.PublisherID, Name: name}
+	}
+}
+
+// publisherBookCount is the number of books of the publisher aliased as p.
+const publisherBookCount = `(select count(*) from books pb where pb.publisher_id = p.id and pb.deleted_at is null)`
+
+func (p PublisherModel) GetAll(ctx context.Context, name string, filters Filters) (_ []*Publisher, _ Metadata, err error) {
+	keys, err := filters.keyset("publishers", "p."+filters.sortColumn(), "p.id")
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	where, tail, pageArgs := keys.clauses(2)
+	query := fmt.Sprintf(`select %s, p.id, p.name, %s, p.created_at, p.updated_at, p.version from publishers p
+			where %s and %s %s`, keys.countColumn(), publisherBookCount, p.Dialect.textSearch("publishers", "p", "name", "$1"), where, tail)
+	ctx, cancel := context.WithTimeout(ctx, p.QueryTimeout)
+	defer cancel()
+	ctx, span := startSpan(ctx, p.Dialect, "PublisherModel.GetAll", query)
+	defer endSpan(span, &err)
+	args := append([]interface{}{p.Dialect.searchArg(name)}, pageArgs...)
+	rows, err := p.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+	totalRecords := 0
+	var publishers []*Publisher
+	for rows.Next() {
+		var publisher Publisher
+		err := rows.Scan(&totalRecords, &publisher.ID, &publisher.Name, &publisher.BookCount, &publisher.CreatedAt, &publisher.UpdatedAt, &publisher.Version)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		publishers = append(publishers, &publisher)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	publishers, metadata := finish(keys, publishers, totalRecords, func(publisher *Publisher) []any {
+		return []any{publisherSortKey(publisher, filters.sortColumn()), publisher.ID}
+	})
+	return publishers, metadata, nil
+}
+
+func publisherSortKey(publisher *Publisher, column string) any {
+	switch column {
+	case "name":
+		return publisher.Name
+	default:
+		return publisher.ID
+	}
+}
+
+func (p PublisherModel) GetByID(ctx context.Context, id int64) (_ *Publisher, err error) {
+	query := fmt.Sprintf(`select p.id, p.name, %s, p.created_at, p.updated_at, p.version from publishers p where p.id = $1`, publisherBookCount)
+	ctx, cancel := context.WithTimeout(ctx, p.QueryTimeout)
+	defer cancel()
+	ctx, span := startSpan(ctx, p.Dialect, "PublisherModel.GetByID", query)
+	defer endSpan(span, &err)
+	var publisher Publisher
+	err = p.DB.QueryRowContext(ctx, query, id).Scan(&publisher.ID, &publisher.Name, &publisher.BookCount, &publisher.CreatedAt, &publisher.UpdatedAt, &publisher.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNoRecordFound
+		default:
+			return nil, err
+		}
+	}
+	return &publisher, nil
+}
+
+func (p PublisherModel) Insert(ctx context.Context, publisher *Publisher) (err error) {
+	query := `insert into publishers (name) values ($1) returning id, created_at, updated_at, version`
+	ctx, cancel := context.WithTimeout(ctx, p.QueryTimeout)
+	defer cancel()
+	ctx, span := startSpan(ctx, p.Dialect, "PublisherModel.Insert", query)
+	defer endSpan(span, &err)
+	err = p.DB.QueryRowContext(ctx, query, publisher.Name).Scan(&publisher.ID, &publisher.CreatedAt, &publisher.UpdatedAt, &publisher.Version)
+	if err != nil {
+		switch {
+		case p.Dialect.isUniqueViolation(err, "publishers", "name"):
+			return ErrDuplicatePublisher
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+// Update saves the publisher if it has not changed since it was read, ErrNoRecordFound otherwise.
+func (p PublisherModel) Update(ctx context.Context, publisher *Publisher) (err error) {
+	query := `update publishers set name = $1, updated_at = current_timestamp, version = version + 1 where id = $2 and version = $3 returning updated_at, version`
+	ctx, cancel := context.WithTimeout(ctx, p.QueryTimeout)
+	defer cancel()
+	ctx, span := startSpan(ctx, p.Dialect, "PublisherModel.Update", query)
+	defer endSpan(span, &err)
+	err = p.DB.QueryRowContext(ctx, query, publisher.Name, publisher.ID, publisher.Version).Scan(&publisher.UpdatedAt, &publisher.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrNoRecordFound
+		case p.Dialect.isUniqueViolation(err, "publishers", "name"):
+			return ErrDuplicatePublisher
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+// Delete deletes the publisher, its books are left without one.
+func (p PublisherModel) Delete(ctx context.Context, id int64) (err error) {
+	query := `delete from publishers where id = $1`
+	ctx, cancel := context.WithTimeout(ctx, p.QueryTimeout)
+	defer cancel()
+	ctx, span := startSpan(ctx, p.Dialect, "PublisherModel.Delete", query)
+	defer endSpan(span, &err)
+	result, err := p.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+	row, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if row != 1 {
+		return ErrNoRecordFound
+	}
+	return nil
+}
+
+// publisherName returns the name of the publisher with the id, or an empty string for 0.
+func (b BookModel) publisherName(ctx context.Context, id int64) (_ string, err error) {
+	if id == 0 {
+		return "", nil
+	}
+	query := `select name from publishers where id = $1`
+	ctx, span := startSpan(ctx, b.Dialect, "BookModel.publisherName", query)
+	defer endSpan(span, &err)
+	var name string
+	err = b.DB.QueryRowContext(ctx, query, id).Scan(&name)
+	return name, err
+}
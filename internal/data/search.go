@@ -0,0 +1,249 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// SearchResult is a book matching a search, with its rank and the matches highlighted in its title
+// and description. Ranks only compare results of the same search.
+type SearchResult struct {
+	Book       *Book      `json:"book"`
+	Rank       float64    `json:"rank"`
+	Highlights Highlights `json:"highlights"`
+}
+
+// Highlights are the title of the book and an excerpt of its description, HTML-escaped, with the
+// words matching the search between <mark> and </mark>.
+type Highlights struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+}
+
+// The matches in the highlights are put between these.
+const (
+	searchStartSel = "<mark>"
+	searchStopSel  = "</mark>"
+)
+
+// The databases put the matches between these private use characters, which no book has. They
+// become searchStartSel and searchStopSel once the text around them is escaped.
+const (
+	searchStartMark = "\uE000"
+	searchStopMark  = "\uE001"
+)
+
+var searchMarks = strings.NewReplacer(searchStartMark, searchStartSel, searchStopMark, searchStopSel)
+
+// escapeHighlight HTML-escapes a highlight of the database, leaving only its marks as tags.
+func escapeHighlight(s string) string {
+	return searchMarks.Replace(html.EscapeString(s))
+}
+
+// postgresSearchQuery matches the search vector of the book, its title, contributors, genres and
+// description in the book's language, against every word of the search, like the other dialects.
+// The search is parsed in every language first, so that books_search_idx finds the books matching
+// it in any of them, and then in the language of each.
+var postgresSearchQuery = fmt.Sprintf(`select count(*) over(), b.id, b.title, b.author_id, b.publication_year, b.slug, b.description, cast(b.language as text), coalesce(b.isbn, ''), coalesce(b.work_id, 0), b.format, coalesce(b.publisher_id, 0), coalesce(p.name, ''), b.page_count,
+	b.created_at, b.updated_at, a.id, a.author_name, a.created_at, a.updated_at, a.version,
+	ts_rank(b.search, q.query) as rank,
+	ts_headline(b.language, b.title, q.query, 'HighlightAll=true, StartSel=%[1]s, StopSel=%[2]s'),
+	ts_headline(b.language, b.description, q.query, 'MaxWords=35, MinWords=15, MaxFragments=2, StartSel=%[1]s, StopSel=%[2]s')
+from books b
+left join authors a on (b.author_id = a.id)
+left join publishers p on (b.publisher_id = p.id)
+cross join lateral (select plainto_tsquery(b.language, $1) as query) q
+where b.deleted_at is null and b.search @@ (%[3]s) and b.search @@ q.query
+order by rank desc, b.id asc limit $2 offset $3`, searchStartMark, searchStopMark, anyLanguageQuery("$1"))
+
+// anyLanguageQuery returns the tsquery matching the documents that match the search in param in
+// any of the Languages.
+func anyLanguageQuery(param string) string {
+	var queries []string
+	for _, language := range Languages {
+		queries = append(queries, fmt.Sprintf("plainto_tsquery('%s', %s)", language, param))
+	}
+	return strings.Join(queries, " || ")
+}
+
+// sqliteSearchQuery does the same with the books_search FTS5 table, bm25 weighs its columns like the
+// Postgres weights A to D. The FTS5 functions cannot be used next to a window function, they are
+// computed first.
+var sqliteSearchQuery = fmt.Sprintf(`with matches as materialized (
+	select rowid, -bm25(books_search, 10.0, 4.0, 2.0, 1.0) as rank,
+		highlight(books_search, 0, '%[1]s', '%[2]s') as title,
+		snippet(books_search, 3, '%[1]s', '%[2]s', '...', 35) as description
+	from books_search where books_search match $1
+)
+select count(*) over(), b.id, b.title, b.author_id, b.publication_year, b.slug, b.description, cast(b.language as text), coalesce(b.isbn, ''), coalesce(b.work_id, 0), b.format, coalesce(b.publisher_id, 0), coalesce(p.name, ''), b.page_count,
+	b.created_at, b.updated_at, a.id, a.author_name, a.created_at, a.updated_at, a.version,
+	m.rank, m.title, m.description
+from matches m
+join books b on (b.id = m.rowid)
+left join authors a on (b.author_id = a.id)
+left join publishers p on (b.publisher_id = p.id)
+where b.deleted_at is null
+order by m.rank desc, b.id asc limit $2 offset $3`, searchStartMark, searchStopMark)
+
+// Search returns the books matching every word of the search in their title, description,
+// contributors or genres, best matches first. Case and punctuation are ignored, quotes, or and
+// -word have no special meaning in any dialect. Only the page and page size of the filters are used.
+func (b BookModel) Search(ctx context.Context, search string, filters Filters) (_ []*SearchResult, _ Metadata, err error) {
+	query := postgresSearchQuery
+	if b.Dialect == SQLite {
+		query = sqliteSearchQuery
+	}
+	ctx, cancel := context.WithTimeout(ctx, b.QueryTimeout)
+	defer cancel()
+	ctx, span := startSpan(ctx, b.Dialect, "BookModel.Search", query)
+	defer endSpan(span, &err)
+
+	rows, err := b.DB.QueryContext(ctx, query, b.Dialect.searchArg(search), filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+	totalRecords := 0
+	var results []*SearchResult
+	for rows.Next() {
+		var book Book
+		var publisherName string
+		var result SearchResult
+		err := rows.Scan(&totalRecords, &book.ID, &book.Title, &book.AuthorID, &book.PublicationYear, &book.Slug, &book.Description, &book.Language, &book.ISBN13, &book.WorkID, &book.Format, &book.PublisherID, &publisherName, &book.PageCount,
+			&book.CreatedAt, &book.UpdatedAt, &book.Author.ID, &book.Author.AuthorName, &book.Author.CreatedAt, &book.Author.UpdatedAt, &book.Author.Version,
+			&result.Rank, &result.Highlights.Title, &result.Highlights.Description)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		book.setPublisher(publisherName)
+		result.Highlights.Title = escapeHighlight(result.Highlights.Title)
+		result.Highlights.Description = escapeHighlight(result.Highlights.Description)
+		result.Book = &book
+		results = append(results, &result)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	for _, result := range results {
+		genres, err := b.genresByBook(ctx, result.Book.ID)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		for _, genre := range genres {
+			result.Book.Genres = append(result.Book.Genres, genre.GenreName)
+		}
+		result.Book.setISBNs()
+		result.Book.Contributors, err = b.contributorsByBook(ctx, result.Book.ID)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		result.Book.Series, err = b.seriesByBook(ctx, result.Book.ID)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+	}
+	return results, calculateMetadata(totalRecords, filters.Page, filters.PageSize), nil
+}
+
+// Search ranks the books the way ts_rank does with its default weights: every word of the search
+// has to be in the book, and each match counts 1 in the title, 0.4 in the contributor names, 0.2 in the
+// genres and 0.1 in the description. There is no stemming.
+func (b MemoryBookModel) Search(ctx context.Context, search string, filters Filters) ([]*SearchResult, Metadata, error) {
+	b.store.mu.RLock()
+	defer b.store.mu.RUnlock()
+
+	searchWords := words(search)
+	var results []*SearchResult
+	for _, row := range b.store.books {
+		book := b.store.bookWithJoins(row, false)
+		var names []string
+		for _, contributor := range book.Contributors {
+			names = append(names, contributor.AuthorName)
+		}
+		fields := []struct {
+			text   string
+			weight float64
+		}{
+			{book.Title, 1}, {strings.Join(names, " "), 0.4}, {strings.Join(book.Genres, " "), 0.2}, {book.Description, 0.1},
+		}
+		rank := 0.0
+		for _, word := range searchWords {
+			found := false
+			for _, field := range fields {
+				for _, w := range words(field.text) {
+					if w == word {
+						rank += field.weight
+						found = true
+					}
+				}
+			}
+			if !found {
+				rank = 0
+				break
+			}
+		}
+		if rank == 0 {
+			continue
+		}
+		results = append(results, &SearchResult{
+			Book: book,
+			Rank: rank,
+			Highlights: Highlights{
+				Title:       highlight(book.Title, searchWords),
+				Description: highlight(book.Description, searchWords),
+			},
+		})
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Rank != results[j].Rank {
+			return results[i].Rank > results[j].Rank
+		}
+		return results[i].Book.ID < results[j].Book.ID
+	})
+
+	total := len(results)
+	start := filters.offset()
+	if start >= total {
+		return nil, Metadata{}, nil
+	}
+	end := min(start+filters.limit(), total)
+	return results[start:end], calculateMetadata(total, filters.Page, filters.PageSize), nil
+}
+
+// highlight HTML-escapes text and marks its words that are among searchWords. Its whitespace is
+// kept as it is.
+func highlight(text string, searchWords []string) string {
+	var out strings.Builder
+	for text != "" {
+		space := strings.IndexFunc(text, unicode.IsSpace) == 0
+		end := strings.IndexFunc(text, func(r rune) bool { return unicode.IsSpace(r) != space })
+		if end < 0 {
+			end = len(text)
+		}
+		run := text[:end]
+		text = text[end:]
+		if !space && matchesAny(run, searchWords) {
+			out.WriteString(searchStartSel + html.EscapeString(run) + searchStopSel)
+		} else {
+			out.WriteString(html.EscapeString(run))
+		}
+	}
+	return out.String()
+}
+
+// matchesAny reports whether one of the words of field is among searchWords.
+func matchesAny(field string, searchWords []string) bool {
+	for _, word := range words(field) {
+		for _, searchWord := range searchWords {
+			if word == searchWord {
+				return true
+			}
+		}
+	}
+	return false
+}
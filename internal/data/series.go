@@ -0,0 +1,261 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/rrebeiz/quickbooks/internal/validator"
+	"time"
+)
+
+// Series is a sequence of books, like the volumes of a saga.
+type Series struct {
+	ID          int64     `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	BookCount   int       `json:"book_count"`
+	CreatedAt   time.Time `json:"-"`
+	UpdatedAt   time.Time `json:"-"`
+	Version     int       `json:"version"`
+}
+
+// SeriesEntry is the place of a book in a series. Positions can be decimals, 2.5 comes between the
+// second and third books.
+type SeriesEntry struct {
+	SeriesID int64   `json:"series_id"`
+	Name     string  `json:"name"`
+	Position float64 `json:"position"`
+}
+
+type SeriesStore interface {
+	GetAll(ctx context.Context, name string, filters Filters) ([]*Series, Metadata, error)
+	GetByID(ctx context.Context, id int64) (*Series, error)
+	GetBooks(ctx context.Context, id int64) ([]*Book, error)
+	Insert(ctx context.Context, series *Series) error
+	Update(ctx context.Context, series *Series) error
+	Delete(ctx context.Context, id int64) error
+}
+
+type SeriesModel struct {
+	DB           *sql.DB
+	Dialect      Dialect
+	QueryTimeout time.Duration
+}
+
+func NewSeriesModel(db *sql.DB, dialect Dialect, queryTimeout time.Duration) SeriesModel {
+	return SeriesModel{DB: db, Dialect: dialect, QueryTimeout: queryTimeout}
+}
+
+func ValidateSeries(v *validator.Validator, series *Series) {
+	v.Check(series.Name != "", "name", "should not be empty")
+	v.Check(len(series.Name) <= 512, "name", "should not be more than 512 bytes long")
+}
+
+func validateSeriesEntries(v *validator.Validator, entries []SeriesEntry) {
+	seen := make(map[int64]bool)
+	for _, entry := range entries {
+		v.Check(entry.SeriesID > 0, "series", "must all have a series_id")
+		v.Check(entry.Position >= 0, "series", "positions must not be negative")
+		v.Check(!seen[entry.SeriesID], "series", "must not have the same series twice")
+		seen[entry.SeriesID] = true
+	}
+}
+
+// seriesBookCount is the number of books of the series aliased as s.
+const seriesBookCount = `(select count(*) from books_series bs join books b on b.id = bs.book_id where bs.series_id = s.id and b.deleted_at is null)`
+
+func (s SeriesModel) GetAll(ctx context.Context, name string, filters Filters) (_ []*Series, _ Metadata, err error) {
+	keys, err := filters.keyset("series", "s."+filters.sortColumn(), "s.id")
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	where, tail, pageArgs := keys.clauses(2)
+	query := fmt.Sprintf(`select %s, s.id, s.name, s.description, %s, s.created_at, s.updated_at, s.version from series s
+			where %s and %s %s`, keys.countColumn(), seriesBookCount, s.Dialect.textSearch("series", "s", "name", "$1"), where, tail)
+	ctx, cancel := context.WithTimeout(ctx, s.QueryTimeout)
+	defer cancel()
+	ctx, span := startSpan(ctx, s.Dialect, "SeriesModel.GetAll", query)
+	defer endSpan(span, &err)
+	args := append([]interface{}{s.Dialect.searchArg(name)}, pageArgs...)
+	rows, err := s.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+	totalRecords := 0
+	var all []*Series
+	for rows.Next() {
+		var series Series
+		err := rows.Scan(&totalRecords, &series.ID, &series.Name, &series.Description, &series.BookCount, &series.CreatedAt, &series.UpdatedAt, &series.Version)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		all = append(all, &series)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	all, metadata := finish(keys, all, totalRecords, func(series *Series) []any {
+		return []any{seriesSortKey(series, filters.sortColumn()), series.ID}
+	})
+	return all, metadata, nil
+}
+
+func seriesSortKey(series *Series, column string) any {
+	switch column {
+	case "name":
+		return series.Name
+	default:
+		return series.ID
+	}
+}
+
+func (s SeriesModel) GetByID(ctx context.Context, id int64) (_ *Series, err error) {
+	query := fmt.Sprintf(`select s.id, s.name, s.description, %s, s.created_at, s.updated_at, s.version from series s where s.id = $1`, seriesBookCount)
+	ctx, cancel := context.WithTimeout(ctx, s.QueryTimeout)
+	defer cancel()
+	ctx, span := startSpan(ctx, s.Dialect, "SeriesModel.GetByID", query)
+	defer endSpan(span, &err)
+	var series Series
+	err = s.DB.QueryRowContext(ctx, query, id).Scan(&series.ID, &series.Name, &series.Description, &series.BookCount, &series.CreatedAt, &series.UpdatedAt, &series.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNoRecordFound
+		default:
+			return nil, err
+		}
+	}
+	return &series, nil
+}
+
+// GetBooks returns the books of the series by position, the books at the same position by title.
+// They come without their reviews.
+func (s SeriesModel) GetBooks(ctx context.Context, id int64) (_ []*Book, err error) {
+	query := `select bs.book_id from books_series bs join books b on b.id = bs.book_id where bs.series_id = $1 and b.deleted_at is null order by bs.position, b.title, b.id`
+	ctx, cancel := context.WithTimeout(ctx, s.QueryTimeout)
+	defer cancel()
+	ctx, span := startSpan(ctx, s.Dialect, "SeriesModel.GetBooks", query)
+	defer endSpan(span, &err)
+	rows, err := s.DB.QueryContext(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+	var ids []int64
+	for rows.Next() {
+		var bookID int64
+		err := rows.Scan(&bookID)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		ids = append(ids, bookID)
+	}
+	rows.Close()
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	bookModel := NewBookModel(s.DB, s.Dialect, s.QueryTimeout)
+	var books []*Book
+	for _, bookID := range ids {
+		book, err := bookModel.GetByID(ctx, bookID)
+		if err != nil {
+			return nil, err
+		}
+		book.Reviews = nil
+		books = append(books, book)
+	}
+	return books, nil
+}
+
+func (s SeriesModel) Insert(ctx context.Context, series *Series) (err error) {
+	query := `insert into series (name, description) values ($1, $2) returning id, created_at, updated_at, version`
+	ctx, cancel := context.WithTimeout(ctx, s.QueryTimeout)
+	defer cancel()
+	ctx, span := startSpan(ctx, s.Dialect, "SeriesModel.Insert", query)
+	defer endSpan(span, &err)
+	return s.DB.QueryRowContext(ctx, query, series.Name, series.Description).Scan(&series.ID, &series.CreatedAt, &series.UpdatedAt, &series.Version)
+}
+
+// Update saves the series if it has not changed since it was read, ErrNoRecordFound otherwise.
+func (s SeriesModel) Update(ctx context.Context, series *Series) (err error) {
+	query := `update series set name = $1, description = $2, updated_at = current_timestamp, version = version + 1 where id = $3 and version = $4 returning updated_at, version`
+	ctx, cancel := context.WithTimeout(ctx, s.QueryTimeout)
+	defer cancel()
+	ctx, span := startSpan(ctx, s.Dialect, "SeriesModel.Update", query)
+	defer endSpan(span, &err)
+	err = s.DB.QueryRowContext(ctx, query, series.Name, series.Description, series.ID, series.Version).Scan(&series.UpdatedAt, &series.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrNoRecordFound
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+// Delete deletes the series, its books stay.
+func (s SeriesModel) Delete(ctx context.Context, id int64) (err error) {
+	query := `delete from series where id = $1`
+	ctx, cancel := context.WithTimeout(ctx, s.QueryTimeout)
+	defer cancel()
+	ctx, span := startSpan(ctx, s.Dialect, "SeriesModel.Delete", query)
+	defer endSpan(span, &err)
+	result, err := s.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+	row, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if row != 1 {
+		return ErrNoRecordFound
+	}
+	return nil
+}
+
+// replaceSeries replaces the series of the book with book.Series.
+func (b BookModel) replaceSeries(ctx context.Context, tx *sql.Tx, book *Book) (err error) {
+	query := `delete from books_series where book_id = $1`
+	ctx, span := startSpan(ctx, b.Dialect, "BookModel.replaceSeries", query)
+	defer endSpan(span, &err)
+	_, err = tx.ExecContext(ctx, query, book.ID)
+	if err != nil {
+		return err
+	}
+	query = `insert into books_series (book_id, series_id, position) values ($1, $2, $3)`
+	for _, entry := range book.Series {
+		_, err := tx.ExecContext(ctx, query, book.ID, entry.SeriesID, entry.Position)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b BookModel) seriesByBook(ctx context.Context, id int64) (_ []SeriesEntry, err error) {
+	query := `select s.id, s.name, bs.position from books_series bs join series s on s.id = bs.series_id where bs.book_id = $1 order by s.name, s.id`
+	ctx, span := startSpan(ctx, b.Dialect, "BookModel.seriesByBook", query)
+	defer endSpan(span, &err)
+	rows, err := b.DB.QueryContext(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var entries []SeriesEntry
+	for rows.Next() {
+		var entry SeriesEntry
+		err := rows.Scan(&entry.SeriesID, &entry.Name, &entry.Position)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
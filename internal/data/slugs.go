@@ -0,0 +1,163 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/mozillazg/go-slugify"
+	"strconv"
+	"strings"
+)
+
+// slugAttempts is how many times a book is saved with a new slug when another book saved at the
+// same time took it.
+const slugAttempts = 3
+
+// baseSlug is the slug of the title, before making it unique.
+func baseSlug(title string) string {
+	slug := slugify.Slugify(title)
+	if slug == "" {
+		return "book"
+	}
+	return slug
+}
+
+// derivesFrom reports whether slug is base or base with a numbered suffix.
+func derivesFrom(slug, base string) bool {
+	if slug == base {
+		return true
+	}
+	suffix, ok := strings.CutPrefix(slug, base+"-")
+	if !ok {
+		return false
+	}
+	n, err := strconv.Atoi(suffix)
+	return err == nil && n > 1 && strconv.Itoa(n) == suffix
+}
+
+// uniqueSlug returns the first of base, base-2, base-3... that is not taken.
+func uniqueSlug(base string, taken func(slug string) bool) string {
+	slug := base
+	for n := 2; taken(slug); n++ {
+		slug = base + "-" + strconv.Itoa(n)
+	}
+	return slug
+}
+
+// setSlug gives the book a slug derived from its title that no other book has now or had before.
+// A book keeps its current slug while its title still gives the same base, and its own old slugs
+// are free for it.
+func (b BookModel) setSlug(ctx context.Context, tx *sql.Tx, book *Book, current string) (err error) {
+	base := baseSlug(book.Title)
+	if current != "" && derivesFrom(current, base) {
+		book.Slug = current
+		return nil
+	}
+	query := `select slug from books where id <> $1 and (slug = $2 or slug like $3)
+		union select slug from book_slugs where book_id <> $1 and (slug = $2 or slug like $3)`
+	ctx, span := startSpan(ctx, b.Dialect, "BookModel.setSlug", query)
+	defer endSpan(span, &err)
+	rows, err := tx.QueryContext(ctx, query, book.ID, base, base+"-%")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	taken := make(map[string]bool)
+	for rows.Next() {
+		var slug string
+		err := rows.Scan(&slug)
+		if err != nil {
+			return err
+		}
+		taken[slug] = true
+	}
+	err = rows.Err()
+	if err != nil {
+		return err
+	}
+	book.Slug = uniqueSlug(base, func(slug string) bool { return taken[slug] })
+	return nil
+}
+
+// saveWithSlug runs save, which writes the book with book.Slug, after giving the book a slug. When
+// another book took the slug in the meantime save is run again with a new one.
+func (b BookModel) saveWithSlug(ctx context.Context, tx *sql.Tx, book *Book, current string, save func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := b.setSlug(ctx, tx, book, current)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `savepoint book_slug`)
+		if err != nil {
+			return err
+		}
+		err = save()
+		if attempt < slugAttempts && b.Dialect.isUniqueViolation(err, "books", "slug") {
+			_, err = tx.ExecContext(ctx, `rollback to savepoint book_slug`)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		return err
+	}
+}
+
+// keepSlug records the slug the book had before being renamed, so that it still leads to it. The
+// new slug is no longer an old one if the book had it before.
+func (b BookModel) keepSlug(ctx context.Context, tx *sql.Tx, book *Book, previous string) (err error) {
+	if previous == book.Slug {
+		return nil
+	}
+	query := `insert into book_slugs (slug, book_id) values ($1, $2) on conflict (slug) do nothing`
+	ctx, span := startSpan(ctx, b.Dialect, "BookModel.keepSlug", query)
+	defer endSpan(span, &err)
+	_, err = tx.ExecContext(ctx, query, previous, book.ID)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `delete from book_slugs where slug = $1 and book_id = $2`, book.Slug, book.ID)
+	return err
+}
+
+// GetOldSlugs returns the slugs the book had before being renamed, which still lead to it.
+func (b BookModel) GetOldSlugs(ctx context.Context, id int64) (_ []string, err error) {
+	query := `select slug from book_slugs where book_id = $1 order by slug`
+	ctx, cancel := context.WithTimeout(ctx, b.QueryTimeout)
+	defer cancel()
+	ctx, span := startSpan(ctx, b.Dialect, "BookModel.GetOldSlugs", query)
+	defer endSpan(span, &err)
+	rows, err := b.DB.QueryContext(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var slugs []string
+	for rows.Next() {
+		var slug string
+		err := rows.Scan(&slug)
+		if err != nil {
+			return nil, err
+		}
+		slugs = append(slugs, slug)
+	}
+	return slugs, rows.Err()
+}
+
+// slugOwner returns the book that had the old slug, ErrNoRecordFound if none did.
+func (b BookModel) slugOwner(ctx context.Context, slug string) (_ int64, err error) {
+	query := `select book_id from book_slugs where slug = $1`
+	ctx, span := startSpan(ctx, b.Dialect, "BookModel.slugOwner", query)
+	defer endSpan(span, &err)
+	var id int64
+	err = b.DB.QueryRowContext(ctx, query, slug).Scan(&id)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return 0, ErrNoRecordFound
+		default:
+			return 0, err
+		}
+	}
+	return id, nil
+}
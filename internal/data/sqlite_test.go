@@ -0,0 +1,105 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	_ "modernc.org/sqlite"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newSQLiteDB returns a migrated SQLite database in a file of the test's temporary directory.
+func newSQLiteDB(t *testing.T) *sql.DB {
+	t.Helper()
+	dialect, dsn, err := ParseDSN("sqlite:" + filepath.Join(t.TempDir(), "books.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open(dialect.DriverName(), dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	err = Migrate(context.Background(), db, dialect)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestSQLiteConformance(t *testing.T) {
+	runConformance(t, func(t *testing.T) Models {
+		return NewModels(newSQLiteDB(t), SQLite, 3*time.Second)
+	})
+}
+
+func TestMigrateTwice(t *testing.T) {
+	db := newSQLiteDB(t)
+	err := Migrate(context.Background(), db, SQLite)
+	if err != nil {
+		t.Fatalf("second Migrate: %v", err)
+	}
+	var genres int
+	err = db.QueryRow(`select count(*) from genres`).Scan(&genres)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if genres != len(Genres) {
+		t.Errorf("%d genres after migrating twice; want %d", genres, len(Genres))
+	}
+}
+
+// TestMigrationVersions checks that a version is the same change in both dialects. SQLite skips
+// the ones it has nothing to do for.
+func TestMigrationVersions(t *testing.T) {
+	postgres, err := loadMigrations(Postgres)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlite, err := loadMigrations(SQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make(map[int64]string)
+	for _, m := range postgres {
+		names[m.version] = m.name
+	}
+	for _, m := range sqlite {
+		if names[m.version] != m.name {
+			t.Errorf("migration %s of SQLite is %q in Postgres", m.name, names[m.version])
+		}
+	}
+}
+
+func TestParseDSN(t *testing.T) {
+	tests := []struct {
+		dsn     string
+		dialect Dialect
+		source  string
+		wantErr bool
+	}{
+		{dsn: "postgres://user:pw@localhost/go_books?sslmode=disable", dialect: Postgres, source: "postgres://user:pw@localhost/go_books?sslmode=disable"},
+		{dsn: "postgresql://localhost/go_books", dialect: Postgres, source: "postgresql://localhost/go_books"},
+		{dsn: "sqlite:books.db", dialect: SQLite, source: "books.db?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)"},
+		{dsn: "sqlite:///var/lib/books.db?_txlock=immediate", dialect: SQLite, source: "/var/lib/books.db?_txlock=immediate&_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)"},
+		{dsn: "sqlite::memory:", wantErr: true},
+		{dsn: "sqlite:", wantErr: true},
+		{dsn: "mysql://localhost/go_books", wantErr: true},
+		{dsn: "books.db", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.dsn, func(t *testing.T) {
+			dialect, source, err := ParseDSN(tt.dsn)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("ParseDSN(%q) succeeded", tt.dsn)
+				}
+				return
+			}
+			if err != nil || dialect != tt.dialect || source != tt.source {
+				t.Errorf("ParseDSN(%q) = %v, %q, %v; want %v, %q", tt.dsn, dialect, source, err, tt.dialect, tt.source)
+			}
+		})
+	}
+}
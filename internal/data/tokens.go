@@ -11,11 +11,11 @@ import (
 )
 
 type Tokens interface {
-	GetByToken(plainText string) (*Token, error)
-	GetUserForToken(token *Token) (*User, error)
+	GetByToken(ctx context.Context, plainText string) (*Token, error)
+	GetUserForToken(ctx context.Context, token *Token) (*User, error)
 	GenerateToken(userID int64, ttl time.Duration) (*Token, error)
-	InsertToken(token *Token) error
-	DeleteToken(id int64) error
+	InsertToken(ctx context.Context, token *Token) error
+	DeleteToken(ctx context.Context, id int64) error
 }
 
 type Token struct {
@@ -30,20 +30,24 @@ type Token struct {
 }
 
 type TokenModel struct {
-	DB *sql.DB
+	DB           *sql.DB
+	Dialect      Dialect
+	QueryTimeout time.Duration
 }
 
-func NewTokenModel(db *sql.DB) TokenModel {
-	return TokenModel{DB: db}
+func NewTokenModel(db *sql.DB, dialect Dialect, queryTimeout time.Duration) TokenModel {
+	return TokenModel{DB: db, Dialect: dialect, QueryTimeout: queryTimeout}
 }
 
-func (t TokenModel) GetByToken(plainText string) (*Token, error) {
+func (t TokenModel) GetByToken(ctx context.Context, plainText string) (_ *Token, err error) {
 	query := `select id, user_id, email, token, token_hash, created_at, updated_at, expiry from tokens where token = $1`
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, t.QueryTimeout)
 	defer cancel()
+	ctx, span := startSpan(ctx, t.Dialect, "TokenModel.GetByToken", query)
+	defer endSpan(span, &err)
 
 	var token Token
-	err := t.DB.QueryRowContext(ctx, query, plainText).Scan(&token.ID, &token.UserID, &token.Email, &token.Token, &token.TokenHash, &token.CreatedAt, &token.UpdatedAt, &token.Expiry)
+	err = t.DB.QueryRowContext(ctx, query, plainText).Scan(&token.ID, &token.UserID, &token.Email, &token.Token, &token.TokenHash, &token.CreatedAt, &token.UpdatedAt, &token.Expiry)
 	if err != nil {
 		switch {
 		case errors.Is(err, sql.ErrNoRows):
@@ -55,16 +59,18 @@ func (t TokenModel) GetByToken(plainText string) (*Token, error) {
 	return &token, nil
 }
 
-func (t TokenModel) GetUserForToken(token *Token) (*User, error) {
+func (t TokenModel) GetUserForToken(ctx context.Context, token *Token) (_ *User, err error) {
 	//query := `select id, name, email, password_hash, created_at, version from users where id = $1`
 	query := `select users.id, users.name, users.email, users.password_hash, users.created_at, users.version, users.account_type, tokens.id, 
        tokens.user_id, tokens.email, tokens.token, tokens.token_hash, tokens.created_at, tokens.updated_at, tokens.expiry 
-		from users inner join tokens on users.id = tokens.user_id where users.id = $1`
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		from users inner join tokens on users.id = tokens.user_id where users.id = $1 and users.deleted_at is null`
+	ctx, cancel := context.WithTimeout(ctx, t.QueryTimeout)
 	defer cancel()
+	ctx, span := startSpan(ctx, t.Dialect, "TokenModel.GetUserForToken", query)
+	defer endSpan(span, &err)
 
 	var user User
-	err := t.DB.QueryRowContext(ctx, query, token.UserID).Scan(&user.ID, &user.Name, &user.Email, &user.Password.Hash, &user.CreatedAt, &user.Version, &user.AccountType, &user.Token.ID, &user.Token.UserID, &user.Token.Email, &user.Token.Token, &user.Token.TokenHash, &user.Token.CreatedAt, &user.Token.UpdatedAt, &user.Token.Expiry)
+	err = t.DB.QueryRowContext(ctx, query, token.UserID).Scan(&user.ID, &user.Name, &user.Email, &user.Password.Hash, &user.CreatedAt, &user.Version, &user.AccountType, &user.Token.ID, &user.Token.UserID, &user.Token.Email, &user.Token.Token, &user.Token.TokenHash, &user.Token.CreatedAt, &user.Token.UpdatedAt, &user.Token.Expiry)
 	if err != nil {
 		switch {
 		case errors.Is(err, sql.ErrNoRows):
@@ -77,6 +83,10 @@ func (t TokenModel) GetUserForToken(token *Token) (*User, error) {
 }
 
 func (t TokenModel) GenerateToken(userID int64, ttl time.Duration) (*Token, error) {
+	return generateToken(userID, ttl)
+}
+
+func generateToken(userID int64, ttl time.Duration) (*Token, error) {
 	token := &Token{
 		UserID: userID,
 		Expiry: time.Now().Add(ttl),
@@ -97,27 +107,33 @@ func (t TokenModel) GenerateToken(userID int64, ttl time.Duration) (*Token, erro
 
 }
 
-func (t TokenModel) InsertToken(token *Token) error {
+func (t TokenModel) InsertToken(ctx context.Context, token *Token) (err error) {
 	query := `delete from tokens where user_id = $1`
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, t.QueryTimeout)
 	defer cancel()
-	_, err := t.DB.ExecContext(ctx, query, token.UserID)
+	deleteCtx, span := startSpan(ctx, t.Dialect, "TokenModel.InsertToken delete", query)
+	_, err = t.DB.ExecContext(deleteCtx, query, token.UserID)
+	endSpan(span, &err)
 	if err != nil {
 		return err
 	}
 
 	query = `insert into tokens (user_id, email, token, token_hash, expiry) values ($1, $2, $3, $4, $5) returning id, created_at, updated_at`
+	ctx, span = startSpan(ctx, t.Dialect, "TokenModel.InsertToken", query)
+	defer endSpan(span, &err)
 	args := []interface{}{token.UserID, token.Email, token.Token, token.TokenHash, token.Expiry}
 	return t.DB.QueryRowContext(ctx, query, args...).Scan(&token.ID, &token.CreatedAt, &token.UpdatedAt)
 }
 
-func (t TokenModel) DeleteToken(id int64) error {
+func (t TokenModel) DeleteToken(ctx context.Context, id int64) (err error) {
 	if id < 1 {
 		return ErrNoRecordFound
 	}
 	query := `delete from tokens where id = $1`
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, t.QueryTimeout)
 	defer cancel()
+	ctx, span := startSpan(ctx, t.Dialect, "TokenModel.DeleteToken", query)
+	defer endSpan(span, &err)
 
 	result, err := t.DB.ExecContext(ctx, query, id)
 	if err != nil {
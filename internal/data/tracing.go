@@ -0,0 +1,44 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer uses the global tracer provider, so spans are only exported once the
+// application has installed one with otel.SetTracerProvider.
+var tracer = otel.Tracer("github.com/rrebeiz/quickbooks/internal/data")
+
+// startSpan starts a client span for a single query, named after the model method running it.
+func startSpan(ctx context.Context, dialect Dialect, name, query string) (context.Context, trace.Span) {
+	return tracer.Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(dialect.dbSystem(), semconv.DBQueryText(query)),
+	)
+}
+
+// endSpan ends the span of a query, marking it failed with the error it returned if any. Finding
+// no record is not a failure.
+func endSpan(span trace.Span, err *error) {
+	if *err != nil && !errors.Is(*err, ErrNoRecordFound) && !errors.Is(*err, sql.ErrNoRows) {
+		span.RecordError(*err)
+		span.SetStatus(codes.Error, (*err).Error())
+	}
+	span.End()
+}
+
+// dbSystem is the db.system attribute of the dialect's spans.
+func (d Dialect) dbSystem() attribute.KeyValue {
+	switch d {
+	case SQLite:
+		return semconv.DBSystemSqlite
+	default:
+		return semconv.DBSystemPostgreSQL
+	}
+}
@@ -0,0 +1,203 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// The types of the records in the trash.
+const (
+	TrashBook   = "book"
+	TrashReview = "review"
+	TrashUser   = "user"
+)
+
+var TrashTypes = []string{TrashBook, TrashReview, TrashUser}
+
+// TrashItem is a deleted book, review or user. The name of a book is its title, of a review the
+// title of its book, and of a user their name.
+type TrashItem struct {
+	Type      string    `json:"type"`
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	DeletedAt time.Time `json:"deleted_at"`
+	// PurgeAt is when the record is deleted for good, it depends on the retention of the trash.
+	PurgeAt time.Time `json:"purge_at"`
+}
+
+// Purged counts the records a purge deleted for good, Covers are the renditions of the books
+// whose images are to be removed.
+type Purged struct {
+	Books   int64
+	Reviews int64
+	Users   int64
+	Covers  []Cover
+}
+
+type Trash interface {
+	GetAll(ctx context.Context, itemType string, filters Filters) ([]*TrashItem, Metadata, error)
+	Purge(ctx context.Context, before time.Time) (*Purged, error)
+}
+
+// trashReviews moves the live reviews of the book or user, parent being TrashBook or TrashUser, to
+// the trash along with it.
+func trashReviews(ctx context.Context, tx *sql.Tx, parent string, id int64, deletedAt time.Time) error {
+	query := fmt.Sprintf(`update reviews set deleted_at = $1, deleted_with = $2 where %s_id = $3 and deleted_at is null`, parent)
+	_, err := tx.ExecContext(ctx, query, deletedAt, parent, id)
+	return err
+}
+
+// restoreReviews brings back the reviews deleted along with the book or user. Those whose other
+// parent has been deleted since wait for it instead, and are purged with it.
+func restoreReviews(ctx context.Context, tx *sql.Tx, parent string, id int64) error {
+	other := TrashUser
+	if parent == TrashUser {
+		other = TrashBook
+	}
+	query := fmt.Sprintf(`update reviews set deleted_at = null, deleted_with = null where %s_id = $1 and deleted_with = $2
+			and %s_id in (select id from %ss where deleted_at is null)`, parent, other, other)
+	_, err := tx.ExecContext(ctx, query, id, parent)
+	if err != nil {
+		return err
+	}
+	query = fmt.Sprintf(`update reviews set deleted_with = $1, deleted_at = (select o.deleted_at from %ss o where o.id = reviews.%s_id)
+			where %s_id = $2 and deleted_with = $3`, other, other, parent)
+	_, err = tx.ExecContext(ctx, query, other, id, parent)
+	return err
+}
+
+type TrashModel struct {
+	DB           *sql.DB
+	Dialect      Dialect
+	QueryTimeout time.Duration
+}
+
+func NewTrashModel(db *sql.DB, dialect Dialect, queryTimeout time.Duration) TrashModel {
+	return TrashModel{DB: db, Dialect: dialect, QueryTimeout: queryTimeout}
+}
+
+// GetAll returns the records in the trash, of the type unless it is empty, the most recently
+// deleted first.
+func (t TrashModel) GetAll(ctx context.Context, itemType string, filters Filters) (_ []*TrashItem, _ Metadata, err error) {
+	query := fmt.Sprintf(`select count(*) over(), type, id, name, deleted_at from (
+			select '%s' as type, id, title as name, deleted_at from books where deleted_at is not null
+			union all
+			select '%s', r.id, b.title, r.deleted_at from reviews r inner join books b on b.id = r.book_id where r.deleted_at is not null
+			union all
+			select '%s', id, name, deleted_at from users where deleted_at is not null
+		) trash where ($1 = '' or type = $1) order by deleted_at desc, type, id limit $2 offset $3`, TrashBook, TrashReview, TrashUser)
+	ctx, cancel := context.WithTimeout(ctx, t.QueryTimeout)
+	defer cancel()
+	ctx, span := startSpan(ctx, t.Dialect, "TrashModel.GetAll", query)
+	defer endSpan(span, &err)
+	rows, err := t.DB.QueryContext(ctx, query, itemType, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+	totalRecords := 0
+	items := []*TrashItem{}
+	for rows.Next() {
+		var item TrashItem
+		err := rows.Scan(&totalRecords, &item.Type, &item.ID, &item.Name, &item.DeletedAt)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		items = append(items, &item)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	return items, calculateMetadata(totalRecords, filters.Page, filters.PageSize), nil
+}
+
+// Purge deletes for good the records that were put in the trash before the time, and the works
+// left without editions.
+func (t TrashModel) Purge(ctx context.Context, before time.Time) (_ *Purged, err error) {
+	query := `delete from books where deleted_at < $1`
+	ctx, cancel := context.WithTimeout(ctx, t.QueryTimeout)
+	defer cancel()
+	ctx, span := startSpan(ctx, t.Dialect, "TrashModel.Purge", query)
+	defer endSpan(span, &err)
+	// deleted_at is written in UTC to the second. SQLite compares the times as text, the cutoff
+	// has to be written the same way.
+	before = before.UTC().Truncate(time.Second)
+	tx, err := t.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var purged Purged
+	rows, err := tx.QueryContext(ctx, `select c.size, c.key, c.url, c.width, c.height from book_covers c inner join books b on b.id = c.book_id
+			where b.deleted_at < $1 order by c.book_id, c.width, c.size`, before)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var cover Cover
+		err := rows.Scan(&cover.Size, &cover.Key, &cover.URL, &cover.Width, &cover.Height)
+		if err != nil {
+			return nil, err
+		}
+		purged.Covers = append(purged.Covers, cover)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	var workIDs []int64
+	rows, err = tx.QueryContext(ctx, `select distinct work_id from books where deleted_at < $1 and work_id is not null`, before)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int64
+		err := rows.Scan(&id)
+		if err != nil {
+			return nil, err
+		}
+		workIDs = append(workIDs, id)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	// The reviews go first so that those deleted with their book or user are counted, the others
+	// would go with them through the foreign keys.
+	for _, step := range []struct {
+		query string
+		count *int64
+	}{
+		{`delete from reviews where deleted_at < $1`, &purged.Reviews},
+		{query, &purged.Books},
+		{`delete from users where deleted_at < $1`, &purged.Users},
+	} {
+		result, err := tx.ExecContext(ctx, step.query, before)
+		if err != nil {
+			return nil, err
+		}
+		*step.count, err = result.RowsAffected()
+		if err != nil {
+			return nil, err
+		}
+	}
+	// The work goes with its last edition.
+	for _, id := range workIDs {
+		_, err = tx.ExecContext(ctx, `delete from works where id = $1 and not exists (select 1 from books where work_id = $1)`, id)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+	return &purged, nil
+}
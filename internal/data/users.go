@@ -15,13 +15,14 @@ var (
 )
 
 type Users interface {
-	GetByEmail(email string) (*User, error)
-	GetByID(id int64) (*User, error)
-	Insert(user *User) error
-	Delete(id int64) error
-	Update(user *User) error
-	GetAll() ([]*User, error)
-	GetAllLoggedIn() ([]*User, error)
+	GetByEmail(ctx context.Context, email string) (*User, error)
+	GetByID(ctx context.Context, id int64) (*User, error)
+	Insert(ctx context.Context, user *User) error
+	Delete(ctx context.Context, id int64) error
+	Restore(ctx context.Context, id int64) error
+	Update(ctx context.Context, user *User) error
+	GetAll(ctx context.Context) ([]*User, error)
+	GetAllLoggedIn(ctx context.Context) ([]*User, error)
 }
 
 type Password struct {
@@ -41,11 +42,13 @@ type User struct {
 }
 
 type UserModel struct {
-	DB *sql.DB
+	DB           *sql.DB
+	Dialect      Dialect
+	QueryTimeout time.Duration
 }
 
-func NewUserModel(db *sql.DB) UserModel {
-	return UserModel{DB: db}
+func NewUserModel(db *sql.DB, dialect Dialect, queryTimeout time.Duration) UserModel {
+	return UserModel{DB: db, Dialect: dialect, QueryTimeout: queryTimeout}
 }
 
 func ValidateUser(v *validator.Validator, user *User) {
@@ -65,8 +68,8 @@ func ValidateName(v *validator.Validator, name string) {
 	v.Check(name != "", "name", "should not be empty")
 }
 
-func (p *Password) HashPassword(plaintext, pepper string) error {
-	passwordHash, err := bcrypt.GenerateFromPassword([]byte(plaintext+pepper), 12)
+func (p *Password) HashPassword(plaintext, pepper string, cost int) error {
+	passwordHash, err := bcrypt.GenerateFromPassword([]byte(plaintext+pepper), cost)
 	if err != nil {
 		return err
 	}
@@ -89,12 +92,14 @@ func (p *Password) CheckPassword(password, pepper string) (bool, error) {
 	return true, nil
 }
 
-func (u UserModel) GetByEmail(email string) (*User, error) {
-	query := `select id, name, email, password_hash, created_at, updated_at, version, account_type from users where email = $1`
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+func (u UserModel) GetByEmail(ctx context.Context, email string) (_ *User, err error) {
+	query := `select id, name, email, password_hash, created_at, updated_at, version, account_type from users where email = $1 and deleted_at is null`
+	ctx, cancel := context.WithTimeout(ctx, u.QueryTimeout)
 	defer cancel()
+	ctx, span := startSpan(ctx, u.Dialect, "UserModel.GetByEmail", query)
+	defer endSpan(span, &err)
 	var user User
-	err := u.DB.QueryRowContext(ctx, query, email).Scan(&user.ID, &user.Name, &user.Email, &user.Password.Hash, &user.CreatedAt, &user.UpdatedAt, &user.Version, &user.AccountType)
+	err = u.DB.QueryRowContext(ctx, query, email).Scan(&user.ID, &user.Name, &user.Email, &user.Password.Hash, &user.CreatedAt, &user.UpdatedAt, &user.Version, &user.AccountType)
 
 	if err != nil {
 		switch {
@@ -107,16 +112,22 @@ func (u UserModel) GetByEmail(email string) (*User, error) {
 	return &user, nil
 }
 
-func (u UserModel) Insert(user *User) error {
-	query := `insert into users (name, email, password_hash) values($1, $2, $3) returning id, created_at, updated_at, version`
-	args := []interface{}{user.Name, user.Email, user.Password.Hash}
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+// Insert creates the user, as a regular "user" account unless AccountType is already set.
+func (u UserModel) Insert(ctx context.Context, user *User) (err error) {
+	if user.AccountType == "" {
+		user.AccountType = "user"
+	}
+	query := `insert into users (name, email, password_hash, account_type) values($1, $2, $3, $4) returning id, created_at, updated_at, version`
+	args := []interface{}{user.Name, user.Email, user.Password.Hash, user.AccountType}
+	ctx, cancel := context.WithTimeout(ctx, u.QueryTimeout)
 	defer cancel()
+	ctx, span := startSpan(ctx, u.Dialect, "UserModel.Insert", query)
+	defer endSpan(span, &err)
 
-	err := u.DB.QueryRowContext(ctx, query, args...).Scan(&user.ID, &user.CreatedAt, &user.UpdatedAt, &user.Version)
+	err = u.DB.QueryRowContext(ctx, query, args...).Scan(&user.ID, &user.CreatedAt, &user.UpdatedAt, &user.Version)
 	if err != nil {
 		switch {
-		case err.Error() == `pq: duplicate key value violates unique constraint "users_email_key"`:
+		case u.Dialect.isUniqueViolation(err, "users", "email"):
 			return ErrDuplicateEmail
 		default:
 			return err
@@ -125,10 +136,12 @@ func (u UserModel) Insert(user *User) error {
 	return nil
 }
 
-func (u UserModel) GetAll() ([]*User, error) {
-	query := `select id, name, email, password_hash, created_at, updated_at, version, account_type from users order by name`
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+func (u UserModel) GetAll(ctx context.Context) (_ []*User, err error) {
+	query := `select id, name, email, password_hash, created_at, updated_at, version, account_type from users where deleted_at is null order by name`
+	ctx, cancel := context.WithTimeout(ctx, u.QueryTimeout)
 	defer cancel()
+	ctx, span := startSpan(ctx, u.Dialect, "UserModel.GetAll", query)
+	defer endSpan(span, &err)
 	var users []*User
 	rows, err := u.DB.QueryContext(ctx, query)
 	if err != nil {
@@ -142,10 +155,10 @@ func (u UserModel) GetAll() ([]*User, error) {
 			return nil, err
 		}
 		query := `select id, user_id, email, token, token_hash, created_at, updated_at, expiry from tokens where user_id = $1`
-		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-		defer cancel()
+		ctx, span := startSpan(ctx, u.Dialect, "UserModel.GetAll token", query)
 		var token Token
 		err = u.DB.QueryRowContext(ctx, query, user.ID).Scan(&token.ID, &token.UserID, &token.Email, &token.Token, &token.TokenHash, &token.CreatedAt, &token.UpdatedAt, &token.Expiry)
+		endSpan(span, &err)
 		user.Token = token
 		users = append(users, &user)
 	}
@@ -155,11 +168,13 @@ func (u UserModel) GetAll() ([]*User, error) {
 	}
 	return users, nil
 }
-func (u UserModel) GetAllLoggedIn() ([]*User, error) {
+func (u UserModel) GetAllLoggedIn(ctx context.Context) (_ []*User, err error) {
 	query := `select u.id, u.name, u.email, u.password_hash, u.created_at, u.updated_at, u.version, u.account_type,
-	  t.id, t.user_id, t.email, t.token, t.token_hash, t.created_at, t.updated_at, t.expiry from users u inner join tokens t on u.id = t.user_id order by name`
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	  t.id, t.user_id, t.email, t.token, t.token_hash, t.created_at, t.updated_at, t.expiry from users u inner join tokens t on u.id = t.user_id where u.deleted_at is null order by name`
+	ctx, cancel := context.WithTimeout(ctx, u.QueryTimeout)
 	defer cancel()
+	ctx, span := startSpan(ctx, u.Dialect, "UserModel.GetAllLoggedIn", query)
+	defer endSpan(span, &err)
 
 	var users []*User
 	rows, err := u.DB.QueryContext(ctx, query)
@@ -182,15 +197,17 @@ func (u UserModel) GetAllLoggedIn() ([]*User, error) {
 	return users, nil
 }
 
-func (u UserModel) GetByID(id int64) (*User, error) {
+func (u UserModel) GetByID(ctx context.Context, id int64) (_ *User, err error) {
 	if id < 1 {
 		return nil, ErrNoRecordFound
 	}
-	query := `select id, name, email, password_hash, created_at, updated_at, version, account_type from users where id = $1`
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	query := `select id, name, email, password_hash, created_at, updated_at, version, account_type from users where id = $1 and deleted_at is null`
+	ctx, cancel := context.WithTimeout(ctx, u.QueryTimeout)
 	defer cancel()
+	ctx, span := startSpan(ctx, u.Dialect, "UserModel.GetByID", query)
+	defer endSpan(span, &err)
 	var user User
-	err := u.DB.QueryRowContext(ctx, query, id).Scan(&user.ID, &user.Name, &user.Email, &user.Password.Hash, &user.CreatedAt, &user.UpdatedAt, &user.Version, &user.AccountType)
+	err = u.DB.QueryRowContext(ctx, query, id).Scan(&user.ID, &user.Name, &user.Email, &user.Password.Hash, &user.CreatedAt, &user.UpdatedAt, &user.Version, &user.AccountType)
 	if err != nil {
 		switch {
 		case errors.Is(err, sql.ErrNoRows):
@@ -202,23 +219,26 @@ func (u UserModel) GetByID(id int64) (*User, error) {
 	return &user, nil
 }
 
-func (u UserModel) Delete(id int64) error {
+func (u UserModel) Delete(ctx context.Context, id int64) (err error) {
 	if id < 1 {
 		return ErrNoRecordFound
 	}
 
-	query := `delete from users where id = $1`
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	query := `update users set deleted_at = $1 where id = $2 and deleted_at is null`
+	ctx, cancel := context.WithTimeout(ctx, u.QueryTimeout)
 	defer cancel()
+	ctx, span := startSpan(ctx, u.Dialect, "UserModel.Delete", query)
+	defer endSpan(span, &err)
 
-	result, err := u.DB.ExecContext(ctx, query, id)
+	tx, err := u.DB.BeginTx(ctx, nil)
 	if err != nil {
-		switch {
-		case errors.Is(err, sql.ErrNoRows):
-			return ErrNoRecordFound
-		default:
-			return err
-		}
+		return err
+	}
+	defer tx.Rollback()
+	deletedAt := time.Now().UTC().Truncate(time.Second)
+	result, err := tx.ExecContext(ctx, query, deletedAt, id)
+	if err != nil {
+		return err
 	}
 	affectedRow, err := result.RowsAffected()
 	if err != nil {
@@ -227,19 +247,63 @@ func (u UserModel) Delete(id int64) error {
 	if affectedRow != 1 {
 		return ErrNoRecordFound
 	}
-	return nil
+	err = trashReviews(ctx, tx, TrashUser, id, deletedAt)
+	if err != nil {
+		return err
+	}
+	// The user is logged out for good, restoring them does not bring their tokens back.
+	_, err = tx.ExecContext(ctx, `delete from tokens where user_id = $1`, id)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Restore takes the user out of the trash, with the reviews deleted along with them.
+func (u UserModel) Restore(ctx context.Context, id int64) (err error) {
+	query := `update users set deleted_at = null where id = $1 and deleted_at is not null`
+	ctx, cancel := context.WithTimeout(ctx, u.QueryTimeout)
+	defer cancel()
+	ctx, span := startSpan(ctx, u.Dialect, "UserModel.Restore", query)
+	defer endSpan(span, &err)
+
+	tx, err := u.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	result, err := tx.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+	affectedRow, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affectedRow != 1 {
+		return ErrNoRecordFound
+	}
+	err = restoreReviews(ctx, tx, TrashUser, id)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
-func (u UserModel) Update(user *User) error {
-	query := `update users set name = $1, email = $2, password_hash = $3, updated_at = now(), version = version + 1 where id = $4 returning updated_at`
+func (u UserModel) Update(ctx context.Context, user *User) (err error) {
+	query := `update users set name = $1, email = $2, password_hash = $3, updated_at = current_timestamp, version = version + 1 where id = $4 and deleted_at is null returning updated_at`
 	args := []interface{}{user.Name, user.Email, user.Password.Hash, user.ID}
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, u.QueryTimeout)
 	defer cancel()
-	err := u.DB.QueryRowContext(ctx, query, args...).Scan(&user.UpdatedAt)
+	ctx, span := startSpan(ctx, u.Dialect, "UserModel.Update", query)
+	defer endSpan(span, &err)
+	err = u.DB.QueryRowContext(ctx, query, args...).Scan(&user.UpdatedAt)
 	if err != nil {
 		switch {
-		case err.Error() == `pq: duplicate key value violates unique constraint "users_email_key"`:
+		case u.Dialect.isUniqueViolation(err, "users", "email"):
 			return ErrDuplicateEmail
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrNoRecordFound
 		default:
 			return err
 		}
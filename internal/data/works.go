@@ -0,0 +1,271 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sort"
+	"time"
+)
+
+// Work is a book independently of its editions: the hardcover, paperback, ebook and translations
+// of it are Books with the same WorkID. Reviews are about the work.
+type Work struct {
+	ID        int64     `json:"id"`
+	Title     string    `json:"title"`
+	Editions  int       `json:"editions"`
+	CreatedAt time.Time `json:"-"`
+	UpdatedAt time.Time `json:"-"`
+}
+
+// Formats are the formats an edition can be published in.
+var Formats = []string{"hardcover", "paperback", "ebook", "audiobook"}
+
+func (b BookModel) GetWork(ctx context.Context, id int64) (_ *Work, err error) {
+	query := `select w.id, w.title, w.created_at, w.updated_at, (select count(*) from books e where e.work_id = w.id and e.deleted_at is null) from works w where w.id = $1`
+	ctx, cancel := context.WithTimeout(ctx, b.QueryTimeout)
+	defer cancel()
+	ctx, span := startSpan(ctx, b.Dialect, "BookModel.GetWork", query)
+	defer endSpan(span, &err)
+	var work Work
+	err = b.DB.QueryRowContext(ctx, query, id).Scan(&work.ID, &work.Title, &work.CreatedAt, &work.UpdatedAt, &work.Editions)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNoRecordFound
+		default:
+			return nil, err
+		}
+	}
+	return &work, nil
+}
+
+// GetEditions returns the editions of the work, oldest first, without their reviews. Their genres,
+// contributors, series and covers are loaded for all of them at once.
+func (b BookModel) GetEditions(ctx context.Context, workID int64) (_ []*Book, err error) {
+	query := `select b.id, b.title, b.author_id, b.publication_year, b.slug, b.description, cast(b.language as text), coalesce(b.isbn, ''), coalesce(b.work_id, 0), b.format, coalesce(b.publisher_id, 0), coalesce(p.name, ''), b.page_count, b.created_at, b.updated_at, a.id, 
+       a.author_name, a.created_at, a.updated_at, a.version from books b left join authors a on (b.author_id = a.id) left join publishers p on (b.publisher_id = p.id) 
+       where b.work_id = $1 and b.deleted_at is null order by b.publication_year, b.id`
+	ctx, cancel := context.WithTimeout(ctx, b.QueryTimeout)
+	defer cancel()
+	ctx, span := startSpan(ctx, b.Dialect, "BookModel.GetEditions", query)
+	defer endSpan(span, &err)
+	rows, err := b.DB.QueryContext(ctx, query, workID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var editions []*Book
+	for rows.Next() {
+		var book Book
+		var publisherName string
+		err := rows.Scan(&book.ID, &book.Title, &book.AuthorID, &book.PublicationYear, &book.Slug, &book.Description, &book.Language, &book.ISBN13, &book.WorkID, &book.Format, &book.PublisherID, &publisherName, &book.PageCount, &book.CreatedAt,
+			&book.UpdatedAt, &book.Author.ID, &book.Author.AuthorName, &book.Author.CreatedAt, &book.Author.UpdatedAt, &book.Author.Version)
+		if err != nil {
+			return nil, err
+		}
+		book.setISBNs()
+		book.setPublisher(publisherName)
+		editions = append(editions, &book)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	rows.Close()
+	if len(editions) == 0 {
+		return nil, nil
+	}
+
+	genres, err := byEdition(ctx, b, "BookModel.genresByEdition", `select bg.book_id, g.genre_name from books_genres bg join genres g on g.id = bg.genre_id 
+       where bg.book_id in (select id from books where work_id = $1 and deleted_at is null) order by g.genre_name`, workID,
+		func(rows *sql.Rows, genre *string) (int64, error) {
+			var id int64
+			return id, rows.Scan(&id, genre)
+		})
+	if err != nil {
+		return nil, err
+	}
+	contributors, err := byEdition(ctx, b, "BookModel.contributorsByEdition", `select bc.book_id, a.id, a.author_name, bc.role from book_contributors bc join authors a on a.id = bc.author_id 
+       where bc.book_id in (select id from books where work_id = $1 and deleted_at is null) order by bc.position`, workID,
+		func(rows *sql.Rows, contributor *Contributor) (int64, error) {
+			var id int64
+			return id, rows.Scan(&id, &contributor.AuthorID, &contributor.AuthorName, &contributor.Role)
+		})
+	if err != nil {
+		return nil, err
+	}
+	series, err := byEdition(ctx, b, "BookModel.seriesByEdition", `select bs.book_id, s.id, s.name, bs.position from books_series bs join series s on s.id = bs.series_id 
+       where bs.book_id in (select id from books where work_id = $1 and deleted_at is null) order by s.name, s.id`, workID,
+		func(rows *sql.Rows, entry *SeriesEntry) (int64, error) {
+			var id int64
+			return id, rows.Scan(&id, &entry.SeriesID, &entry.Name, &entry.Position)
+		})
+	if err != nil {
+		return nil, err
+	}
+	covers, err := byEdition(ctx, b, "BookModel.coversByEdition", `select book_id, size, key, url, width, height from book_covers 
+       where book_id in (select id from books where work_id = $1 and deleted_at is null) order by width, size`, workID,
+		func(rows *sql.Rows, cover *Cover) (int64, error) {
+			var id int64
+			return id, rows.Scan(&id, &cover.Size, &cover.Key, &cover.URL, &cover.Width, &cover.Height)
+		})
+	if err != nil {
+		return nil, err
+	}
+	for _, book := range editions {
+		book.Genres = genres[book.ID]
+		book.Contributors = contributors[book.ID]
+		book.Series = series[book.ID]
+		book.Covers = covers[book.ID]
+	}
+	return editions, nil
+}
+
+// byEdition runs a query over the editions of the work whose rows start with the book_id, and
+// groups what scan reads of them by edition.
+func byEdition[T any](ctx context.Context, b BookModel, name, query string, workID int64, scan func(*sql.Rows, *T) (int64, error)) (_ map[int64][]T, err error) {
+	ctx, span := startSpan(ctx, b.Dialect, name, query)
+	defer endSpan(span, &err)
+	rows, err := b.DB.QueryContext(ctx, query, workID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	values := make(map[int64][]T)
+	for rows.Next() {
+		var value T
+		id, err := scan(rows, &value)
+		if err != nil {
+			return nil, err
+		}
+		values[id] = append(values[id], value)
+	}
+	return values, rows.Err()
+}
+
+// MergeWorks makes every edition of the works of the books editions of the work with workID, and
+// deletes the works left without editions. Their reviews follow the editions.
+func (b BookModel) MergeWorks(ctx context.Context, workID int64, bookIDs []int64) (err error) {
+	query := `update books set work_id = $1, updated_at = current_timestamp where work_id = $2`
+	ctx, cancel := context.WithTimeout(ctx, b.QueryTimeout)
+	defer cancel()
+	ctx, span := startSpan(ctx, b.Dialect, "BookModel.MergeWorks", query)
+	defer endSpan(span, &err)
+	tx, err := b.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	var exists bool
+	err = tx.QueryRowContext(ctx, `select exists (select 1 from works where id = $1)`, workID).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrNoRecordFound
+	}
+	for _, id := range bookIDs {
+		var merged int64
+		err := tx.QueryRowContext(ctx, `select coalesce(work_id, 0) from books where id = $1 and deleted_at is null`, id).Scan(&merged)
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrNoRecordFound
+			default:
+				return err
+			}
+		}
+		if merged == workID {
+			continue
+		}
+		_, err = tx.ExecContext(ctx, query, workID, merged)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `delete from works where id = $1`, merged)
+		if err != nil {
+			return err
+		}
+	}
+	_, err = tx.ExecContext(ctx, `update works set updated_at = current_timestamp where id = $1`, workID)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (b MemoryBookModel) GetWork(ctx context.Context, id int64) (*Work, error) {
+	b.store.mu.RLock()
+	defer b.store.mu.RUnlock()
+
+	w, ok := b.store.works[id]
+	if !ok {
+		return nil, ErrNoRecordFound
+	}
+	work := *w
+	work.Editions = len(b.store.editions(id))
+	return &work, nil
+}
+
+func (b MemoryBookModel) GetEditions(ctx context.Context, workID int64) ([]*Book, error) {
+	b.store.mu.RLock()
+	defer b.store.mu.RUnlock()
+
+	var editions []*Book
+	for _, row := range b.store.editions(workID) {
+		editions = append(editions, b.store.bookWithJoins(row, false))
+	}
+	sort.Slice(editions, func(i, j int) bool {
+		if editions[i].PublicationYear != editions[j].PublicationYear {
+			return editions[i].PublicationYear < editions[j].PublicationYear
+		}
+		return editions[i].ID < editions[j].ID
+	})
+	return editions, nil
+}
+
+func (b MemoryBookModel) MergeWorks(ctx context.Context, workID int64, bookIDs []int64) error {
+	b.store.mu.Lock()
+	defer b.store.mu.Unlock()
+
+	if _, ok := b.store.works[workID]; !ok {
+		return ErrNoRecordFound
+	}
+	for _, id := range bookIDs {
+		if _, ok := b.store.books[id]; !ok {
+			return ErrNoRecordFound
+		}
+	}
+	updated := now()
+	for _, id := range bookIDs {
+		merged := b.store.books[id].book.WorkID
+		if merged == workID {
+			continue
+		}
+		for _, row := range b.store.editions(merged) {
+			row.book.WorkID = workID
+			row.book.UpdatedAt = updated
+		}
+		for _, t := range b.store.trashedBooks {
+			if t.record.book.WorkID == merged {
+				t.record.book.WorkID = workID
+				t.record.book.UpdatedAt = updated
+			}
+		}
+		delete(b.store.works, merged)
+	}
+	b.store.works[workID].UpdatedAt = updated
+	return nil
+}
+
+// editions returns the rows of the editions of the work.
+func (s *memoryStore) editions(workID int64) []*memoryBook {
+	var rows []*memoryBook
+	for _, row := range s.books {
+		if row.book.WorkID == workID {
+			rows = append(rows, row)
+		}
+	}
+	return rows
+}
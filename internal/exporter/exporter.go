@@ -0,0 +1,225 @@
+// Package exporter writes the catalogue as CSV, JSON Lines or MARCXML.
+//
+// The books are read a page at a time, so that an export of the whole catalogue only ever holds
+// a page of books in memory and no query runs for longer than a page takes.
+package exporter
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"github.com/rrebeiz/quickbooks/internal/data"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Formats are the formats the catalogue can be exported in.
+var Formats = []string{"csv", "jsonl", "marcxml"}
+
+// ContentTypes are the content types of the formats.
+var ContentTypes = map[string]string{
+	"csv":     "text/csv; charset=utf-8",
+	"jsonl":   "application/jsonl",
+	"marcxml": "application/marcxml+xml",
+}
+
+// Extensions are the file extensions of the formats.
+var Extensions = map[string]string{"csv": ".csv", "jsonl": ".jsonl", "marcxml": ".xml"}
+
+// pageSize is how many books are read at a time.
+const pageSize = 100
+
+// Filter selects the books exported, like the filters of the book listing.
+type Filter struct {
+	Title       string
+	PublisherID int64
+}
+
+// Book is an exported book.
+type Book struct {
+	ID              int64              `json:"id"`
+	Title           string             `json:"title"`
+	Slug            string             `json:"slug"`
+	Author          string             `json:"author"`
+	Contributors    []data.Contributor `json:"contributors"`
+	PublicationYear int                `json:"publication_year"`
+	Description     string             `json:"description"`
+	Genres          []string           `json:"genres"`
+	Language        string             `json:"language"`
+	ISBN10          string             `json:"isbn_10,omitempty"`
+	ISBN13          string             `json:"isbn_13,omitempty"`
+	Format          string             `json:"format,omitempty"`
+	Publisher       string             `json:"publisher,omitempty"`
+	PageCount       int                `json:"page_count,omitempty"`
+	Series          []data.SeriesEntry `json:"series,omitempty"`
+	Ratings         Ratings            `json:"ratings"`
+}
+
+// Ratings sums up the ratings of the reviews of a book.
+type Ratings struct {
+	Count   int     `json:"count"`
+	Average float64 `json:"average"`
+}
+
+func newBook(book *data.Book) Book {
+	exported := Book{
+		ID:              book.ID,
+		Title:           book.Title,
+		Slug:            book.Slug,
+		Author:          book.Author.AuthorName,
+		Contributors:    book.Contributors,
+		PublicationYear: book.PublicationYear,
+		Description:     book.Description,
+		Genres:          book.Genres,
+		Language:        book.Language,
+		ISBN10:          book.ISBN10,
+		ISBN13:          book.ISBN13,
+		Format:          book.Format,
+		PageCount:       book.PageCount,
+		Series:          book.Series,
+	}
+	if exported.Contributors == nil {
+		exported.Contributors = []data.Contributor{}
+	}
+	if exported.Genres == nil {
+		exported.Genres = []string{}
+	}
+	if book.Publisher != nil {
+		exported.Publisher = book.Publisher.Name
+	}
+	total := 0
+	for _, review := range book.Reviews {
+		total += review.Rating
+	}
+	if len(book.Reviews) > 0 {
+		exported.Ratings = Ratings{Count: len(book.Reviews), Average: math.Round(float64(total)/float64(len(book.Reviews))*100) / 100}
+	}
+	return exported
+}
+
+// writer writes the books of an export in a format.
+type writer interface {
+	begin() error
+	write(book Book) error
+	// flush writes out what the writer buffered.
+	flush() error
+	end() error
+}
+
+// Export writes the books of the filter to w in the format, in the order of their IDs, and
+// returns how many there were. flush is called after every page, when it is not nil. The cursors
+// sign the pages read, any codec will do.
+func Export(ctx context.Context, w io.Writer, books data.Books, cursors *data.CursorCodec, format string, filter Filter,
+	flush func() error) (int, error) {
+	var out writer
+	switch format {
+	case "csv":
+		out = &csvWriter{w: csv.NewWriter(w)}
+	case "jsonl":
+		out = &jsonlWriter{enc: json.NewEncoder(w)}
+	case "marcxml":
+		out = &marcWriter{w: w, enc: xml.NewEncoder(w)}
+	default:
+		return 0, fmt.Errorf("unsupported format %q, use %s", format, strings.Join(Formats, ", "))
+	}
+
+	err := out.begin()
+	if err != nil {
+		return 0, err
+	}
+	filters := data.Filters{Page: 1, PageSize: pageSize, Sort: "id", SortSafeList: []string{"id"}, Cursors: cursors}
+	count := 0
+	for {
+		page, metadata, err := books.GetAll(ctx, filter.Title, filter.PublisherID, filters)
+		if err != nil {
+			return count, err
+		}
+		for _, book := range page {
+			err = out.write(newBook(book))
+			if err != nil {
+				return count, err
+			}
+			count++
+		}
+		if metadata.Next == "" {
+			break
+		}
+		filters.Cursor = metadata.Next
+		err = out.flush()
+		if err == nil && flush != nil {
+			err = flush()
+		}
+		if err != nil {
+			return count, err
+		}
+	}
+	return count, out.end()
+}
+
+// csvColumns are the columns of a CSV export. Lists are separated by semicolons, contributors
+// are written as name (role).
+var csvColumns = []string{"id", "title", "slug", "author", "contributors", "publication_year", "description", "genres", "language",
+	"isbn_10", "isbn_13", "format", "publisher", "page_count", "series", "ratings_count", "average_rating"}
+
+type csvWriter struct {
+	w *csv.Writer
+}
+
+func (c *csvWriter) begin() error {
+	return c.w.Write(csvColumns)
+}
+
+func (c *csvWriter) write(book Book) error {
+	contributors := make([]string, len(book.Contributors))
+	for i, contributor := range book.Contributors {
+		contributors[i] = fmt.Sprintf("%s (%s)", contributor.AuthorName, contributor.Role)
+	}
+	series := make([]string, len(book.Series))
+	for i, entry := range book.Series {
+		series[i] = fmt.Sprintf("%s #%s", entry.Name, strconv.FormatFloat(entry.Position, 'f', -1, 64))
+	}
+	pageCount := ""
+	if book.PageCount > 0 {
+		pageCount = strconv.Itoa(book.PageCount)
+	}
+	average := ""
+	if book.Ratings.Count > 0 {
+		average = strconv.FormatFloat(book.Ratings.Average, 'f', 2, 64)
+	}
+	return c.w.Write([]string{strconv.FormatInt(book.ID, 10), book.Title, book.Slug, book.Author, strings.Join(contributors, "; "),
+		strconv.Itoa(book.PublicationYear), book.Description, strings.Join(book.Genres, "; "), book.Language, book.ISBN10, book.ISBN13,
+		book.Format, book.Publisher, pageCount, strings.Join(series, "; "), strconv.Itoa(book.Ratings.Count), average})
+}
+
+func (c *csvWriter) flush() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+func (c *csvWriter) end() error {
+	return c.flush()
+}
+
+type jsonlWriter struct {
+	enc *json.Encoder
+}
+
+func (j *jsonlWriter) begin() error {
+	return nil
+}
+
+func (j *jsonlWriter) write(book Book) error {
+	return j.enc.Encode(book)
+}
+
+func (j *jsonlWriter) flush() error {
+	return nil
+}
+
+func (j *jsonlWriter) end() error {
+	return nil
+}
@@ -0,0 +1,201 @@
+package exporter
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"github.com/rrebeiz/quickbooks/internal/data"
+	"strings"
+	"testing"
+)
+
+// testCatalogue returns models with more books than fit in a page. The first book has every
+// detail an export carries.
+func testCatalogue(t *testing.T, books int) data.Models {
+	t.Helper()
+	ctx := context.Background()
+	models := data.NewMemoryModels()
+	herbert := &data.Author{AuthorName: "Frank Herbert"}
+	anderson := &data.Author{AuthorName: "Kevin J. Anderson"}
+	for _, author := range []*data.Author{herbert, anderson} {
+		err := models.Books.InsertAuthor(ctx, author)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	ace := &data.Publisher{Name: "Ace"}
+	err := models.Publishers.Insert(ctx, ace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= books; i++ {
+		book := &data.Book{Title: fmt.Sprintf("Book %d", i), AuthorID: int(herbert.ID), PublicationYear: 1900 + i, Description: "A book"}
+		if i == 1 {
+			book.Title = "Dune"
+			book.Description = `Spice, "melange" & sand`
+			book.ISBN13 = "9780441172719"
+			book.PublisherID = ace.ID
+			book.PageCount = 412
+			book.Contributors = []data.Contributor{{AuthorID: herbert.ID, Role: "author"}, {AuthorID: anderson.ID, Role: "editor"}}
+		}
+		err := models.Books.Insert(ctx, book)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i == 1 {
+			book.Genres = []string{"Science Fiction", "Classic"}
+			err = models.Books.Update(ctx, book)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for j, rating := range []int{5, 4, 4} {
+				user := &data.User{Name: "Reader", Email: fmt.Sprintf("reader%d@example.com", j)}
+				err = models.Users.Insert(ctx, user)
+				if err != nil {
+					t.Fatal(err)
+				}
+				err = models.Books.InsertReview(ctx, &data.Review{BookID: book.ID, UserID: user.ID, Rating: rating, Review: "Good"})
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+	}
+	return models
+}
+
+func export(t *testing.T, models data.Models, format string, filter Filter) (string, int) {
+	t.Helper()
+	var buf bytes.Buffer
+	flushes := 0
+	count, err := Export(context.Background(), &buf, models.Books, data.NewCursorCodec([]byte("secret")), format, filter, func() error {
+		flushes++
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Export(%s) = %v", format, err)
+	}
+	if want := (count - 1) / pageSize; flushes != want {
+		t.Errorf("Export(%s) flushed %d times; want once after every page but the last, %d times", format, flushes, want)
+	}
+	return buf.String(), count
+}
+
+func TestExportCSV(t *testing.T) {
+	models := testCatalogue(t, 2*pageSize+10)
+	out, count := export(t, models, "csv", Filter{})
+	records, err := csv.NewReader(strings.NewReader(out)).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 2*pageSize+10 || len(records) != count+1 {
+		t.Fatalf("exported %d books in %d records; want %d books and a header", count, len(records), 2*pageSize+10)
+	}
+	if strings.Join(records[0], ",") != strings.Join(csvColumns, ",") {
+		t.Errorf("header = %v; want %v", records[0], csvColumns)
+	}
+	want := []string{"1", "Dune", "dune", "Frank Herbert", "Frank Herbert (author); Kevin J. Anderson (editor)", "1901", `Spice, "melange" & sand`,
+		"Classic; Science Fiction", "simple", "0441172717", "9780441172719", "", "Ace", "412", "", "3", "4.33"}
+	if fmt.Sprintf("%q", records[1]) != fmt.Sprintf("%q", want) {
+		t.Errorf("Dune = %q; want %q", records[1], want)
+	}
+	for i, record := range records[1:] {
+		if record[0] != fmt.Sprint(i+1) {
+			t.Fatalf("record %d has ID %s; want the books in order of ID", i+1, record[0])
+		}
+	}
+
+	out, count = export(t, models, "csv", Filter{Title: "dune"})
+	if count != 1 || !strings.Contains(out, "Dune") {
+		t.Errorf("export of the title search = %d books; want Dune", count)
+	}
+	_, count = export(t, models, "csv", Filter{PublisherID: 1})
+	if count != 1 {
+		t.Errorf("export of the publisher = %d books; want Dune", count)
+	}
+}
+
+func TestExportJSONL(t *testing.T) {
+	models := testCatalogue(t, pageSize+1)
+	out, count := export(t, models, "jsonl", Filter{})
+	lines := strings.Split(strings.TrimSuffix(out, "\n"), "\n")
+	if count != pageSize+1 || len(lines) != count {
+		t.Fatalf("exported %d books in %d lines; want %d", count, len(lines), pageSize+1)
+	}
+	var dune Book
+	err := json.Unmarshal([]byte(lines[0]), &dune)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dune.Title != "Dune" || dune.Author != "Frank Herbert" || len(dune.Contributors) != 2 || fmt.Sprint(dune.Genres) != "[Classic Science Fiction]" ||
+		dune.Publisher != "Ace" || dune.Ratings != (Ratings{Count: 3, Average: 4.33}) {
+		t.Errorf("Dune = %+v; want its author, contributors, genres, publisher and ratings", dune)
+	}
+	var book map[string]any
+	err = json.Unmarshal([]byte(lines[1]), &book)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(book["genres"], book["ratings"]) != "[] map[average:0 count:0]" {
+		t.Errorf("book without genres or reviews = %v; want empty genres and ratings", book)
+	}
+}
+
+func TestExportMARCXML(t *testing.T) {
+	models := testCatalogue(t, pageSize+1)
+	out, count := export(t, models, "marcxml", Filter{})
+	var collection struct {
+		XMLName xml.Name     `xml:"http://www.loc.gov/MARC21/slim collection"`
+		Records []marcRecord `xml:"record"`
+	}
+	err := xml.Unmarshal([]byte(out), &collection)
+	if err != nil {
+		t.Fatalf("the export is not XML: %v\n%s", err, out[:500])
+	}
+	if len(collection.Records) != count || count != pageSize+1 {
+		t.Fatalf("exported %d books in %d records; want %d", count, len(collection.Records), pageSize+1)
+	}
+	dune := collection.Records[0]
+	if dune.Leader != marcLeader || len(dune.Leader) != 24 || dune.ControlFields[0].Value != "1" {
+		t.Errorf("leader and 001 = %q, %v; want the leader and ID", dune.Leader, dune.ControlFields)
+	}
+	var fields []string
+	for _, f := range dune.DataFields {
+		var subfields []string
+		for _, subfield := range f.Subfields {
+			subfields = append(subfields, "$"+subfield.Code+" "+subfield.Value)
+		}
+		fields = append(fields, f.Tag+" "+f.Ind1+f.Ind2+" "+strings.Join(subfields, " "))
+	}
+	want := []string{
+		"020    $a 9780441172719",
+		"020    $a 0441172717",
+		"100 1  $a Frank Herbert $e author",
+		"245 10 $a Dune",
+		"264  1 $b Ace $c 1901",
+		"300    $a 412 pages",
+		"500    $a Rated 4.33 out of 5 in 3 reviews",
+		`520    $a Spice, "melange" & sand`,
+		"650  4 $a Classic",
+		"650  4 $a Science Fiction",
+		"700 1  $a Kevin J. Anderson $e editor",
+	}
+	if strings.Join(fields, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Dune fields =\n%s\nwant\n%s", strings.Join(fields, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestExportFormat(t *testing.T) {
+	_, err := Export(context.Background(), &bytes.Buffer{}, data.NewMemoryModels().Books, nil, "xml", Filter{}, nil)
+	if err == nil {
+		t.Error("Export of the xml format = nil error; want one")
+	}
+	for _, format := range Formats {
+		if ContentTypes[format] == "" || Extensions[format] == "" {
+			t.Errorf("format %s has no content type or extension", format)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package exporter
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// marcNamespace is the namespace of MARC 21 XML.
+const marcNamespace = "http://www.loc.gov/MARC21/slim"
+
+// marcLeader describes every record: a new (n) monograph (am) in Unicode (a), of unknown encoding
+// level (u) without ISBD punctuation (c). The length and base address are left zero, they have
+// no meaning in MARCXML.
+const marcLeader = "00000nam a2200000uc 4500"
+
+type marcRecord struct {
+	XMLName       xml.Name           `xml:"record"`
+	Leader        string             `xml:"leader"`
+	ControlFields []marcControlField `xml:"controlfield"`
+	DataFields    []marcDataField    `xml:"datafield"`
+}
+
+type marcControlField struct {
+	Tag   string `xml:"tag,attr"`
+	Value string `xml:",chardata"`
+}
+
+type marcDataField struct {
+	Tag       string         `xml:"tag,attr"`
+	Ind1      string         `xml:"ind1,attr"`
+	Ind2      string         `xml:"ind2,attr"`
+	Subfields []marcSubfield `xml:"subfield"`
+}
+
+type marcSubfield struct {
+	Code  string `xml:"code,attr"`
+	Value string `xml:",chardata"`
+}
+
+// field returns a data field, its subfields are given as code, value pairs and left out when
+// their value is empty.
+func field(tag, ind1, ind2 string, subfields ...string) marcDataField {
+	f := marcDataField{Tag: tag, Ind1: ind1, Ind2: ind2}
+	for i := 0; i+1 < len(subfields); i += 2 {
+		if subfields[i+1] != "" {
+			f.Subfields = append(f.Subfields, marcSubfield{Code: subfields[i], Value: subfields[i+1]})
+		}
+	}
+	return f
+}
+
+// newMARCRecord maps the book to MARC 21 bibliographic fields: ISBNs (020), main author (100),
+// title (245), publication (264), pages (300), series (490), ratings (500), description (520),
+// genres (650) and the other contributors (700).
+func newMARCRecord(book Book) marcRecord {
+	record := marcRecord{Leader: marcLeader, ControlFields: []marcControlField{{Tag: "001", Value: strconv.FormatInt(book.ID, 10)}}}
+	add := func(f marcDataField) {
+		if len(f.Subfields) > 0 {
+			record.DataFields = append(record.DataFields, f)
+		}
+	}
+	add(field("020", " ", " ", "a", book.ISBN13))
+	add(field("020", " ", " ", "a", book.ISBN10))
+	add(field("100", "1", " ", "a", book.Author, "e", "author"))
+	add(field("245", "1", "0", "a", book.Title))
+	year := ""
+	if book.PublicationYear > 0 {
+		year = strconv.Itoa(book.PublicationYear)
+	}
+	add(field("264", " ", "1", "b", book.Publisher, "c", year))
+	if book.PageCount > 0 {
+		add(field("300", " ", " ", "a", fmt.Sprintf("%d pages", book.PageCount)))
+	}
+	for _, entry := range book.Series {
+		add(field("490", "0", " ", "a", entry.Name, "v", strconv.FormatFloat(entry.Position, 'f', -1, 64)))
+	}
+	if book.Ratings.Count > 0 {
+		add(field("500", " ", " ", "a", fmt.Sprintf("Rated %.2f out of 5 in %d reviews", book.Ratings.Average, book.Ratings.Count)))
+	}
+	add(field("520", " ", " ", "a", book.Description))
+	for _, genre := range book.Genres {
+		add(field("650", " ", "4", "a", genre))
+	}
+	for i, contributor := range book.Contributors {
+		// The first contributor is the author of 100.
+		if i > 0 {
+			add(field("700", "1", " ", "a", contributor.AuthorName, "e", contributor.Role))
+		}
+	}
+	return record
+}
+
+type marcWriter struct {
+	w   io.Writer
+	enc *xml.Encoder
+}
+
+func (m *marcWriter) begin() error {
+	_, err := io.WriteString(m.w, xml.Header+`<collection xmlns="`+marcNamespace+`">`+"\n")
+	return err
+}
+
+func (m *marcWriter) write(book Book) error {
+	return m.enc.Encode(newMARCRecord(book))
+}
+
+func (m *marcWriter) flush() error {
+	return m.enc.Flush()
+}
+
+func (m *marcWriter) end() error {
+	err := m.enc.Flush()
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(m.w, "\n</collection>\n")
+	return err
+}
@@ -0,0 +1,253 @@
+// Package importer adds books in bulk from CSV or JSON Lines files.
+//
+// Both formats have the same fields: title, author (the author's name), year, description,
+// genres and isbn. A CSV file starts with a header naming its columns, in any order, and
+// separates genres with semicolons. A JSON Lines file has an object per line, with genres as an
+// array, and may have other fields, which are ignored.
+package importer
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/rrebeiz/quickbooks/internal/data"
+	"github.com/rrebeiz/quickbooks/internal/validator"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Formats are the file formats accepted.
+var Formats = []string{"csv", "jsonl"}
+
+// FormatOf returns the format of a file from its extension.
+func FormatOf(filename string) (string, bool) {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".csv":
+		return "csv", true
+	case ".jsonl", ".ndjson":
+		return "jsonl", true
+	default:
+		return "", false
+	}
+}
+
+// Row is a book of an import file. Errors holds the problems found reading it, by field.
+type Row struct {
+	Line        int
+	Title       string
+	Author      string
+	Year        int
+	Description string
+	Genres      []string
+	ISBN        string
+	Errors      map[string]string
+}
+
+// csvColumns are the columns a CSV file must have. genres and isbn are optional.
+var csvColumns = []string{"title", "author", "year", "description"}
+
+// Parse reads the rows of the file. The rows that cannot be read are returned with their Errors,
+// the error is for a file that cannot be read at all.
+func Parse(r io.Reader, format string) ([]Row, error) {
+	switch format {
+	case "csv":
+		return parseCSV(r)
+	case "jsonl":
+		return parseJSONL(r)
+	default:
+		return nil, fmt.Errorf("unsupported format %q, use %s", format, strings.Join(Formats, " or "))
+	}
+}
+
+func parseCSV(r io.Reader) ([]Row, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("the file is empty, it must start with a header")
+		}
+		return nil, err
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		if _, ok := columns[name]; ok {
+			return nil, fmt.Errorf("the header has the %s column twice", name)
+		}
+		columns[name] = i
+	}
+	for _, name := range csvColumns {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("the header has no %s column, it must have %s", name, strings.Join(csvColumns, ", "))
+		}
+	}
+
+	var rows []Row
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return rows, nil
+		}
+		line, _ := reader.FieldPos(0)
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			// A quoting error loses the rest of the line, the reader carries on with the next one.
+			rows = append(rows, Row{Line: parseErr.StartLine, Errors: map[string]string{"row": parseErr.Err.Error()}})
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(record) != len(header) {
+			rows = append(rows, Row{Line: line, Errors: map[string]string{"row": fmt.Sprintf("has %d fields; the header has %d", len(record), len(header))}})
+			continue
+		}
+		field := func(name string) string {
+			i, ok := columns[name]
+			if !ok {
+				return ""
+			}
+			return strings.TrimSpace(record[i])
+		}
+		row := Row{Line: line, Title: field("title"), Author: field("author"), Description: field("description"), ISBN: field("isbn")}
+		for _, genre := range strings.Split(field("genres"), ";") {
+			if genre = strings.TrimSpace(genre); genre != "" {
+				row.Genres = append(row.Genres, genre)
+			}
+		}
+		if year := field("year"); year != "" {
+			row.Year, err = strconv.Atoi(year)
+			if err != nil {
+				row.Errors = map[string]string{"year": "must be a number"}
+			}
+		}
+		rows = append(rows, row)
+	}
+}
+
+func parseJSONL(r io.Reader) ([]Row, error) {
+	var rows []Row
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	for i, line := range bytes.Split(content, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		var object struct {
+			Title       string   `json:"title"`
+			Author      string   `json:"author"`
+			Year        int      `json:"year"`
+			Description string   `json:"description"`
+			Genres      []string `json:"genres"`
+			ISBN        string   `json:"isbn"`
+		}
+		row := Row{Line: i + 1}
+		err := json.Unmarshal(line, &object)
+		if err != nil {
+			var typeErr *json.UnmarshalTypeError
+			switch {
+			case errors.As(err, &typeErr) && typeErr.Field != "":
+				row.Errors = map[string]string{typeErr.Field: "must be a " + typeErr.Type.String()}
+			default:
+				row.Errors = map[string]string{"row": "is not a JSON object"}
+			}
+			rows = append(rows, row)
+			continue
+		}
+		row.Title = strings.TrimSpace(object.Title)
+		row.Author = strings.TrimSpace(object.Author)
+		row.Year = object.Year
+		row.Description = strings.TrimSpace(object.Description)
+		row.Genres = object.Genres
+		row.ISBN = strings.TrimSpace(object.ISBN)
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// Importer adds the books of rows, creating the authors that do not exist yet.
+type Importer struct {
+	books data.Books
+	// authors are the IDs of the authors already found or created, by lower case name.
+	authors map[string]int64
+}
+
+func New(books data.Books) *Importer {
+	return &Importer{books: books, authors: make(map[string]int64)}
+}
+
+// Import adds the book of the row. A row that cannot be imported is returned as an
+// ImportRowError, the error is for the models failing.
+func (im *Importer) Import(ctx context.Context, row Row) (*data.ImportRowError, error) {
+	rowError := func(errors map[string]string) *data.ImportRowError {
+		return &data.ImportRowError{Line: row.Line, Title: row.Title, Errors: errors}
+	}
+	if len(row.Errors) > 0 {
+		return rowError(row.Errors), nil
+	}
+
+	book := data.Book{Title: row.Title, PublicationYear: row.Year, Description: row.Description}
+	isbn := strings.NewReplacer("-", "", " ", "").Replace(row.ISBN)
+	if len(isbn) == 10 {
+		book.ISBN10 = row.ISBN
+	} else {
+		book.ISBN13 = row.ISBN
+	}
+	v := validator.NewValidator()
+	v.Check(row.Author != "", "author", "should not be empty")
+	// The author is only looked up, and created when missing, once the rest of the row is known to
+	// be valid. Until then any ID gets the book through ValidateBook.
+	book.AuthorID = 1
+	data.ValidateBook(v, &book)
+	data.ValidateGenres(v, row.Genres)
+	if !v.Valid() {
+		return rowError(v.Errors), nil
+	}
+
+	authorID, err := im.authorID(ctx, row.Author)
+	if err != nil {
+		return nil, err
+	}
+	book.AuthorID = int(authorID)
+	book.Genres = row.Genres
+	err = im.books.Insert(ctx, &book)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateISBN):
+			return rowError(map[string]string{"isbn": "a book with this ISBN already exists"}), nil
+		default:
+			return nil, err
+		}
+	}
+	return nil, nil
+}
+
+// authorID returns the ID of the author with the name, which is created if there is none.
+func (im *Importer) authorID(ctx context.Context, name string) (int64, error) {
+	key := strings.ToLower(name)
+	if id, ok := im.authors[key]; ok {
+		return id, nil
+	}
+	author, err := im.books.GetAuthorByName(ctx, name)
+	switch {
+	case errors.Is(err, data.ErrNoRecordFound):
+		author = &data.Author{AuthorName: name}
+		err = im.books.InsertAuthor(ctx, author)
+		if err != nil {
+			return 0, err
+		}
+	case err != nil:
+		return 0, err
+	}
+	im.authors[key] = author.ID
+	return author.ID, nil
+}
@@ -0,0 +1,168 @@
+package importer
+
+import (
+	"context"
+	"fmt"
+	"github.com/rrebeiz/quickbooks/internal/data"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestFormatOf(t *testing.T) {
+	tests := map[string]string{"books.csv": "csv", "BOOKS.CSV": "csv", "books.jsonl": "jsonl", "books.ndjson": "jsonl", "books.json": "", "books": ""}
+	for filename, want := range tests {
+		format, ok := FormatOf(filename)
+		if format != want || ok != (want != "") {
+			t.Errorf("FormatOf(%q) = %q, %v; want %q", filename, format, ok, want)
+		}
+	}
+}
+
+func TestParseCSV(t *testing.T) {
+	file := "\ufeffISBN,Title,Author,Year,Description,Genres,Pages\n" +
+		"9780441172719,Dune,Frank Herbert,1965,Spice,Science Fiction; Adventure,412\n" +
+		"\n" +
+		",Children of Dune,Frank Herbert,soon,Sequel,,\n" +
+		"too,few\n" +
+		",\"Dune\nMessiah\",Frank Herbert,1969,\"The second\nbook\",,\n" +
+		",God Emperor of Dune,Frank Herbert,1981,Leto,,\n"
+	rows, err := Parse(strings.NewReader(file), "csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 5 {
+		t.Fatalf("got %d rows; want 5: %v", len(rows), rows)
+	}
+	dune := rows[0]
+	if dune.Line != 2 || dune.Title != "Dune" || dune.Author != "Frank Herbert" || dune.Year != 1965 || dune.Description != "Spice" ||
+		fmt.Sprint(dune.Genres) != "[Science Fiction Adventure]" || dune.ISBN != "9780441172719" || len(dune.Errors) != 0 {
+		t.Errorf("row 0 = %+v; want Dune", dune)
+	}
+	if rows[1].Line != 4 || rows[1].Title != "Children of Dune" || rows[1].Errors["year"] != "must be a number" {
+		t.Errorf("row 1 = %+v; want a year error on line 4", rows[1])
+	}
+	if rows[2].Line != 5 || rows[2].Errors["row"] != "has 2 fields; the header has 7" {
+		t.Errorf("row 2 = %+v; want a row error on line 5", rows[2])
+	}
+	// Lines are those of the file, a quoted field can span several.
+	if rows[3].Line != 6 || rows[3].Title != "Dune\nMessiah" || len(rows[3].Errors) != 0 {
+		t.Errorf("row 3 = %+v; want Dune Messiah on line 6", rows[3])
+	}
+	if rows[4].Line != 9 || rows[4].Year != 1981 {
+		t.Errorf("row 4 = %+v; want God Emperor of Dune on line 9", rows[4])
+	}
+
+	for name, file := range map[string]string{
+		"empty":          "",
+		"missing column": "title,author,year\n",
+		"repeated":       "title,author,year,description,title\n",
+	} {
+		_, err := Parse(strings.NewReader(file), "csv")
+		if err == nil {
+			t.Errorf("Parse of a file %s = nil error; want one", name)
+		}
+	}
+	_, err = Parse(strings.NewReader(file), "xml")
+	if err == nil {
+		t.Error("Parse of the xml format = nil error; want one")
+	}
+}
+
+func TestParseJSONL(t *testing.T) {
+	file := `{"title": "Dune", "author": "Frank Herbert", "year": 1965, "description": "Spice", "genres": ["Science Fiction"], "isbn": "0441172717", "pages": 412}` + "\n" +
+		"\n" +
+		`{"title": "Children of Dune", "year": "1976"}` + "\n" +
+		`not json` + "\n"
+	rows, err := Parse(strings.NewReader(file), "jsonl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 3 {
+		t.Fatalf("got %d rows; want 3: %v", len(rows), rows)
+	}
+	dune := rows[0]
+	if dune.Line != 1 || dune.Title != "Dune" || dune.Author != "Frank Herbert" || dune.Year != 1965 || dune.Description != "Spice" ||
+		fmt.Sprint(dune.Genres) != "[Science Fiction]" || dune.ISBN != "0441172717" || len(dune.Errors) != 0 {
+		t.Errorf("row 0 = %+v; want Dune", dune)
+	}
+	if rows[1].Line != 3 || rows[1].Errors["year"] != "must be a int" {
+		t.Errorf("row 1 = %+v; want a year error on line 3", rows[1])
+	}
+	if rows[2].Line != 4 || rows[2].Errors["row"] == "" {
+		t.Errorf("row 2 = %+v; want a row error on line 4", rows[2])
+	}
+}
+
+func TestImport(t *testing.T) {
+	ctx := context.Background()
+	books := data.NewMemoryModels().Books
+	herbert := &data.Author{AuthorName: "Frank Herbert"}
+	err := books.InsertAuthor(ctx, herbert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	im := New(books)
+
+	rowError, err := im.Import(ctx, Row{Line: 2, Title: "Dune", Author: "frank herbert", Year: 1965, Description: "Spice",
+		Genres: []string{"Science Fiction"}, ISBN: "0-441-17271-7"})
+	if err != nil || rowError != nil {
+		t.Fatalf("Import(Dune) = %v, %v; want it imported", rowError, err)
+	}
+	rowError, err = im.Import(ctx, Row{Line: 3, Title: "Hyperion", Author: "Dan Simmons", Year: 1989, Description: "Pilgrims",
+		ISBN: "9780553283686"})
+	if err != nil || rowError != nil {
+		t.Fatalf("Import(Hyperion) = %v, %v; want it imported", rowError, err)
+	}
+
+	all, _, err := books.GetAll(ctx, "", 0, data.Filters{Page: 1, PageSize: 10, Sort: "id", SortSafeList: []string{"id"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 2 {
+		t.Fatalf("got %d books; want 2", len(all))
+	}
+	if all[0].AuthorID != int(herbert.ID) || all[0].ISBN10 != "0441172717" || fmt.Sprint(all[0].Genres) != "[Science Fiction]" {
+		t.Errorf("Dune = %+v; want it by the existing author, with its ISBN-10 and genre", all[0])
+	}
+	simmons, err := books.GetAuthorByName(ctx, "Dan Simmons")
+	if err != nil {
+		t.Fatalf("the missing author was not created: %v", err)
+	}
+	if all[1].AuthorID != int(simmons.ID) || all[1].ISBN13 != "9780553283686" {
+		t.Errorf("Hyperion = %+v; want it by the new author, with its ISBN-13", all[1])
+	}
+
+	tests := []struct {
+		row    Row
+		fields string
+	}{
+		{Row{Line: 4, Title: "Dune", Author: "Frank Herbert", Year: 1965, Description: "Again", ISBN: "0441172717"}, "[isbn]"},
+		{Row{Line: 5, Author: "Nobody", Year: -1, Genres: []string{"Poetry", "Poetry"}}, "[description genres publication_year title]"},
+		{Row{Line: 6, Title: "Anonymous", Year: 2000, Description: "No author"}, "[author]"},
+		{Row{Line: 7, Errors: map[string]string{"row": "unreadable"}}, "[row]"},
+		{Row{Line: 8, Title: "Hyperion", Author: "Dan Simmons", Year: 1989, Description: "Pilgrims", ISBN: "0553283687"}, "[isbn_10]"},
+	}
+	for _, test := range tests {
+		rowError, err := im.Import(ctx, test.row)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rowError == nil || rowError.Line != test.row.Line {
+			t.Errorf("Import(line %d) = %v; want an error on line %d", test.row.Line, rowError, test.row.Line)
+			continue
+		}
+		var fields []string
+		for field := range rowError.Errors {
+			fields = append(fields, field)
+		}
+		sort.Strings(fields)
+		if got := fmt.Sprint(fields); got != test.fields {
+			t.Errorf("Import(line %d) errors = %v; want errors for %s", test.row.Line, rowError.Errors, test.fields)
+		}
+	}
+	_, err = books.GetAuthorByName(ctx, "Nobody")
+	if err != data.ErrNoRecordFound {
+		t.Errorf("GetAuthorByName(Nobody) = %v; want the author of an invalid row not created", err)
+	}
+}
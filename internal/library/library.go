@@ -0,0 +1,394 @@
+// Package library imports a user's reading history, the books they rated and reviewed on
+// Goodreads or StoryGraph, as reviews.
+//
+// An import happens in two steps. Preview matches the rows of the export to books, by ISBN
+// then by title and author, and marks the rows that cannot be imported with the reason. Once the
+// user has checked the preview, Apply adds the books that were not found but have enough details
+// to be created, the candidates, and the reviews.
+package library
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"github.com/rrebeiz/quickbooks/internal/data"
+	"github.com/rrebeiz/quickbooks/internal/validator"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Sources are the sites the imports come from.
+const (
+	SourceGoodreads  = "goodreads"
+	SourceStoryGraph = "storygraph"
+)
+
+// exportColumns are the columns the export of each source needs, the others are ignored.
+var exportColumns = []struct {
+	source  string
+	columns []string
+}{
+	{SourceGoodreads, []string{"Title", "Author", "My Rating"}},
+	{SourceStoryGraph, []string{"Title", "Authors", "Star Rating"}},
+}
+
+// Parse reads the rows of a Goodreads or StoryGraph export, told apart by their header, and
+// returns them with the source of the export. The rows that cannot be read are skipped with the
+// reason, the error is for a file that is neither.
+func Parse(r io.Reader) (string, []data.LibraryImportRow, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return "", nil, errors.New("the file is empty, it must be a Goodreads or StoryGraph export")
+		}
+		return "", nil, err
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+	source := ""
+	for _, export := range exportColumns {
+		found := true
+		for _, name := range export.columns {
+			if _, ok := columns[strings.ToLower(name)]; !ok {
+				found = false
+			}
+		}
+		if found {
+			source = export.source
+			break
+		}
+	}
+	if source == "" {
+		return "", nil, errors.New("the header lacks the Title, Author and My Rating columns of a Goodreads export, or the Title, Authors and Star Rating ones of a StoryGraph export")
+	}
+
+	var rows []data.LibraryImportRow
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return source, rows, nil
+		}
+		line, _ := reader.FieldPos(0)
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			rows = append(rows, skipped(data.LibraryImportRow{Line: parseErr.StartLine}, parseErr.Err.Error()))
+			continue
+		}
+		if err != nil {
+			return "", nil, err
+		}
+		if len(record) != len(header) {
+			rows = append(rows, skipped(data.LibraryImportRow{Line: line}, fmt.Sprintf("has %d fields; the header has %d", len(record), len(header))))
+			continue
+		}
+		field := func(name string) string {
+			i, ok := columns[strings.ToLower(name)]
+			if !ok {
+				return ""
+			}
+			return strings.TrimSpace(record[i])
+		}
+		var row data.LibraryImportRow
+		switch source {
+		case SourceStoryGraph:
+			row = storyGraphRow(field)
+		default:
+			row = goodreadsRow(field)
+		}
+		row.Line = line
+		rows = append(rows, row)
+	}
+}
+
+// goodreadsRow reads a row of a Goodreads export from its fields.
+func goodreadsRow(field func(name string) string) data.LibraryImportRow {
+	row := data.LibraryImportRow{
+		Title:  field("Title"),
+		Author: field("Author"),
+		Review: reviewText(field("My Review")),
+		Status: data.LibraryRowPending,
+	}
+	// Goodreads writes ISBNs as ="0441172717" so that spreadsheets keep them as text.
+	for _, name := range []string{"ISBN13", "ISBN"} {
+		if isbn := strings.Trim(field(name), `="`); isbn != "" && row.ISBN == "" {
+			row.ISBN = isbn
+		}
+	}
+	for _, name := range []string{"Original Publication Year", "Year Published"} {
+		if year, err := strconv.Atoi(field(name)); err == nil && year > 0 && row.Year == 0 {
+			row.Year = year
+		}
+	}
+	rating, err := strconv.Atoi(field("My Rating"))
+	return rated(row, rating, err == nil)
+}
+
+// storyGraphRow reads a row of a StoryGraph export from its fields. StoryGraph has no publication
+// years, a row matching no book cannot be added as a candidate.
+func storyGraphRow(field func(name string) string) data.LibraryImportRow {
+	// The authors are separated by commas, the first one is the book's author.
+	author, _, _ := strings.Cut(field("Authors"), ",")
+	row := data.LibraryImportRow{
+		Title:  field("Title"),
+		Author: strings.TrimSpace(author),
+		Review: field("Review"),
+		Status: data.LibraryRowPending,
+	}
+	// The column has StoryGraph's own ID for the books without an ISBN.
+	if isbn := field("ISBN/UID"); data.ValidISBN10(isbn) || data.ValidISBN13(isbn) {
+		row.ISBN = isbn
+	}
+	// Ratings go by quarter stars, they are rounded to whole ones. A rated book keeps at least one.
+	stars := field("Star Rating")
+	if stars == "" {
+		return rated(row, 0, true)
+	}
+	rating, err := strconv.ParseFloat(stars, 64)
+	if err != nil || rating < 0 || rating > 5 {
+		return rated(row, 0, false)
+	}
+	if rating == 0 {
+		return rated(row, 0, true)
+	}
+	return rated(row, max(1, int(math.Round(rating))), true)
+}
+
+// rated sets the rating of the row, which is skipped when it has none or it is not valid.
+func rated(row data.LibraryImportRow, rating int, ok bool) data.LibraryImportRow {
+	row.Rating = rating
+	switch {
+	case !ok || rating < 0 || rating > 5:
+		return skipped(row, "has a rating that is not a number from 0 to 5")
+	case rating == 0:
+		return skipped(row, "has no rating")
+	}
+	return row
+}
+
+// reviewText turns the HTML of a Goodreads review into text: it only has line breaks.
+func reviewText(review string) string {
+	return strings.TrimSpace(strings.NewReplacer("<br/>", "\n", "<br />", "\n", "<br>", "\n").Replace(review))
+}
+
+func skipped(row data.LibraryImportRow, reason string) data.LibraryImportRow {
+	row.Match = data.MatchNone
+	row.Status = data.LibraryRowSkipped
+	row.Reason = reason
+	return row
+}
+
+// seriesSuffix is how Goodreads adds the series to a title, as in "Dune (Dune Chronicles, #1)".
+var seriesSuffix = regexp.MustCompile(`\s*\([^()]*#[^()]*\)$`)
+
+// match finds the book of the row, by ISBN then by title and author. The title is also tried
+// without its series.
+func match(ctx context.Context, books data.Books, row data.LibraryImportRow) (*data.Book, string, error) {
+	if isbn13, ok := data.ParseISBN(row.ISBN); ok {
+		book, err := books.GetByISBN(ctx, isbn13)
+		switch {
+		case err == nil:
+			return book, data.MatchISBN, nil
+		case !errors.Is(err, data.ErrNoRecordFound):
+			return nil, "", err
+		}
+	}
+	if row.Title == "" || row.Author == "" {
+		return nil, data.MatchNone, nil
+	}
+	titles := []string{row.Title}
+	if title := seriesSuffix.ReplaceAllString(row.Title, ""); title != row.Title && title != "" {
+		titles = append(titles, title)
+	}
+	for _, title := range titles {
+		book, err := books.GetByTitleAndAuthor(ctx, title, row.Author)
+		switch {
+		case err == nil:
+			return book, data.MatchTitleAuthor, nil
+		case !errors.Is(err, data.ErrNoRecordFound):
+			return nil, "", err
+		}
+	}
+	if row.Year == 0 {
+		return nil, data.MatchNone, nil
+	}
+	return nil, data.MatchCandidate, nil
+}
+
+// candidateKey identifies the book a candidate row would create.
+func candidateKey(row data.LibraryImportRow) string {
+	return strings.ToLower(seriesSuffix.ReplaceAllString(row.Title, "")) + "\x00" + strings.ToLower(row.Author)
+}
+
+// Preview matches the pending rows to books and skips those that cannot be imported: the rows
+// matching no book that lack the title, author or year to create one, and the books the user
+// has already reviewed, here or earlier in the file.
+func Preview(ctx context.Context, books data.Books, userID int64, rows []data.LibraryImportRow) error {
+	reviewed, err := reviewedBooks(ctx, books, userID)
+	if err != nil {
+		return err
+	}
+	candidates := make(map[string]int)
+	for i := range rows {
+		row := &rows[i]
+		if row.Status != data.LibraryRowPending {
+			continue
+		}
+		book, how, err := match(ctx, books, *row)
+		if err != nil {
+			return err
+		}
+		row.Match = how
+		switch {
+		case how == data.MatchNone:
+			*row = skipped(*row, "matches no book and lacks the title, author or year to add it")
+		case how == data.MatchCandidate && candidates[candidateKey(*row)] != 0:
+			*row = skipped(*row, fmt.Sprintf("is the same book as line %d", candidates[candidateKey(*row)]))
+			row.Match = data.MatchCandidate
+		case how == data.MatchCandidate:
+			candidates[candidateKey(*row)] = row.Line
+		case reviewed[book.ID]:
+			row.BookID = book.ID
+			row.Status = data.LibraryRowSkipped
+			row.Reason = "the book is already reviewed"
+		default:
+			row.BookID = book.ID
+			reviewed[book.ID] = true
+		}
+	}
+	return nil
+}
+
+func reviewedBooks(ctx context.Context, books data.Books, userID int64) (map[int64]bool, error) {
+	ids, err := books.GetReviewedBookIDs(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	reviewed := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		reviewed[id] = true
+	}
+	return reviewed, nil
+}
+
+// Apply creates the reviews of the pending rows, adding the books of the candidates and their
+// authors first, and sets the status of each row. The books are matched again, the catalogue
+// and the user's reviews may have changed since the preview. Reviews can be ratings without
+// text, as on Goodreads. After each pending row, progress is called with the number of rows
+// done, the import stops with its error.
+//
+// The error is for ctx being done or the models failing, the rows before the one that failed
+// have their status and the others are still pending. Applying the rows again imports those, a
+// book reviewed or added by the row that failed is then matched.
+func Apply(ctx context.Context, books data.Books, userID int64, rows []data.LibraryImportRow, progress func(done int) error) error {
+	reviewed, err := reviewedBooks(ctx, books, userID)
+	if err != nil {
+		return err
+	}
+	authors := make(map[string]int64)
+	for i := range rows {
+		row := &rows[i]
+		if row.Status != data.LibraryRowPending {
+			continue
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		err := applyRow(ctx, books, userID, reviewed, authors, row)
+		if err != nil {
+			return err
+		}
+		err = progress(i + 1)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyRow imports a pending row, unless its book is among the reviewed ones.
+func applyRow(ctx context.Context, books data.Books, userID int64, reviewed map[int64]bool, authors map[string]int64, row *data.LibraryImportRow) error {
+	// Unlike the reviews of the API, the imported ones can be a rating alone.
+	v := validator.NewValidator()
+	data.ValidateRating(v, row.Rating)
+	if !v.Valid() {
+		row.Status, row.Reason = data.LibraryRowFailed, "has a rating that is not a number from 1 to 5"
+		return nil
+	}
+	book, how, err := match(ctx, books, *row)
+	if err != nil {
+		return err
+	}
+	switch how {
+	case data.MatchNone:
+		row.Status, row.Reason = data.LibraryRowFailed, "matches no book anymore"
+		return nil
+	case data.MatchCandidate:
+		book, err = addBook(ctx, books, authors, *row)
+		switch {
+		// The ISBN is still held by a book in the trash, the book is not added again.
+		case errors.Is(err, data.ErrDuplicateISBN):
+			row.Status, row.Reason = data.LibraryRowFailed, "the book of the ISBN has been deleted"
+			return nil
+		case err != nil:
+			return err
+		}
+	}
+	row.BookID = book.ID
+	if reviewed[book.ID] {
+		row.Status, row.Reason = data.LibraryRowSkipped, "the book is already reviewed"
+		return nil
+	}
+	err = books.InsertReview(ctx, &data.Review{BookID: book.ID, UserID: userID, Rating: row.Rating, Review: row.Review})
+	if err != nil {
+		return err
+	}
+	reviewed[book.ID] = true
+	row.Status = data.LibraryRowImported
+	return nil
+}
+
+// addBook creates the book of a candidate row, and its author unless there is one with the name.
+// It returns ErrDuplicateISBN when a deleted book has the ISBN of the row.
+func addBook(ctx context.Context, books data.Books, authors map[string]int64, row data.LibraryImportRow) (*data.Book, error) {
+	key := strings.ToLower(row.Author)
+	authorID, ok := authors[key]
+	if !ok {
+		author, err := books.GetAuthorByName(ctx, row.Author)
+		switch {
+		case errors.Is(err, data.ErrNoRecordFound):
+			author = &data.Author{AuthorName: row.Author}
+			err = books.InsertAuthor(ctx, author)
+			if err != nil {
+				return nil, err
+			}
+		case err != nil:
+			return nil, err
+		}
+		authorID = author.ID
+		authors[key] = authorID
+	}
+	book := &data.Book{
+		Title:           seriesSuffix.ReplaceAllString(row.Title, ""),
+		AuthorID:        int(authorID),
+		PublicationYear: row.Year,
+		Description:     "Added from a library import.",
+	}
+	// An ISBN that does not parse is left out rather than failing the row, match already found
+	// the books having the others.
+	if isbn13, ok := data.ParseISBN(row.ISBN); ok {
+		book.ISBN13 = isbn13
+	}
+	err := books.Insert(ctx, book)
+	if err != nil {
+		return nil, err
+	}
+	return book, nil
+}
@@ -0,0 +1,301 @@
+package library
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/rrebeiz/quickbooks/internal/data"
+	"strings"
+	"testing"
+)
+
+const goodreadsHeader = "\ufeffBook Id,Title,Author,Author l-f,Additional Authors,ISBN,ISBN13,My Rating,Average Rating,Publisher,Binding," +
+	"Number of Pages,Year Published,Original Publication Year,Date Read,Date Added,Bookshelves,Bookshelves with positions," +
+	"Exclusive Shelf,My Review,Spoiler,Private Notes,Read Count,Owned Copies\n"
+
+// goodreadsLine is a line of a Goodreads export with the columns the import reads.
+func goodreadsLine(title, author, isbn, isbn13 string, rating int, year, review string) string {
+	return fmt.Sprintf("1,%q,%s,,,\"=\"\"%s\"\"\",\"=\"\"%s\"\"\",%d,4.2,Ace,Paperback,412,%s,%s,,2020/01/01,,,read,%q,,,1,0\n",
+		title, author, isbn, isbn13, rating, year, year, review)
+}
+
+func TestParse(t *testing.T) {
+	file := goodreadsHeader +
+		goodreadsLine("Dune (Dune Chronicles, #1)", "Frank Herbert", "0441172717", "9780441172719", 5, "1965", "Spice<br/><br/>and sand") +
+		goodreadsLine("Hyperion", "Dan Simmons", "", "", 0, "1989", "") +
+		"too,few\n" +
+		goodreadsLine("Ubik", "Philip K. Dick", "", "", 7, "", "")
+	source, rows, err := Parse(strings.NewReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if source != SourceGoodreads {
+		t.Errorf("source = %q; want %q", source, SourceGoodreads)
+	}
+	if len(rows) != 4 {
+		t.Fatalf("got %d rows; want 4: %+v", len(rows), rows)
+	}
+	want := data.LibraryImportRow{Line: 2, Title: "Dune (Dune Chronicles, #1)", Author: "Frank Herbert", ISBN: "9780441172719", Year: 1965,
+		Rating: 5, Review: "Spice\n\nand sand", Status: data.LibraryRowPending}
+	if rows[0] != want {
+		t.Errorf("row 0 = %+v; want %+v", rows[0], want)
+	}
+	if rows[1].Line != 3 || rows[1].Status != data.LibraryRowSkipped || rows[1].Reason != "has no rating" || rows[1].ISBN != "" {
+		t.Errorf("row 1 = %+v; want Hyperion skipped without a rating", rows[1])
+	}
+	if rows[2].Line != 4 || rows[2].Status != data.LibraryRowSkipped || rows[2].Reason != "has 2 fields; the header has 24" {
+		t.Errorf("row 2 = %+v; want line 4 skipped for its fields", rows[2])
+	}
+	if rows[3].Status != data.LibraryRowSkipped || !strings.Contains(rows[3].Reason, "rating") {
+		t.Errorf("row 3 = %+v; want Ubik skipped for its rating", rows[3])
+	}
+
+	for name, file := range map[string]string{
+		"empty":     "",
+		"not books": "title,author,year,description\n",
+	} {
+		_, _, err := Parse(strings.NewReader(file))
+		if err == nil {
+			t.Errorf("Parse(%s) = nil error; want one", name)
+		}
+	}
+}
+
+func TestParseStoryGraph(t *testing.T) {
+	file := "Title,Authors,Contributors,ISBN/UID,Format,Read Status,Date Added,Last Date Read,Dates Read,Read Count," +
+		"Moods,Pace,Character- or Plot-Driven?,Strong Character Development?,Loveable Characters?,Diverse Characters?," +
+		"Flawed Characters?,Star Rating,Review,Content Warnings,Content Warning Description,Tags,Owned?\n" +
+		"Dune,\"Frank Herbert, Brian Herbert\",,9780441172719,paperback,read,2020/01/01,,,1,,,,,,,,4.5,\"Spice\n\nand sand\",,,,No\n" +
+		"Hyperion,Dan Simmons,,a1b2c3d4,ebook,read,2020/01/01,,,1,,,,,,,,0.25,,,,,No\n" +
+		"Ubik,Philip K. Dick,,,,to-read,2020/01/01,,,0,,,,,,,,,,,,,No\n" +
+		"Solaris,Stanislaw Lem,,,,read,2020/01/01,,,1,,,,,,,,great,,,,,No\n"
+	source, rows, err := Parse(strings.NewReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if source != SourceStoryGraph {
+		t.Errorf("source = %q; want %q", source, SourceStoryGraph)
+	}
+	if len(rows) != 4 {
+		t.Fatalf("got %d rows; want 4: %+v", len(rows), rows)
+	}
+	want := data.LibraryImportRow{Line: 2, Title: "Dune", Author: "Frank Herbert", ISBN: "9780441172719", Rating: 5, Review: "Spice\n\nand sand",
+		Status: data.LibraryRowPending}
+	if rows[0] != want {
+		t.Errorf("row 0 = %+v; want %+v", rows[0], want)
+	}
+	if rows[1].Rating != 1 || rows[1].ISBN != "" || rows[1].Status != data.LibraryRowPending {
+		t.Errorf("row 1 = %+v; want Hyperion with a star and without its StoryGraph ID", rows[1])
+	}
+	if rows[2].Status != data.LibraryRowSkipped || rows[2].Reason != "has no rating" {
+		t.Errorf("row 2 = %+v; want Ubik skipped without a rating", rows[2])
+	}
+	if rows[3].Status != data.LibraryRowSkipped || !strings.Contains(rows[3].Reason, "rating") {
+		t.Errorf("row 3 = %+v; want Solaris skipped for its rating", rows[3])
+	}
+}
+
+func TestPreviewAndApply(t *testing.T) {
+	ctx := context.Background()
+	models := data.NewMemoryModels()
+	user := &data.User{Name: "Alice", Email: "alice@example.com"}
+	err := models.Users.Insert(ctx, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	herbert := &data.Author{AuthorName: "Frank Herbert"}
+	err = models.Books.InsertAuthor(ctx, herbert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var books []*data.Book
+	for _, book := range []*data.Book{
+		{Title: "Dune", PublicationYear: 1965, ISBN13: "9780441172719"},
+		{Title: "Dune Messiah", PublicationYear: 1969},
+		{Title: "Children of Dune", PublicationYear: 1976},
+	} {
+		book.AuthorID, book.Description = int(herbert.ID), "A book"
+		err = models.Books.Insert(ctx, book)
+		if err != nil {
+			t.Fatal(err)
+		}
+		books = append(books, book)
+	}
+	dune, messiah, children := books[0], books[1], books[2]
+	err = models.Books.InsertReview(ctx, &data.Review{BookID: children.ID, UserID: user.ID, Rating: 3, Review: "Slow"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pending := func(line int, title, author, isbn string, year, rating int) data.LibraryImportRow {
+		return data.LibraryImportRow{Line: line, Title: title, Author: author, ISBN: isbn, Year: year, Rating: rating, Status: data.LibraryRowPending}
+	}
+	rows := []data.LibraryImportRow{
+		pending(2, "Dune: the first book", "Someone", "0-441-17271-7", 1965, 5),
+		pending(3, "Dune Messiah (Dune Chronicles, #2)", "FRANK HERBERT", "", 0, 4),
+		pending(4, "Children of Dune", "Frank Herbert", "", 1976, 2),
+		pending(5, "Hyperion (Hyperion Cantos, #1)", "Dan Simmons", "", 1989, 5),
+		pending(6, "Hyperion", "Dan Simmons", "", 1989, 4),
+		pending(7, "The Fall of Hyperion", "Dan Simmons", "9780553288209", 1990, 4),
+		pending(8, "Unknown", "", "", 2000, 3),
+		pending(9, "Dune", "Frank Herbert", "", 1965, 1),
+		skipped(pending(10, "Ubik", "Philip K. Dick", "", 1969, 0), "has no rating"),
+	}
+	rows[0].Review = "Spice"
+	err = Preview(ctx, models.Books, user.ID, rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, row := range rows {
+		got = append(got, fmt.Sprintf("%d %s %d %s %s", row.Line, row.Match, row.BookID, row.Status, row.Reason))
+	}
+	want := []string{
+		fmt.Sprintf("2 isbn %d pending ", dune.ID),
+		fmt.Sprintf("3 title_author %d pending ", messiah.ID),
+		fmt.Sprintf("4 title_author %d skipped the book is already reviewed", children.ID),
+		"5 candidate 0 pending ",
+		"6 candidate 0 skipped is the same book as line 5",
+		"7 candidate 0 pending ",
+		"8 none 0 skipped matches no book and lacks the title, author or year to add it",
+		fmt.Sprintf("9 title_author %d skipped the book is already reviewed", dune.ID),
+		"10 none 0 skipped has no rating",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Preview =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	// Dune Messiah is reviewed between the preview and the confirmation.
+	err = models.Books.InsertReview(ctx, &data.Review{BookID: messiah.ID, UserID: user.ID, Rating: 4, Review: "Good"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The import stops when progress fails, after the first Hyperion, and applying the rows again
+	// imports the others.
+	errStop := errors.New("stop")
+	err = Apply(ctx, models.Books, user.ID, rows, func(done int) error {
+		if done == 4 {
+			return errStop
+		}
+		return nil
+	})
+	if !errors.Is(err, errStop) {
+		t.Fatalf("Apply = %v; want the error of progress", err)
+	}
+	if rows[3].Status != data.LibraryRowImported || rows[5].Status != data.LibraryRowPending {
+		t.Errorf("rows after the stopped Apply = %+v; want the first Hyperion imported and the second pending", rows)
+	}
+	err = Apply(ctx, models.Books, user.ID, rows, func(int) error { return nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rows[0].Status != data.LibraryRowImported || rows[1].Status != data.LibraryRowSkipped || rows[3].Status != data.LibraryRowImported ||
+		rows[5].Status != data.LibraryRowImported || rows[3].BookID == 0 || rows[5].BookID == 0 {
+		t.Errorf("rows after Apply = %+v; want Dune and the Hyperion candidates imported", rows)
+	}
+	hyperion, err := models.Books.GetByID(ctx, rows[3].BookID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hyperion.Title != "Hyperion" || hyperion.Author.AuthorName != "Dan Simmons" || hyperion.PublicationYear != 1989 {
+		t.Errorf("candidate book = %+v; want Hyperion without its series, by Dan Simmons", hyperion)
+	}
+	fall, err := models.Books.GetByID(ctx, rows[5].BookID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fall.AuthorID != hyperion.AuthorID || fall.ISBN13 != "9780553288209" {
+		t.Errorf("second candidate = %+v; want the same author and the ISBN", fall)
+	}
+	reviewed, err := models.Books.GetReviewedBookIDs(ctx, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reviewed) != 5 {
+		t.Errorf("reviewed books = %v; want Dune, Dune Messiah, Children of Dune and the 2 Hyperion books", reviewed)
+	}
+	review, err := models.Books.GetReviewByID(ctx, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if review.BookID != dune.ID || review.Rating != 5 || review.Review != "Spice" {
+		t.Errorf("review of Dune = %+v; want 5 stars and the text", review)
+	}
+}
+
+func TestApplyDeletedBookISBN(t *testing.T) {
+	ctx := context.Background()
+	models := data.NewMemoryModels()
+	user := &data.User{Name: "Alice", Email: "alice@example.com"}
+	err := models.Users.Insert(ctx, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	herbert := &data.Author{AuthorName: "Frank Herbert"}
+	err = models.Books.InsertAuthor(ctx, herbert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dune := &data.Book{Title: "Dune", AuthorID: int(herbert.ID), PublicationYear: 1965, Description: "Spice", ISBN13: "9780441172719"}
+	err = models.Books.Insert(ctx, dune)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = models.Books.Delete(ctx, dune.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The book in the trash is not matched, its ISBN keeps the candidate from being added.
+	rows := []data.LibraryImportRow{
+		{Line: 2, Title: "Dune (Dune Chronicles, #1)", Author: "Frank Herbert", ISBN: "9780441172719", Year: 1965, Rating: 5, Status: data.LibraryRowPending},
+		{Line: 3, Title: "Dune Messiah", Author: "Frank Herbert", Year: 1969, Rating: 4, Status: data.LibraryRowPending},
+	}
+	err = Preview(ctx, models.Books, user.ID, rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rows[0].Match != data.MatchCandidate {
+		t.Fatalf("row = %+v; want a candidate", rows[0])
+	}
+	err = Apply(ctx, models.Books, user.ID, rows, func(int) error { return nil })
+	if err != nil {
+		t.Fatalf("Apply = %v; want the row failed", err)
+	}
+	if rows[0].Status != data.LibraryRowFailed || rows[0].Reason == "" || rows[0].BookID != 0 {
+		t.Errorf("row = %+v; want it failed with the reason", rows[0])
+	}
+	if rows[1].Status != data.LibraryRowImported {
+		t.Errorf("row = %+v; want the next row imported", rows[1])
+	}
+}
+
+func TestApplyInvalidRating(t *testing.T) {
+	ctx := context.Background()
+	models := data.NewMemoryModels()
+	user := &data.User{Name: "Alice", Email: "alice@example.com"}
+	err := models.Users.Insert(ctx, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The rows are checked again when applied, a bad rating does not add the candidate's book.
+	rows := []data.LibraryImportRow{
+		{Line: 2, Title: "Hyperion", Author: "Dan Simmons", Year: 1989, Rating: 6, Match: data.MatchCandidate, Status: data.LibraryRowPending},
+		{Line: 3, Title: "Ubik", Author: "Philip K. Dick", Year: 1969, Rating: 4, Match: data.MatchCandidate, Status: data.LibraryRowPending},
+	}
+	err = Apply(ctx, models.Books, user.ID, rows, func(int) error { return nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rows[0].Status != data.LibraryRowFailed || rows[0].BookID != 0 {
+		t.Errorf("row = %+v; want it failed for its rating", rows[0])
+	}
+	if rows[1].Status != data.LibraryRowImported || rows[1].Review != "" {
+		t.Errorf("row = %+v; want the rating alone imported", rows[1])
+	}
+	_, err = models.Books.GetByTitleAndAuthor(ctx, "Hyperion", "Dan Simmons")
+	if !errors.Is(err, data.ErrNoRecordFound) {
+		t.Errorf("GetByTitleAndAuthor(Hyperion) error = %v; want the book not added", err)
+	}
+}